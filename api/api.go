@@ -2,52 +2,124 @@ package api
 
 import (
 	"context"
-	"errors"
+	"sync"
 	"time"
 
-	"golang.org/x/crypto/bcrypt"
+	"github.com/go-webauthn/webauthn/webauthn"
 
 	"github.com/ashep/a23n/sqldb"
 )
 
 type API interface {
-	SecretKey() string
-	CheckSecret(hashed, secret string) (bool, error)
+	LoadKeys(ctx context.Context) error
+	RotateKey(ctx context.Context, alg string, activateAfter, retireAfter time.Duration) (SigningKey, error)
+	JWKS() JWKSet
 
-	CreateEntity(ctx context.Context, id string, secret []byte, scope Scope, attrs Attrs) error
-	UpdateEntity(ctx context.Context, id string, secret []byte, scope Scope, attrs Attrs) error
+	CreateEntity(ctx context.Context, id, login, secret string, scope Scope, attrs Attrs) error
+	UpdateEntity(ctx context.Context, id, login, secret string, scope Scope, attrs Attrs) error
 	GetEntity(ctx context.Context, id string) (Entity, error)
+	DeleteEntity(ctx context.Context, id string) error
+	DisableEntity(ctx context.Context, id string) error
+	EnableEntity(ctx context.Context, id string) error
+	ListEntities(ctx context.Context, filter EntityFilter, cursor string, limit int) ([]Entity, string, error)
+	UnlockEntity(ctx context.Context, id string) error
+	UnlockSource(ctx context.Context, source string) error
+	VerifyCredentials(ctx context.Context, idOrLogin, secret, source string) (Entity, error)
 	CheckScope(target Scope, required Scope) bool
 
-	CreateToken(subject string, scope []string, ttl time.Duration) Token
-	ParseToken(token string) (TokenClaims, error)
+	EnrollTOTP(ctx context.Context, id string) (TOTPKey, error)
+	ConfirmTOTP(ctx context.Context, id, code string) error
+	VerifyTOTP(ctx context.Context, id, code, source string) error
+	DisableTOTP(ctx context.Context, id string) error
+
+	BeginWebAuthnRegistration(ctx context.Context, entityID string) (WebAuthnChallenge, error)
+	FinishWebAuthnRegistration(ctx context.Context, entityID, challengeID string, resp []byte, mfa bool) (string, error)
+	BeginWebAuthnLogin(ctx context.Context) (WebAuthnChallenge, error)
+	FinishWebAuthnLogin(ctx context.Context, challengeID string, response []byte, source string) (Entity, bool, error)
+
+	CreateAPIKey(ctx context.Context, entityID, name string, scope Scope, expires time.Time) (APIKey, string, error)
+	ListAPIKeys(ctx context.Context, entityID string) ([]APIKey, error)
+	RevokeAPIKey(ctx context.Context, entityID, id string) error
+	VerifyAPIKey(ctx context.Context, key string) (TokenClaims, error)
+
+	CreateClient(ctx context.Context, c Client) (string, error)
+	GetClient(ctx context.Context, id string) (Client, error)
+	ListClients(ctx context.Context) ([]Client, error)
+	UpdateClient(ctx context.Context, c Client, mask []string, resetSecret bool) (string, error)
+	DeleteClient(ctx context.Context, id string) error
+	VerifyClient(ctx context.Context, id, secret string) (Client, error)
+	CreateAuthorizationCode(ctx context.Context, c AuthorizationCode) (string, error)
+	UseAuthorizationCode(ctx context.Context, code, clientID, redirectURI, verifier string) (AuthorizationCode, error)
+
+	CreateToken(typ TokenType, subject, clientID string, scope []string, ttl time.Duration) Token
+	CreateExchangedToken(subject TokenClaims, actor string, scope []string, audience string, ttl time.Duration) Token
+	CreateIDToken(clientID, subject string, authTime time.Time, nonce string, claims map[string]interface{},
+		ttl time.Duration) (Token, error)
+	Issuer() string
+	ParseToken(ctx context.Context, token string, typ TokenType) (TokenClaims, error)
+	ParseTokenForAudiences(ctx context.Context, token string, typ TokenType, audiences []string) (TokenClaims, error)
+	RevokeToken(ctx context.Context, token string, clm TokenClaims) error
+	RevokeEntityTokens(ctx context.Context, entityID string) error
+	PurgeExpiredTokens(ctx context.Context) (int64, error)
+
+	SaveRefreshToken(ctx context.Context, token, entityID, family string, expires time.Time) error
+	UseRefreshToken(ctx context.Context, token string) (RefreshTokenInfo, error)
+	IsRefreshTokenActive(ctx context.Context, token string) (bool, error)
 }
 
 type DefaultAPI struct {
-	db        sqldb.DB
-	secretKey string
-	now       func() time.Time
-}
+	db       sqldb.DB
+	keys     *keyRing
+	enc      *Encrypter
+	hasher   Hasher
+	policy   PasswordPolicy
+	lockout  LockoutPolicy
+	webauthn *webauthn.WebAuthn
+	issuer   string
+	audience string
+	maxTTL   time.Duration
+	now      func() time.Time
 
-func NewDefault(db sqldb.DB, secretKey string, now func() time.Time) *DefaultAPI {
-	return &DefaultAPI{
-		db:        db,
-		secretKey: secretKey,
-		now:       now,
-	}
+	revocations *revocationCache
+
+	dummyHash     string
+	dummyHashOnce sync.Once
 }
 
-func (a *DefaultAPI) SecretKey() string {
-	return a.secretKey
+// Options configure DefaultAPI. Encryption of stored secrets and passkeys are disabled if Encrypter and WebAuthn are
+// not set. MaxTokenTTL caps the token lifetimes clients may be registered with, no cap is applied if it is zero. Now
+// defaults to time.Now.
+type Options struct {
+	Key         SigningKey
+	Encrypter   *Encrypter
+	Hasher      Hasher
+	Policy      PasswordPolicy
+	Lockout     LockoutPolicy
+	WebAuthn    *webauthn.WebAuthn
+	Issuer      string
+	Audience    string
+	MaxTokenTTL time.Duration
+	Now         func() time.Time
 }
 
-func (a *DefaultAPI) CheckSecret(hashed, secret string) (bool, error) {
-	err := bcrypt.CompareHashAndPassword([]byte(hashed), []byte(secret))
-	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
-		return false, nil
-	} else if err != nil {
-		return false, err
+func NewDefault(db sqldb.DB, opts Options) *DefaultAPI {
+	if opts.Now == nil {
+		opts.Now = time.Now
 	}
 
-	return true, nil
+	return &DefaultAPI{
+		db:       db,
+		keys:     newKeyRing(opts.Key),
+		enc:      opts.Encrypter,
+		hasher:   opts.Hasher,
+		policy:   opts.Policy,
+		lockout:  opts.Lockout,
+		webauthn: opts.WebAuthn,
+		issuer:   opts.Issuer,
+		audience: opts.Audience,
+		maxTTL:   opts.MaxTokenTTL,
+		now:      opts.Now,
+
+		revocations: newRevocationCache(),
+	}
 }
@@ -0,0 +1,197 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// APIKeyPrefix starts every API key, so keys are easy to tell apart from tokens and to spot by secret scanners
+const APIKeyPrefix = "a23n_"
+
+const (
+	apiKeyLength        = 32
+	maxAPIKeyNameLength = 100
+)
+
+// APIKey describes a long-lived key an entity can authenticate with instead of exchanging its secret for tokens.
+type APIKey struct {
+	ID       string
+	EntityID string
+	Name     string
+	// Scope is the subset of the entity scope granted to the key
+	Scope     Scope
+	CreatedAt time.Time
+	// ExpiresAt is zero if the key never expires
+	ExpiresAt time.Time
+	// LastUsedAt is zero if the key has never been used
+	LastUsedAt time.Time
+}
+
+// CreateAPIKey creates an API key granting a subset of the entity scope. Only the hash of the key is stored, so the
+// returned key cannot be retrieved later.
+func (a *DefaultAPI) CreateAPIKey(
+	ctx context.Context,
+	entityID string,
+	name string,
+	scope Scope,
+	expires time.Time,
+) (APIKey, string, error) {
+	if _, err := uuid.Parse(entityID); err != nil {
+		return APIKey{}, "", ErrInvalidArg{Msg: fmt.Sprintf("invalid id: %s", err.Error())}
+	}
+
+	if utf8.RuneCountInString(name) > maxAPIKeyNameLength {
+		msg := fmt.Sprintf("invalid name: longer than %d characters", maxAPIKeyNameLength)
+		return APIKey{}, "", ErrInvalidArg{Msg: msg}
+	}
+
+	now := a.now()
+	if !expires.IsZero() && !expires.After(now) {
+		return APIKey{}, "", ErrInvalidArg{Msg: "invalid expiration time: must be in the future"}
+	}
+
+	e, err := a.GetEntity(ctx, entityID)
+	if err != nil {
+		return APIKey{}, "", err
+	}
+
+	if !a.CheckScope(e.Scope, scope) {
+		return APIKey{}, "", ErrInvalidArg{Msg: "invalid scope: must be a subset of the entity scope"}
+	}
+
+	b := make([]byte, apiKeyLength)
+	if _, err = rand.Read(b); err != nil {
+		return APIKey{}, "", fmt.Errorf("generate key: %w", err)
+	}
+	key := APIKeyPrefix + base64.RawURLEncoding.EncodeToString(b)
+
+	k := APIKey{
+		ID:        uuid.NewString(),
+		EntityID:  entityID,
+		Name:      name,
+		Scope:     append(make(Scope, 0, len(scope)), scope...),
+		CreatedAt: now,
+		ExpiresAt: expires,
+	}
+
+	q := `INSERT INTO api_key (id, entity_id, name, hash, scope, created_at, expires_at) ` +
+		`VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	expiresAt := sql.NullTime{Time: expires, Valid: !expires.IsZero()}
+	_, err = a.db.ExecContext(ctx, q, k.ID, entityID, name, hashToken(key), pq.StringArray(k.Scope), now, expiresAt)
+	if err != nil {
+		return APIKey{}, "", err
+	}
+
+	return k, key, nil
+}
+
+// ListAPIKeys returns all the API keys of an entity including the expired ones.
+func (a *DefaultAPI) ListAPIKeys(ctx context.Context, entityID string) ([]APIKey, error) {
+	if _, err := uuid.Parse(entityID); err != nil {
+		return nil, ErrInvalidArg{Msg: fmt.Sprintf("invalid id: %s", err.Error())}
+	}
+
+	q := `SELECT id, name, scope, created_at, expires_at, last_used_at FROM api_key WHERE entity_id=$1 ` +
+		`ORDER BY created_at, id`
+	rows, err := a.db.QueryContext(ctx, q, entityID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := make([]APIKey, 0)
+	for rows.Next() {
+		var (
+			k         = APIKey{EntityID: entityID}
+			scope     pq.StringArray
+			expiresAt sql.NullTime
+			lastUsed  sql.NullTime
+		)
+
+		if err = rows.Scan(&k.ID, &k.Name, &scope, &k.CreatedAt, &expiresAt, &lastUsed); err != nil {
+			return nil, err
+		}
+
+		k.Scope = append(make(Scope, 0, len(scope)), scope...)
+		k.ExpiresAt = expiresAt.Time
+		k.LastUsedAt = lastUsed.Time
+
+		res = append(res, k)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// RevokeAPIKey deletes an API key of an entity.
+func (a *DefaultAPI) RevokeAPIKey(ctx context.Context, entityID, id string) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return ErrInvalidArg{Msg: fmt.Sprintf("invalid id: %s", err.Error())}
+	}
+
+	r, err := a.db.ExecContext(ctx, `DELETE FROM api_key WHERE id=$1 AND entity_id=$2`, id, entityID)
+	if err != nil {
+		return err
+	}
+
+	if ra, err := r.RowsAffected(); err != nil {
+		return err
+	} else if ra == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// VerifyAPIKey returns the claims of an API key as if it were a token of TokenTypeAPIKey type, so it can be checked
+// the same way as access tokens. The granted scope is narrowed down to the current scope of the entity. Unknown and
+// expired keys, keys of disabled entities and keys created before the entity tokens were revoked by
+// RevokeEntityTokens or DisableEntity result in ErrInvalidCredentials. The time the key is used is recorded.
+func (a *DefaultAPI) VerifyAPIKey(ctx context.Context, key string) (TokenClaims, error) {
+	var (
+		clm         = TokenClaims{Type: TokenTypeAPIKey}
+		keyScope    pq.StringArray
+		entityScope pq.StringArray
+		createdAt   time.Time
+		expiresAt   sql.NullTime
+	)
+
+	if !strings.HasPrefix(key, APIKeyPrefix) {
+		return TokenClaims{}, ErrInvalidCredentials
+	}
+
+	q := `UPDATE api_key k SET last_used_at=$1 FROM entity e WHERE k.hash=$2 AND e.id=k.entity_id AND NOT e.disabled ` +
+		`AND (k.expires_at IS NULL OR k.expires_at>$1) ` +
+		`AND (e.tokens_revoked_at IS NULL OR k.created_at>=e.tokens_revoked_at) ` +
+		`RETURNING k.id, k.entity_id, k.scope, e.scope, k.created_at, k.expires_at`
+	err := a.db.QueryRowContext(ctx, q, a.now(), hashToken(key)).
+		Scan(&clm.ID, &clm.Subject, &keyScope, &entityScope, &createdAt, &expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return TokenClaims{}, ErrInvalidCredentials
+	} else if err != nil {
+		return TokenClaims{}, err
+	}
+
+	clm.Scope = Scope(keyScope).Intersect(Scope(entityScope))
+
+	clm.IssuedAt = jwt.NewNumericDate(createdAt)
+	if expiresAt.Valid {
+		clm.ExpiresAt = jwt.NewNumericDate(expiresAt.Time)
+	}
+
+	return clm, nil
+}
@@ -0,0 +1,123 @@
+package api_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/ashep/a23n/api"
+	"github.com/ashep/a23n/sqldb"
+)
+
+// expectAPIKeyUse expects an API key to be looked up and its use to be recorded.
+func (s *EntityTestSuite) expectAPIKeyUse(key string, keyScope, entityScope []string, err error) {
+	row := &sqldb.RowMock{}
+	row.On("Scan", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			*args.Get(0).(*string) = "b8f1c2f5-7d0e-4c9a-8f3b-2a1d6e5c4b3a"
+			*args.Get(1).(*string) = "2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d"
+			*args.Get(2).(*pq.StringArray) = keyScope
+			*args.Get(3).(*pq.StringArray) = entityScope
+			*args.Get(4).(*time.Time) = time.Unix(123456000, 0)
+		}).
+		Return(err)
+
+	sum := sha256.Sum256([]byte(key))
+	s.db.
+		On("QueryRowContext", mock.Anything, "UPDATE api_key k SET last_used_at=$1 FROM entity e WHERE k.hash=$2 "+
+			"AND e.id=k.entity_id AND NOT e.disabled AND (k.expires_at IS NULL OR k.expires_at>$1) "+
+			"AND (e.tokens_revoked_at IS NULL OR k.created_at>=e.tokens_revoked_at) "+
+			"RETURNING k.id, k.entity_id, k.scope, e.scope, k.created_at, k.expires_at",
+			[]interface{}{time.Unix(123456789, 0), sum[:]}).
+		Return(row)
+}
+
+func (s *EntityTestSuite) TestCreateAPIKeyExpired() {
+	_, _, err := s.api.CreateAPIKey(context.Background(), "2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d", "theName", nil,
+		time.Unix(123456789, 0))
+	s.Require().EqualError(err, "invalid expiration time: must be in the future")
+}
+
+func (s *EntityTestSuite) TestCreateAPIKeyScopeExceeded() {
+	s.expectGetEntity("theSecretHash", false, nil)
+
+	_, _, err := s.api.CreateAPIKey(context.Background(), "2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d", "theName",
+		api.Scope{"foo"}, time.Time{})
+	s.Require().EqualError(err, "invalid scope: must be a subset of the entity scope")
+}
+
+func (s *EntityTestSuite) TestCreateAPIKeyOk() {
+	var hash []byte
+
+	s.expectGetEntity("theSecretHash", false, nil)
+	s.db.
+		On("ExecContext", mock.Anything, "INSERT INTO api_key (id, entity_id, name, hash, scope, created_at, "+
+			"expires_at) VALUES ($1, $2, $3, $4, $5, $6, $7)",
+			mock.MatchedBy(func(args []interface{}) bool {
+				return args[1] == "2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d" && args[2] == "theName" &&
+					len(args[4].(pq.StringArray)) == 0 && args[5] == time.Unix(123456789, 0) &&
+					args[6] == sql.NullTime{Time: time.Unix(123456789, 0).Add(time.Hour), Valid: true}
+			})).
+		Run(func(args mock.Arguments) { hash = args.Get(2).([]interface{})[3].([]byte) }).
+		Return(&sqldb.ResultMock{}, nil)
+
+	k, key, err := s.api.CreateAPIKey(context.Background(), "2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d", "theName", nil,
+		time.Unix(123456789, 0).Add(time.Hour))
+	s.Require().NoError(err)
+
+	s.Assert().True(strings.HasPrefix(key, "a23n_"))
+	s.Assert().Len(key, 48)
+	sum := sha256.Sum256([]byte(key))
+	s.Assert().Equal(sum[:], hash)
+
+	s.Assert().NotEmpty(k.ID)
+	s.Assert().Equal("theName", k.Name)
+	s.Assert().Equal(time.Unix(123456789, 0).Add(time.Hour), k.ExpiresAt)
+}
+
+func (s *EntityTestSuite) TestVerifyAPIKeyInvalidPrefix() {
+	_, err := s.api.VerifyAPIKey(context.Background(), "theKey")
+	s.Require().ErrorIs(err, api.ErrInvalidCredentials)
+}
+
+func (s *EntityTestSuite) TestVerifyAPIKeyNotFound() {
+	s.expectAPIKeyUse("a23n_theKey", nil, nil, sql.ErrNoRows)
+
+	_, err := s.api.VerifyAPIKey(context.Background(), "a23n_theKey")
+	s.Require().ErrorIs(err, api.ErrInvalidCredentials)
+}
+
+func (s *EntityTestSuite) TestVerifyAPIKeyOk() {
+	// The scope removed from the entity after the key has been created is not granted
+	s.expectAPIKeyUse("a23n_theKey", []string{"foo", "bar"}, []string{"bar", "baz"}, nil)
+
+	clm, err := s.api.VerifyAPIKey(context.Background(), "a23n_theKey")
+	s.Require().NoError(err)
+
+	s.Assert().Equal(api.TokenTypeAPIKey, clm.Type)
+	s.Assert().Equal("b8f1c2f5-7d0e-4c9a-8f3b-2a1d6e5c4b3a", clm.ID)
+	s.Assert().Equal("2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d", clm.Subject)
+	s.Assert().Equal([]string{"bar"}, clm.Scope)
+	s.Assert().Equal(jwt.NewNumericDate(time.Unix(123456000, 0)), clm.IssuedAt)
+	s.Assert().Nil(clm.ExpiresAt)
+}
+
+func (s *EntityTestSuite) TestRevokeAPIKeyNotFound() {
+	res := &sqldb.ResultMock{}
+	res.On("RowsAffected").Return(int64(0), nil)
+
+	s.db.
+		On("ExecContext", mock.Anything, "DELETE FROM api_key WHERE id=$1 AND entity_id=$2",
+			[]interface{}{"b8f1c2f5-7d0e-4c9a-8f3b-2a1d6e5c4b3a", "2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d"}).
+		Return(res, nil)
+
+	err := s.api.RevokeAPIKey(context.Background(), "2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d",
+		"b8f1c2f5-7d0e-4c9a-8f3b-2a1d6e5c4b3a")
+	s.Require().ErrorIs(err, api.ErrNotFound)
+}
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	// authorizationCodeTTL defines how long a client has to exchange an authorization code for tokens
+	authorizationCodeTTL = time.Minute
+
+	authorizationCodeLength = 32
+)
+
+// AuthorizationCode describes an authorization granted to a client by an entity, see RFC 6749, section 4.1.
+type AuthorizationCode struct {
+	ClientID    string
+	EntityID    string
+	RedirectURI string
+	Scope       Scope
+	// CodeChallenge is the S256 PKCE code challenge, see RFC 7636
+	CodeChallenge string
+	// Nonce is passed by OpenID Connect clients to be included into the ID token
+	Nonce string
+	// AuthTime is the time the entity has authenticated, it is set on creation
+	AuthTime time.Time
+}
+
+// CreateAuthorizationCode stores an authorization code and returns it. Only the hash of the code is stored. The
+// entity is considered to have authenticated at the time of the call.
+func (a *DefaultAPI) CreateAuthorizationCode(ctx context.Context, c AuthorizationCode) (string, error) {
+	if c.CodeChallenge == "" {
+		return "", ErrInvalidArg{Msg: "empty code challenge"}
+	}
+
+	b := make([]byte, authorizationCodeLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate code: %w", err)
+	}
+	code := base64.RawURLEncoding.EncodeToString(b)
+
+	now := a.now()
+	q := `INSERT INTO oauth_authorization_code (hash, client_id, entity_id, redirect_uri, scope, code_challenge, ` +
+		`nonce, auth_time, expires_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+	_, err := a.db.ExecContext(ctx, q, hashToken(code), c.ClientID, c.EntityID, c.RedirectURI,
+		pq.StringArray(c.Scope), c.CodeChallenge, c.Nonce, now, now.Add(authorizationCodeTTL))
+	if err != nil {
+		return "", err
+	}
+
+	return code, nil
+}
+
+// UseAuthorizationCode deletes an authorization code and returns the authorization it describes. The code must have
+// been issued to the client for the redirect URI, and the code verifier must match its challenge. Otherwise, as well as
+// if the code is unknown, expired or has been used already, ErrInvalidCredentials is returned.
+func (a *DefaultAPI) UseAuthorizationCode(
+	ctx context.Context,
+	code string,
+	clientID string,
+	redirectURI string,
+	verifier string,
+) (AuthorizationCode, error) {
+	if !isCodeVerifier(verifier) {
+		return AuthorizationCode{}, ErrInvalidArg{Msg: "invalid code verifier"}
+	}
+
+	var (
+		c     AuthorizationCode
+		scope pq.StringArray
+	)
+
+	q := `DELETE FROM oauth_authorization_code WHERE hash=$1 AND expires_at>$2 RETURNING client_id, entity_id, ` +
+		`redirect_uri, scope, code_challenge, nonce, auth_time`
+	err := a.db.QueryRowContext(ctx, q, hashToken(code), a.now()).
+		Scan(&c.ClientID, &c.EntityID, &c.RedirectURI, &scope, &c.CodeChallenge, &c.Nonce, &c.AuthTime)
+	if errors.Is(err, sql.ErrNoRows) {
+		return AuthorizationCode{}, ErrInvalidCredentials
+	} else if err != nil {
+		return AuthorizationCode{}, err
+	}
+	c.Scope = Scope(scope)
+
+	if c.ClientID != clientID || c.RedirectURI != redirectURI {
+		return AuthorizationCode{}, ErrInvalidCredentials
+	}
+
+	h := sha256.Sum256([]byte(verifier))
+	challenge := base64.RawURLEncoding.EncodeToString(h[:])
+	if subtle.ConstantTimeCompare([]byte(challenge), []byte(c.CodeChallenge)) != 1 {
+		return AuthorizationCode{}, ErrInvalidCredentials
+	}
+
+	return c, nil
+}
+
+// isCodeVerifier checks whether v is a valid PKCE code verifier, see RFC 7636, section 4.1.
+func isCodeVerifier(v string) bool {
+	if len(v) < 43 || len(v) > 128 {
+		return false
+	}
+
+	for _, c := range v {
+		if !(c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '.' ||
+			c == '_' || c == '~') {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,120 @@
+package api_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/ashep/a23n/api"
+	"github.com/ashep/a23n/sqldb"
+)
+
+const (
+	testCodeVerifier  = "dBjftJeZ4CVP-mJ0Y6j7lPuuv5cSpSI0kIL6A0WgiDc"
+	testCodeChallenge = "84bLFyZBR7tGpbNExYm-_KpVxAMhUjhGIMXRcqfBLeA"
+)
+
+// expectAuthorizationCodeUse expects an authorization code to be deleted returning the authorization it describes.
+func (s *EntityTestSuite) expectAuthorizationCodeUse(code string, err error) {
+	row := &sqldb.RowMock{}
+	row.On("Scan", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything,
+		mock.Anything).
+		Run(func(args mock.Arguments) {
+			*args.Get(0).(*string) = "theClient"
+			*args.Get(1).(*string) = "2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d"
+			*args.Get(2).(*string) = "https://example.com/callback"
+			*args.Get(3).(*pq.StringArray) = pq.StringArray{"foo"}
+			*args.Get(4).(*string) = testCodeChallenge
+			*args.Get(5).(*string) = "theNonce"
+			*args.Get(6).(*time.Time) = time.Unix(123456700, 0)
+		}).
+		Return(err)
+
+	hash := sha256.Sum256([]byte(code))
+	s.db.
+		On("QueryRowContext", mock.Anything, "DELETE FROM oauth_authorization_code WHERE hash=$1 AND expires_at>$2 "+
+			"RETURNING client_id, entity_id, redirect_uri, scope, code_challenge, nonce, auth_time",
+			[]interface{}{hash[:], time.Unix(123456789, 0)}).
+		Return(row)
+}
+
+func (s *EntityTestSuite) TestCreateAuthorizationCodeOk() {
+	var hash []byte
+
+	s.db.
+		On("ExecContext", mock.Anything, "INSERT INTO oauth_authorization_code (hash, client_id, entity_id, "+
+			"redirect_uri, scope, code_challenge, nonce, auth_time, expires_at) VALUES ($1, $2, $3, $4, $5, $6, $7, "+
+			"$8, $9)",
+			mock.MatchedBy(func(args []interface{}) bool {
+				return args[1] == "theClient" && args[2] == "2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d" &&
+					args[3] == "https://example.com/callback" && args[5] == testCodeChallenge &&
+					args[6] == "theNonce" && args[7] == time.Unix(123456789, 0) &&
+					args[8] == time.Unix(123456789, 0).Add(time.Minute)
+			})).
+		Run(func(args mock.Arguments) { hash = args.Get(2).([]interface{})[0].([]byte) }).
+		Return(&sqldb.ResultMock{}, nil)
+
+	code, err := s.api.CreateAuthorizationCode(context.Background(), api.AuthorizationCode{
+		ClientID:      "theClient",
+		EntityID:      "2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d",
+		RedirectURI:   "https://example.com/callback",
+		Scope:         api.Scope{"foo"},
+		CodeChallenge: testCodeChallenge,
+		Nonce:         "theNonce",
+	})
+	s.Require().NoError(err)
+
+	sum := sha256.Sum256([]byte(code))
+	s.Assert().Equal(sum[:], hash)
+}
+
+func (s *EntityTestSuite) TestUseAuthorizationCodeInvalidVerifier() {
+	_, err := s.api.UseAuthorizationCode(context.Background(), "theCode", "theClient",
+		"https://example.com/callback", "tooShort")
+	s.Require().EqualError(err, "invalid code verifier")
+}
+
+func (s *EntityTestSuite) TestUseAuthorizationCodeNotFound() {
+	s.expectAuthorizationCodeUse("theCode", sql.ErrNoRows)
+
+	_, err := s.api.UseAuthorizationCode(context.Background(), "theCode", "theClient",
+		"https://example.com/callback", testCodeVerifier)
+	s.Require().ErrorIs(err, api.ErrInvalidCredentials)
+}
+
+func (s *EntityTestSuite) TestUseAuthorizationCodeOtherClient() {
+	s.expectAuthorizationCodeUse("theCode", nil)
+
+	_, err := s.api.UseAuthorizationCode(context.Background(), "theCode", "otherClient",
+		"https://example.com/callback", testCodeVerifier)
+	s.Require().ErrorIs(err, api.ErrInvalidCredentials)
+}
+
+func (s *EntityTestSuite) TestUseAuthorizationCodeWrongVerifier() {
+	s.expectAuthorizationCodeUse("theCode", nil)
+
+	_, err := s.api.UseAuthorizationCode(context.Background(), "theCode", "theClient",
+		"https://example.com/callback", testCodeVerifier+"x")
+	s.Require().ErrorIs(err, api.ErrInvalidCredentials)
+}
+
+func (s *EntityTestSuite) TestUseAuthorizationCodeOk() {
+	s.expectAuthorizationCodeUse("theCode", nil)
+
+	c, err := s.api.UseAuthorizationCode(context.Background(), "theCode", "theClient",
+		"https://example.com/callback", testCodeVerifier)
+	s.Require().NoError(err)
+	s.Assert().Equal(api.AuthorizationCode{
+		ClientID:      "theClient",
+		EntityID:      "2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d",
+		RedirectURI:   "https://example.com/callback",
+		Scope:         api.Scope{"foo"},
+		CodeChallenge: testCodeChallenge,
+		Nonce:         "theNonce",
+		AuthTime:      time.Unix(123456700, 0),
+	}, c)
+}
@@ -0,0 +1,360 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+	"unicode/utf8"
+
+	"github.com/lib/pq"
+
+	"github.com/ashep/a23n/sqldb"
+)
+
+// Grant types a client can be allowed to use, see RFC 6749. Only confidential clients can use the client_credentials
+// grant, which issues them tokens of their own.
+const (
+	GrantTypeAuthorizationCode = "authorization_code"
+	GrantTypeClientCredentials = "client_credentials"
+	GrantTypePassword          = "password"
+	GrantTypeRefreshToken      = "refresh_token"
+)
+
+const (
+	clientSecretLength  = 32
+	maxClientNameLength = 100
+)
+
+// Client is an application which obtains tokens on behalf of entities by the OAuth 2.0 authorization code or password
+// flows, or for itself by the client credentials one. Confidential clients authenticate with a secret, public ones
+// only identify themselves.
+type Client struct {
+	ID   string
+	Name string
+	// RedirectURIs are the only URIs authorization responses are sent to
+	RedirectURIs []string
+	// GrantTypes are the grant types the client is allowed to use, the authorization code one by default
+	GrantTypes []string
+	// Scope limits the scopes granted to the client, any of the entity scopes can be granted if it is empty
+	Scope Scope
+	// AccessTokenTTL and RefreshTokenTTL override the lifetimes of tokens issued to the client if not zero
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	// Confidential is set on creation and cannot be changed
+	Confidential bool
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+
+	secretHash []byte
+}
+
+// AllowsGrantType checks whether the client is allowed to use a grant type.
+func (c Client) AllowsGrantType(typ string) bool {
+	for _, t := range c.GrantTypes {
+		if t == typ {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RestrictScope returns the scopes of s the client can be granted.
+func (c Client) RestrictScope(s Scope) Scope {
+	if len(c.Scope) == 0 {
+		return s
+	}
+
+	return s.Intersect(c.Scope)
+}
+
+// validateRedirectURI checks that u is an absolute URI without a fragment, see RFC 6749, section 3.1.2.
+func validateRedirectURI(u string) error {
+	pu, err := url.Parse(u)
+	if err != nil {
+		return ErrInvalidArg{Msg: fmt.Sprintf("invalid redirect uri: %s", err.Error())}
+	}
+
+	if !pu.IsAbs() || pu.Fragment != "" {
+		return ErrInvalidArg{Msg: fmt.Sprintf("invalid redirect uri: %s: must be absolute and have no fragment", u)}
+	}
+
+	return nil
+}
+
+// validateClient checks the mutable properties of a client, setting the default grant types if none are set.
+func (a *DefaultAPI) validateClient(c *Client) error {
+	if utf8.RuneCountInString(c.Name) > maxClientNameLength {
+		return ErrInvalidArg{Msg: fmt.Sprintf("invalid name: longer than %d characters", maxClientNameLength)}
+	}
+
+	if len(c.GrantTypes) == 0 {
+		c.GrantTypes = []string{GrantTypeAuthorizationCode}
+	}
+
+	for _, t := range c.GrantTypes {
+		switch t {
+		case GrantTypeAuthorizationCode, GrantTypePassword, GrantTypeRefreshToken:
+		case GrantTypeClientCredentials:
+			if !c.Confidential {
+				return ErrInvalidArg{Msg: fmt.Sprintf("invalid grant type: %s: confidential clients only", t)}
+			}
+		default:
+			return ErrInvalidArg{Msg: fmt.Sprintf("invalid grant type: %s", t)}
+		}
+	}
+
+	if c.AllowsGrantType(GrantTypeAuthorizationCode) && len(c.RedirectURIs) == 0 {
+		return ErrInvalidArg{Msg: "empty redirect uris"}
+	}
+
+	for _, u := range c.RedirectURIs {
+		if err := validateRedirectURI(u); err != nil {
+			return err
+		}
+	}
+
+	if c.AccessTokenTTL < 0 || c.RefreshTokenTTL < 0 {
+		return ErrInvalidArg{Msg: "invalid token ttl: must not be negative"}
+	}
+
+	// Signing keys are retired after the longest server-wide lifetime, so tokens must not outlive it
+	if a.maxTTL != 0 && (c.AccessTokenTTL > a.maxTTL || c.RefreshTokenTTL > a.maxTTL) {
+		return ErrInvalidArg{Msg: fmt.Sprintf("invalid token ttl: longer than %s", a.maxTTL)}
+	}
+
+	return nil
+}
+
+// generateClientSecret returns a random secret along with its hash.
+func generateClientSecret() (string, []byte, error) {
+	b := make([]byte, clientSecretLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", nil, fmt.Errorf("generate secret: %w", err)
+	}
+	secret := base64.RawURLEncoding.EncodeToString(b)
+
+	return secret, hashToken(secret), nil
+}
+
+// CreateClient registers an OAuth 2.0 client. A secret is generated for confidential clients and returned, only its
+// hash is stored, so it cannot be retrieved later.
+func (a *DefaultAPI) CreateClient(ctx context.Context, c Client) (string, error) {
+	if c.ID == "" {
+		return "", ErrInvalidArg{Msg: "empty id"}
+	}
+
+	if err := a.validateClient(&c); err != nil {
+		return "", err
+	}
+
+	var (
+		secret     string
+		secretHash []byte
+		err        error
+	)
+	if c.Confidential {
+		if secret, secretHash, err = generateClientSecret(); err != nil {
+			return "", err
+		}
+	}
+
+	now := a.now()
+	q := `INSERT INTO oauth_client (id, name, redirect_uris, grant_types, scope, access_token_ttl, ` +
+		`refresh_token_ttl, confidential, secret_hash, created_at, updated_at) ` +
+		`VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $10)`
+	_, err = a.db.ExecContext(ctx, q, c.ID, c.Name, pq.StringArray(c.RedirectURIs), pq.StringArray(c.GrantTypes),
+		pq.StringArray(c.Scope), int64(c.AccessTokenTTL.Seconds()), int64(c.RefreshTokenTTL.Seconds()), c.Confidential,
+		secretHash, now)
+	if isUniqueViolation(err) {
+		return "", ErrAlreadyExists
+	} else if err != nil {
+		return "", err
+	}
+
+	return secret, nil
+}
+
+// clientColumns are selected by the queries returning clients, in the order scanClient expects them
+const clientColumns = `id, name, redirect_uris, grant_types, scope, access_token_ttl, refresh_token_ttl, ` +
+	`confidential, secret_hash, created_at, updated_at`
+
+func scanClient(row sqldb.Row) (Client, error) {
+	var (
+		c            Client
+		redirectURIs pq.StringArray
+		grantTypes   pq.StringArray
+		scope        pq.StringArray
+		accessTTL    int64
+		refreshTTL   int64
+		secretHash   []byte
+	)
+
+	err := row.Scan(&c.ID, &c.Name, &redirectURIs, &grantTypes, &scope, &accessTTL, &refreshTTL, &c.Confidential,
+		&secretHash, &c.CreatedAt, &c.UpdatedAt)
+	if err != nil {
+		return Client{}, err
+	}
+
+	c.RedirectURIs = redirectURIs
+	c.GrantTypes = grantTypes
+	c.Scope = Scope(scope)
+	c.AccessTokenTTL = time.Duration(accessTTL) * time.Second
+	c.RefreshTokenTTL = time.Duration(refreshTTL) * time.Second
+	c.secretHash = secretHash
+
+	return c, nil
+}
+
+// GetClient returns an OAuth 2.0 client.
+func (a *DefaultAPI) GetClient(ctx context.Context, id string) (Client, error) {
+	q := `SELECT ` + clientColumns + ` FROM oauth_client WHERE id=$1`
+	c, err := scanClient(a.db.QueryRowContext(ctx, q, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Client{}, ErrNotFound
+	} else if err != nil {
+		return Client{}, err
+	}
+
+	return c, nil
+}
+
+// ListClients returns all the registered OAuth 2.0 clients.
+func (a *DefaultAPI) ListClients(ctx context.Context) ([]Client, error) {
+	rows, err := a.db.QueryContext(ctx, `SELECT `+clientColumns+` FROM oauth_client ORDER BY created_at, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := make([]Client, 0)
+	for rows.Next() {
+		c, sErr := scanClient(rows)
+		if sErr != nil {
+			return nil, sErr
+		}
+		res = append(res, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// UpdateClient updates the fields of an OAuth 2.0 client listed in mask by the values taken from c, the other fields
+// are kept. The fields are named as in the API: name, redirect_uris, grant_types, scope, access_token_ttl and
+// refresh_token_ttl. If resetSecret is set, a new secret is generated for a confidential client and returned, the
+// previous one stops working immediately.
+func (a *DefaultAPI) UpdateClient(ctx context.Context, c Client, mask []string, resetSecret bool) (string, error) {
+	if len(mask) == 0 && !resetSecret {
+		return "", ErrInvalidArg{Msg: "empty update mask"}
+	}
+
+	cur, err := a.GetClient(ctx, c.ID)
+	if err != nil {
+		return "", err
+	}
+
+	for _, f := range mask {
+		switch f {
+		case "name":
+			cur.Name = c.Name
+		case "redirect_uris":
+			cur.RedirectURIs = c.RedirectURIs
+		case "grant_types":
+			cur.GrantTypes = c.GrantTypes
+		case "scope":
+			cur.Scope = c.Scope
+		case "access_token_ttl":
+			cur.AccessTokenTTL = c.AccessTokenTTL
+		case "refresh_token_ttl":
+			cur.RefreshTokenTTL = c.RefreshTokenTTL
+		default:
+			return "", ErrInvalidArg{Msg: fmt.Sprintf("invalid update mask: unknown field %s", f)}
+		}
+	}
+
+	if err = a.validateClient(&cur); err != nil {
+		return "", err
+	}
+
+	var secret string
+	secretHash := cur.secretHash
+	if resetSecret {
+		if !cur.Confidential {
+			return "", ErrInvalidArg{Msg: "public clients have no secret"}
+		}
+		if secret, secretHash, err = generateClientSecret(); err != nil {
+			return "", err
+		}
+	}
+
+	q := `UPDATE oauth_client SET name=$2, redirect_uris=$3, grant_types=$4, scope=$5, access_token_ttl=$6, ` +
+		`refresh_token_ttl=$7, secret_hash=$8, updated_at=$9 WHERE id=$1`
+	r, err := a.db.ExecContext(ctx, q, cur.ID, cur.Name, pq.StringArray(cur.RedirectURIs),
+		pq.StringArray(cur.GrantTypes), pq.StringArray(cur.Scope), int64(cur.AccessTokenTTL.Seconds()),
+		int64(cur.RefreshTokenTTL.Seconds()), secretHash, a.now())
+	if err != nil {
+		return "", err
+	}
+
+	if ra, err := r.RowsAffected(); err != nil {
+		return "", err
+	} else if ra == 0 {
+		return "", ErrNotFound
+	}
+
+	return secret, nil
+}
+
+// DeleteClient deletes an OAuth 2.0 client along with the authorization codes issued to it. Tokens which have been
+// issued to the client remain valid until they expire.
+func (a *DefaultAPI) DeleteClient(ctx context.Context, id string) error {
+	r, err := a.db.ExecContext(ctx, `DELETE FROM oauth_client WHERE id=$1`, id)
+	if err != nil {
+		return err
+	}
+
+	if ra, err := r.RowsAffected(); err != nil {
+		return err
+	} else if ra == 0 {
+		return ErrNotFound
+	}
+
+	// Tokens the client has obtained for itself are cached with the client as the subject
+	a.revocations.removeEntity(id)
+
+	return nil
+}
+
+// VerifyClient identifies an OAuth 2.0 client. Confidential clients must pass their secret, public ones must not pass
+// any. ErrInvalidCredentials is returned if the client is unknown or the secret does not match.
+func (a *DefaultAPI) VerifyClient(ctx context.Context, id, secret string) (Client, error) {
+	c, err := a.GetClient(ctx, id)
+	if errors.Is(err, ErrNotFound) {
+		return Client{}, ErrInvalidCredentials
+	} else if err != nil {
+		return Client{}, err
+	}
+
+	if !c.Confidential {
+		if secret != "" {
+			return Client{}, ErrInvalidCredentials
+		}
+		return c, nil
+	}
+
+	if secret == "" || subtle.ConstantTimeCompare(hashToken(secret), c.secretHash) != 1 {
+		return Client{}, ErrInvalidCredentials
+	}
+
+	return c, nil
+}
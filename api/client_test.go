@@ -0,0 +1,219 @@
+package api_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/ashep/a23n/api"
+	"github.com/ashep/a23n/sqldb"
+)
+
+// expectGetClient expects a client to be selected. Confidential clients have the secret "theSecret".
+func (s *EntityTestSuite) expectGetClient(confidential bool, err error) {
+	var secretHash []byte
+	if confidential {
+		h := sha256.Sum256([]byte("theSecret"))
+		secretHash = h[:]
+	}
+
+	row := &sqldb.RowMock{}
+	row.On("Scan", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything,
+		mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			*args.Get(0).(*string) = "theClient"
+			*args.Get(1).(*string) = "The Client"
+			*args.Get(2).(*pq.StringArray) = pq.StringArray{"https://example.com/callback"}
+			*args.Get(3).(*pq.StringArray) = pq.StringArray{"authorization_code"}
+			*args.Get(4).(*pq.StringArray) = pq.StringArray{}
+			*args.Get(5).(*int64) = 60
+			*args.Get(6).(*int64) = 0
+			*args.Get(7).(*bool) = confidential
+			*args.Get(8).(*[]byte) = secretHash
+			*args.Get(9).(*time.Time) = time.Unix(123456700, 0)
+			*args.Get(10).(*time.Time) = time.Unix(123456700, 0)
+		}).
+		Return(err)
+
+	s.db.
+		On("QueryRowContext", mock.Anything, "SELECT id, name, redirect_uris, grant_types, scope, access_token_ttl, "+
+			"refresh_token_ttl, confidential, secret_hash, created_at, updated_at FROM oauth_client WHERE id=$1",
+			[]interface{}{"theClient"}).
+		Return(row)
+}
+
+func (s *EntityTestSuite) TestCreateClientInvalidRedirectURI() {
+	_, err := s.api.CreateClient(context.Background(), api.Client{
+		ID:           "theClient",
+		RedirectURIs: []string{"https://example.com/callback#fragment"},
+	})
+	s.Require().ErrorIs(err, api.ErrInvalidArg{})
+}
+
+func (s *EntityTestSuite) TestCreateClientInvalidGrantType() {
+	_, err := s.api.CreateClient(context.Background(), api.Client{
+		ID:         "theClient",
+		GrantTypes: []string{"password", "implicit"},
+	})
+	s.Require().EqualError(err, "invalid grant type: implicit")
+}
+
+func (s *EntityTestSuite) TestCreateClientPublicClientCredentials() {
+	_, err := s.api.CreateClient(context.Background(), api.Client{
+		ID:         "theClient",
+		GrantTypes: []string{"client_credentials"},
+	})
+	s.Require().EqualError(err, "invalid grant type: client_credentials: confidential clients only")
+}
+
+func (s *EntityTestSuite) TestCreateClientMissingRedirectURIs() {
+	// The authorization code grant is allowed by default
+	_, err := s.api.CreateClient(context.Background(), api.Client{ID: "theClient"})
+	s.Require().EqualError(err, "empty redirect uris")
+}
+
+func (s *EntityTestSuite) TestCreateClientTokenTTLTooLong() {
+	_, err := s.api.CreateClient(context.Background(), api.Client{
+		ID:              "theClient",
+		GrantTypes:      []string{"password"},
+		RefreshTokenTTL: 25 * time.Hour,
+	})
+	s.Require().EqualError(err, "invalid token ttl: longer than 24h0m0s")
+}
+
+func (s *EntityTestSuite) TestCreateClientConfidential() {
+	var hash []byte
+
+	s.db.
+		On("ExecContext", mock.Anything, "INSERT INTO oauth_client (id, name, redirect_uris, grant_types, scope, "+
+			"access_token_ttl, refresh_token_ttl, confidential, secret_hash, created_at, updated_at) "+
+			"VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $10)",
+			mock.MatchedBy(func(args []interface{}) bool {
+				return args[0] == "theClient" &&
+					assert.ObjectsAreEqual(pq.StringArray{"password", "refresh_token"}, args[3]) &&
+					args[5] == int64(300) && args[6] == int64(0) && args[7] == true &&
+					args[9] == time.Unix(123456789, 0)
+			})).
+		Run(func(args mock.Arguments) { hash = args.Get(2).([]interface{})[8].([]byte) }).
+		Return(&sqldb.ResultMock{}, nil)
+
+	secret, err := s.api.CreateClient(context.Background(), api.Client{
+		ID:             "theClient",
+		GrantTypes:     []string{"password", "refresh_token"},
+		AccessTokenTTL: 5 * time.Minute,
+		Confidential:   true,
+	})
+	s.Require().NoError(err)
+
+	sum := sha256.Sum256([]byte(secret))
+	s.Assert().Equal(sum[:], hash)
+}
+
+func (s *EntityTestSuite) TestGetClientNotFound() {
+	s.expectGetClient(false, sql.ErrNoRows)
+
+	_, err := s.api.GetClient(context.Background(), "theClient")
+	s.Require().ErrorIs(err, api.ErrNotFound)
+}
+
+func (s *EntityTestSuite) TestUpdateClientResetPublicSecret() {
+	s.expectGetClient(false, nil)
+
+	_, err := s.api.UpdateClient(context.Background(), api.Client{
+		ID:           "theClient",
+		RedirectURIs: []string{"https://example.com/callback"},
+	}, nil, true)
+	s.Require().EqualError(err, "public clients have no secret")
+}
+
+func (s *EntityTestSuite) TestUpdateClientEmptyMask() {
+	_, err := s.api.UpdateClient(context.Background(), api.Client{ID: "theClient"}, nil, false)
+	s.Require().EqualError(err, "empty update mask")
+}
+
+func (s *EntityTestSuite) TestUpdateClientInvalidMask() {
+	s.expectGetClient(false, nil)
+
+	_, err := s.api.UpdateClient(context.Background(), api.Client{ID: "theClient"}, []string{"confidential"}, false)
+	s.Require().EqualError(err, "invalid update mask: unknown field confidential")
+}
+
+func (s *EntityTestSuite) TestUpdateClientInvalid() {
+	// The authorization code grant is kept, so the redirect URIs cannot be cleared
+	s.expectGetClient(false, nil)
+
+	_, err := s.api.UpdateClient(context.Background(), api.Client{ID: "theClient"}, []string{"redirect_uris"}, false)
+	s.Require().EqualError(err, "empty redirect uris")
+}
+
+func (s *EntityTestSuite) TestUpdateClientOk() {
+	// The fields which are not in the mask are kept even though they are not set
+	s.expectGetClient(false, nil)
+
+	res := &sqldb.ResultMock{}
+	res.On("RowsAffected").Return(int64(1), nil)
+	s.db.
+		On("ExecContext", mock.Anything, "UPDATE oauth_client SET name=$2, redirect_uris=$3, grant_types=$4, "+
+			"scope=$5, access_token_ttl=$6, refresh_token_ttl=$7, secret_hash=$8, updated_at=$9 WHERE id=$1",
+			[]interface{}{"theClient", "The Client", pq.StringArray{"https://example.com/callback"},
+				pq.StringArray{"authorization_code"}, pq.StringArray{"foo"}, int64(60), int64(0), []byte(nil),
+				time.Unix(123456789, 0)}).
+		Return(res, nil)
+
+	secret, err := s.api.UpdateClient(context.Background(), api.Client{
+		ID:             "theClient",
+		Name:           "The Other Client",
+		Scope:          api.Scope{"foo"},
+		AccessTokenTTL: time.Hour,
+	}, []string{"scope"}, false)
+	s.Require().NoError(err)
+	s.Assert().Empty(secret)
+}
+
+func (s *EntityTestSuite) TestDeleteClientNotFound() {
+	res := &sqldb.ResultMock{}
+	res.On("RowsAffected").Return(int64(0), nil)
+	s.db.
+		On("ExecContext", mock.Anything, "DELETE FROM oauth_client WHERE id=$1", []interface{}{"theClient"}).
+		Return(res, nil)
+
+	err := s.api.DeleteClient(context.Background(), "theClient")
+	s.Require().ErrorIs(err, api.ErrNotFound)
+}
+
+func (s *EntityTestSuite) TestVerifyClientUnknown() {
+	s.expectGetClient(false, sql.ErrNoRows)
+
+	_, err := s.api.VerifyClient(context.Background(), "theClient", "")
+	s.Require().ErrorIs(err, api.ErrInvalidCredentials)
+}
+
+func (s *EntityTestSuite) TestVerifyClientPublicWithSecret() {
+	s.expectGetClient(false, nil)
+
+	_, err := s.api.VerifyClient(context.Background(), "theClient", "theSecret")
+	s.Require().ErrorIs(err, api.ErrInvalidCredentials)
+}
+
+func (s *EntityTestSuite) TestVerifyClientWrongSecret() {
+	s.expectGetClient(true, nil)
+
+	_, err := s.api.VerifyClient(context.Background(), "theClient", "wrongSecret")
+	s.Require().ErrorIs(err, api.ErrInvalidCredentials)
+}
+
+func (s *EntityTestSuite) TestVerifyClientOk() {
+	s.expectGetClient(true, nil)
+
+	c, err := s.api.VerifyClient(context.Background(), "theClient", "theSecret")
+	s.Require().NoError(err)
+	s.Assert().Equal("theClient", c.ID)
+	s.Assert().Equal(time.Minute, c.AccessTokenTTL)
+	s.Assert().True(c.AllowsGrantType(api.GrantTypeAuthorizationCode))
+	s.Assert().False(c.AllowsGrantType(api.GrantTypePassword))
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+// EncryptionKeyLength is the length of keys accepted by NewEncrypter.
+const EncryptionKeyLength = 32
+
+// encryptedPrefix marks values encrypted by an Encrypter, so values stored before the encryption has been configured
+// are still readable.
+var encryptedPrefix = []byte("a23n:enc:v1:")
+
+// ErrNoEncryptionKey is returned when an encrypted value is read while no encryption key is configured.
+var ErrNoEncryptionKey = errors.New("no encryption key")
+
+// Encrypter protects sensitive values stored in the database, like private signing keys and TOTP secrets, with
+// AES-256-GCM. Each value is bound to the record it belongs to by associated data, so encrypted values cannot be moved
+// between records. A nil Encrypter stores values as is.
+type Encrypter struct {
+	aead cipher.AEAD
+}
+
+// NewEncrypter creates an Encrypter using a key of EncryptionKeyLength bytes.
+func NewEncrypter(key []byte) (*Encrypter, error) {
+	if len(key) != EncryptionKeyLength {
+		return nil, fmt.Errorf("encryption key must be %d bytes long", EncryptionKeyLength)
+	}
+
+	b, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(b)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Encrypter{aead: aead}, nil
+}
+
+// encrypt returns v encrypted, or v itself if e is nil.
+func (e *Encrypter) encrypt(v, ad []byte) ([]byte, error) {
+	if e == nil {
+		return v, nil
+	}
+
+	nonce := make([]byte, e.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("generate nonce: %w", err)
+	}
+
+	r := make([]byte, 0, len(encryptedPrefix)+len(nonce)+len(v)+e.aead.Overhead())
+	r = append(r, encryptedPrefix...)
+	r = append(r, nonce...)
+
+	return e.aead.Seal(r, nonce, v, ad), nil
+}
+
+// decrypt returns the plaintext of a value returned by encrypt. Values stored unencrypted are returned as is.
+func (e *Encrypter) decrypt(v, ad []byte) ([]byte, error) {
+	if !bytes.HasPrefix(v, encryptedPrefix) {
+		return v, nil
+	}
+
+	if e == nil {
+		return nil, ErrNoEncryptionKey
+	}
+
+	v = v[len(encryptedPrefix):]
+	if len(v) < e.aead.NonceSize() {
+		return nil, errors.New("encrypted value is too short")
+	}
+
+	r, err := e.aead.Open(nil, v[:e.aead.NonceSize()], v[e.aead.NonceSize():], ad)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt: %w", err)
+	}
+
+	return r, nil
+}
@@ -6,29 +6,92 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
 	"github.com/lib/pq"
-	"golang.org/x/crypto/bcrypt"
 )
 
+// maxLoginLength is the maximum length of an entity login, enough to hold any valid email address
+const maxLoginLength = 254
+
 type Entity struct {
-	ID     string
-	Secret string
-	Scope  Scope
-	Attrs  Attrs
+	ID              string
+	Login           string
+	Secret          string
+	Scope           Scope
+	Attrs           Attrs
+	Disabled        bool
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	SecretChangedAt time.Time
+	// LastAuthenticatedAt is zero if the entity has never authenticated with its secret
+	LastAuthenticatedAt time.Time
+	// FailedAuthCount is the number of failed authentication attempts since the last successful one
+	FailedAuthCount int
+	// LockedUntil is zero if the entity has not been locked out since its last successful authentication
+	LockedUntil time.Time
+	// TOTPEnabled tells whether the entity must pass a TOTP code as the second authentication factor
+	TOTPEnabled bool
 }
 
-// CreateEntity creates a new entity. The secret should contain a hashed string, not clear text.
-func (a *DefaultAPI) CreateEntity(ctx context.Context, id string, secret []byte, scope Scope, attrs Attrs) error {
+// validateLogin checks whether a login can be used to identify an entity in the Basic authorization header.
+func validateLogin(login string) error {
+	if utf8.RuneCountInString(login) > maxLoginLength {
+		return ErrInvalidArg{Msg: fmt.Sprintf("invalid login: longer than %d characters", maxLoginLength)}
+	}
+
+	if _, err := uuid.Parse(login); err == nil {
+		return ErrInvalidArg{Msg: "invalid login: must not be a UUID"}
+	}
+
+	for _, r := range login {
+		if r == ':' || unicode.IsSpace(r) || !unicode.IsPrint(r) {
+			return ErrInvalidArg{Msg: fmt.Sprintf("invalid login: character %q is not allowed", r)}
+		}
+	}
+
+	return nil
+}
+
+// isUniqueViolation checks whether err is caused by a unique constraint violation.
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// CreateEntity creates a new entity. The secret must meet the password policy and is hashed before storing. The login
+// is optional and must be unique regardless of the case.
+func (a *DefaultAPI) CreateEntity(
+	ctx context.Context,
+	id string,
+	login string,
+	secret string,
+	scope Scope,
+	attrs Attrs,
+) error {
 	var err error
 
 	if _, err = uuid.Parse(id); err != nil {
 		return ErrInvalidArg{Msg: fmt.Sprintf("invalid id: %s", err.Error())}
 	}
 
-	if _, err = bcrypt.Cost(secret); err != nil {
-		return ErrInvalidArg{Msg: fmt.Sprintf("invalid secretKey: %s", err.Error())}
+	if login != "" {
+		if err = validateLogin(login); err != nil {
+			return err
+		}
+	}
+
+	if err = a.policy.checkSecret(secret, login, attrs); err != nil {
+		return err
+	}
+
+	secretHash, err := a.hasher.Hash(secret)
+	if err != nil {
+		return fmt.Errorf("hash secret: %w", err)
 	}
 
 	scopeArg := pq.StringArray{}
@@ -44,24 +107,43 @@ func (a *DefaultAPI) CreateEntity(ctx context.Context, id string, secret []byte,
 		}
 	}
 
-	q := `INSERT INTO entity (id, secretKey, scope, attrs) VALUES ($1, $2, $3, $4)`
-	_, err = a.db.ExecContext(ctx, q, id, secret, scopeArg, attrsJSON)
-	if err != nil {
+	loginArg := sql.NullString{String: login, Valid: login != ""}
+
+	q := `INSERT INTO entity (id, login, secret, scope, attrs, created_at, updated_at, secret_changed_at) ` +
+		`VALUES ($1, $2, $3, $4, $5, $6, $6, $6)`
+	_, err = a.db.ExecContext(ctx, q, id, loginArg, []byte(secretHash), scopeArg, attrsJSON, a.now())
+	if isUniqueViolation(err) {
+		return ErrAlreadyExists
+	} else if err != nil {
 		return err
 	}
 
 	return nil
 }
 
-// UpdateEntity updates an existing entity. The secret should contain a hashed string, not clear text. Empty secret
-// tells this method that it should not be updated.
-func (a *DefaultAPI) UpdateEntity(ctx context.Context, id string, secret []byte, scope Scope, attrs Attrs) error {
+// UpdateEntity updates an existing entity. The secret must meet the password policy and is hashed before storing. Empty
+// secret and login tell this method that they should not be updated. A new secret is checked against the passed login,
+// or the stored one if no login is passed.
+func (a *DefaultAPI) UpdateEntity(
+	ctx context.Context,
+	id string,
+	login string,
+	secret string,
+	scope Scope,
+	attrs Attrs,
+) error {
 	var err error
 
 	if _, err := uuid.Parse(id); err != nil {
 		return ErrInvalidArg{Msg: fmt.Sprintf("invalid id: %s", err.Error())}
 	}
 
+	if login != "" {
+		if err = validateLogin(login); err != nil {
+			return err
+		}
+	}
+
 	scopeArg := pq.StringArray{}
 	for _, s := range scope {
 		scopeArg = append(scopeArg, s)
@@ -76,20 +158,42 @@ func (a *DefaultAPI) UpdateEntity(ctx context.Context, id string, secret []byte,
 		}
 	}
 
-	q := `UPDATE entity SET scope=$1, attrs=$2 WHERE id=$3`
-	qArgs := []interface{}{scopeArg, attrsJSON, id}
+	set := []string{"scope=$1", "attrs=$2", "updated_at=$3"}
+	qArgs := []interface{}{scopeArg, attrsJSON, a.now()}
+
+	if secret != "" {
+		policyLogin := login
+		if policyLogin == "" && a.policy.DisallowLogin {
+			if policyLogin, err = a.entityLogin(ctx, id); err != nil {
+				return err
+			}
+		}
+
+		if err = a.policy.checkSecret(secret, policyLogin, attrs); err != nil {
+			return err
+		}
 
-	if len(secret) != 0 {
-		if _, err = bcrypt.Cost(secret); err != nil {
-			return ErrInvalidArg{Msg: fmt.Sprintf("invalid secretKey: %s", err.Error())}
+		secretHash, err := a.hasher.Hash(secret)
+		if err != nil {
+			return fmt.Errorf("hash secret: %w", err)
 		}
 
-		q = `UPDATE entity SET secretKey=$1, scope=$2, attrs=$3 WHERE id=$4`
-		qArgs = []interface{}{secret, scopeArg, attrsJSON, id}
+		qArgs = append(qArgs, []byte(secretHash))
+		set = append(set, fmt.Sprintf("secret=$%d", len(qArgs)), "secret_changed_at=$3")
+	}
+
+	if login != "" {
+		qArgs = append(qArgs, login)
+		set = append(set, fmt.Sprintf("login=$%d", len(qArgs)))
 	}
 
+	qArgs = append(qArgs, id)
+	q := fmt.Sprintf(`UPDATE entity SET %s WHERE id=$%d`, strings.Join(set, ", "), len(qArgs))
+
 	qr, err := a.db.ExecContext(ctx, q, qArgs...)
-	if err != nil {
+	if isUniqueViolation(err) {
+		return ErrAlreadyExists
+	} else if err != nil {
 		return err
 	}
 
@@ -103,23 +207,230 @@ func (a *DefaultAPI) UpdateEntity(ctx context.Context, id string, secret []byte,
 }
 
 func (a *DefaultAPI) GetEntity(ctx context.Context, id string) (Entity, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return Entity{}, ErrNotFound
+	}
+
+	return a.getEntity(ctx, `id=$1`, id)
+}
+
+// entityLogin returns the stored login of an entity, which is empty if the entity has no login.
+func (a *DefaultAPI) entityLogin(ctx context.Context, id string) (string, error) {
+	var login sql.NullString
+
+	err := a.db.QueryRowContext(ctx, `SELECT login FROM entity WHERE id=$1`, id).Scan(&login)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrNotFound
+	} else if err != nil {
+		return "", err
+	}
+
+	return login.String, nil
+}
+
+// getEntity returns the entity matching a condition with a single argument.
+func (a *DefaultAPI) getEntity(ctx context.Context, cond string, arg interface{}) (Entity, error) {
 	var (
-		secret string
-		scope  pq.StringArray
-		attrs  map[string]string
+		e           Entity
+		login       sql.NullString
+		scope       pq.StringArray
+		attrsJSON   []byte
+		lastAuth    sql.NullTime
+		lockedUntil sql.NullTime
 	)
 
-	row := a.db.QueryRowContext(ctx, `SELECT secretKey, scope, attrs FROM entity WHERE id=$1`, id)
-	if err := row.Scan(&secret, &scope, &attrs); errors.Is(err, sql.ErrNoRows) {
+	q := `SELECT id, login, secret, scope, attrs, disabled, created_at, updated_at, secret_changed_at, ` +
+		`last_authenticated_at, failed_auth_count, locked_until, totp_secret IS NOT NULL FROM entity WHERE ` + cond
+	err := a.db.QueryRowContext(ctx, q, arg).Scan(
+		&e.ID, &login, &e.Secret, &scope, &attrsJSON, &e.Disabled, &e.CreatedAt, &e.UpdatedAt, &e.SecretChangedAt,
+		&lastAuth, &e.FailedAuthCount, &lockedUntil, &e.TOTPEnabled,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
 		return Entity{}, ErrNotFound
 	} else if err != nil {
 		return Entity{}, err
 	}
 
-	scopeArg := make([]string, 0)
-	for _, s := range scope {
-		scopeArg = append(scopeArg, s)
+	if err := json.Unmarshal(attrsJSON, &e.Attrs); err != nil {
+		return Entity{}, fmt.Errorf("invalid attrs: %w", err)
+	}
+
+	e.Login = login.String
+	e.Scope = make(Scope, 0, len(scope))
+	e.Scope = append(e.Scope, scope...)
+	e.LastAuthenticatedAt = lastAuth.Time
+	e.LockedUntil = lockedUntil.Time
+
+	return e, nil
+}
+
+// DeleteEntity deletes an entity along with all its dependent records.
+func (a *DefaultAPI) DeleteEntity(ctx context.Context, id string) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return ErrInvalidArg{Msg: fmt.Sprintf("invalid id: %s", err.Error())}
+	}
+
+	r, err := a.db.ExecContext(ctx, `DELETE FROM entity WHERE id=$1`, id)
+	if err != nil {
+		return err
+	}
+
+	if ra, err := r.RowsAffected(); err != nil {
+		return err
+	} else if ra == 0 {
+		return ErrNotFound
+	}
+	a.revocations.removeEntity(id)
+
+	return nil
+}
+
+// DisableEntity disables an entity. A disabled entity cannot authenticate and all its tokens are rejected. The tokens
+// issued before disabling remain revoked after the entity is enabled again.
+func (a *DefaultAPI) DisableEntity(ctx context.Context, id string) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return ErrInvalidArg{Msg: fmt.Sprintf("invalid id: %s", err.Error())}
+	}
+
+	now := a.now()
+
+	q := `UPDATE entity SET disabled=true, tokens_revoked_at=$1, updated_at=$1 WHERE id=$2`
+	r, err := a.db.ExecContext(ctx, q, now, id)
+	if err != nil {
+		return err
+	}
+
+	if ra, err := r.RowsAffected(); err != nil {
+		return err
+	} else if ra == 0 {
+		return ErrNotFound
+	}
+	a.revocations.removeEntity(id)
+
+	q = `UPDATE refresh_token SET revoked_at=$1 WHERE entity_id=$2 AND revoked_at IS NULL`
+	if _, err = a.db.ExecContext(ctx, q, now, id); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// EnableEntity enables a previously disabled entity.
+func (a *DefaultAPI) EnableEntity(ctx context.Context, id string) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return ErrInvalidArg{Msg: fmt.Sprintf("invalid id: %s", err.Error())}
+	}
+
+	r, err := a.db.ExecContext(ctx, `UPDATE entity SET disabled=false, updated_at=$1 WHERE id=$2`, a.now(), id)
+	if err != nil {
+		return err
+	}
+
+	if ra, err := r.RowsAffected(); err != nil {
+		return err
+	} else if ra == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// VerifyCredentials returns the entity identified by its ID or login if secret matches its stored hash. Unknown
+// entities are checked against a dummy hash, so they cannot be told apart from wrong secrets by response time.
+// ErrInvalidCredentials is returned in both cases. ErrEntityDisabled is returned only if the secret is correct.
+// Failed attempts are counted per entity and per source of the request, e.g. an IP address, which may be empty.
+// ErrLocked is returned if the source is locked out according to the lockout policy. Entities which are locked out are
+// treated as unknown ones, so a caller cannot tell which entities exist and are under attack: ErrInvalidCredentials is
+// returned after checking the secret regardless of whether it matches.
+// A successful verification is recorded as the last authentication time of the entity and resets its failures counter
+// unless the entity has TOTP enabled and still has to pass VerifyTOTP.
+// Hashes produced by outdated algorithms or parameters are replaced with new ones at the same time.
+func (a *DefaultAPI) VerifyCredentials(ctx context.Context, idOrLogin, secret, source string) (Entity, error) {
+	var (
+		e   Entity
+		err error
+	)
+
+	if err = a.checkSourceLock(ctx, source); err != nil {
+		return Entity{}, err
+	}
+
+	if _, err = uuid.Parse(idOrLogin); err == nil {
+		e, err = a.GetEntity(ctx, idOrLogin)
+	} else {
+		e, err = a.getEntity(ctx, `lower(login)=lower($1)`, idOrLogin)
+	}
+
+	if errors.Is(err, ErrNotFound) {
+		a.dummyHashOnce.Do(func() {
+			a.dummyHash, _ = a.hasher.Hash("dummy")
+		})
+		_, _ = a.hasher.Verify(a.dummyHash, secret)
+
+		if err = a.recordSourceFailure(ctx, source); err != nil {
+			return Entity{}, err
+		}
+
+		return Entity{}, ErrInvalidCredentials
+	} else if err != nil {
+		return Entity{}, err
+	}
+
+	ok, err := a.hasher.Verify(e.Secret, secret)
+	if err != nil {
+		return Entity{}, fmt.Errorf("verify secret: %w", err)
+	}
+
+	if e.LockedUntil.After(a.now()) {
+		if err = a.recordSourceFailure(ctx, source); err != nil {
+			return Entity{}, err
+		}
+
+		return Entity{}, ErrInvalidCredentials
+	}
+
+	if !ok {
+		if err = a.recordEntityFailure(ctx, e.ID); err != nil {
+			return Entity{}, err
+		}
+		if err = a.recordSourceFailure(ctx, source); err != nil {
+			return Entity{}, err
+		}
+
+		return Entity{}, ErrInvalidCredentials
+	}
+
+	if e.Disabled {
+		return Entity{}, ErrEntityDisabled
+	}
+
+	e.LastAuthenticatedAt = a.now()
+
+	q := `UPDATE entity SET last_authenticated_at=$1`
+
+	// Failures of entities having TOTP enabled are reset by VerifyTOTP, otherwise guessing codes would never lead to
+	// a lockout
+	if !e.TOTPEnabled {
+		e.FailedAuthCount = 0
+		e.LockedUntil = time.Time{}
+		q += `, failed_auth_count=0, failed_auth_reset_at=NULL, locked_until=NULL`
+	}
+
+	qArgs := []interface{}{e.LastAuthenticatedAt, e.ID}
+	if a.hasher.NeedsRehash(e.Secret) {
+		if e.Secret, err = a.hasher.Hash(secret); err != nil {
+			return Entity{}, fmt.Errorf("rehash secret: %w", err)
+		}
+
+		q += `, secret=$2 WHERE id=$3`
+		qArgs = []interface{}{e.LastAuthenticatedAt, []byte(e.Secret), e.ID}
+	} else {
+		q += ` WHERE id=$2`
+	}
+
+	if _, err = a.db.ExecContext(ctx, q, qArgs...); err != nil {
+		return Entity{}, err
 	}
 
-	return Entity{ID: id, Secret: secret, Scope: scopeArg, Attrs: attrs}, nil
+	return e, nil
 }
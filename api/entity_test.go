@@ -2,10 +2,12 @@ package api_test
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"testing"
 	"time"
 
+	"github.com/go-webauthn/webauthn/webauthn"
 	"github.com/lib/pq"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
@@ -17,51 +19,90 @@ import (
 type EntityTestSuite struct {
 	suite.Suite
 
-	db  *sqldb.DBMock
-	api *api.DefaultAPI
+	db     *sqldb.DBMock
+	hasher *api.HasherMock
+	api    *api.DefaultAPI
 }
 
 func (s *EntityTestSuite) SetupTest() {
+	wa, err := webauthn.New(&webauthn.Config{
+		RPID:          "example.com",
+		RPDisplayName: "Example",
+		RPOrigins:     []string{"https://example.com"},
+	})
+	s.Require().NoError(err)
+
 	s.db = &sqldb.DBMock{}
-	s.api = api.NewDefault(s.db, "abc", time.Now)
+	s.hasher = &api.HasherMock{}
+	s.api = api.NewDefault(s.db, api.Options{
+		Key:    api.NewHMACKey([]byte("abc")),
+		Hasher: s.hasher,
+		Policy: api.PasswordPolicy{MinLength: 8, DisallowLogin: true},
+		Lockout: api.LockoutPolicy{
+			EntityThreshold: 3,
+			SourceThreshold: 10,
+			Duration:        time.Minute,
+			MaxDuration:     time.Hour,
+			Window:          15 * time.Minute,
+		},
+		WebAuthn:    wa,
+		Issuer:      "theIssuer",
+		Audience:    "theAudience",
+		MaxTokenTTL: 24 * time.Hour,
+		Now:         func() time.Time { return time.Unix(123456789, 0) },
+	})
 }
 
 func (s *EntityTestSuite) TearDownTest() {
 	s.db.AssertExpectations(s.T())
+	s.hasher.AssertExpectations(s.T())
 }
 
 func (s *EntityTestSuite) TestCreateEntityEmptyID() {
-	err := s.api.CreateEntity(context.Background(), "", nil, nil, nil)
+	err := s.api.CreateEntity(context.Background(), "", "", "", nil, nil)
 
 	s.Require().EqualError(err, "invalid id: invalid UUID length: 0")
 	s.Assert().ErrorIs(err, api.ErrInvalidArg{})
 }
 
 func (s *EntityTestSuite) TestCreateEntityInvalidID() {
-	err := s.api.CreateEntity(context.Background(), "de2a6f34-5371-4409-89ec-62bfda13fcby", nil, nil, nil)
+	err := s.api.CreateEntity(context.Background(), "de2a6f34-5371-4409-89ec-62bfda13fcby", "", "", nil, nil)
 
 	s.Require().EqualError(err, "invalid id: invalid UUID format")
 	s.Assert().ErrorIs(err, api.ErrInvalidArg{})
 }
 
 func (s *EntityTestSuite) TestCreateEntityEmptySecret() {
-	err := s.api.CreateEntity(context.Background(), "de2a6f34-5371-4409-89ec-62bfda13fcb7", nil, nil, nil)
+	err := s.api.CreateEntity(context.Background(), "de2a6f34-5371-4409-89ec-62bfda13fcb7", "", "", nil, nil)
 
-	s.Require().EqualError(err, "invalid secretKey: crypto/bcrypt: hashedSecret too short to be a bcrypted password")
+	s.Require().EqualError(err, "empty secret")
 	s.Assert().ErrorIs(err, api.ErrInvalidArg{})
 }
 
-func (s *EntityTestSuite) TestCreateEntityInvalidSecret() {
-	err := s.api.CreateEntity(context.Background(), "de2a6f34-5371-4409-89ec-62bfda13fcb7", []byte("abc"), nil, nil)
+func (s *EntityTestSuite) TestCreateEntityWeakSecret() {
+	err := s.api.CreateEntity(context.Background(), "de2a6f34-5371-4409-89ec-62bfda13fcb7", "bob", "Bob1", nil, nil)
 
-	s.Require().EqualError(err, "invalid secretKey: crypto/bcrypt: hashedSecret too short to be a bcrypted password")
-	s.Assert().ErrorIs(err, api.ErrInvalidArg{})
+	s.Require().EqualError(err, "weak secret: contains_login, min_length")
+	s.Assert().Equal(api.ErrInvalidArg{
+		Msg:   "weak secret: contains_login, min_length",
+		Rules: []string{api.PasswordRuleContainsLogin, api.PasswordRuleMinLength},
+	}, err)
+}
+
+func (s *EntityTestSuite) TestCreateEntityHashError() {
+	s.hasher.On("Hash", "theSecret").Return("", errors.New("theHashError"))
+
+	err := s.api.CreateEntity(context.Background(), "de2a6f34-5371-4409-89ec-62bfda13fcb7", "", "theSecret", nil, nil)
+
+	s.Require().EqualError(err, "hash secret: theHashError")
 }
 
 func (s *EntityTestSuite) TestCreateEntityDbExecError() {
+	s.hasher.On("Hash", "theSecret").Return("theSecretHash", nil)
+
 	s.db.On(
 		"ExecContext",
-		mock.AnythingOfType("*context.emptyCtx"),
+		mock.Anything,
 		mock.AnythingOfType("string"),
 		mock.AnythingOfType("[]interface {}"),
 	).Return(&sqldb.ResultMock{}, errors.New("theExecContextError"))
@@ -69,7 +110,8 @@ func (s *EntityTestSuite) TestCreateEntityDbExecError() {
 	err := s.api.CreateEntity(
 		context.Background(),
 		"de2a6f34-5371-4409-89ec-62bfda13fcb7",
-		[]byte("$2a$12$5GiSCPaURd2vLHGm.HgtF.SJGGPjJyuXaiTFnKSCqVbRTJl75ZUvy"),
+		"",
+		"theSecret",
 		nil,
 		nil,
 	)
@@ -78,22 +120,28 @@ func (s *EntityTestSuite) TestCreateEntityDbExecError() {
 }
 
 func (s *EntityTestSuite) TestCreateEntityEmptyScope() {
+	s.hasher.On("Hash", "theSecret").Return("theSecretHash", nil)
+
 	s.db.On(
 		"ExecContext",
-		mock.AnythingOfType("*context.emptyCtx"),
-		"INSERT INTO entity (id, secretKey, scope, attrs) VALUES ($1, $2, $3, $4)",
+		mock.Anything,
+		"INSERT INTO entity (id, login, secret, scope, attrs, created_at, updated_at, secret_changed_at) "+
+			"VALUES ($1, $2, $3, $4, $5, $6, $6, $6)",
 		[]interface{}{
 			"de2a6f34-5371-4409-89ec-62bfda13fcb7",
-			[]byte("$2a$12$5GiSCPaURd2vLHGm.HgtF.SJGGPjJyuXaiTFnKSCqVbRTJl75ZUvy"),
+			sql.NullString{},
+			[]byte("theSecretHash"),
 			pq.StringArray{},
 			[]byte(`{"attrName":"attrValue"}`),
+			time.Unix(123456789, 0),
 		},
 	).Return(&sqldb.ResultMock{}, nil)
 
 	err := s.api.CreateEntity(
 		context.Background(),
 		"de2a6f34-5371-4409-89ec-62bfda13fcb7",
-		[]byte("$2a$12$5GiSCPaURd2vLHGm.HgtF.SJGGPjJyuXaiTFnKSCqVbRTJl75ZUvy"),
+		"",
+		"theSecret",
 		nil,
 		api.Attrs{"attrName": "attrValue"},
 	)
@@ -102,22 +150,28 @@ func (s *EntityTestSuite) TestCreateEntityEmptyScope() {
 }
 
 func (s *EntityTestSuite) TestCreateEntityEmptyAttrs() {
+	s.hasher.On("Hash", "theSecret").Return("theSecretHash", nil)
+
 	s.db.On(
 		"ExecContext",
-		mock.AnythingOfType("*context.emptyCtx"),
-		"INSERT INTO entity (id, secretKey, scope, attrs) VALUES ($1, $2, $3, $4)",
+		mock.Anything,
+		"INSERT INTO entity (id, login, secret, scope, attrs, created_at, updated_at, secret_changed_at) "+
+			"VALUES ($1, $2, $3, $4, $5, $6, $6, $6)",
 		[]interface{}{
 			"de2a6f34-5371-4409-89ec-62bfda13fcb7",
-			[]byte("$2a$12$5GiSCPaURd2vLHGm.HgtF.SJGGPjJyuXaiTFnKSCqVbRTJl75ZUvy"),
+			sql.NullString{},
+			[]byte("theSecretHash"),
 			pq.StringArray{"aScope"},
 			[]byte("{}"),
+			time.Unix(123456789, 0),
 		},
 	).Return(&sqldb.ResultMock{}, nil)
 
 	err := s.api.CreateEntity(
 		context.Background(),
 		"de2a6f34-5371-4409-89ec-62bfda13fcb7",
-		[]byte("$2a$12$5GiSCPaURd2vLHGm.HgtF.SJGGPjJyuXaiTFnKSCqVbRTJl75ZUvy"),
+		"",
+		"theSecret",
 		api.Scope{"aScope"},
 		nil,
 	)
@@ -126,22 +180,28 @@ func (s *EntityTestSuite) TestCreateEntityEmptyAttrs() {
 }
 
 func (s *EntityTestSuite) TestCreateEntityEmptyScopeAndAttrs() {
+	s.hasher.On("Hash", "theSecret").Return("theSecretHash", nil)
+
 	s.db.On(
 		"ExecContext",
-		mock.AnythingOfType("*context.emptyCtx"),
-		"INSERT INTO entity (id, secretKey, scope, attrs) VALUES ($1, $2, $3, $4)",
+		mock.Anything,
+		"INSERT INTO entity (id, login, secret, scope, attrs, created_at, updated_at, secret_changed_at) "+
+			"VALUES ($1, $2, $3, $4, $5, $6, $6, $6)",
 		[]interface{}{
 			"de2a6f34-5371-4409-89ec-62bfda13fcb7",
-			[]byte("$2a$12$5GiSCPaURd2vLHGm.HgtF.SJGGPjJyuXaiTFnKSCqVbRTJl75ZUvy"),
+			sql.NullString{},
+			[]byte("theSecretHash"),
 			pq.StringArray{},
 			[]byte("{}"),
+			time.Unix(123456789, 0),
 		},
 	).Return(&sqldb.ResultMock{}, nil)
 
 	err := s.api.CreateEntity(
 		context.Background(),
 		"de2a6f34-5371-4409-89ec-62bfda13fcb7",
-		[]byte("$2a$12$5GiSCPaURd2vLHGm.HgtF.SJGGPjJyuXaiTFnKSCqVbRTJl75ZUvy"),
+		"",
+		"theSecret",
 		nil,
 		nil,
 	)
@@ -150,22 +210,28 @@ func (s *EntityTestSuite) TestCreateEntityEmptyScopeAndAttrs() {
 }
 
 func (s *EntityTestSuite) TestCreateEntityOk() {
+	s.hasher.On("Hash", "theSecret").Return("theSecretHash", nil)
+
 	s.db.On(
 		"ExecContext",
-		mock.AnythingOfType("*context.emptyCtx"),
-		"INSERT INTO entity (id, secretKey, scope, attrs) VALUES ($1, $2, $3, $4)",
+		mock.Anything,
+		"INSERT INTO entity (id, login, secret, scope, attrs, created_at, updated_at, secret_changed_at) "+
+			"VALUES ($1, $2, $3, $4, $5, $6, $6, $6)",
 		[]interface{}{
 			"de2a6f34-5371-4409-89ec-62bfda13fcb7",
-			[]byte("$2a$12$5GiSCPaURd2vLHGm.HgtF.SJGGPjJyuXaiTFnKSCqVbRTJl75ZUvy"),
+			sql.NullString{},
+			[]byte("theSecretHash"),
 			pq.StringArray{"theScope"},
 			[]byte(`{"theAttrName":"theAttrValue"}`),
+			time.Unix(123456789, 0),
 		},
 	).Return(&sqldb.ResultMock{}, nil)
 
 	err := s.api.CreateEntity(
 		context.Background(),
 		"de2a6f34-5371-4409-89ec-62bfda13fcb7",
-		[]byte("$2a$12$5GiSCPaURd2vLHGm.HgtF.SJGGPjJyuXaiTFnKSCqVbRTJl75ZUvy"),
+		"",
+		"theSecret",
 		api.Scope{"theScope"},
 		api.Attrs{"theAttrName": "theAttrValue"},
 	)
@@ -173,27 +239,68 @@ func (s *EntityTestSuite) TestCreateEntityOk() {
 	s.Require().NoError(err)
 }
 
+// expectStoredLogin expects the stored login of the entity to be read to check a new secret against it.
+func (s *EntityTestSuite) expectStoredLogin(login string, err error) {
+	row := &sqldb.RowMock{}
+	row.On("Scan", mock.Anything).Run(func(args mock.Arguments) {
+		*args.Get(0).(*sql.NullString) = sql.NullString{String: login, Valid: login != ""}
+	}).Return(err)
+
+	s.db.
+		On("QueryRowContext", mock.Anything, "SELECT login FROM entity WHERE id=$1",
+			[]interface{}{"de2a6f34-5371-4409-89ec-62bfda13fcb7"}).
+		Return(row)
+}
+
 func (s *EntityTestSuite) TestUpdateEntityEmptyID() {
-	err := s.api.UpdateEntity(context.Background(), "", nil, nil, nil)
+	err := s.api.UpdateEntity(context.Background(), "", "", "", nil, nil)
 
 	s.Require().EqualError(err, "invalid id: invalid UUID length: 0")
 	s.Assert().ErrorIs(err, api.ErrInvalidArg{})
 }
 
 func (s *EntityTestSuite) TestUpdateEntityInvalidID() {
-	err := s.api.UpdateEntity(context.Background(), "de2a6f34-5371-4409-89ec-62bfda13fcby", nil, nil, nil)
+	err := s.api.UpdateEntity(context.Background(), "de2a6f34-5371-4409-89ec-62bfda13fcby", "", "", nil, nil)
 
 	s.Require().EqualError(err, "invalid id: invalid UUID format")
 	s.Assert().ErrorIs(err, api.ErrInvalidArg{})
 }
 
-func (s *EntityTestSuite) TestUpdateEntityInvalidSecret() {
-	err := s.api.UpdateEntity(context.Background(), "de2a6f34-5371-4409-89ec-62bfda13fcb7", []byte("abc"), nil, nil)
+func (s *EntityTestSuite) TestUpdateEntityWeakSecret() {
+	s.expectStoredLogin("", nil)
+
+	err := s.api.UpdateEntity(context.Background(), "de2a6f34-5371-4409-89ec-62bfda13fcb7", "", "short", nil, nil)
+
+	s.Require().EqualError(err, "weak secret: min_length")
+	s.Assert().ErrorIs(err, api.ErrInvalidArg{})
+}
+
+func (s *EntityTestSuite) TestUpdateEntitySecretContainsStoredLogin() {
+	s.expectStoredLogin("alice", nil)
+
+	err := s.api.UpdateEntity(context.Background(), "de2a6f34-5371-4409-89ec-62bfda13fcb7", "", "alice1234", nil, nil)
 
-	s.Require().EqualError(err, "invalid secretKey: crypto/bcrypt: hashedSecret too short to be a bcrypted password")
+	s.Require().EqualError(err, "weak secret: contains_login")
 	s.Assert().ErrorIs(err, api.ErrInvalidArg{})
 }
 
+func (s *EntityTestSuite) TestUpdateEntitySecretNotFound() {
+	s.expectStoredLogin("", sql.ErrNoRows)
+
+	err := s.api.UpdateEntity(context.Background(), "de2a6f34-5371-4409-89ec-62bfda13fcb7", "", "theSecret", nil, nil)
+
+	s.Require().ErrorIs(err, api.ErrNotFound)
+}
+
+func (s *EntityTestSuite) TestUpdateEntityHashError() {
+	s.expectStoredLogin("", nil)
+	s.hasher.On("Hash", "theSecret").Return("", errors.New("theHashError"))
+
+	err := s.api.UpdateEntity(context.Background(), "de2a6f34-5371-4409-89ec-62bfda13fcb7", "", "theSecret", nil, nil)
+
+	s.Require().EqualError(err, "hash secret: theHashError")
+}
+
 func (s *EntityTestSuite) TestUpdateEntityRowsAffectedError() {
 	res := &sqldb.ResultMock{}
 
@@ -201,7 +308,7 @@ func (s *EntityTestSuite) TestUpdateEntityRowsAffectedError() {
 
 	s.db.On(
 		"ExecContext",
-		mock.AnythingOfType("*context.emptyCtx"),
+		mock.Anything,
 		mock.AnythingOfType("string"),
 		mock.AnythingOfType("[]interface {}"),
 	).Return(res, nil)
@@ -209,7 +316,8 @@ func (s *EntityTestSuite) TestUpdateEntityRowsAffectedError() {
 	err := s.api.UpdateEntity(
 		context.Background(),
 		"de2a6f34-5371-4409-89ec-62bfda13fcb7",
-		nil,
+		"",
+		"",
 		nil,
 		nil,
 	)
@@ -224,7 +332,7 @@ func (s *EntityTestSuite) TestUpdateEntityNotFoundEntity() {
 
 	s.db.On(
 		"ExecContext",
-		mock.AnythingOfType("*context.emptyCtx"),
+		mock.Anything,
 		mock.AnythingOfType("string"),
 		mock.AnythingOfType("[]interface {}"),
 	).Return(res, nil)
@@ -232,7 +340,8 @@ func (s *EntityTestSuite) TestUpdateEntityNotFoundEntity() {
 	err := s.api.UpdateEntity(
 		context.Background(),
 		"de2a6f34-5371-4409-89ec-62bfda13fcb7",
-		nil,
+		"",
+		"",
 		nil,
 		nil,
 	)
@@ -247,11 +356,12 @@ func (s *EntityTestSuite) TestUpdateEntityEmptySecret() {
 
 	s.db.On(
 		"ExecContext",
-		mock.AnythingOfType("*context.emptyCtx"),
-		"UPDATE entity SET scope=$1, attrs=$2 WHERE id=$3",
+		mock.Anything,
+		"UPDATE entity SET scope=$1, attrs=$2, updated_at=$3 WHERE id=$4",
 		[]interface{}{
 			pq.StringArray{},
 			[]byte("{}"),
+			time.Unix(123456789, 0),
 			"de2a6f34-5371-4409-89ec-62bfda13fcb7",
 		},
 	).Return(res, nil)
@@ -259,7 +369,8 @@ func (s *EntityTestSuite) TestUpdateEntityEmptySecret() {
 	err := s.api.UpdateEntity(
 		context.Background(),
 		"de2a6f34-5371-4409-89ec-62bfda13fcb7",
-		nil,
+		"",
+		"",
 		nil,
 		nil,
 	)
@@ -268,18 +379,22 @@ func (s *EntityTestSuite) TestUpdateEntityEmptySecret() {
 }
 
 func (s *EntityTestSuite) TestUpdateEntityNonEmptySecret() {
+	s.expectStoredLogin("", nil)
+	s.hasher.On("Hash", "theSecret").Return("theSecretHash", nil)
+
 	res := &sqldb.ResultMock{}
 
 	res.On("RowsAffected").Return(int64(1), nil)
 
 	s.db.On(
 		"ExecContext",
-		mock.AnythingOfType("*context.emptyCtx"),
-		"UPDATE entity SET secretKey=$1, scope=$2, attrs=$3 WHERE id=$4",
+		mock.Anything,
+		"UPDATE entity SET scope=$1, attrs=$2, updated_at=$3, secret=$4, secret_changed_at=$3 WHERE id=$5",
 		[]interface{}{
-			[]byte("$2a$12$5GiSCPaURd2vLHGm.HgtF.SJGGPjJyuXaiTFnKSCqVbRTJl75ZUvy"),
 			pq.StringArray{},
 			[]byte("{}"),
+			time.Unix(123456789, 0),
+			[]byte("theSecretHash"),
 			"de2a6f34-5371-4409-89ec-62bfda13fcb7",
 		},
 	).Return(res, nil)
@@ -287,7 +402,8 @@ func (s *EntityTestSuite) TestUpdateEntityNonEmptySecret() {
 	err := s.api.UpdateEntity(
 		context.Background(),
 		"de2a6f34-5371-4409-89ec-62bfda13fcb7",
-		[]byte("$2a$12$5GiSCPaURd2vLHGm.HgtF.SJGGPjJyuXaiTFnKSCqVbRTJl75ZUvy"),
+		"",
+		"theSecret",
 		nil,
 		nil,
 	)
@@ -296,18 +412,22 @@ func (s *EntityTestSuite) TestUpdateEntityNonEmptySecret() {
 }
 
 func (s *EntityTestSuite) TestUpdateEntityNonEmptyScope() {
+	s.expectStoredLogin("", nil)
+	s.hasher.On("Hash", "theSecret").Return("theSecretHash", nil)
+
 	res := &sqldb.ResultMock{}
 
 	res.On("RowsAffected").Return(int64(1), nil)
 
 	s.db.On(
 		"ExecContext",
-		mock.AnythingOfType("*context.emptyCtx"),
-		"UPDATE entity SET secretKey=$1, scope=$2, attrs=$3 WHERE id=$4",
+		mock.Anything,
+		"UPDATE entity SET scope=$1, attrs=$2, updated_at=$3, secret=$4, secret_changed_at=$3 WHERE id=$5",
 		[]interface{}{
-			[]byte("$2a$12$5GiSCPaURd2vLHGm.HgtF.SJGGPjJyuXaiTFnKSCqVbRTJl75ZUvy"),
 			pq.StringArray{"theScope"},
 			[]byte("{}"),
+			time.Unix(123456789, 0),
+			[]byte("theSecretHash"),
 			"de2a6f34-5371-4409-89ec-62bfda13fcb7",
 		},
 	).Return(res, nil)
@@ -315,7 +435,8 @@ func (s *EntityTestSuite) TestUpdateEntityNonEmptyScope() {
 	err := s.api.UpdateEntity(
 		context.Background(),
 		"de2a6f34-5371-4409-89ec-62bfda13fcb7",
-		[]byte("$2a$12$5GiSCPaURd2vLHGm.HgtF.SJGGPjJyuXaiTFnKSCqVbRTJl75ZUvy"),
+		"",
+		"theSecret",
 		api.Scope{"theScope"},
 		nil,
 	)
@@ -324,18 +445,22 @@ func (s *EntityTestSuite) TestUpdateEntityNonEmptyScope() {
 }
 
 func (s *EntityTestSuite) TestUpdateEntityNonEmptyAttrs() {
+	s.expectStoredLogin("", nil)
+	s.hasher.On("Hash", "theSecret").Return("theSecretHash", nil)
+
 	res := &sqldb.ResultMock{}
 
 	res.On("RowsAffected").Return(int64(1), nil)
 
 	s.db.On(
 		"ExecContext",
-		mock.AnythingOfType("*context.emptyCtx"),
-		"UPDATE entity SET secretKey=$1, scope=$2, attrs=$3 WHERE id=$4",
+		mock.Anything,
+		"UPDATE entity SET scope=$1, attrs=$2, updated_at=$3, secret=$4, secret_changed_at=$3 WHERE id=$5",
 		[]interface{}{
-			[]byte("$2a$12$5GiSCPaURd2vLHGm.HgtF.SJGGPjJyuXaiTFnKSCqVbRTJl75ZUvy"),
 			pq.StringArray{"theScope"},
 			[]byte(`{"theAttrName":"theAttrValue"}`),
+			time.Unix(123456789, 0),
+			[]byte("theSecretHash"),
 			"de2a6f34-5371-4409-89ec-62bfda13fcb7",
 		},
 	).Return(res, nil)
@@ -343,7 +468,8 @@ func (s *EntityTestSuite) TestUpdateEntityNonEmptyAttrs() {
 	err := s.api.UpdateEntity(
 		context.Background(),
 		"de2a6f34-5371-4409-89ec-62bfda13fcb7",
-		[]byte("$2a$12$5GiSCPaURd2vLHGm.HgtF.SJGGPjJyuXaiTFnKSCqVbRTJl75ZUvy"),
+		"",
+		"theSecret",
 		api.Scope{"theScope"},
 		api.Attrs{"theAttrName": "theAttrValue"},
 	)
@@ -351,6 +477,454 @@ func (s *EntityTestSuite) TestUpdateEntityNonEmptyAttrs() {
 	s.Require().NoError(err)
 }
 
+func (s *EntityTestSuite) expectGetEntity(secret string, disabled bool, err error) *mock.Call {
+	return s.expectEntityQuery("id=$1", secret, disabled, time.Time{}, err)
+}
+
+func (s *EntityTestSuite) expectEntityQuery(
+	cond, secret string,
+	disabled bool,
+	lockedUntil time.Time,
+	err error,
+) *mock.Call {
+	row := &sqldb.RowMock{}
+	row.On("Scan", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything,
+		mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			*args.Get(0).(*string) = "2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d"
+			*args.Get(2).(*string) = secret
+			*args.Get(4).(*[]byte) = []byte("{}")
+			*args.Get(5).(*bool) = disabled
+			*args.Get(11).(*sql.NullTime) = sql.NullTime{Time: lockedUntil, Valid: !lockedUntil.IsZero()}
+		}).
+		Return(err)
+
+	q := "SELECT id, login, secret, scope, attrs, disabled, created_at, updated_at, secret_changed_at, " +
+		"last_authenticated_at, failed_auth_count, locked_until, totp_secret IS NOT NULL FROM entity WHERE " + cond
+	return s.db.On("QueryRowContext", mock.Anything, q, mock.Anything).Return(row)
+}
+
+func (s *EntityTestSuite) TestVerifyCredentialsNotFound() {
+	s.expectGetEntity("", false, sql.ErrNoRows)
+	s.hasher.On("Hash", "dummy").Return("theDummyHash", nil)
+	s.hasher.On("Verify", "theDummyHash", "theSecret").Return(false, nil)
+
+	_, err := s.api.VerifyCredentials(context.Background(), "2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d", "theSecret", "")
+	s.Require().ErrorIs(err, api.ErrInvalidCredentials)
+}
+
+func (s *EntityTestSuite) TestVerifyCredentialsLoginNotFound() {
+	s.expectEntityQuery("lower(login)=lower($1)", "", false, time.Time{}, sql.ErrNoRows)
+	s.hasher.On("Hash", "dummy").Return("theDummyHash", nil)
+	s.hasher.On("Verify", "theDummyHash", "theSecret").Return(false, nil)
+
+	_, err := s.api.VerifyCredentials(context.Background(), "theLogin", "theSecret", "")
+	s.Require().ErrorIs(err, api.ErrInvalidCredentials)
+}
+
+func (s *EntityTestSuite) TestVerifyCredentialsByLogin() {
+	s.expectEntityQuery("lower(login)=lower($1)", "theSecretHash", false, time.Time{}, nil)
+	s.hasher.On("Verify", "theSecretHash", "theSecret").Return(true, nil)
+	s.hasher.On("NeedsRehash", "theSecretHash").Return(false)
+
+	s.db.
+		On("ExecContext", mock.Anything, "UPDATE entity SET last_authenticated_at=$1, failed_auth_count=0, "+
+			"failed_auth_reset_at=NULL, locked_until=NULL WHERE id=$2",
+			[]interface{}{time.Unix(123456789, 0), "2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d"}).
+		Return(&sqldb.ResultMock{}, nil)
+
+	e, err := s.api.VerifyCredentials(context.Background(), "TheLogin", "theSecret", "")
+	s.Require().NoError(err)
+	s.Assert().Equal("2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d", e.ID)
+}
+
+func (s *EntityTestSuite) TestCreateEntityInvalidLogin() {
+	for _, login := range []string{"the:login", "the login", "2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d"} {
+		err := s.api.CreateEntity(context.Background(), "de2a6f34-5371-4409-89ec-62bfda13fcb7", login, "", nil, nil)
+		s.Assert().ErrorIs(err, api.ErrInvalidArg{}, login)
+	}
+}
+
+func (s *EntityTestSuite) TestCreateEntityLoginExists() {
+	s.hasher.On("Hash", "theSecret").Return("theSecretHash", nil)
+
+	s.db.
+		On("ExecContext", mock.Anything, mock.AnythingOfType("string"), mock.Anything).
+		Return(&sqldb.ResultMock{}, &pq.Error{Code: "23505"})
+
+	err := s.api.CreateEntity(
+		context.Background(),
+		"de2a6f34-5371-4409-89ec-62bfda13fcb7",
+		"theLogin",
+		"theSecret",
+		nil,
+		nil,
+	)
+	s.Require().ErrorIs(err, api.ErrAlreadyExists)
+}
+
+func (s *EntityTestSuite) TestUpdateEntityLogin() {
+	res := &sqldb.ResultMock{}
+	res.On("RowsAffected").Return(int64(1), nil)
+
+	s.db.On(
+		"ExecContext",
+		mock.Anything,
+		"UPDATE entity SET scope=$1, attrs=$2, updated_at=$3, login=$4 WHERE id=$5",
+		[]interface{}{
+			pq.StringArray{},
+			[]byte("{}"),
+			time.Unix(123456789, 0),
+			"theLogin",
+			"de2a6f34-5371-4409-89ec-62bfda13fcb7",
+		},
+	).Return(res, nil)
+
+	err := s.api.UpdateEntity(context.Background(), "de2a6f34-5371-4409-89ec-62bfda13fcb7", "theLogin", "", nil, nil)
+	s.Require().NoError(err)
+}
+
+func (s *EntityTestSuite) TestVerifyCredentialsDbError() {
+	s.expectGetEntity("", false, errors.New("theDbError"))
+
+	_, err := s.api.VerifyCredentials(context.Background(), "2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d", "theSecret", "")
+	s.Require().EqualError(err, "theDbError")
+}
+
+func (s *EntityTestSuite) TestVerifyCredentialsWrongSecret() {
+	s.expectGetEntity("theSecretHash", false, nil)
+	s.hasher.On("Verify", "theSecretHash", "wrongSecret").Return(false, nil)
+	s.expectEntityFailure(1, nil)
+
+	_, err := s.api.VerifyCredentials(context.Background(), "2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d", "wrongSecret", "")
+	s.Require().ErrorIs(err, api.ErrInvalidCredentials)
+}
+
+func (s *EntityTestSuite) TestVerifyCredentialsVerifyError() {
+	s.expectGetEntity("theSecretHash", false, nil)
+	s.hasher.On("Verify", "theSecretHash", "theSecret").Return(false, api.ErrUnsupportedHash)
+
+	_, err := s.api.VerifyCredentials(context.Background(), "2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d", "theSecret", "")
+	s.Require().EqualError(err, "verify secret: unsupported hash")
+}
+
+func (s *EntityTestSuite) TestVerifyCredentialsOK() {
+	s.expectGetEntity("theSecretHash", false, nil)
+	s.hasher.On("Verify", "theSecretHash", "theSecret").Return(true, nil)
+	s.hasher.On("NeedsRehash", "theSecretHash").Return(false)
+
+	s.db.
+		On("ExecContext", mock.Anything, "UPDATE entity SET last_authenticated_at=$1, failed_auth_count=0, "+
+			"failed_auth_reset_at=NULL, locked_until=NULL WHERE id=$2",
+			[]interface{}{time.Unix(123456789, 0), "2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d"}).
+		Return(&sqldb.ResultMock{}, nil)
+
+	e, err := s.api.VerifyCredentials(context.Background(), "2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d", "theSecret", "")
+	s.Require().NoError(err)
+	s.Assert().Equal("2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d", e.ID)
+	s.Assert().Equal("theSecretHash", e.Secret)
+	s.Assert().Equal(time.Unix(123456789, 0), e.LastAuthenticatedAt)
+}
+
+func (s *EntityTestSuite) TestVerifyCredentialsRehash() {
+	s.expectGetEntity("theOldHash", false, nil)
+	s.hasher.On("Verify", "theOldHash", "theSecret").Return(true, nil)
+	s.hasher.On("NeedsRehash", "theOldHash").Return(true)
+	s.hasher.On("Hash", "theSecret").Return("theNewHash", nil)
+
+	s.db.
+		On("ExecContext", mock.Anything, "UPDATE entity SET last_authenticated_at=$1, failed_auth_count=0, "+
+			"failed_auth_reset_at=NULL, locked_until=NULL, secret=$2 WHERE id=$3",
+			[]interface{}{time.Unix(123456789, 0), []byte("theNewHash"), "2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d"}).
+		Return(&sqldb.ResultMock{}, nil)
+
+	e, err := s.api.VerifyCredentials(context.Background(), "2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d", "theSecret", "")
+	s.Require().NoError(err)
+	s.Assert().Equal("theNewHash", e.Secret)
+}
+
+func (s *EntityTestSuite) TestVerifyCredentialsDisabled() {
+	s.expectGetEntity("theSecretHash", true, nil)
+	s.hasher.On("Verify", "theSecretHash", "theSecret").Return(true, nil)
+
+	_, err := s.api.VerifyCredentials(context.Background(), "2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d", "theSecret", "")
+	s.Require().ErrorIs(err, api.ErrEntityDisabled)
+}
+
+func (s *EntityTestSuite) TestVerifyCredentialsDisabledWrongSecret() {
+	s.expectGetEntity("theSecretHash", true, nil)
+	s.hasher.On("Verify", "theSecretHash", "wrongSecret").Return(false, nil)
+	s.expectEntityFailure(1, nil)
+
+	_, err := s.api.VerifyCredentials(context.Background(), "2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d", "wrongSecret", "")
+	s.Require().ErrorIs(err, api.ErrInvalidCredentials)
+}
+
+func (s *EntityTestSuite) TestDeleteEntityInvalidID() {
+	err := s.api.DeleteEntity(context.Background(), "notAUUID")
+	s.Require().ErrorIs(err, api.ErrInvalidArg{})
+}
+
+func (s *EntityTestSuite) TestDeleteEntityNotFound() {
+	res := &sqldb.ResultMock{}
+	res.On("RowsAffected").Return(int64(0), nil)
+
+	s.db.
+		On("ExecContext", mock.Anything, "DELETE FROM entity WHERE id=$1",
+			[]interface{}{"de2a6f34-5371-4409-89ec-62bfda13fcb7"}).
+		Return(res, nil)
+
+	err := s.api.DeleteEntity(context.Background(), "de2a6f34-5371-4409-89ec-62bfda13fcb7")
+	s.Require().ErrorIs(err, api.ErrNotFound)
+}
+
+func (s *EntityTestSuite) TestDeleteEntityOk() {
+	res := &sqldb.ResultMock{}
+	res.On("RowsAffected").Return(int64(1), nil)
+
+	s.db.
+		On("ExecContext", mock.Anything, "DELETE FROM entity WHERE id=$1",
+			[]interface{}{"de2a6f34-5371-4409-89ec-62bfda13fcb7"}).
+		Return(res, nil)
+
+	s.Require().NoError(s.api.DeleteEntity(context.Background(), "de2a6f34-5371-4409-89ec-62bfda13fcb7"))
+}
+
+func (s *EntityTestSuite) TestDisableEntityNotFound() {
+	res := &sqldb.ResultMock{}
+	res.On("RowsAffected").Return(int64(0), nil)
+
+	s.db.
+		On("ExecContext", mock.Anything,
+			"UPDATE entity SET disabled=true, tokens_revoked_at=$1, updated_at=$1 WHERE id=$2",
+			[]interface{}{time.Unix(123456789, 0), "de2a6f34-5371-4409-89ec-62bfda13fcb7"}).
+		Return(res, nil)
+
+	err := s.api.DisableEntity(context.Background(), "de2a6f34-5371-4409-89ec-62bfda13fcb7")
+	s.Require().ErrorIs(err, api.ErrNotFound)
+}
+
+func (s *EntityTestSuite) TestDisableEntityOk() {
+	res := &sqldb.ResultMock{}
+	res.On("RowsAffected").Return(int64(1), nil)
+
+	s.db.
+		On("ExecContext", mock.Anything,
+			"UPDATE entity SET disabled=true, tokens_revoked_at=$1, updated_at=$1 WHERE id=$2",
+			[]interface{}{time.Unix(123456789, 0), "de2a6f34-5371-4409-89ec-62bfda13fcb7"}).
+		Return(res, nil)
+
+	s.db.
+		On("ExecContext", mock.Anything,
+			"UPDATE refresh_token SET revoked_at=$1 WHERE entity_id=$2 AND revoked_at IS NULL",
+			[]interface{}{time.Unix(123456789, 0), "de2a6f34-5371-4409-89ec-62bfda13fcb7"}).
+		Return(&sqldb.ResultMock{}, nil)
+
+	s.Require().NoError(s.api.DisableEntity(context.Background(), "de2a6f34-5371-4409-89ec-62bfda13fcb7"))
+}
+
+func (s *EntityTestSuite) TestEnableEntityNotFound() {
+	res := &sqldb.ResultMock{}
+	res.On("RowsAffected").Return(int64(0), nil)
+
+	s.db.
+		On("ExecContext", mock.Anything, "UPDATE entity SET disabled=false, updated_at=$1 WHERE id=$2", mock.Anything).
+		Return(res, nil)
+
+	err := s.api.EnableEntity(context.Background(), "de2a6f34-5371-4409-89ec-62bfda13fcb7")
+	s.Require().ErrorIs(err, api.ErrNotFound)
+}
+
+func (s *EntityTestSuite) TestEnableEntityOk() {
+	res := &sqldb.ResultMock{}
+	res.On("RowsAffected").Return(int64(1), nil)
+
+	s.db.
+		On("ExecContext", mock.Anything, "UPDATE entity SET disabled=false, updated_at=$1 WHERE id=$2",
+			[]interface{}{time.Unix(123456789, 0), "de2a6f34-5371-4409-89ec-62bfda13fcb7"}).
+		Return(res, nil)
+
+	s.Require().NoError(s.api.EnableEntity(context.Background(), "de2a6f34-5371-4409-89ec-62bfda13fcb7"))
+}
+
+// expectEntityFailure expects a failed authentication attempt of the entity to be recorded.
+func (s *EntityTestSuite) expectEntityFailure(count int, err error) {
+	row := &sqldb.RowMock{}
+	row.On("Scan", mock.Anything).Run(func(args mock.Arguments) { *args.Get(0).(*int) = count }).Return(err)
+
+	s.db.
+		On("QueryRowContext", mock.Anything, "UPDATE entity SET "+
+			"failed_auth_count=CASE WHEN failed_auth_reset_at>$1 THEN failed_auth_count+1 ELSE 1 END, "+
+			"failed_auth_reset_at=$2 WHERE id=$3 RETURNING failed_auth_count",
+			[]interface{}{
+				time.Unix(123456789, 0),
+				time.Unix(123456789, 0).Add(15 * time.Minute),
+				"2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d",
+			}).
+		Return(row)
+}
+
+// expectSourceFailure expects a failed authentication attempt from the source to be recorded.
+func (s *EntityTestSuite) expectSourceFailure(source string, count int) {
+	row := &sqldb.RowMock{}
+	row.On("Scan", mock.Anything).Run(func(args mock.Arguments) { *args.Get(0).(*int) = count }).Return(nil)
+
+	s.db.
+		On("QueryRowContext", mock.Anything, "INSERT INTO auth_failure (source, count, reset_at) VALUES ($1, 1, $3) "+
+			"ON CONFLICT (source) DO UPDATE SET count=CASE WHEN auth_failure.reset_at>$2 THEN auth_failure.count+1 "+
+			"ELSE 1 END, reset_at=$3 RETURNING count",
+			[]interface{}{source, time.Unix(123456789, 0), time.Unix(123456789, 0).Add(15 * time.Minute)}).
+		Return(row)
+}
+
+// expectSourceLock expects the lockout state of the source to be checked.
+func (s *EntityTestSuite) expectSourceLock(source string, until time.Time) {
+	row := &sqldb.RowMock{}
+	row.On("Scan", mock.Anything).
+		Run(func(args mock.Arguments) {
+			*args.Get(0).(*sql.NullTime) = sql.NullTime{Time: until, Valid: !until.IsZero()}
+		}).
+		Return(nil)
+
+	s.db.
+		On("QueryRowContext", mock.Anything, "SELECT locked_until FROM auth_failure WHERE source=$1",
+			[]interface{}{source}).
+		Return(row)
+}
+
+func (s *EntityTestSuite) TestVerifyCredentialsSourceLocked() {
+	s.expectSourceLock("192.0.2.1", time.Unix(123456789, 0).Add(time.Minute))
+
+	_, err := s.api.VerifyCredentials(context.Background(), "2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d", "theSecret",
+		"192.0.2.1")
+	s.Require().Equal(api.ErrLocked{Until: time.Unix(123456789, 0).Add(time.Minute)}, err)
+}
+
+func (s *EntityTestSuite) TestVerifyCredentialsEntityLocked() {
+	// Locked out entities cannot be told apart from unknown ones even by the correct secret
+	s.expectSourceLock("192.0.2.1", time.Time{})
+	s.expectEntityQuery("id=$1", "theSecretHash", false, time.Unix(123456789, 0).Add(time.Minute), nil)
+	s.hasher.On("Verify", "theSecretHash", "theSecret").Return(true, nil)
+	s.expectSourceFailure("192.0.2.1", 1)
+
+	_, err := s.api.VerifyCredentials(context.Background(), "2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d", "theSecret",
+		"192.0.2.1")
+	s.Require().Equal(api.ErrInvalidCredentials, err)
+}
+
+func (s *EntityTestSuite) TestVerifyCredentialsLockExpired() {
+	s.expectEntityQuery("id=$1", "theSecretHash", false, time.Unix(123456789, 0), nil)
+	s.hasher.On("Verify", "theSecretHash", "wrongSecret").Return(false, nil)
+	s.expectEntityFailure(1, nil)
+
+	_, err := s.api.VerifyCredentials(context.Background(), "2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d", "wrongSecret", "")
+	s.Require().ErrorIs(err, api.ErrInvalidCredentials)
+}
+
+func (s *EntityTestSuite) TestVerifyCredentialsNotFoundSourceFailure() {
+	s.expectSourceLock("192.0.2.1", time.Time{})
+	s.expectGetEntity("", false, sql.ErrNoRows)
+	s.hasher.On("Hash", "dummy").Return("theDummyHash", nil)
+	s.hasher.On("Verify", "theDummyHash", "theSecret").Return(false, nil)
+	s.expectSourceFailure("192.0.2.1", 1)
+
+	_, err := s.api.VerifyCredentials(context.Background(), "2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d", "theSecret",
+		"192.0.2.1")
+	s.Require().ErrorIs(err, api.ErrInvalidCredentials)
+}
+
+func (s *EntityTestSuite) TestVerifyCredentialsLockOut() {
+	s.expectSourceLock("192.0.2.1", time.Time{})
+	s.expectGetEntity("theSecretHash", false, nil)
+	s.hasher.On("Verify", "theSecretHash", "wrongSecret").Return(false, nil)
+	s.expectEntityFailure(5, nil)
+	s.expectSourceFailure("192.0.2.1", 20)
+
+	// The entity threshold is exceeded twice, so it is locked out for 4 minutes
+	entityUntil := time.Unix(123456789, 0).Add(4 * time.Minute)
+	s.db.
+		On("ExecContext", mock.Anything, "UPDATE entity SET locked_until=$1, failed_auth_reset_at=$2 WHERE id=$3",
+			[]interface{}{entityUntil, entityUntil.Add(15 * time.Minute), "2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d"}).
+		Return(&sqldb.ResultMock{}, nil)
+
+	// The source threshold is exceeded more times than needed to reach the maximum duration
+	sourceUntil := time.Unix(123456789, 0).Add(time.Hour)
+	s.db.
+		On("ExecContext", mock.Anything, "UPDATE auth_failure SET locked_until=$1, reset_at=$2 WHERE source=$3",
+			[]interface{}{sourceUntil, sourceUntil.Add(15 * time.Minute), "192.0.2.1"}).
+		Return(&sqldb.ResultMock{}, nil)
+
+	_, err := s.api.VerifyCredentials(context.Background(), "2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d", "wrongSecret",
+		"192.0.2.1")
+	s.Require().ErrorIs(err, api.ErrInvalidCredentials)
+}
+
+func (s *EntityTestSuite) TestVerifyCredentialsFailureDbError() {
+	s.expectGetEntity("theSecretHash", false, nil)
+	s.hasher.On("Verify", "theSecretHash", "wrongSecret").Return(false, nil)
+	s.expectEntityFailure(0, errors.New("theDbError"))
+
+	_, err := s.api.VerifyCredentials(context.Background(), "2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d", "wrongSecret", "")
+	s.Require().EqualError(err, "theDbError")
+}
+
+func (s *EntityTestSuite) TestUnlockEntityInvalidID() {
+	s.Require().ErrorIs(s.api.UnlockEntity(context.Background(), "notAUUID"), api.ErrInvalidArg{})
+}
+
+func (s *EntityTestSuite) TestUnlockEntityNotFound() {
+	res := &sqldb.ResultMock{}
+	res.On("RowsAffected").Return(int64(0), nil)
+
+	s.db.
+		On("ExecContext", mock.Anything,
+			"UPDATE entity SET failed_auth_count=0, failed_auth_reset_at=NULL, locked_until=NULL WHERE id=$1",
+			[]interface{}{"de2a6f34-5371-4409-89ec-62bfda13fcb7"}).
+		Return(res, nil)
+
+	err := s.api.UnlockEntity(context.Background(), "de2a6f34-5371-4409-89ec-62bfda13fcb7")
+	s.Require().ErrorIs(err, api.ErrNotFound)
+}
+
+func (s *EntityTestSuite) TestUnlockEntityOk() {
+	res := &sqldb.ResultMock{}
+	res.On("RowsAffected").Return(int64(1), nil)
+
+	s.db.
+		On("ExecContext", mock.Anything,
+			"UPDATE entity SET failed_auth_count=0, failed_auth_reset_at=NULL, locked_until=NULL WHERE id=$1",
+			[]interface{}{"de2a6f34-5371-4409-89ec-62bfda13fcb7"}).
+		Return(res, nil)
+
+	s.Require().NoError(s.api.UnlockEntity(context.Background(), "de2a6f34-5371-4409-89ec-62bfda13fcb7"))
+}
+
+func (s *EntityTestSuite) TestUnlockSourceEmpty() {
+	s.Require().ErrorIs(s.api.UnlockSource(context.Background(), ""), api.ErrInvalidArg{})
+}
+
+func (s *EntityTestSuite) TestUnlockSourceNotFound() {
+	res := &sqldb.ResultMock{}
+	res.On("RowsAffected").Return(int64(0), nil)
+
+	s.db.
+		On("ExecContext", mock.Anything, "DELETE FROM auth_failure WHERE source=$1", []interface{}{"192.0.2.1"}).
+		Return(res, nil)
+
+	s.Require().ErrorIs(s.api.UnlockSource(context.Background(), "192.0.2.1"), api.ErrNotFound)
+}
+
+func (s *EntityTestSuite) TestUnlockSourceOk() {
+	res := &sqldb.ResultMock{}
+	res.On("RowsAffected").Return(int64(1), nil)
+
+	s.db.
+		On("ExecContext", mock.Anything, "DELETE FROM auth_failure WHERE source=$1", []interface{}{"192.0.2.1"}).
+		Return(res, nil)
+
+	s.Require().NoError(s.api.UnlockSource(context.Background(), "192.0.2.1"))
+}
+
 func TestDefaultAPI_Entity(t *testing.T) {
 	suite.Run(t, new(EntityTestSuite))
 }
@@ -0,0 +1,158 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	// DefaultListLimit is the number of entities returned by ListEntities if no limit is given
+	DefaultListLimit = 100
+	// MaxListLimit is the maximum number of entities returned by ListEntities at once
+	MaxListLimit = 1000
+)
+
+// EntityFilter narrows down the entities returned by ListEntities. Entities must have all the listed scopes and all
+// the listed attributes with exactly the same values. An empty filter matches all entities.
+type EntityFilter struct {
+	Scope Scope
+	Attrs Attrs
+}
+
+func encodeEntityCursor(e Entity) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(e.CreatedAt.UTC().Format(time.RFC3339Nano) + "/" + e.ID))
+}
+
+func decodeEntityCursor(cursor string) (time.Time, string, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, "", err
+	}
+
+	createdAt, id, ok := strings.Cut(string(b), "/")
+	if !ok {
+		return time.Time{}, "", fmt.Errorf("malformed cursor")
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, createdAt)
+	if err != nil {
+		return time.Time{}, "", err
+	}
+
+	if _, err = uuid.Parse(id); err != nil {
+		return time.Time{}, "", err
+	}
+
+	return t, id, nil
+}
+
+// ListEntities returns a page of entities matching filter ordered by creation time. An empty cursor requests the
+// first page. The returned cursor points to the next page and is empty if there are no more entities.
+func (a *DefaultAPI) ListEntities(
+	ctx context.Context,
+	filter EntityFilter,
+	cursor string,
+	limit int,
+) ([]Entity, string, error) {
+	if limit <= 0 {
+		limit = DefaultListLimit
+	} else if limit > MaxListLimit {
+		limit = MaxListLimit
+	}
+
+	var (
+		conds []string
+		args  []interface{}
+	)
+
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	if len(filter.Scope) != 0 {
+		conds = append(conds, "scope @> "+arg(pq.StringArray(filter.Scope))+"::varchar[]")
+	}
+
+	if len(filter.Attrs) != 0 {
+		attrsJSON, err := json.Marshal(filter.Attrs)
+		if err != nil {
+			return nil, "", ErrInvalidArg{Msg: fmt.Sprintf("invalid attrs: %s", err.Error())}
+		}
+		conds = append(conds, "attrs @> "+arg(attrsJSON)+"::jsonb")
+	}
+
+	if cursor != "" {
+		createdAt, id, err := decodeEntityCursor(cursor)
+		if err != nil {
+			return nil, "", ErrInvalidArg{Msg: fmt.Sprintf("invalid cursor: %s", err.Error())}
+		}
+		conds = append(conds, "(created_at, id) > ("+arg(createdAt)+", "+arg(id)+")")
+	}
+
+	q := `SELECT id, login, scope, attrs, disabled, created_at, updated_at, secret_changed_at, ` +
+		`last_authenticated_at, failed_auth_count, locked_until, totp_secret IS NOT NULL FROM entity`
+	if len(conds) != 0 {
+		q += ` WHERE ` + strings.Join(conds, " AND ")
+	}
+	q += ` ORDER BY created_at, id LIMIT ` + arg(limit+1)
+
+	rows, err := a.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	res := make([]Entity, 0)
+	for rows.Next() {
+		var (
+			e           Entity
+			scope       pq.StringArray
+			login       sql.NullString
+			attrsJSON   []byte
+			lastAuth    sql.NullTime
+			lockedUntil sql.NullTime
+		)
+
+		err = rows.Scan(
+			&e.ID, &login, &scope, &attrsJSON, &e.Disabled, &e.CreatedAt, &e.UpdatedAt, &e.SecretChangedAt, &lastAuth,
+			&e.FailedAuthCount, &lockedUntil, &e.TOTPEnabled,
+		)
+		if err != nil {
+			return nil, "", err
+		}
+
+		if err = json.Unmarshal(attrsJSON, &e.Attrs); err != nil {
+			return nil, "", fmt.Errorf("invalid attrs of entity %s: %w", e.ID, err)
+		}
+
+		e.Login = login.String
+		e.Scope = make(Scope, 0, len(scope))
+		e.Scope = append(e.Scope, scope...)
+		e.LastAuthenticatedAt = lastAuth.Time
+		e.LockedUntil = lockedUntil.Time
+
+		res = append(res, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	next := ""
+	if len(res) > limit {
+		res = res[:limit]
+		next = encodeEntityCursor(res[limit-1])
+	}
+
+	return res, next, nil
+}
@@ -0,0 +1,145 @@
+package api_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/ashep/a23n/api"
+	"github.com/ashep/a23n/sqldb"
+)
+
+type EntityListTestSuite struct {
+	suite.Suite
+
+	db  *sqldb.DBMock
+	api *api.DefaultAPI
+}
+
+func (s *EntityListTestSuite) SetupTest() {
+	s.db = &sqldb.DBMock{}
+	s.api = api.NewDefault(s.db, api.Options{
+		Key:      api.NewHMACKey([]byte("abc")),
+		Issuer:   "theIssuer",
+		Audience: "theAudience",
+	})
+}
+
+func (s *EntityListTestSuite) TearDownTest() {
+	s.db.AssertExpectations(s.T())
+}
+
+func (s *EntityListTestSuite) rows(ids ...string) *sqldb.RowsMock {
+	rows := &sqldb.RowsMock{}
+
+	for i, id := range ids {
+		id, i := id, i
+		rows.On("Next").Return(true).Once()
+		rows.On("Scan", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything,
+			mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+			Run(func(args mock.Arguments) {
+				*args.Get(0).(*string) = id
+				*args.Get(2).(*pq.StringArray) = pq.StringArray{"theScope"}
+				*args.Get(3).(*[]byte) = []byte(`{"theAttr":"theValue"}`)
+				*args.Get(5).(*time.Time) = time.Unix(int64(123456789+i), 0).UTC()
+			}).
+			Return(nil).Once()
+	}
+
+	rows.On("Next").Return(false).Once()
+	rows.On("Err").Return(nil)
+	rows.On("Close").Return(nil)
+
+	return rows
+}
+
+func (s *EntityListTestSuite) TestInvalidCursor() {
+	_, _, err := s.api.ListEntities(context.Background(), api.EntityFilter{}, "notACursor", 0)
+	s.Require().ErrorIs(err, api.ErrInvalidArg{})
+}
+
+func (s *EntityListTestSuite) TestDbError() {
+	s.db.
+		On("QueryContext", mock.Anything, mock.AnythingOfType("string"), mock.Anything).
+		Return(&sqldb.RowsMock{}, errors.New("theQueryError"))
+
+	_, _, err := s.api.ListEntities(context.Background(), api.EntityFilter{}, "", 0)
+	s.Require().EqualError(err, "theQueryError")
+}
+
+func (s *EntityListTestSuite) TestNoFilter() {
+	s.db.
+		On("QueryContext", mock.Anything,
+			"SELECT id, login, scope, attrs, disabled, created_at, updated_at, secret_changed_at, "+
+				"last_authenticated_at, failed_auth_count, locked_until, totp_secret IS NOT NULL FROM entity "+
+				"ORDER BY created_at, id LIMIT $1",
+			[]interface{}{api.DefaultListLimit + 1},
+		).
+		Return(s.rows("de2a6f34-5371-4409-89ec-62bfda13fcb7"), nil)
+
+	res, next, err := s.api.ListEntities(context.Background(), api.EntityFilter{}, "", 0)
+	s.Require().NoError(err)
+	s.Assert().Empty(next)
+	s.Assert().Equal([]api.Entity{{
+		ID:        "de2a6f34-5371-4409-89ec-62bfda13fcb7",
+		Scope:     api.Scope{"theScope"},
+		Attrs:     api.Attrs{"theAttr": "theValue"},
+		CreatedAt: time.Unix(123456789, 0).UTC(),
+	}}, res)
+}
+
+func (s *EntityListTestSuite) TestFilterAndPagination() {
+	s.db.
+		On("QueryContext", mock.Anything,
+			"SELECT id, login, scope, attrs, disabled, created_at, updated_at, secret_changed_at, "+
+				"last_authenticated_at, failed_auth_count, locked_until, totp_secret IS NOT NULL FROM entity "+
+				"WHERE scope @> $1::varchar[] AND attrs @> $2::jsonb ORDER BY created_at, id LIMIT $3",
+			[]interface{}{pq.StringArray{"theScope"}, []byte(`{"theAttr":"theValue"}`), 3},
+		).
+		Return(s.rows(
+			"de2a6f34-5371-4409-89ec-62bfda13fcb7",
+			"0b3b1f4e-3c8a-4d8f-9d0c-3f3a1b2c4d5e",
+			"7c9e6679-7425-40de-944b-e07fc1f90ae7",
+		), nil)
+
+	filter := api.EntityFilter{Scope: api.Scope{"theScope"}, Attrs: api.Attrs{"theAttr": "theValue"}}
+
+	res, next, err := s.api.ListEntities(context.Background(), filter, "", 2)
+	s.Require().NoError(err)
+	s.Require().Len(res, 2)
+	s.Assert().Equal("0b3b1f4e-3c8a-4d8f-9d0c-3f3a1b2c4d5e", res[1].ID)
+	s.Require().NotEmpty(next)
+
+	s.db.
+		On("QueryContext", mock.Anything,
+			"SELECT id, login, scope, attrs, disabled, created_at, updated_at, secret_changed_at, "+
+				"last_authenticated_at, failed_auth_count, locked_until, totp_secret IS NOT NULL FROM entity "+
+				"WHERE (created_at, id) > ($1, $2) ORDER BY created_at, id LIMIT $3",
+			[]interface{}{time.Unix(123456790, 0).UTC(), "0b3b1f4e-3c8a-4d8f-9d0c-3f3a1b2c4d5e", 3},
+		).
+		Return(s.rows("7c9e6679-7425-40de-944b-e07fc1f90ae7"), nil)
+
+	res, next, err = s.api.ListEntities(context.Background(), api.EntityFilter{}, next, 2)
+	s.Require().NoError(err)
+	s.Require().Len(res, 1)
+	s.Assert().Empty(next)
+}
+
+func (s *EntityListTestSuite) TestLimitCapped() {
+	s.db.
+		On("QueryContext", mock.Anything, mock.AnythingOfType("string"), []interface{}{api.MaxListLimit + 1}).
+		Return(s.rows(), nil)
+
+	res, _, err := s.api.ListEntities(context.Background(), api.EntityFilter{}, "", api.MaxListLimit*10)
+	s.Require().NoError(err)
+	s.Assert().Empty(res)
+}
+
+func TestDefaultAPI_ListEntities(t *testing.T) {
+	suite.Run(t, new(EntityListTestSuite))
+}
@@ -2,14 +2,29 @@ package api
 
 import (
 	"errors"
+	"time"
 )
 
 var (
-	ErrNotFound = errors.New("not found")
+	ErrNotFound      = errors.New("not found")
+	ErrAlreadyExists = errors.New("already exists")
+	ErrTokenReused   = errors.New("token reused")
+
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrEntityDisabled     = errors.New("entity disabled")
+	ErrUnsupportedHash    = errors.New("unsupported hash")
+	ErrWebAuthnDisabled   = errors.New("webauthn disabled")
+	ErrIDTokenUnsupported = errors.New("id tokens unsupported")
+
+	ErrInvalidTokenType = errors.New("invalid token type")
+	ErrUnknownKey       = errors.New("unknown key")
+	ErrTokenRevoked     = errors.New("token revoked")
 )
 
 type ErrInvalidArg struct {
 	Msg string
+	// Rules lists the password policy rules violated by a secret, if any
+	Rules []string
 }
 
 func (e ErrInvalidArg) Error() string {
@@ -20,3 +35,18 @@ func (e ErrInvalidArg) Is(err error) bool {
 	_, ok := err.(ErrInvalidArg)
 	return ok
 }
+
+// ErrLocked is returned if an entity or a source of requests is locked out after too many failed authentication
+// attempts.
+type ErrLocked struct {
+	Until time.Time
+}
+
+func (e ErrLocked) Error() string {
+	return "locked out"
+}
+
+func (e ErrLocked) Is(err error) bool {
+	_, ok := err.(ErrLocked)
+	return ok
+}
@@ -0,0 +1,206 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	HashAlgArgon2id = "argon2id"
+	HashAlgBcrypt   = "bcrypt"
+)
+
+// DefaultArgon2idParams follows the second recommended option of RFC 9106.
+var DefaultArgon2idParams = Argon2idParams{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 4,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// Hasher hashes entity secrets and verifies secrets against stored hashes.
+type Hasher interface {
+	// Hash returns an encoded hash of a secret.
+	Hash(secret string) (string, error)
+	// Verify reports whether a secret matches an encoded hash. ErrUnsupportedHash is returned if the hash has been
+	// produced by an algorithm the hasher does not know.
+	Verify(hash, secret string) (bool, error)
+	// NeedsRehash reports whether an encoded hash has been produced by another algorithm or with other parameters than
+	// the hasher would use now.
+	NeedsRehash(hash string) bool
+}
+
+// Argon2idParams defines the cost of argon2id hashing. Memory is measured in KiB.
+type Argon2idParams struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// Argon2idHasher produces argon2id hashes encoded in the PHC string format.
+type Argon2idHasher struct {
+	params Argon2idParams
+}
+
+func NewArgon2idHasher(params Argon2idParams) *Argon2idHasher {
+	return &Argon2idHasher{params: params}
+}
+
+func (h *Argon2idHasher) Hash(secret string) (string, error) {
+	salt := make([]byte, h.params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(secret), salt, h.params.Iterations, h.params.Memory, h.params.Parallelism,
+		h.params.KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version,
+		h.params.Memory,
+		h.params.Iterations,
+		h.params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (h *Argon2idHasher) Verify(hash, secret string) (bool, error) {
+	params, salt, key, err := decodeArgon2id(hash)
+	if err != nil {
+		return false, err
+	}
+
+	other := argon2.IDKey([]byte(secret), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+
+	return subtle.ConstantTimeCompare(key, other) == 1, nil
+}
+
+func (h *Argon2idHasher) NeedsRehash(hash string) bool {
+	params, _, _, err := decodeArgon2id(hash)
+
+	return err != nil || params != h.params
+}
+
+// decodeArgon2id parses a hash in the PHC string format.
+func decodeArgon2id(hash string) (Argon2idParams, []byte, []byte, error) {
+	var (
+		params  Argon2idParams
+		version int
+	)
+
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != HashAlgArgon2id {
+		return params, nil, nil, ErrUnsupportedHash
+	}
+
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2id version: %w", err)
+	} else if version != argon2.Version {
+		return params, nil, nil, fmt.Errorf("unsupported argon2id version: %d", version)
+	}
+
+	_, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism)
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2id params: %w", err)
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2id salt: %w", err)
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2id key: %w", err)
+	}
+
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+
+	return params, salt, key, nil
+}
+
+// BcryptMaxSecretLength is the maximum length of a secret in bytes which bcrypt can hash.
+const BcryptMaxSecretLength = 72
+
+// BcryptHasher produces bcrypt hashes.
+type BcryptHasher struct {
+	cost int
+}
+
+func NewBcryptHasher(cost int) *BcryptHasher {
+	return &BcryptHasher{cost: cost}
+}
+
+func (h *BcryptHasher) Hash(secret string) (string, error) {
+	b, err := bcrypt.GenerateFromPassword([]byte(secret), h.cost)
+	if err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
+
+func (h *BcryptHasher) Verify(hash, secret string) (bool, error) {
+	if !strings.HasPrefix(hash, "$2") {
+		return false, ErrUnsupportedHash
+	}
+
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(secret))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (h *BcryptHasher) NeedsRehash(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+
+	return err != nil || cost != h.cost
+}
+
+// MultiHasher hashes secrets with its primary hasher and verifies hashes produced by any of its hashers, so hashes
+// made by outdated algorithms keep working until they are rehashed.
+type MultiHasher struct {
+	primary Hasher
+	others  []Hasher
+}
+
+func NewMultiHasher(primary Hasher, others ...Hasher) *MultiHasher {
+	return &MultiHasher{primary: primary, others: others}
+}
+
+func (h *MultiHasher) Hash(secret string) (string, error) {
+	return h.primary.Hash(secret)
+}
+
+func (h *MultiHasher) Verify(hash, secret string) (bool, error) {
+	for _, hr := range append([]Hasher{h.primary}, h.others...) {
+		ok, err := hr.Verify(hash, secret)
+		if errors.Is(err, ErrUnsupportedHash) {
+			continue
+		}
+
+		return ok, err
+	}
+
+	return false, ErrUnsupportedHash
+}
+
+func (h *MultiHasher) NeedsRehash(hash string) bool {
+	return h.primary.NeedsRehash(hash)
+}
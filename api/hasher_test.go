@@ -0,0 +1,118 @@
+package api_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/ashep/a23n/api"
+)
+
+var testArgon2idParams = api.Argon2idParams{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+type HasherTestSuite struct {
+	suite.Suite
+}
+
+func (s *HasherTestSuite) TestArgon2id() {
+	h := api.NewArgon2idHasher(testArgon2idParams)
+
+	hash, err := h.Hash("theSecret")
+	s.Require().NoError(err)
+	s.Assert().True(strings.HasPrefix(hash, "$argon2id$v=19$m=64,t=1,p=1$"))
+
+	other, err := h.Hash("theSecret")
+	s.Require().NoError(err)
+	s.Assert().NotEqual(hash, other)
+
+	ok, err := h.Verify(hash, "theSecret")
+	s.Require().NoError(err)
+	s.Assert().True(ok)
+
+	ok, err = h.Verify(hash, "wrongSecret")
+	s.Require().NoError(err)
+	s.Assert().False(ok)
+
+	s.Assert().False(h.NeedsRehash(hash))
+}
+
+func (s *HasherTestSuite) TestArgon2idNeedsRehash() {
+	params := testArgon2idParams
+	params.Iterations = 2
+
+	hash, err := api.NewArgon2idHasher(testArgon2idParams).Hash("theSecret")
+	s.Require().NoError(err)
+
+	h := api.NewArgon2idHasher(params)
+	s.Assert().True(h.NeedsRehash(hash))
+	s.Assert().True(h.NeedsRehash("$2a$04$invalid"))
+
+	ok, err := h.Verify(hash, "theSecret")
+	s.Require().NoError(err)
+	s.Assert().True(ok)
+}
+
+func (s *HasherTestSuite) TestArgon2idInvalidHash() {
+	h := api.NewArgon2idHasher(testArgon2idParams)
+
+	_, err := h.Verify("$2a$04$invalid", "theSecret")
+	s.Assert().ErrorIs(err, api.ErrUnsupportedHash)
+
+	_, err = h.Verify("$argon2id$v=18$m=64,t=1,p=1$c2FsdA$a2V5", "theSecret")
+	s.Assert().EqualError(err, "unsupported argon2id version: 18")
+
+	_, err = h.Verify("$argon2id$v=19$m=64,t=1,p=1$!$a2V5", "theSecret")
+	s.Assert().ErrorContains(err, "invalid argon2id salt")
+}
+
+func (s *HasherTestSuite) TestBcrypt() {
+	h := api.NewBcryptHasher(bcrypt.MinCost)
+
+	hash, err := h.Hash("theSecret")
+	s.Require().NoError(err)
+
+	ok, err := h.Verify(hash, "theSecret")
+	s.Require().NoError(err)
+	s.Assert().True(ok)
+
+	ok, err = h.Verify(hash, "wrongSecret")
+	s.Require().NoError(err)
+	s.Assert().False(ok)
+
+	s.Assert().False(h.NeedsRehash(hash))
+	s.Assert().True(api.NewBcryptHasher(bcrypt.MinCost + 1).NeedsRehash(hash))
+
+	_, err = h.Verify("$argon2id$v=19$m=64,t=1,p=1$c2FsdA$a2V5", "theSecret")
+	s.Assert().ErrorIs(err, api.ErrUnsupportedHash)
+}
+
+func (s *HasherTestSuite) TestMulti() {
+	bcryptHasher := api.NewBcryptHasher(bcrypt.MinCost)
+	h := api.NewMultiHasher(api.NewArgon2idHasher(testArgon2idParams), bcryptHasher)
+
+	hash, err := h.Hash("theSecret")
+	s.Require().NoError(err)
+	s.Assert().True(strings.HasPrefix(hash, "$argon2id$"))
+	s.Assert().False(h.NeedsRehash(hash))
+
+	oldHash, err := bcryptHasher.Hash("theSecret")
+	s.Require().NoError(err)
+	s.Assert().True(h.NeedsRehash(oldHash))
+
+	ok, err := h.Verify(oldHash, "theSecret")
+	s.Require().NoError(err)
+	s.Assert().True(ok)
+
+	ok, err = h.Verify(oldHash, "wrongSecret")
+	s.Require().NoError(err)
+	s.Assert().False(ok)
+
+	_, err = h.Verify("plainText", "theSecret")
+	s.Assert().ErrorIs(err, api.ErrUnsupportedHash)
+}
+
+func TestHasher(t *testing.T) {
+	suite.Run(t, new(HasherTestSuite))
+}
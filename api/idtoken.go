@@ -0,0 +1,90 @@
+package api
+
+import (
+	"encoding/json"
+	"net/url"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// IDTokenClaims are the claims of an OpenID Connect ID token, see OpenID Connect Core 1.0, section 2.
+type IDTokenClaims struct {
+	jwt.RegisteredClaims
+	AuthTime *jwt.NumericDate
+	Nonce    string
+	// Entity holds the claims describing the entity, like name or email
+	Entity map[string]interface{}
+}
+
+// MarshalJSON puts the entity claims at the top level of the token claims. They cannot override the standard ones.
+func (c IDTokenClaims) MarshalJSON() ([]byte, error) {
+	m := make(map[string]interface{}, len(c.Entity)+7)
+	for k, v := range c.Entity {
+		m[k] = v
+	}
+
+	m["iss"] = c.Issuer
+	m["sub"] = c.Subject
+	m["aud"] = c.Audience
+	m["iat"] = c.IssuedAt
+	m["exp"] = c.ExpiresAt
+	m["auth_time"] = c.AuthTime
+	if c.Nonce != "" {
+		m["nonce"] = c.Nonce
+	} else {
+		delete(m, "nonce")
+	}
+
+	return json.Marshal(m)
+}
+
+// Issuer returns the issuer of tokens, which is the OpenID Connect issuer identifier as well.
+func (a *DefaultAPI) Issuer() string {
+	return a.issuer
+}
+
+// ValidOIDCIssuer checks whether iss can identify an OpenID provider. It must be an https URL having no query and
+// fragment, see OpenID Connect Discovery 1.0, section 4, nor path, since the endpoints are served at the root only.
+func ValidOIDCIssuer(iss string) bool {
+	u, err := url.Parse(iss)
+
+	return err == nil && u.Scheme == "https" && u.Host != "" && (u.Path == "" || u.Path == "/") && u.RawQuery == "" &&
+		u.Fragment == "" && !u.ForceQuery
+}
+
+// CreateIDToken creates an ID token issued to a client. Like other tokens, it is signed by the currently active
+// signing key. Clients can verify it only if the key is an asymmetric one published in the JWKS, and tokens signed by
+// a shared secret would let anyone knowing it forge them, so ErrIDTokenUnsupported is returned for symmetric keys as
+// well as for issuers not valid for an OpenID provider.
+func (a *DefaultAPI) CreateIDToken(
+	clientID string,
+	subject string,
+	authTime time.Time,
+	nonce string,
+	claims map[string]interface{},
+	ttl time.Duration,
+) (Token, error) {
+	k := a.keys.signing()
+	if _, ok := k.JWK(); !ok || !ValidOIDCIssuer(a.issuer) {
+		return nil, ErrIDTokenUnsupported
+	}
+
+	n := jwt.NewNumericDate(a.now())
+
+	t := jwt.NewWithClaims(k.Method, IDTokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    a.issuer,
+			Audience:  jwt.ClaimStrings{clientID},
+			Subject:   subject,
+			IssuedAt:  n,
+			ExpiresAt: jwt.NewNumericDate(n.Add(ttl)),
+		},
+		AuthTime: jwt.NewNumericDate(authTime),
+		Nonce:    nonce,
+		Entity:   claims,
+	})
+	t.Header["kid"] = k.ID
+
+	return &DefaultToken{t: t, key: k.Private}, nil
+}
@@ -0,0 +1,228 @@
+package api
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// hmacKeyID is the key ID of a symmetric signing key. Symmetric keys are never published, so there is no need to
+// derive their IDs from key material.
+const hmacKeyID = "hmac"
+
+// SigningKey is a key used to sign tokens and to verify their signatures. For symmetric algorithms both the private
+// and the public parts are the same secret.
+type SigningKey struct {
+	ID      string
+	Method  jwt.SigningMethod
+	Private interface{}
+	Public  interface{}
+}
+
+// JWK is a public key in the JSON Web Key format, RFC 7517.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKSet is a set of public keys in the JSON Web Key Set format, RFC 7517.
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// NewHMACKey creates an HS256 signing key from a shared secret.
+func NewHMACKey(secret []byte) SigningKey {
+	return SigningKey{ID: hmacKeyID, Method: jwt.SigningMethodHS256, Private: secret, Public: secret}
+}
+
+// ParseSigningKey parses a PEM encoded private key for one of the supported asymmetric algorithms: RS256, ES256 or
+// EdDSA. The public key used to verify signatures is derived from the private one.
+func ParseSigningKey(alg string, pemData []byte) (SigningKey, error) {
+	var k SigningKey
+
+	switch alg {
+	case jwt.SigningMethodRS256.Alg():
+		pk, err := jwt.ParseRSAPrivateKeyFromPEM(pemData)
+		if err != nil {
+			return SigningKey{}, err
+		}
+		if pk.N.BitLen() < 2048 {
+			return SigningKey{}, errors.New("rsa key must be at least 2048 bits long")
+		}
+		k = SigningKey{Method: jwt.SigningMethodRS256, Private: pk, Public: &pk.PublicKey}
+
+	case jwt.SigningMethodES256.Alg():
+		pk, err := jwt.ParseECPrivateKeyFromPEM(pemData)
+		if err != nil {
+			return SigningKey{}, err
+		}
+		if pk.Curve != elliptic.P256() {
+			return SigningKey{}, errors.New("ecdsa key must use the P-256 curve")
+		}
+		k = SigningKey{Method: jwt.SigningMethodES256, Private: pk, Public: &pk.PublicKey}
+
+	case jwt.SigningMethodEdDSA.Alg():
+		pk, err := jwt.ParseEdPrivateKeyFromPEM(pemData)
+		if err != nil {
+			return SigningKey{}, err
+		}
+		edK, ok := pk.(ed25519.PrivateKey)
+		if !ok {
+			return SigningKey{}, errors.New("not an ed25519 key")
+		}
+		k = SigningKey{Method: jwt.SigningMethodEdDSA, Private: edK, Public: edK.Public()}
+
+	default:
+		return SigningKey{}, fmt.Errorf("unsupported signing algorithm: %q", alg)
+	}
+
+	k.ID = k.Thumbprint()
+
+	return k, nil
+}
+
+// LoadSigningKey reads a PEM encoded private key from a file. See ParseSigningKey.
+func LoadSigningKey(alg, path string) (SigningKey, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return SigningKey{}, err
+	}
+
+	return ParseSigningKey(alg, b)
+}
+
+// GenerateSigningKey generates a new key for one of the supported asymmetric algorithms.
+func GenerateSigningKey(alg string) (SigningKey, error) {
+	var (
+		pk  interface{}
+		err error
+	)
+
+	switch alg {
+	case jwt.SigningMethodRS256.Alg():
+		pk, err = rsa.GenerateKey(rand.Reader, 2048)
+	case jwt.SigningMethodES256.Alg():
+		pk, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case jwt.SigningMethodEdDSA.Alg():
+		_, pk, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return SigningKey{}, fmt.Errorf("unsupported signing algorithm: %q", alg)
+	}
+	if err != nil {
+		return SigningKey{}, err
+	}
+
+	b, err := SigningKey{Private: pk}.MarshalPEM()
+	if err != nil {
+		return SigningKey{}, err
+	}
+
+	return ParseSigningKey(alg, b)
+}
+
+// MarshalPEM returns the private key in the PEM encoded PKCS #8 form accepted by ParseSigningKey.
+func (k SigningKey) MarshalPEM() ([]byte, error) {
+	b, err := x509.MarshalPKCS8PrivateKey(k.Private)
+	if err != nil {
+		return nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: b}), nil
+}
+
+// JWK returns the public part of the key in the JSON Web Key format. Symmetric keys cannot be published, so false is
+// returned for them.
+func (k SigningKey) JWK() (JWK, bool) {
+	enc := base64.RawURLEncoding.EncodeToString
+
+	switch pub := k.Public.(type) {
+	case *rsa.PublicKey:
+		return JWK{
+			Kty: "RSA",
+			Kid: k.ID,
+			Use: "sig",
+			Alg: k.Method.Alg(),
+			N:   enc(pub.N.Bytes()),
+			E:   enc(big.NewInt(int64(pub.E)).Bytes()),
+		}, true
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		return JWK{
+			Kty: "EC",
+			Kid: k.ID,
+			Use: "sig",
+			Alg: k.Method.Alg(),
+			Crv: pub.Curve.Params().Name,
+			X:   enc(pub.X.FillBytes(make([]byte, size))),
+			Y:   enc(pub.Y.FillBytes(make([]byte, size))),
+		}, true
+	case ed25519.PublicKey:
+		return JWK{
+			Kty: "OKP",
+			Kid: k.ID,
+			Use: "sig",
+			Alg: k.Method.Alg(),
+			Crv: "Ed25519",
+			X:   enc(pub),
+		}, true
+	default:
+		return JWK{}, false
+	}
+}
+
+// Thumbprint returns the JWK thumbprint of the public key as defined by RFC 7638.
+func (k SigningKey) Thumbprint() string {
+	jwk, ok := k.JWK()
+	if !ok {
+		return hmacKeyID
+	}
+
+	// Required members only, in lexicographic order
+	var m interface{}
+	switch jwk.Kty {
+	case "RSA":
+		m = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.Kty, jwk.N}
+	case "EC":
+		m = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{jwk.Crv, jwk.Kty, jwk.X, jwk.Y}
+	default:
+		m = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Crv, jwk.Kty, jwk.X}
+	}
+
+	b, _ := json.Marshal(m)
+	h := sha256.Sum256(b)
+
+	return base64.RawURLEncoding.EncodeToString(h[:])
+}
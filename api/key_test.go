@@ -0,0 +1,370 @@
+package api_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/ashep/a23n/api"
+	"github.com/ashep/a23n/sqldb"
+)
+
+type KeyTestSuite struct {
+	suite.Suite
+}
+
+func (s *KeyTestSuite) pemPKCS8(k interface{}) []byte {
+	b, err := x509.MarshalPKCS8PrivateKey(k)
+	s.Require().NoError(err)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: b})
+}
+
+// notRevokedDB returns a database mock which reports all the tokens as not revoked.
+func (s *KeyTestSuite) notRevokedDB() *sqldb.DBMock {
+	row := &sqldb.RowMock{}
+	row.On("Scan", mock.Anything).Return(nil)
+
+	db := &sqldb.DBMock{}
+	db.On("QueryRowContext", mock.Anything, mock.AnythingOfType("string"), mock.Anything).Return(row)
+
+	return db
+}
+
+// expectLoadKeys makes db return a single stored activated key, or no keys if pemData is nil, on loading keys once.
+func (s *KeyTestSuite) expectLoadKeys(db *sqldb.DBMock, id, alg string, pemData []byte) {
+	s.expectLoadKey(db, id, alg, pemData, true)
+}
+
+func (s *KeyTestSuite) expectLoadKey(db *sqldb.DBMock, id, alg string, pemData []byte, activated bool) {
+	rows := &sqldb.RowsMock{}
+	if pemData != nil {
+		rows.On("Next").Return(true).Once()
+		rows.On("Scan", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+			Run(func(args mock.Arguments) {
+				*args.Get(0).(*string) = id
+				*args.Get(1).(*string) = alg
+				*args.Get(2).(*[]byte) = pemData
+				*args.Get(3).(*bool) = activated
+			}).
+			Return(nil)
+	}
+	rows.On("Next").Return(false).Once()
+	rows.On("Err").Return(nil)
+	rows.On("Close").Return(nil)
+
+	db.On("QueryContext", mock.Anything, mock.AnythingOfType("string"), mock.Anything).Return(rows, nil).Once()
+}
+
+func (s *KeyTestSuite) roundTrip(key api.SigningKey) {
+	a := api.NewDefault(s.notRevokedDB(), api.Options{
+		Key:      key,
+		Issuer:   "theIssuer",
+		Audience: "theAudience",
+	})
+
+	ts, err := a.CreateToken(api.TokenTypeAccess, "theSubject", "", nil, time.Minute).SignedString()
+	s.Require().NoError(err)
+
+	clm, err := a.ParseToken(context.Background(), ts, api.TokenTypeAccess)
+	s.Require().NoError(err)
+	s.Assert().Equal("theSubject", clm.Subject)
+
+	// The key is looked for among the stored ones before the token is rejected
+	hmacDB := &sqldb.DBMock{}
+	s.expectLoadKeys(hmacDB, "", "", nil)
+
+	hmac := api.NewDefault(hmacDB, api.Options{
+		Key:      api.NewHMACKey([]byte("abc")),
+		Issuer:   "theIssuer",
+		Audience: "theAudience",
+	})
+	_, err = hmac.ParseToken(context.Background(), ts, api.TokenTypeAccess)
+	s.Require().ErrorIs(err, api.ErrUnknownKey)
+	hmacDB.AssertExpectations(s.T())
+}
+
+func (s *KeyTestSuite) TestRS256() {
+	k, err := rsa.GenerateKey(rand.Reader, 2048)
+	s.Require().NoError(err)
+
+	key, err := api.ParseSigningKey("RS256", s.pemPKCS8(k))
+	s.Require().NoError(err)
+	s.Assert().Equal(&k.PublicKey, key.Public)
+
+	s.roundTrip(key)
+}
+
+func (s *KeyTestSuite) TestRS256ShortKey() {
+	k, err := rsa.GenerateKey(rand.Reader, 1024)
+	s.Require().NoError(err)
+
+	_, err = api.ParseSigningKey("RS256", s.pemPKCS8(k))
+	s.Require().EqualError(err, "rsa key must be at least 2048 bits long")
+}
+
+func (s *KeyTestSuite) TestES256() {
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	s.Require().NoError(err)
+
+	key, err := api.ParseSigningKey("ES256", s.pemPKCS8(k))
+	s.Require().NoError(err)
+	s.Assert().Equal(&k.PublicKey, key.Public)
+
+	s.roundTrip(key)
+}
+
+func (s *KeyTestSuite) TestES256WrongCurve() {
+	k, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	s.Require().NoError(err)
+
+	_, err = api.ParseSigningKey("ES256", s.pemPKCS8(k))
+	s.Require().EqualError(err, "ecdsa key must use the P-256 curve")
+}
+
+func (s *KeyTestSuite) TestEdDSA() {
+	pub, k, err := ed25519.GenerateKey(rand.Reader)
+	s.Require().NoError(err)
+
+	key, err := api.ParseSigningKey("EdDSA", s.pemPKCS8(k))
+	s.Require().NoError(err)
+	s.Assert().Equal(pub, key.Public)
+
+	s.roundTrip(key)
+}
+
+func (s *KeyTestSuite) TestAlgorithmMismatch() {
+	_, k, err := ed25519.GenerateKey(rand.Reader)
+	s.Require().NoError(err)
+
+	_, err = api.ParseSigningKey("RS256", s.pemPKCS8(k))
+	s.Require().Error(err)
+}
+
+func (s *KeyTestSuite) TestUnsupportedAlgorithm() {
+	_, err := api.ParseSigningKey("HS512", nil)
+	s.Require().EqualError(err, `unsupported signing algorithm: "HS512"`)
+}
+
+func (s *KeyTestSuite) TestJWKS() {
+	k, err := api.GenerateSigningKey("ES256")
+	s.Require().NoError(err)
+
+	a := api.NewDefault(&sqldb.DBMock{}, api.Options{
+		Key:      k,
+		Issuer:   "theIssuer",
+		Audience: "theAudience",
+	})
+	jwks := a.JWKS()
+	s.Require().Len(jwks.Keys, 1)
+
+	jwk := jwks.Keys[0]
+	s.Assert().Equal("EC", jwk.Kty)
+	s.Assert().Equal(k.ID, jwk.Kid)
+	s.Assert().Equal("sig", jwk.Use)
+	s.Assert().Equal("ES256", jwk.Alg)
+	s.Assert().Equal("P-256", jwk.Crv)
+	s.Assert().Len(jwk.X, 43)
+	s.Assert().Len(jwk.Y, 43)
+
+	hmac := api.NewDefault(&sqldb.DBMock{}, api.Options{
+		Key:      api.NewHMACKey([]byte("abc")),
+		Issuer:   "theIssuer",
+		Audience: "theAudience",
+	})
+	s.Assert().Empty(hmac.JWKS().Keys)
+}
+
+func (s *KeyTestSuite) TestRotateKey() {
+	db := &sqldb.DBMock{}
+	defer db.AssertExpectations(s.T())
+
+	now := time.Now()
+	a := api.NewDefault(db, api.Options{
+		Key:      api.NewHMACKey([]byte("abc")),
+		Issuer:   "theIssuer",
+		Audience: "theAudience",
+		Now:      func() time.Time { return now },
+	})
+
+	db.On(
+		"ExecContext",
+		mock.Anything,
+		"WITH retired AS (UPDATE signing_key SET expires_at=$1 WHERE expires_at IS NULL) "+
+			"INSERT INTO signing_key (id, alg, private_key, created_at, activates_at) VALUES ($2, $3, $4, $5, $6)",
+		mock.MatchedBy(func(args []interface{}) bool {
+			// Previous keys are retired an hour after the new key is activated
+			return len(args) == 6 && args[0] == now.Add(time.Hour+5*time.Minute) && args[2] == "EdDSA" &&
+				args[4] == now && args[5] == now.Add(5*time.Minute)
+		}),
+	).Return(&sqldb.ResultMock{}, nil)
+
+	k, err := a.RotateKey(context.Background(), "EdDSA", 5*time.Minute, time.Hour)
+	s.Require().NoError(err)
+	s.Assert().Equal(k.Thumbprint(), k.ID)
+}
+
+func (s *KeyTestSuite) TestLoadKeys() {
+	static := api.NewHMACKey([]byte("abc"))
+	rotated, err := api.GenerateSigningKey("ES256")
+	s.Require().NoError(err)
+	rotatedPEM, err := rotated.MarshalPEM()
+	s.Require().NoError(err)
+
+	db := s.notRevokedDB()
+	defer db.AssertExpectations(s.T())
+
+	a := api.NewDefault(db, api.Options{
+		Key:      static,
+		Issuer:   "theIssuer",
+		Audience: "theAudience",
+	})
+
+	oldToken, err := a.CreateToken(api.TokenTypeAccess, "theSubject", "", nil, time.Minute).SignedString()
+	s.Require().NoError(err)
+
+	s.expectLoadKeys(db, rotated.ID, "ES256", rotatedPEM)
+
+	s.Require().NoError(a.LoadKeys(context.Background()))
+	s.Require().Len(a.JWKS().Keys, 1)
+	s.Assert().Equal(rotated.ID, a.JWKS().Keys[0].Kid)
+
+	newToken, err := a.CreateToken(api.TokenTypeAccess, "theSubject", "", nil, time.Minute).SignedString()
+	s.Require().NoError(err)
+
+	t, _, err := jwt.NewParser().ParseUnverified(newToken, &api.TokenClaims{})
+	s.Require().NoError(err)
+	s.Assert().Equal(rotated.ID, t.Header["kid"])
+	s.Assert().Equal("ES256", t.Method.Alg())
+
+	_, err = a.ParseToken(context.Background(), newToken, api.TokenTypeAccess)
+	s.Assert().NoError(err)
+
+	_, err = a.ParseToken(context.Background(), oldToken, api.TokenTypeAccess)
+	s.Assert().NoError(err)
+}
+
+func (s *KeyTestSuite) TestLoadKeysNotActivated() {
+	static := api.NewHMACKey([]byte("abc"))
+	rotated, err := api.GenerateSigningKey("ES256")
+	s.Require().NoError(err)
+	rotatedPEM, err := rotated.MarshalPEM()
+	s.Require().NoError(err)
+
+	db := &sqldb.DBMock{}
+	defer db.AssertExpectations(s.T())
+
+	a := api.NewDefault(db, api.Options{
+		Key:      static,
+		Issuer:   "theIssuer",
+		Audience: "theAudience",
+	})
+
+	s.expectLoadKey(db, rotated.ID, "ES256", rotatedPEM, false)
+	s.Require().NoError(a.LoadKeys(context.Background()))
+
+	// The key is published, but tokens are still signed by the previous one
+	s.Require().Len(a.JWKS().Keys, 1)
+	s.Assert().Equal(rotated.ID, a.JWKS().Keys[0].Kid)
+
+	ts, err := a.CreateToken(api.TokenTypeAccess, "theSubject", "", nil, time.Minute).SignedString()
+	s.Require().NoError(err)
+
+	t, _, err := jwt.NewParser().ParseUnverified(ts, &api.TokenClaims{})
+	s.Require().NoError(err)
+	s.Assert().Equal(static.ID, t.Header["kid"])
+}
+
+func (s *KeyTestSuite) TestParseTokenReloadsKeys() {
+	rotated, err := api.GenerateSigningKey("ES256")
+	s.Require().NoError(err)
+	rotatedPEM, err := rotated.MarshalPEM()
+	s.Require().NoError(err)
+	other, err := api.GenerateSigningKey("ES256")
+	s.Require().NoError(err)
+
+	// Tokens signed by keys rotated by other processes
+	newAPI := func(db sqldb.DB, key api.SigningKey) *api.DefaultAPI {
+		return api.NewDefault(db, api.Options{
+			Key:      key,
+			Issuer:   "theIssuer",
+			Audience: "theAudience",
+		})
+	}
+	rotatedToken, err := newAPI(nil, rotated).CreateToken(api.TokenTypeAccess, "theSubject", "", nil, time.Minute).
+		SignedString()
+	s.Require().NoError(err)
+	otherToken, err := newAPI(nil, other).CreateToken(api.TokenTypeAccess, "theSubject", "", nil, time.Minute).
+		SignedString()
+	s.Require().NoError(err)
+
+	db := s.notRevokedDB()
+	defer db.AssertExpectations(s.T())
+	s.expectLoadKeys(db, rotated.ID, "ES256", rotatedPEM)
+
+	a := newAPI(db, api.NewHMACKey([]byte("abc")))
+
+	_, err = a.ParseToken(context.Background(), rotatedToken, api.TokenTypeAccess)
+	s.Require().NoError(err)
+
+	// Keys have just been reloaded, so they are not reloaded again
+	_, err = a.ParseToken(context.Background(), otherToken, api.TokenTypeAccess)
+	s.Require().ErrorIs(err, api.ErrUnknownKey)
+}
+
+func (s *KeyTestSuite) TestRotateKeyEncrypted() {
+	enc, err := api.NewEncrypter([]byte("0123456789abcdef0123456789abcdef"))
+	s.Require().NoError(err)
+
+	var stored []byte
+
+	db := &sqldb.DBMock{}
+	defer db.AssertExpectations(s.T())
+	db.On("ExecContext", mock.Anything, mock.AnythingOfType("string"), mock.Anything).
+		Run(func(args mock.Arguments) { stored = args.Get(2).([]interface{})[3].([]byte) }).
+		Return(&sqldb.ResultMock{}, nil)
+
+	a := api.NewDefault(db, api.Options{
+		Key:       api.NewHMACKey([]byte("abc")),
+		Encrypter: enc,
+		Issuer:    "theIssuer",
+		Audience:  "theAudience",
+	})
+
+	k, err := a.RotateKey(context.Background(), "EdDSA", 0, time.Hour)
+	s.Require().NoError(err)
+	s.Assert().NotContains(string(stored), "PRIVATE KEY")
+
+	s.expectLoadKeys(db, k.ID, "EdDSA", stored)
+	s.Require().NoError(a.LoadKeys(context.Background()))
+	s.Require().Len(a.JWKS().Keys, 1)
+	s.Assert().Equal(k.ID, a.JWKS().Keys[0].Kid)
+
+	// The key cannot be read without the encryption key
+	noEnc := api.NewDefault(db, api.Options{
+		Key:      api.NewHMACKey([]byte("abc")),
+		Issuer:   "theIssuer",
+		Audience: "theAudience",
+	})
+	s.expectLoadKeys(db, k.ID, "EdDSA", stored)
+	s.Require().ErrorIs(noEnc.LoadKeys(context.Background()), api.ErrNoEncryptionKey)
+
+	// Encrypted keys are bound to their IDs
+	s.expectLoadKeys(db, "otherID", "EdDSA", stored)
+	s.Require().Error(a.LoadKeys(context.Background()))
+}
+
+func TestSigningKey(t *testing.T) {
+	suite.Run(t, new(KeyTestSuite))
+}
@@ -0,0 +1,219 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// unknownKeyReloadInterval limits how often keys are reloaded on encountering a token signed by an unknown key, so
+// tokens with made up key IDs cannot flood the database
+const unknownKeyReloadInterval = 10 * time.Second
+
+// keyRing holds the key used to sign new tokens and all the keys which are still valid for verification.
+type keyRing struct {
+	mu     sync.RWMutex
+	static SigningKey
+	active SigningKey
+	keys   map[string]SigningKey
+
+	reloadMu   sync.Mutex
+	lastReload time.Time
+}
+
+func newKeyRing(static SigningKey) *keyRing {
+	return &keyRing{
+		static: static,
+		active: static,
+		keys:   map[string]SigningKey{static.ID: static},
+	}
+}
+
+func (r *keyRing) set(active SigningKey, keys map[string]SigningKey) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.active = active
+	r.keys = keys
+}
+
+func (r *keyRing) signing() SigningKey {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.active
+}
+
+func (r *keyRing) get(id string) (SigningKey, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if id == "" {
+		// Tokens issued before key IDs were introduced
+		return r.static, true
+	}
+
+	k, ok := r.keys[id]
+
+	return k, ok
+}
+
+// reloadAllowed tells whether keys can be reloaded to look for an unknown key. It returns true at most once per
+// unknownKeyReloadInterval.
+func (r *keyRing) reloadAllowed(now time.Time) bool {
+	r.reloadMu.Lock()
+	defer r.reloadMu.Unlock()
+
+	if !r.lastReload.IsZero() && now.Sub(r.lastReload) < unknownKeyReloadInterval {
+		return false
+	}
+	r.lastReload = now
+
+	return true
+}
+
+func (r *keyRing) all() []SigningKey {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	res := make([]SigningKey, 0, len(r.keys))
+	res = append(res, r.active)
+	for _, k := range r.keys {
+		if k.ID != r.active.ID {
+			res = append(res, k)
+		}
+	}
+
+	return res
+}
+
+// signingKeyAD returns the associated data binding an encrypted private key to its ID.
+func signingKeyAD(id string) []byte {
+	return []byte("signing_key:" + id)
+}
+
+// LoadKeys loads signing keys from the database. The most recently activated key becomes the one used to sign new
+// tokens, while retired keys remain valid for verification until they expire. Keys which are not activated yet are only
+// published. The configured key is always kept for
+// verification and remains the signing one until the first rotation. Encrypted private keys are decrypted with the
+// configured encryption key, the ones stored before it has been configured are read as is.
+func (a *DefaultAPI) LoadKeys(ctx context.Context) error {
+	q := `SELECT id, alg, private_key, activates_at<=$1 FROM signing_key WHERE expires_at IS NULL OR expires_at>$1 ` +
+		`ORDER BY activates_at DESC`
+	rows, err := a.db.QueryContext(ctx, q, a.now())
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	active := a.keys.static
+	keys := map[string]SigningKey{active.ID: active}
+	activeFound := false
+
+	for rows.Next() {
+		var (
+			id, alg  string
+			pemData  []byte
+			isActive bool
+		)
+
+		if err = rows.Scan(&id, &alg, &pemData, &isActive); err != nil {
+			return err
+		}
+
+		if pemData, err = a.enc.decrypt(pemData, signingKeyAD(id)); err != nil {
+			return fmt.Errorf("failed to decrypt key %s: %w", id, err)
+		}
+
+		k, err := ParseSigningKey(alg, pemData)
+		if err != nil {
+			return fmt.Errorf("failed to parse key %s: %w", id, err)
+		}
+		keys[k.ID] = k
+
+		if isActive && !activeFound {
+			active = k
+			activeFound = true
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	a.keys.set(active, keys)
+
+	return nil
+}
+
+// RotateKey generates a new signing key and promotes it to be used for new tokens after activateAfter, so it can be
+// published before any token is signed by it. Previously active keys are retired: they keep signing tokens until the
+// new key is activated and remain valid for verification during retireAfter since then, which should not be less than
+// the longest token TTL. The private key is stored encrypted if an encryption key is configured.
+func (a *DefaultAPI) RotateKey(
+	ctx context.Context,
+	alg string,
+	activateAfter time.Duration,
+	retireAfter time.Duration,
+) (SigningKey, error) {
+	k, err := GenerateSigningKey(alg)
+	if err != nil {
+		return SigningKey{}, err
+	}
+
+	pemData, err := k.MarshalPEM()
+	if err != nil {
+		return SigningKey{}, err
+	}
+
+	if pemData, err = a.enc.encrypt(pemData, signingKeyAD(k.ID)); err != nil {
+		return SigningKey{}, err
+	}
+
+	now := a.now()
+	activatesAt := now.Add(activateAfter)
+	q := `WITH retired AS (UPDATE signing_key SET expires_at=$1 WHERE expires_at IS NULL) ` +
+		`INSERT INTO signing_key (id, alg, private_key, created_at, activates_at) VALUES ($2, $3, $4, $5, $6)`
+	_, err = a.db.ExecContext(ctx, q, activatesAt.Add(retireAfter), k.ID, alg, pemData, now, activatesAt)
+	if err != nil {
+		return SigningKey{}, err
+	}
+
+	return k, nil
+}
+
+// verificationKey returns the key with an ID. Keys rotated by other processes are unknown until they are reloaded, so
+// keys are reloaded on a miss, but not more often than once per unknownKeyReloadInterval.
+func (a *DefaultAPI) verificationKey(ctx context.Context, id string) (SigningKey, error) {
+	if k, ok := a.keys.get(id); ok {
+		return k, nil
+	}
+
+	if !a.keys.reloadAllowed(a.now()) {
+		return SigningKey{}, ErrUnknownKey
+	}
+
+	if err := a.LoadKeys(ctx); err != nil {
+		return SigningKey{}, fmt.Errorf("reload keys: %w", err)
+	}
+
+	if k, ok := a.keys.get(id); ok {
+		return k, nil
+	}
+
+	return SigningKey{}, ErrUnknownKey
+}
+
+// JWKS returns public keys of all the asymmetric keys valid for verification.
+func (a *DefaultAPI) JWKS() JWKSet {
+	res := JWKSet{Keys: make([]JWK, 0)}
+
+	for _, k := range a.keys.all() {
+		if jwk, ok := k.JWK(); ok {
+			res.Keys = append(res.Keys, jwk)
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,170 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LockoutPolicy defines how entities and sources of requests are locked out after failed authentication attempts.
+// Every failure after reaching a threshold locks out for twice as long as the previous one, up to MaxDuration. Failure
+// counters are reset after Window passes without failures or the entity successfully authenticates.
+type LockoutPolicy struct {
+	// EntityThreshold and SourceThreshold are the numbers of failures which lead to a lockout, zero disables it
+	EntityThreshold int
+	SourceThreshold int
+
+	Duration    time.Duration
+	MaxDuration time.Duration
+	Window      time.Duration
+}
+
+// lockDuration returns how long to lock out after a number of failures, zero if the threshold is not reached yet.
+func (p LockoutPolicy) lockDuration(failures, threshold int) time.Duration {
+	if threshold <= 0 || failures < threshold {
+		return 0
+	}
+
+	d := p.Duration
+	for i := threshold; i < failures && d < math.MaxInt64/2; i++ {
+		if p.MaxDuration > 0 && d >= p.MaxDuration {
+			break
+		}
+		d *= 2
+	}
+
+	if p.MaxDuration > 0 && d > p.MaxDuration {
+		d = p.MaxDuration
+	}
+
+	return d
+}
+
+// checkSourceLock returns ErrLocked if a source of requests is locked out.
+func (a *DefaultAPI) checkSourceLock(ctx context.Context, source string) error {
+	if source == "" || a.lockout.SourceThreshold <= 0 {
+		return nil
+	}
+
+	var until sql.NullTime
+
+	err := a.db.QueryRowContext(ctx, `SELECT locked_until FROM auth_failure WHERE source=$1`, source).Scan(&until)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if until.Valid && until.Time.After(a.now()) {
+		return ErrLocked{Until: until.Time}
+	}
+
+	return nil
+}
+
+// recordEntityFailure counts a failed authentication attempt of an entity and locks it out if the threshold is reached.
+func (a *DefaultAPI) recordEntityFailure(ctx context.Context, id string) error {
+	if a.lockout.EntityThreshold <= 0 {
+		return nil
+	}
+
+	var count int
+
+	now := a.now()
+	q := `UPDATE entity SET ` +
+		`failed_auth_count=CASE WHEN failed_auth_reset_at>$1 THEN failed_auth_count+1 ELSE 1 END, ` +
+		`failed_auth_reset_at=$2 WHERE id=$3 RETURNING failed_auth_count`
+	if err := a.db.QueryRowContext(ctx, q, now, now.Add(a.lockout.Window), id).Scan(&count); err != nil {
+		return err
+	}
+
+	d := a.lockout.lockDuration(count, a.lockout.EntityThreshold)
+	if d == 0 {
+		return nil
+	}
+
+	until := now.Add(d)
+	q = `UPDATE entity SET locked_until=$1, failed_auth_reset_at=$2 WHERE id=$3`
+	if _, err := a.db.ExecContext(ctx, q, until, until.Add(a.lockout.Window), id); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// recordSourceFailure counts a failed authentication attempt made from a source and locks it out if the threshold is
+// reached.
+func (a *DefaultAPI) recordSourceFailure(ctx context.Context, source string) error {
+	if source == "" || a.lockout.SourceThreshold <= 0 {
+		return nil
+	}
+
+	var count int
+
+	now := a.now()
+	q := `INSERT INTO auth_failure (source, count, reset_at) VALUES ($1, 1, $3) ON CONFLICT (source) DO UPDATE ` +
+		`SET count=CASE WHEN auth_failure.reset_at>$2 THEN auth_failure.count+1 ELSE 1 END, reset_at=$3 RETURNING count`
+	if err := a.db.QueryRowContext(ctx, q, source, now, now.Add(a.lockout.Window)).Scan(&count); err != nil {
+		return err
+	}
+
+	d := a.lockout.lockDuration(count, a.lockout.SourceThreshold)
+	if d == 0 {
+		return nil
+	}
+
+	until := now.Add(d)
+	q = `UPDATE auth_failure SET locked_until=$1, reset_at=$2 WHERE source=$3`
+	if _, err := a.db.ExecContext(ctx, q, until, until.Add(a.lockout.Window), source); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// UnlockEntity resets the failed authentication attempts counter of an entity and lifts its lockout.
+func (a *DefaultAPI) UnlockEntity(ctx context.Context, id string) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return ErrInvalidArg{Msg: fmt.Sprintf("invalid id: %s", err.Error())}
+	}
+
+	q := `UPDATE entity SET failed_auth_count=0, failed_auth_reset_at=NULL, locked_until=NULL WHERE id=$1`
+	r, err := a.db.ExecContext(ctx, q, id)
+	if err != nil {
+		return err
+	}
+
+	if ra, err := r.RowsAffected(); err != nil {
+		return err
+	} else if ra == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// UnlockSource resets the failed authentication attempts counter of a source of requests and lifts its lockout.
+// ErrNotFound is returned if no failures from the source are recorded.
+func (a *DefaultAPI) UnlockSource(ctx context.Context, source string) error {
+	if source == "" {
+		return ErrInvalidArg{Msg: "empty source"}
+	}
+
+	r, err := a.db.ExecContext(ctx, `DELETE FROM auth_failure WHERE source=$1`, source)
+	if err != nil {
+		return err
+	}
+
+	if ra, err := r.RowsAffected(); err != nil {
+		return err
+	} else if ra == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
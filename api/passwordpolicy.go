@@ -0,0 +1,165 @@
+package api
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Password policy rules reported in ErrInvalidArg.Rules when a secret violates them.
+const (
+	PasswordRuleMinLength     = "min_length"
+	PasswordRuleMaxLength     = "max_length"
+	PasswordRuleLower         = "lower"
+	PasswordRuleUpper         = "upper"
+	PasswordRuleDigit         = "digit"
+	PasswordRuleSymbol        = "symbol"
+	PasswordRuleDenylist      = "denylist"
+	PasswordRuleContainsLogin = "contains_login"
+	PasswordRuleContainsAttr  = "contains_attr"
+)
+
+// minPasswordSubstringLength is the shortest login or attribute value which is looked for in secrets. Shorter values
+// would reject too many good secrets by chance.
+const minPasswordSubstringLength = 3
+
+// PasswordPolicy defines the requirements entity secrets must meet. The zero value accepts any non-empty secret.
+type PasswordPolicy struct {
+	// MinLength and MaxLength are measured in characters, zero means no limit
+	MinLength int
+	MaxLength int
+	// MaxBytes limits the length of a secret in bytes, e.g. to what the hasher accepts, zero means no limit
+	MaxBytes int
+
+	RequireLower  bool
+	RequireUpper  bool
+	RequireDigit  bool
+	RequireSymbol bool
+
+	// Denylist contains lowercase secrets which are not allowed regardless of the case
+	Denylist map[string]struct{}
+
+	// DisallowLogin forbids secrets containing the entity login regardless of the case
+	DisallowLogin bool
+	// DisallowAttrs forbids secrets containing any of the entity attribute values regardless of the case
+	DisallowAttrs bool
+}
+
+// Check returns the rules violated by a secret of an entity having the login and attrs. The rules are sorted.
+func (p PasswordPolicy) Check(secret, login string, attrs Attrs) []string {
+	var (
+		rules                       []string
+		lower, upper, digit, symbol bool
+	)
+
+	length := utf8.RuneCountInString(secret)
+	if p.MinLength > 0 && length < p.MinLength {
+		rules = append(rules, PasswordRuleMinLength)
+	}
+	if (p.MaxLength > 0 && length > p.MaxLength) || (p.MaxBytes > 0 && len(secret) > p.MaxBytes) {
+		rules = append(rules, PasswordRuleMaxLength)
+	}
+
+	for _, r := range secret {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			symbol = true
+		}
+	}
+
+	if p.RequireLower && !lower {
+		rules = append(rules, PasswordRuleLower)
+	}
+	if p.RequireUpper && !upper {
+		rules = append(rules, PasswordRuleUpper)
+	}
+	if p.RequireDigit && !digit {
+		rules = append(rules, PasswordRuleDigit)
+	}
+	if p.RequireSymbol && !symbol {
+		rules = append(rules, PasswordRuleSymbol)
+	}
+
+	lowerSecret := strings.ToLower(secret)
+
+	if _, ok := p.Denylist[lowerSecret]; ok {
+		rules = append(rules, PasswordRuleDenylist)
+	}
+
+	if p.DisallowLogin && containsFold(lowerSecret, login) {
+		rules = append(rules, PasswordRuleContainsLogin)
+	}
+
+	if p.DisallowAttrs {
+		for _, v := range attrs {
+			if containsFold(lowerSecret, v) {
+				rules = append(rules, PasswordRuleContainsAttr)
+				break
+			}
+		}
+	}
+
+	sort.Strings(rules)
+
+	return rules
+}
+
+// checkSecret returns ErrInvalidArg listing the violated rules if secret does not meet the policy.
+func (p PasswordPolicy) checkSecret(secret, login string, attrs Attrs) error {
+	if secret == "" {
+		return ErrInvalidArg{Msg: "empty secret"}
+	}
+
+	rules := p.Check(secret, login, attrs)
+	if len(rules) != 0 {
+		return ErrInvalidArg{Msg: "weak secret: " + strings.Join(rules, ", "), Rules: rules}
+	}
+
+	return nil
+}
+
+// containsFold reports whether lowerS contains substr regardless of the case. Too short substrings are ignored.
+func containsFold(lowerS, substr string) bool {
+	if utf8.RuneCountInString(substr) < minPasswordSubstringLength {
+		return false
+	}
+
+	return strings.Contains(lowerS, strings.ToLower(substr))
+}
+
+// LoadPasswordDenylist loads secrets which are not allowed from a file containing one secret per line. Empty lines and
+// lines starting with '#' are skipped.
+func LoadPasswordDenylist(path string) (map[string]struct{}, error) {
+	fp, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+
+	r := make(map[string]struct{})
+
+	sc := bufio.NewScanner(fp)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		r[strings.ToLower(line)] = struct{}{}
+	}
+
+	if err = sc.Err(); err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+
+	return r, nil
+}
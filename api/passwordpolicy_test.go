@@ -0,0 +1,81 @@
+package api_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/ashep/a23n/api"
+)
+
+type PasswordPolicyTestSuite struct {
+	suite.Suite
+}
+
+func (s *PasswordPolicyTestSuite) TestZeroValue() {
+	s.Assert().Empty(api.PasswordPolicy{}.Check("a", "a", api.Attrs{"name": "a"}))
+}
+
+func (s *PasswordPolicyTestSuite) TestLength() {
+	p := api.PasswordPolicy{MinLength: 3, MaxLength: 4}
+
+	s.Assert().Equal([]string{api.PasswordRuleMinLength}, p.Check("ab", "", nil))
+	s.Assert().Empty(p.Check("абв", "", nil))
+	s.Assert().Empty(p.Check("abcd", "", nil))
+	s.Assert().Equal([]string{api.PasswordRuleMaxLength}, p.Check("abcde", "", nil))
+}
+
+func (s *PasswordPolicyTestSuite) TestMaxBytes() {
+	p := api.PasswordPolicy{MaxLength: 4, MaxBytes: 6}
+
+	s.Assert().Empty(p.Check("абв", "", nil))
+	s.Assert().Equal([]string{api.PasswordRuleMaxLength}, p.Check("абвг", "", nil))
+}
+
+func (s *PasswordPolicyTestSuite) TestCharacterClasses() {
+	p := api.PasswordPolicy{RequireLower: true, RequireUpper: true, RequireDigit: true, RequireSymbol: true}
+
+	s.Assert().Equal([]string{
+		api.PasswordRuleDigit,
+		api.PasswordRuleLower,
+		api.PasswordRuleSymbol,
+		api.PasswordRuleUpper,
+	}, p.Check(" ", "", nil))
+	s.Assert().Equal([]string{api.PasswordRuleDigit, api.PasswordRuleSymbol}, p.Check("aB", "", nil))
+	s.Assert().Empty(p.Check("aB1+", "", nil))
+	s.Assert().Empty(p.Check("яЁ1!", "", nil))
+}
+
+func (s *PasswordPolicyTestSuite) TestDenylist() {
+	p := api.PasswordPolicy{Denylist: map[string]struct{}{"password1": {}}}
+
+	s.Assert().Equal([]string{api.PasswordRuleDenylist}, p.Check("PassWord1", "", nil))
+	s.Assert().Empty(p.Check("password12", "", nil))
+}
+
+func (s *PasswordPolicyTestSuite) TestLoginAndAttrs() {
+	p := api.PasswordPolicy{DisallowLogin: true, DisallowAttrs: true}
+	attrs := api.Attrs{"name": "Alice", "short": "al"}
+
+	s.Assert().Equal([]string{api.PasswordRuleContainsLogin}, p.Check("my-BOB-secret", "bob", attrs))
+	s.Assert().Equal([]string{api.PasswordRuleContainsAttr}, p.Check("aliceSecret", "bob", attrs))
+	s.Assert().Empty(p.Check("alSecret", "bo", attrs))
+}
+
+func (s *PasswordPolicyTestSuite) TestLoadPasswordDenylist() {
+	path := filepath.Join(s.T().TempDir(), "denylist.txt")
+	s.Require().NoError(os.WriteFile(path, []byte("# common secrets\n\nPassword\n  qwerty  \n"), 0o600))
+
+	r, err := api.LoadPasswordDenylist(path)
+	s.Require().NoError(err)
+	s.Assert().Equal(map[string]struct{}{"password": {}, "qwerty": {}}, r)
+
+	_, err = api.LoadPasswordDenylist(filepath.Join(s.T().TempDir(), "nonexistent.txt"))
+	s.Assert().ErrorIs(err, os.ErrNotExist)
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	suite.Run(t, new(PasswordPolicyTestSuite))
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// RefreshTokenInfo describes a registered refresh token.
+type RefreshTokenInfo struct {
+	Family   string
+	EntityID string
+}
+
+func hashToken(token string) []byte {
+	h := sha256.Sum256([]byte(token))
+	return h[:]
+}
+
+// SaveRefreshToken registers an issued refresh token, so it can be exchanged for a new token pair exactly once. All
+// the tokens issued in a chain of refreshes share the same family.
+func (a *DefaultAPI) SaveRefreshToken(ctx context.Context, token, entityID, family string, expires time.Time) error {
+	q := `INSERT INTO refresh_token (hash, family, entity_id, expires_at) VALUES ($1, $2, $3, $4)`
+	_, err := a.db.ExecContext(ctx, q, hashToken(token), family, entityID, expires)
+
+	return err
+}
+
+// UseRefreshToken marks a refresh token as used. An attempt to use a token which has been already used revokes the whole
+// token family and results in ErrTokenReused. Unknown, expired and revoked tokens result in ErrNotFound.
+func (a *DefaultAPI) UseRefreshToken(ctx context.Context, token string) (RefreshTokenInfo, error) {
+	var (
+		r    RefreshTokenInfo
+		used bool
+	)
+
+	hash := hashToken(token)
+	now := a.now()
+
+	q := `UPDATE refresh_token SET used_at=$1 WHERE hash=$2 AND used_at IS NULL AND revoked_at IS NULL AND expires_at>$1 ` +
+		`RETURNING family, entity_id`
+	err := a.db.QueryRowContext(ctx, q, now, hash).Scan(&r.Family, &r.EntityID)
+	if err == nil {
+		return r, nil
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return RefreshTokenInfo{}, err
+	}
+
+	q = `SELECT family, entity_id, used_at IS NOT NULL FROM refresh_token WHERE hash=$1`
+	err = a.db.QueryRowContext(ctx, q, hash).Scan(&r.Family, &r.EntityID, &used)
+	if errors.Is(err, sql.ErrNoRows) {
+		return RefreshTokenInfo{}, ErrNotFound
+	} else if err != nil {
+		return RefreshTokenInfo{}, err
+	} else if !used {
+		return RefreshTokenInfo{}, ErrNotFound
+	}
+
+	q = `UPDATE refresh_token SET revoked_at=$1 WHERE family=$2 AND revoked_at IS NULL`
+	if _, err = a.db.ExecContext(ctx, q, now, r.Family); err != nil {
+		return RefreshTokenInfo{}, err
+	}
+
+	return r, ErrTokenReused
+}
+
+// IsRefreshTokenActive checks whether a refresh token can still be used: it is registered and has been neither used,
+// nor revoked, nor has it expired.
+func (a *DefaultAPI) IsRefreshTokenActive(ctx context.Context, token string) (bool, error) {
+	var active bool
+
+	q := `SELECT EXISTS (SELECT 1 FROM refresh_token WHERE hash=$1 AND used_at IS NULL AND revoked_at IS NULL ` +
+		`AND expires_at>$2)`
+	if err := a.db.QueryRowContext(ctx, q, hashToken(token), a.now()).Scan(&active); err != nil {
+		return false, err
+	}
+
+	return active, nil
+}
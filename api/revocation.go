@@ -0,0 +1,201 @@
+package api
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// revocationCacheTTL defines how long a token found not revoked is not checked again. Tokens revoked by other
+	// processes may be accepted during this time, the ones revoked by this process are rejected immediately.
+	revocationCacheTTL = 5 * time.Second
+	// revocationCacheSize limits the number of tokens cached at once
+	revocationCacheSize = 10000
+)
+
+type revocationCacheEntry struct {
+	entityID string
+	until    time.Time
+}
+
+// revocationCache holds the IDs of tokens recently found not revoked, so parsing a token does not hit the database
+// on every request.
+type revocationCache struct {
+	mu     sync.Mutex
+	tokens map[string]revocationCacheEntry
+}
+
+func newRevocationCache() *revocationCache {
+	return &revocationCache{tokens: make(map[string]revocationCacheEntry)}
+}
+
+func (c *revocationCache) notRevoked(id string, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.tokens[id]
+
+	return ok && now.Before(e.until)
+}
+
+func (c *revocationCache) add(id, entityID string, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.tokens) >= revocationCacheSize {
+		for k, e := range c.tokens {
+			if !now.Before(e.until) {
+				delete(c.tokens, k)
+			}
+		}
+		if len(c.tokens) >= revocationCacheSize {
+			return
+		}
+	}
+
+	c.tokens[id] = revocationCacheEntry{entityID: entityID, until: now.Add(revocationCacheTTL)}
+}
+
+func (c *revocationCache) remove(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.tokens, id)
+}
+
+func (c *revocationCache) removeEntity(entityID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for k, e := range c.tokens {
+		if e.entityID == entityID {
+			delete(c.tokens, k)
+		}
+	}
+}
+
+// RevokeToken revokes a token before its expiration. Revoking a refresh token also revokes all the refresh tokens
+// of its family.
+func (a *DefaultAPI) RevokeToken(ctx context.Context, token string, clm TokenClaims) error {
+	if clm.ID == "" {
+		return ErrInvalidArg{Msg: "token has no id"}
+	}
+
+	expires := a.now()
+	if clm.ExpiresAt != nil {
+		expires = clm.ExpiresAt.Time
+	}
+
+	q := `INSERT INTO revoked_token (id, entity_id, expires_at) VALUES ($1, $2, $3) ON CONFLICT (id) DO NOTHING`
+	if _, err := a.db.ExecContext(ctx, q, clm.ID, clm.Subject, expires); err != nil {
+		return err
+	}
+	a.revocations.remove(clm.ID)
+
+	if clm.Type == TokenTypeRefresh {
+		q = `UPDATE refresh_token SET revoked_at=$1 ` +
+			`WHERE family=(SELECT family FROM refresh_token WHERE hash=$2) AND revoked_at IS NULL`
+		if _, err := a.db.ExecContext(ctx, q, a.now(), hashToken(token)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RevokeEntityTokens revokes all the tokens issued and the API keys created for an entity so far.
+func (a *DefaultAPI) RevokeEntityTokens(ctx context.Context, entityID string) error {
+	now := a.now()
+
+	r, err := a.db.ExecContext(ctx, `UPDATE entity SET tokens_revoked_at=$1 WHERE id=$2`, now, entityID)
+	if err != nil {
+		return err
+	}
+
+	if ra, err := r.RowsAffected(); err != nil {
+		return err
+	} else if ra == 0 {
+		return ErrNotFound
+	}
+	a.revocations.removeEntity(entityID)
+
+	q := `UPDATE refresh_token SET revoked_at=$1 WHERE entity_id=$2 AND revoked_at IS NULL`
+	if _, err = a.db.ExecContext(ctx, q, now, entityID); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// PurgeExpiredTokens deletes revocation entries and refresh token records which are useless because the tokens they
+// describe have expired. Failed authentication counters of request sources which are due to reset, unanswered
+// WebAuthn challenges and unused authorization codes are deleted as well.
+func (a *DefaultAPI) PurgeExpiredTokens(ctx context.Context) (int64, error) {
+	var total int64
+
+	for _, q := range []string{
+		`DELETE FROM revoked_token WHERE expires_at<=$1`,
+		`DELETE FROM refresh_token WHERE expires_at<=$1`,
+		`DELETE FROM auth_failure WHERE reset_at<=$1`,
+		`DELETE FROM webauthn_challenge WHERE expires_at<=$1`,
+		`DELETE FROM oauth_authorization_code WHERE expires_at<=$1`,
+	} {
+		r, err := a.db.ExecContext(ctx, q, a.now())
+		if err != nil {
+			return total, err
+		}
+
+		ra, err := r.RowsAffected()
+		if err != nil {
+			return total, err
+		}
+		total += ra
+	}
+
+	return total, nil
+}
+
+// isTokenRevoked checks whether a token has been revoked either by its ID or along with all the tokens of its entity.
+// Tokens of disabled and deleted entities are considered revoked as well, as are the tokens issued to deleted clients
+// for themselves by the client credentials grant. The issue time is taken with a microsecond precision from the iat_us
+// claim, so the tokens issued right before the entity tokens were revoked are rejected while an entity logging out
+// everywhere can authenticate again right away. Tokens lacking the claim are considered issued at the start of their
+// iat second. Tokens found not revoked are cached for revocationCacheTTL.
+func (a *DefaultAPI) isTokenRevoked(ctx context.Context, clm TokenClaims) (bool, error) {
+	var (
+		revoked bool
+		iat     time.Time
+	)
+
+	now := a.now()
+	if clm.ID != "" && a.revocations.notRevoked(clm.ID, now) {
+		return false, nil
+	}
+
+	if clm.IssuedAtMicro != 0 {
+		iat = time.UnixMicro(clm.IssuedAtMicro)
+	} else if clm.IssuedAt != nil {
+		iat = clm.IssuedAt.Time
+	}
+
+	q := `SELECT EXISTS (SELECT 1 FROM revoked_token WHERE id=$1) ` +
+		`OR NOT EXISTS (SELECT 1 FROM entity WHERE id=$2 AND NOT disabled ` +
+		`AND (tokens_revoked_at IS NULL OR tokens_revoked_at<=$3))`
+	args := []interface{}{clm.ID, clm.Subject, iat}
+	// Clients are the subjects of the tokens they obtain for themselves
+	if clm.ClientID != "" && clm.Subject == clm.ClientID {
+		q = `SELECT EXISTS (SELECT 1 FROM revoked_token WHERE id=$1) ` +
+			`OR NOT EXISTS (SELECT 1 FROM oauth_client WHERE id=$2)`
+		args = args[:2]
+	}
+	if err := a.db.QueryRowContext(ctx, q, args...).Scan(&revoked); err != nil {
+		return false, err
+	}
+
+	if !revoked && clm.ID != "" {
+		a.revocations.add(clm.ID, clm.Subject, now)
+	}
+
+	return revoked, nil
+}
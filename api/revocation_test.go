@@ -0,0 +1,285 @@
+package api_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/ashep/a23n/api"
+	"github.com/ashep/a23n/sqldb"
+)
+
+const revocationCheckQuery = "SELECT EXISTS (SELECT 1 FROM revoked_token WHERE id=$1) " +
+	"OR NOT EXISTS (SELECT 1 FROM entity WHERE id=$2 AND NOT disabled " +
+	"AND (tokens_revoked_at IS NULL OR tokens_revoked_at<=$3))"
+
+type RevocationTestSuite struct {
+	suite.Suite
+
+	db  *sqldb.DBMock
+	api *api.DefaultAPI
+	now time.Time
+}
+
+func (s *RevocationTestSuite) SetupTest() {
+	s.now = time.Unix(123456789, 0)
+	s.db = &sqldb.DBMock{}
+	s.api = api.NewDefault(s.db, api.Options{
+		Key:      api.NewHMACKey([]byte("abc")),
+		Issuer:   "theIssuer",
+		Audience: "theAudience",
+		Now:      func() time.Time { return s.now },
+	})
+}
+
+func (s *RevocationTestSuite) TearDownTest() {
+	s.db.AssertExpectations(s.T())
+}
+
+func (s *RevocationTestSuite) result(rowsAffected int64) *sqldb.ResultMock {
+	r := &sqldb.ResultMock{}
+	r.On("RowsAffected").Return(rowsAffected, nil)
+
+	return r
+}
+
+func (s *RevocationTestSuite) expectRevocationCheck(revoked bool) {
+	row := &sqldb.RowMock{}
+	row.On("Scan", mock.Anything).Run(func(args mock.Arguments) {
+		*args.Get(0).(*bool) = revoked
+	}).Return(nil)
+
+	s.db.On("QueryRowContext", mock.Anything, revocationCheckQuery, mock.Anything).Return(row).Once()
+}
+
+func (s *RevocationTestSuite) token() (string, api.TokenClaims) {
+	t := s.api.CreateToken(api.TokenTypeAccess, "entityID", "", nil, time.Minute)
+	ts, err := t.SignedString()
+	s.Require().NoError(err)
+
+	clm, ok := t.Claims().(api.TokenClaims)
+	s.Require().True(ok)
+
+	return ts, clm
+}
+
+func (s *RevocationTestSuite) TestRevokeTokenNoID() {
+	err := s.api.RevokeToken(context.Background(), "theToken", api.TokenClaims{})
+	s.Require().ErrorIs(err, api.ErrInvalidArg{})
+}
+
+func (s *RevocationTestSuite) TestRevokeAccessToken() {
+	s.db.
+		On(
+			"ExecContext",
+			mock.Anything,
+			"INSERT INTO revoked_token (id, entity_id, expires_at) VALUES ($1, $2, $3) ON CONFLICT (id) DO NOTHING",
+			[]interface{}{"tokenID", "entityID", s.now.Add(time.Minute)},
+		).
+		Return(&sqldb.ResultMock{}, nil)
+
+	err := s.api.RevokeToken(context.Background(), "theToken", api.TokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        "tokenID",
+			Subject:   "entityID",
+			ExpiresAt: jwt.NewNumericDate(s.now.Add(time.Minute)),
+		},
+		Type: api.TokenTypeAccess,
+	})
+	s.Require().NoError(err)
+}
+
+func (s *RevocationTestSuite) TestRevokeRefreshToken() {
+	s.db.
+		On(
+			"ExecContext",
+			mock.Anything,
+			"INSERT INTO revoked_token (id, entity_id, expires_at) VALUES ($1, $2, $3) ON CONFLICT (id) DO NOTHING",
+			[]interface{}{"tokenID", "entityID", s.now},
+		).
+		Return(&sqldb.ResultMock{}, nil)
+	s.db.
+		On(
+			"ExecContext",
+			mock.Anything,
+			"UPDATE refresh_token SET revoked_at=$1 "+
+				"WHERE family=(SELECT family FROM refresh_token WHERE hash=$2) AND revoked_at IS NULL",
+			mock.MatchedBy(func(args []interface{}) bool { return len(args) == 2 && args[0] == s.now }),
+		).
+		Return(&sqldb.ResultMock{}, nil)
+
+	err := s.api.RevokeToken(context.Background(), "theToken", api.TokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{ID: "tokenID", Subject: "entityID"},
+		Type:             api.TokenTypeRefresh,
+	})
+	s.Require().NoError(err)
+}
+
+func (s *RevocationTestSuite) TestRevokeEntityTokens() {
+	s.db.
+		On(
+			"ExecContext",
+			mock.Anything,
+			"UPDATE entity SET tokens_revoked_at=$1 WHERE id=$2",
+			[]interface{}{s.now, "entityID"},
+		).
+		Return(s.result(1), nil)
+	s.db.
+		On(
+			"ExecContext",
+			mock.Anything,
+			"UPDATE refresh_token SET revoked_at=$1 WHERE entity_id=$2 AND revoked_at IS NULL",
+			[]interface{}{s.now, "entityID"},
+		).
+		Return(&sqldb.ResultMock{}, nil)
+
+	s.Require().NoError(s.api.RevokeEntityTokens(context.Background(), "entityID"))
+}
+
+func (s *RevocationTestSuite) TestRevokeEntityTokensNotFound() {
+	s.db.
+		On("ExecContext", mock.Anything, "UPDATE entity SET tokens_revoked_at=$1 WHERE id=$2", mock.Anything).
+		Return(s.result(0), nil)
+
+	s.Require().ErrorIs(s.api.RevokeEntityTokens(context.Background(), "entityID"), api.ErrNotFound)
+}
+
+func (s *RevocationTestSuite) TestPurgeExpiredTokens() {
+	for _, q := range []string{
+		"DELETE FROM revoked_token WHERE expires_at<=$1",
+		"DELETE FROM refresh_token WHERE expires_at<=$1",
+		"DELETE FROM auth_failure WHERE reset_at<=$1",
+		"DELETE FROM webauthn_challenge WHERE expires_at<=$1",
+		"DELETE FROM oauth_authorization_code WHERE expires_at<=$1",
+	} {
+		s.db.On("ExecContext", mock.Anything, q, []interface{}{s.now}).Return(s.result(2), nil)
+	}
+
+	n, err := s.api.PurgeExpiredTokens(context.Background())
+	s.Require().NoError(err)
+	s.Assert().Equal(int64(10), n)
+}
+
+func (s *RevocationTestSuite) TestPurgeExpiredTokensError() {
+	s.db.
+		On("ExecContext", mock.Anything, "DELETE FROM revoked_token WHERE expires_at<=$1", mock.Anything).
+		Return(s.result(3), nil)
+	s.db.
+		On("ExecContext", mock.Anything, "DELETE FROM refresh_token WHERE expires_at<=$1", mock.Anything).
+		Return(&sqldb.ResultMock{}, errors.New("theError"))
+
+	n, err := s.api.PurgeExpiredTokens(context.Background())
+	s.Require().EqualError(err, "theError")
+	s.Assert().Equal(int64(3), n)
+}
+
+func (s *RevocationTestSuite) TestRevocationCheckArgs() {
+	ts, clm := s.token()
+
+	row := &sqldb.RowMock{}
+	row.On("Scan", mock.Anything).Return(nil)
+	s.db.
+		On("QueryRowContext", mock.Anything, revocationCheckQuery, []interface{}{clm.ID, "entityID", s.now}).
+		Return(row)
+
+	_, err := s.api.ParseToken(context.Background(), ts, api.TokenTypeAccess)
+	s.Require().NoError(err)
+}
+
+func (s *RevocationTestSuite) TestRevocationCheckSubSecond() {
+	// The entity tokens are revoked at 123456789.500, so the token issued in the same second, but earlier, is revoked,
+	// which is told by the database comparing the revocation time with the precise issue time
+	s.now = time.Unix(123456789, 250*int64(time.Millisecond))
+	ts, clm := s.token()
+	s.Assert().Equal(int64(123456789250000), clm.IssuedAtMicro)
+
+	row := &sqldb.RowMock{}
+	row.On("Scan", mock.Anything).Run(func(args mock.Arguments) {
+		*args.Get(0).(*bool) = true
+	}).Return(nil)
+	s.db.
+		On("QueryRowContext", mock.Anything, revocationCheckQuery,
+			[]interface{}{clm.ID, "entityID", time.UnixMicro(123456789250000)}).
+		Return(row)
+
+	s.now = time.Unix(123456789, 500*int64(time.Millisecond))
+	_, err := s.api.ParseToken(context.Background(), ts, api.TokenTypeAccess)
+	s.Require().ErrorIs(err, api.ErrTokenRevoked)
+}
+
+func (s *RevocationTestSuite) TestRevocationCheckClientToken() {
+	// Tokens issued to clients for themselves are revoked along with the client
+	t := s.api.CreateToken(api.TokenTypeAccess, "theClient", "theClient", nil, time.Minute)
+	ts, err := t.SignedString()
+	s.Require().NoError(err)
+	clm, ok := t.Claims().(api.TokenClaims)
+	s.Require().True(ok)
+
+	row := &sqldb.RowMock{}
+	row.On("Scan", mock.Anything).Run(func(args mock.Arguments) {
+		*args.Get(0).(*bool) = true
+	}).Return(nil)
+	s.db.
+		On("QueryRowContext", mock.Anything, "SELECT EXISTS (SELECT 1 FROM revoked_token WHERE id=$1) "+
+			"OR NOT EXISTS (SELECT 1 FROM oauth_client WHERE id=$2)", []interface{}{clm.ID, "theClient"}).
+		Return(row)
+
+	_, err = s.api.ParseToken(context.Background(), ts, api.TokenTypeAccess)
+	s.Require().ErrorIs(err, api.ErrTokenRevoked)
+}
+
+func (s *RevocationTestSuite) TestRevocationCheckCached() {
+	ts, _ := s.token()
+	s.expectRevocationCheck(false)
+
+	// The token is checked only once while it is cached
+	for i := 0; i < 3; i++ {
+		_, err := s.api.ParseToken(context.Background(), ts, api.TokenTypeAccess)
+		s.Require().NoError(err)
+	}
+
+	s.now = s.now.Add(time.Second * 6)
+	s.expectRevocationCheck(true)
+
+	_, err := s.api.ParseToken(context.Background(), ts, api.TokenTypeAccess)
+	s.Require().ErrorIs(err, api.ErrTokenRevoked)
+}
+
+func (s *RevocationTestSuite) TestRevokeTokenClearsCache() {
+	ts, clm := s.token()
+	s.expectRevocationCheck(false)
+
+	_, err := s.api.ParseToken(context.Background(), ts, api.TokenTypeAccess)
+	s.Require().NoError(err)
+
+	s.db.On("ExecContext", mock.Anything, mock.AnythingOfType("string"), mock.Anything).Return(&sqldb.ResultMock{}, nil)
+	s.Require().NoError(s.api.RevokeToken(context.Background(), ts, clm))
+
+	s.expectRevocationCheck(true)
+	_, err = s.api.ParseToken(context.Background(), ts, api.TokenTypeAccess)
+	s.Require().ErrorIs(err, api.ErrTokenRevoked)
+}
+
+func (s *RevocationTestSuite) TestRevokeEntityTokensClearsCache() {
+	ts, _ := s.token()
+	s.expectRevocationCheck(false)
+
+	_, err := s.api.ParseToken(context.Background(), ts, api.TokenTypeAccess)
+	s.Require().NoError(err)
+
+	s.db.On("ExecContext", mock.Anything, mock.AnythingOfType("string"), mock.Anything).Return(s.result(1), nil)
+	s.Require().NoError(s.api.RevokeEntityTokens(context.Background(), "entityID"))
+
+	s.expectRevocationCheck(true)
+	_, err = s.api.ParseToken(context.Background(), ts, api.TokenTypeAccess)
+	s.Require().ErrorIs(err, api.ErrTokenRevoked)
+}
+
+func TestDefaultAPI_Revocation(t *testing.T) {
+	suite.Run(t, new(RevocationTestSuite))
+}
@@ -17,3 +17,18 @@ func (a *DefaultAPI) CheckScope(target Scope, required Scope) bool {
 
 	return ok == len(required)
 }
+
+// Intersect returns the scopes of s which other has as well.
+func (s Scope) Intersect(other Scope) Scope {
+	r := make(Scope, 0, len(s))
+	for _, v := range s {
+		for _, ov := range other {
+			if v == ov {
+				r = append(r, v)
+				break
+			}
+		}
+	}
+
+	return r
+}
@@ -26,8 +26,8 @@ func (m *TokenMock) Claims() Claims {
 	return args.Get(0).(Claims)
 }
 
-func (m *TokenMock) SignedString(key interface{}) (string, error) {
-	args := m.Called(key)
+func (m *TokenMock) SignedString() (string, error) {
+	args := m.Called()
 	return args.String(0), args.Error(1)
 }
 
@@ -35,23 +35,33 @@ type APIMock struct {
 	mock.Mock
 }
 
-func (m *APIMock) SecretKey() string {
-	args := m.Called()
-	return args.String(0)
+func (m *APIMock) LoadKeys(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
 }
 
-func (m *APIMock) CheckSecret(hashed, secret string) (bool, error) {
-	args := m.Called(hashed, secret)
-	return args.Bool(0), args.Error(1)
+func (m *APIMock) RotateKey(
+	ctx context.Context,
+	alg string,
+	activateAfter,
+	retireAfter time.Duration,
+) (SigningKey, error) {
+	args := m.Called(ctx, alg, activateAfter, retireAfter)
+	return args.Get(0).(SigningKey), args.Error(1)
+}
+
+func (m *APIMock) JWKS() JWKSet {
+	args := m.Called()
+	return args.Get(0).(JWKSet)
 }
 
-func (m *APIMock) CreateEntity(ctx context.Context, id string, secret []byte, scope Scope, attrs Attrs) error {
-	args := m.Called(ctx, id, secret, scope, attrs)
+func (m *APIMock) CreateEntity(ctx context.Context, id, login, secret string, scope Scope, attrs Attrs) error {
+	args := m.Called(ctx, id, login, secret, scope, attrs)
 	return args.Error(0)
 }
 
-func (m *APIMock) UpdateEntity(ctx context.Context, id string, secret []byte, scope Scope, attrs Attrs) error {
-	args := m.Called(ctx, id, secret, scope, attrs)
+func (m *APIMock) UpdateEntity(ctx context.Context, id, login, secret string, scope Scope, attrs Attrs) error {
+	args := m.Called(ctx, id, login, secret, scope, attrs)
 	return args.Error(0)
 }
 
@@ -60,17 +70,268 @@ func (m *APIMock) GetEntity(ctx context.Context, id string) (Entity, error) {
 	return args.Get(0).(Entity), args.Error(1)
 }
 
+func (m *APIMock) DeleteEntity(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *APIMock) DisableEntity(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *APIMock) EnableEntity(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *APIMock) ListEntities(
+	ctx context.Context,
+	filter EntityFilter,
+	cursor string,
+	limit int,
+) ([]Entity, string, error) {
+	args := m.Called(ctx, filter, cursor, limit)
+	return args.Get(0).([]Entity), args.String(1), args.Error(2)
+}
+
+func (m *APIMock) UnlockEntity(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *APIMock) UnlockSource(ctx context.Context, source string) error {
+	args := m.Called(ctx, source)
+	return args.Error(0)
+}
+
+func (m *APIMock) VerifyCredentials(ctx context.Context, idOrLogin, secret, source string) (Entity, error) {
+	args := m.Called(ctx, idOrLogin, secret, source)
+	return args.Get(0).(Entity), args.Error(1)
+}
+
 func (m *APIMock) CheckScope(target Scope, required Scope) bool {
 	args := m.Called(target, required)
 	return args.Bool(0)
 }
 
-func (m *APIMock) CreateToken(subject string, scope []string, ttl time.Duration) Token {
-	args := m.Called(subject, scope, ttl)
+func (m *APIMock) EnrollTOTP(ctx context.Context, id string) (TOTPKey, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(TOTPKey), args.Error(1)
+}
+
+func (m *APIMock) ConfirmTOTP(ctx context.Context, id, code string) error {
+	args := m.Called(ctx, id, code)
+	return args.Error(0)
+}
+
+func (m *APIMock) VerifyTOTP(ctx context.Context, id, code, source string) error {
+	args := m.Called(ctx, id, code, source)
+	return args.Error(0)
+}
+
+func (m *APIMock) DisableTOTP(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *APIMock) BeginWebAuthnRegistration(ctx context.Context, entityID string) (WebAuthnChallenge, error) {
+	args := m.Called(ctx, entityID)
+	return args.Get(0).(WebAuthnChallenge), args.Error(1)
+}
+
+func (m *APIMock) FinishWebAuthnRegistration(
+	ctx context.Context,
+	entityID string,
+	challengeID string,
+	response []byte,
+	mfa bool,
+) (string, error) {
+	args := m.Called(ctx, entityID, challengeID, response, mfa)
+	return args.String(0), args.Error(1)
+}
+
+func (m *APIMock) BeginWebAuthnLogin(ctx context.Context) (WebAuthnChallenge, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(WebAuthnChallenge), args.Error(1)
+}
+
+func (m *APIMock) FinishWebAuthnLogin(
+	ctx context.Context,
+	challengeID string,
+	response []byte,
+	source string,
+) (Entity, bool, error) {
+	args := m.Called(ctx, challengeID, response, source)
+	return args.Get(0).(Entity), args.Bool(1), args.Error(2)
+}
+
+func (m *APIMock) CreateAPIKey(
+	ctx context.Context,
+	entityID string,
+	name string,
+	scope Scope,
+	expires time.Time,
+) (APIKey, string, error) {
+	args := m.Called(ctx, entityID, name, scope, expires)
+	return args.Get(0).(APIKey), args.String(1), args.Error(2)
+}
+
+func (m *APIMock) ListAPIKeys(ctx context.Context, entityID string) ([]APIKey, error) {
+	args := m.Called(ctx, entityID)
+	return args.Get(0).([]APIKey), args.Error(1)
+}
+
+func (m *APIMock) RevokeAPIKey(ctx context.Context, entityID, id string) error {
+	args := m.Called(ctx, entityID, id)
+	return args.Error(0)
+}
+
+func (m *APIMock) VerifyAPIKey(ctx context.Context, key string) (TokenClaims, error) {
+	args := m.Called(ctx, key)
+	return args.Get(0).(TokenClaims), args.Error(1)
+}
+
+func (m *APIMock) CreateClient(ctx context.Context, c Client) (string, error) {
+	args := m.Called(ctx, c)
+	return args.String(0), args.Error(1)
+}
+
+func (m *APIMock) GetClient(ctx context.Context, id string) (Client, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(Client), args.Error(1)
+}
+
+func (m *APIMock) ListClients(ctx context.Context) ([]Client, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]Client), args.Error(1)
+}
+
+func (m *APIMock) UpdateClient(ctx context.Context, c Client, mask []string, resetSecret bool) (string, error) {
+	args := m.Called(ctx, c, mask, resetSecret)
+	return args.String(0), args.Error(1)
+}
+
+func (m *APIMock) DeleteClient(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *APIMock) VerifyClient(ctx context.Context, id, secret string) (Client, error) {
+	args := m.Called(ctx, id, secret)
+	return args.Get(0).(Client), args.Error(1)
+}
+
+func (m *APIMock) CreateAuthorizationCode(ctx context.Context, c AuthorizationCode) (string, error) {
+	args := m.Called(ctx, c)
+	return args.String(0), args.Error(1)
+}
+
+func (m *APIMock) UseAuthorizationCode(
+	ctx context.Context,
+	code string,
+	clientID string,
+	redirectURI string,
+	verifier string,
+) (AuthorizationCode, error) {
+	args := m.Called(ctx, code, clientID, redirectURI, verifier)
+	return args.Get(0).(AuthorizationCode), args.Error(1)
+}
+
+func (m *APIMock) CreateToken(typ TokenType, subject, clientID string, scope []string, ttl time.Duration) Token {
+	args := m.Called(typ, subject, clientID, scope, ttl)
+	return args.Get(0).(Token)
+}
+
+func (m *APIMock) CreateExchangedToken(
+	subject TokenClaims,
+	actor string,
+	scope []string,
+	audience string,
+	ttl time.Duration,
+) Token {
+	args := m.Called(subject, actor, scope, audience, ttl)
 	return args.Get(0).(Token)
 }
 
-func (m *APIMock) ParseToken(t string) (TokenClaims, error) {
-	args := m.Called(t)
+func (m *APIMock) CreateIDToken(
+	clientID string,
+	subject string,
+	authTime time.Time,
+	nonce string,
+	claims map[string]interface{},
+	ttl time.Duration,
+) (Token, error) {
+	args := m.Called(clientID, subject, authTime, nonce, claims, ttl)
+	t, _ := args.Get(0).(Token)
+	return t, args.Error(1)
+}
+
+func (m *APIMock) Issuer() string {
+	args := m.Called()
+	return args.String(0)
+}
+
+func (m *APIMock) ParseToken(ctx context.Context, t string, typ TokenType) (TokenClaims, error) {
+	args := m.Called(ctx, t, typ)
 	return args.Get(0).(TokenClaims), args.Error(1)
 }
+
+func (m *APIMock) ParseTokenForAudiences(
+	ctx context.Context,
+	t string,
+	typ TokenType,
+	audiences []string,
+) (TokenClaims, error) {
+	args := m.Called(ctx, t, typ, audiences)
+	return args.Get(0).(TokenClaims), args.Error(1)
+}
+
+func (m *APIMock) RevokeToken(ctx context.Context, token string, clm TokenClaims) error {
+	args := m.Called(ctx, token, clm)
+	return args.Error(0)
+}
+
+func (m *APIMock) RevokeEntityTokens(ctx context.Context, entityID string) error {
+	args := m.Called(ctx, entityID)
+	return args.Error(0)
+}
+
+func (m *APIMock) PurgeExpiredTokens(ctx context.Context) (int64, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *APIMock) SaveRefreshToken(ctx context.Context, token, entityID, family string, expires time.Time) error {
+	args := m.Called(ctx, token, entityID, family, expires)
+	return args.Error(0)
+}
+
+func (m *APIMock) UseRefreshToken(ctx context.Context, token string) (RefreshTokenInfo, error) {
+	args := m.Called(ctx, token)
+	return args.Get(0).(RefreshTokenInfo), args.Error(1)
+}
+
+func (m *APIMock) IsRefreshTokenActive(ctx context.Context, token string) (bool, error) {
+	args := m.Called(ctx, token)
+	return args.Bool(0), args.Error(1)
+}
+
+type HasherMock struct {
+	mock.Mock
+}
+
+func (m *HasherMock) Hash(secret string) (string, error) {
+	args := m.Called(secret)
+	return args.String(0), args.Error(1)
+}
+
+func (m *HasherMock) Verify(hash, secret string) (bool, error) {
+	args := m.Called(hash, secret)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *HasherMock) NeedsRehash(hash string) bool {
+	args := m.Called(hash)
+	return args.Bool(0)
+}
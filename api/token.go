@@ -1,9 +1,22 @@
 package api
 
 import (
+	"context"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+type TokenType string
+
+const (
+	TokenTypeAccess  TokenType = "access"
+	TokenTypeRefresh TokenType = "refresh"
+	// TokenTypeMFA is a short-lived token proving that an entity has passed the first authentication factor
+	TokenTypeMFA TokenType = "mfa"
+	// TokenTypeAPIKey is the type of claims describing an API key, see VerifyAPIKey
+	TokenTypeAPIKey TokenType = "api_key"
 )
 
 type Claims interface {
@@ -12,47 +25,187 @@ type Claims interface {
 
 type Token interface {
 	Claims() Claims
-	SignedString(key interface{}) (string, error)
+	SignedString() (string, error)
+}
+
+// Actor is the entity a token has been exchanged by to act on behalf of the token subject, see RFC 8693, section 4.1.
+// Prior actors are nested.
+type Actor struct {
+	Subject string `json:"sub"`
+	Actor   *Actor `json:"act,omitempty"`
 }
 
 type TokenClaims struct {
 	jwt.RegisteredClaims
-	Scope []string `json:"scope,omitempty"`
+	Type  TokenType `json:"token_type"`
+	Scope []string  `json:"scope,omitempty"`
+	// ClientID is the OAuth 2.0 client the token has been issued to, if any
+	ClientID string `json:"client_id,omitempty"`
+	// Actor is set for tokens issued by the token exchange
+	Actor *Actor `json:"act,omitempty"`
+	// IssuedAtMicro is the issue time in microseconds, since the iat claim has a precision of one second
+	IssuedAtMicro int64 `json:"iat_us,omitempty"`
 }
 
 type DefaultToken struct {
-	t *jwt.Token
+	t   *jwt.Token
+	key interface{}
 }
 
 func (t *DefaultToken) Claims() Claims {
 	return t.t.Claims
 }
 
-func (t *DefaultToken) SignedString(key interface{}) (string, error) {
-	return t.t.SignedString(key)
+// SignedString signs the token with the key it has been created for.
+func (t *DefaultToken) SignedString() (string, error) {
+	return t.t.SignedString(t.key)
 }
 
-func (a *DefaultAPI) CreateToken(subject string, scope []string, ttl time.Duration) Token {
-	n := jwt.NewNumericDate(a.now())
+// CreateToken creates a token signed by the currently active signing key, identified by the kid header. The client ID
+// is empty unless the token is issued to an OAuth 2.0 client.
+func (a *DefaultAPI) CreateToken(typ TokenType, subject, clientID string, scope []string, ttl time.Duration) Token {
+	now := a.now()
+	n := jwt.NewNumericDate(now)
+	k := a.keys.signing()
+
+	t := jwt.NewWithClaims(k.Method, TokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			Issuer:    a.issuer,
+			Audience:  jwt.ClaimStrings{a.audience},
+			Subject:   subject,
+			IssuedAt:  n,
+			NotBefore: n,
+			ExpiresAt: jwt.NewNumericDate(n.Add(ttl)),
+		},
+		Type:          typ,
+		Scope:         scope,
+		ClientID:      clientID,
+		IssuedAtMicro: now.UnixMicro(),
+	})
+	t.Header["kid"] = k.ID
+
+	return &DefaultToken{t: t, key: k.Private}
+}
 
-	return &DefaultToken{
-		t: jwt.NewWithClaims(jwt.SigningMethodHS256, TokenClaims{
-			RegisteredClaims: jwt.RegisteredClaims{
-				Subject:   subject,
-				IssuedAt:  n,
-				NotBefore: n,
-				ExpiresAt: jwt.NewNumericDate(n.Add(ttl)),
-			},
-			Scope: scope,
-		}),
+// CreateExchangedToken creates an access token for the subject of another token, see RFC 8693. If the actor is not
+// empty, it is recorded as the current actor and the actors of the subject token become the prior ones. The token is
+// issued for the audience, the configured one if empty, and does not outlive the subject token.
+func (a *DefaultAPI) CreateExchangedToken(
+	subject TokenClaims,
+	actor string,
+	scope []string,
+	audience string,
+	ttl time.Duration,
+) Token {
+	now := a.now()
+	n := jwt.NewNumericDate(now)
+	k := a.keys.signing()
+
+	if audience == "" {
+		audience = a.audience
+	}
+
+	exp := n.Add(ttl)
+	if subject.ExpiresAt != nil && subject.ExpiresAt.Before(exp) {
+		exp = subject.ExpiresAt.Time
+	}
+
+	act := subject.Actor
+	if actor != "" {
+		act = &Actor{Subject: actor, Actor: subject.Actor}
 	}
+
+	t := jwt.NewWithClaims(k.Method, TokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			Issuer:    a.issuer,
+			Audience:  jwt.ClaimStrings{audience},
+			Subject:   subject.Subject,
+			IssuedAt:  n,
+			NotBefore: n,
+			ExpiresAt: jwt.NewNumericDate(exp),
+		},
+		Type:          TokenTypeAccess,
+		Scope:         scope,
+		ClientID:      subject.ClientID,
+		Actor:         act,
+		IssuedAtMicro: now.UnixMicro(),
+	})
+	t.Header["kid"] = k.ID
+
+	return &DefaultToken{t: t, key: k.Private}
 }
 
-func (a *DefaultAPI) ParseToken(token string) (TokenClaims, error) {
+// ParseToken parses and validates a token. Besides the signature and the time based claims, it checks that the token
+// has been issued by this service for the configured audience, that it is of the expected type and that it has not
+// been revoked. Tokens revoked by other processes may still be accepted for a few seconds, see revocationCacheTTL.
+func (a *DefaultAPI) ParseToken(ctx context.Context, token string, typ TokenType) (TokenClaims, error) {
+	return a.ParseTokenForAudiences(ctx, token, typ, nil)
+}
+
+// ParseTokenForAudiences is like ParseToken, but it accepts the tokens issued for any of audiences as well, such as the
+// ones exchanged for other services.
+func (a *DefaultAPI) ParseTokenForAudiences(
+	ctx context.Context,
+	token string,
+	typ TokenType,
+	audiences []string,
+) (TokenClaims, error) {
 	clm := TokenClaims{}
-	_, err := jwt.ParseWithClaims(token, &clm, func(token *jwt.Token) (interface{}, error) {
-		return []byte(a.secretKey), nil
-	})
+	_, err := jwt.ParseWithClaims(
+		token,
+		&clm,
+		func(token *jwt.Token) (interface{}, error) {
+			kid, _ := token.Header["kid"].(string)
+			k, err := a.verificationKey(ctx, kid)
+			if err != nil {
+				return nil, err
+			}
+
+			// Each key is bound to its algorithm to prevent algorithm confusion
+			if token.Method.Alg() != k.Method.Alg() {
+				return nil, jwt.ErrTokenSignatureInvalid
+			}
+
+			return k.Public, nil
+		},
+		jwt.WithIssuer(a.issuer),
+		jwt.WithTimeFunc(a.now),
+	)
+	if err != nil {
+		return clm, err
+	}
+
+	if !hasAudience(clm.Audience, a.audience, audiences) {
+		return clm, jwt.ErrTokenInvalidAudience
+	}
+
+	if clm.Type != typ {
+		return clm, ErrInvalidTokenType
+	}
+
+	if revoked, err := a.isTokenRevoked(ctx, clm); err != nil {
+		return clm, err
+	} else if revoked {
+		return clm, ErrTokenRevoked
+	}
+
+	return clm, nil
+}
+
+// hasAudience checks whether aud contains either the service's own audience or any of the others.
+func hasAudience(aud jwt.ClaimStrings, own string, others []string) bool {
+	for _, a := range aud {
+		if a == own {
+			return true
+		}
+		for _, o := range others {
+			if a == o {
+				return true
+			}
+		}
+	}
 
-	return clm, err
+	return false
 }
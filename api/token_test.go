@@ -0,0 +1,281 @@
+package api_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/ashep/a23n/api"
+	"github.com/ashep/a23n/sqldb"
+)
+
+type TokenTestSuite struct {
+	suite.Suite
+
+	db  *sqldb.DBMock
+	api *api.DefaultAPI
+	key api.SigningKey
+	now time.Time
+}
+
+func (s *TokenTestSuite) SetupTest() {
+	s.now = time.Now().Truncate(time.Second)
+	s.key = api.NewHMACKey([]byte("abc"))
+	s.db = &sqldb.DBMock{}
+	s.api = api.NewDefault(s.db, api.Options{
+		Key:      s.key,
+		Issuer:   "theIssuer",
+		Audience: "theAudience",
+		Now:      func() time.Time { return s.now },
+	})
+}
+
+func (s *TokenTestSuite) TearDownTest() {
+	s.db.AssertExpectations(s.T())
+}
+
+func (s *TokenTestSuite) expectRevocationCheck(revoked bool) {
+	row := &sqldb.RowMock{}
+	row.On("Scan", mock.Anything).Run(func(args mock.Arguments) {
+		*args.Get(0).(*bool) = revoked
+	}).Return(nil)
+
+	s.db.On(
+		"QueryRowContext",
+		mock.Anything,
+		"SELECT EXISTS (SELECT 1 FROM revoked_token WHERE id=$1) "+
+			"OR NOT EXISTS (SELECT 1 FROM entity WHERE id=$2 AND NOT disabled "+
+			"AND (tokens_revoked_at IS NULL OR tokens_revoked_at<=$3))",
+		mock.Anything,
+	).Return(row).Once()
+}
+
+func (s *TokenTestSuite) sign(t api.Token) string {
+	ts, err := t.SignedString()
+	s.Require().NoError(err)
+
+	return ts
+}
+
+func (s *TokenTestSuite) TestCreateToken() {
+	t := s.api.CreateToken(api.TokenTypeAccess, "theSubject", "theClient", []string{"theScope"}, time.Minute)
+
+	clm, ok := t.Claims().(api.TokenClaims)
+	s.Require().True(ok)
+
+	s.Assert().NotEmpty(clm.ID)
+	s.Assert().Equal("theIssuer", clm.Issuer)
+	s.Assert().Equal(jwt.ClaimStrings{"theAudience"}, clm.Audience)
+	s.Assert().Equal("theSubject", clm.Subject)
+	s.Assert().Equal(s.now, clm.IssuedAt.Time)
+	s.Assert().Equal(s.now.Add(time.Minute), clm.ExpiresAt.Time)
+	s.Assert().Equal(api.TokenTypeAccess, clm.Type)
+	s.Assert().Equal([]string{"theScope"}, clm.Scope)
+	s.Assert().Equal("theClient", clm.ClientID)
+}
+
+func (s *TokenTestSuite) TestParseTokenOK() {
+	t := s.sign(s.api.CreateToken(api.TokenTypeRefresh, "theSubject", "", []string{"theScope"}, time.Minute))
+	s.expectRevocationCheck(false)
+
+	clm, err := s.api.ParseToken(context.Background(), t, api.TokenTypeRefresh)
+	s.Require().NoError(err)
+	s.Assert().Equal("theSubject", clm.Subject)
+	s.Assert().Equal(api.TokenTypeRefresh, clm.Type)
+	s.Assert().Equal([]string{"theScope"}, clm.Scope)
+}
+
+func (s *TokenTestSuite) TestParseTokenWrongType() {
+	t := s.sign(s.api.CreateToken(api.TokenTypeRefresh, "theSubject", "", nil, time.Minute))
+
+	_, err := s.api.ParseToken(context.Background(), t, api.TokenTypeAccess)
+	s.Require().ErrorIs(err, api.ErrInvalidTokenType)
+}
+
+func (s *TokenTestSuite) TestParseTokenWrongIssuer() {
+	other := api.NewDefault(&sqldb.DBMock{}, api.Options{
+		Key:      s.key,
+		Issuer:   "otherIssuer",
+		Audience: "theAudience",
+		Now:      func() time.Time { return s.now },
+	})
+	t := s.sign(other.CreateToken(api.TokenTypeAccess, "theSubject", "", nil, time.Minute))
+
+	_, err := s.api.ParseToken(context.Background(), t, api.TokenTypeAccess)
+	s.Require().ErrorIs(err, jwt.ErrTokenInvalidIssuer)
+}
+
+func (s *TokenTestSuite) TestParseTokenWrongAudience() {
+	other := api.NewDefault(&sqldb.DBMock{}, api.Options{
+		Key:      s.key,
+		Issuer:   "theIssuer",
+		Audience: "otherAudience",
+		Now:      func() time.Time { return s.now },
+	})
+	t := s.sign(other.CreateToken(api.TokenTypeAccess, "theSubject", "", nil, time.Minute))
+
+	_, err := s.api.ParseToken(context.Background(), t, api.TokenTypeAccess)
+	s.Require().ErrorIs(err, jwt.ErrTokenInvalidAudience)
+}
+
+func (s *TokenTestSuite) TestParseTokenForAudiences() {
+	subject := api.TokenClaims{RegisteredClaims: jwt.RegisteredClaims{Subject: "theSubject"}}
+	t := s.sign(s.api.CreateExchangedToken(subject, "theActor", nil, "theBackend", time.Minute))
+
+	// Tokens exchanged for other audiences are accepted only if the audience is listed
+	_, err := s.api.ParseToken(context.Background(), t, api.TokenTypeAccess)
+	s.Require().ErrorIs(err, jwt.ErrTokenInvalidAudience)
+	_, err = s.api.ParseTokenForAudiences(context.Background(), t, api.TokenTypeAccess, []string{"otherBackend"})
+	s.Require().ErrorIs(err, jwt.ErrTokenInvalidAudience)
+
+	s.expectRevocationCheck(false)
+	clm, err := s.api.ParseTokenForAudiences(context.Background(), t, api.TokenTypeAccess,
+		[]string{"otherBackend", "theBackend"})
+	s.Require().NoError(err)
+	s.Assert().Equal("theSubject", clm.Subject)
+
+	// The service's own audience is accepted as well
+	s.expectRevocationCheck(false)
+	t = s.sign(s.api.CreateToken(api.TokenTypeAccess, "theSubject", "", nil, time.Minute))
+	_, err = s.api.ParseTokenForAudiences(context.Background(), t, api.TokenTypeAccess, []string{"theBackend"})
+	s.Require().NoError(err)
+}
+
+func (s *TokenTestSuite) TestParseTokenExpired() {
+	t := s.sign(s.api.CreateToken(api.TokenTypeAccess, "theSubject", "", nil, time.Minute))
+	s.now = s.now.Add(time.Hour)
+
+	_, err := s.api.ParseToken(context.Background(), t, api.TokenTypeAccess)
+	s.Require().ErrorIs(err, jwt.ErrTokenExpired)
+}
+
+func (s *TokenTestSuite) TestParseTokenRevoked() {
+	t := s.sign(s.api.CreateToken(api.TokenTypeAccess, "theSubject", "", nil, time.Minute))
+	s.expectRevocationCheck(true)
+
+	_, err := s.api.ParseToken(context.Background(), t, api.TokenTypeAccess)
+	s.Require().ErrorIs(err, api.ErrTokenRevoked)
+}
+
+func (s *TokenTestSuite) TestCreateExchangedToken() {
+	subject := api.TokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "theSubject",
+			ExpiresAt: jwt.NewNumericDate(s.now.Add(time.Hour)),
+		},
+		ClientID: "theClient",
+		Actor:    &api.Actor{Subject: "priorActor"},
+	}
+	t := s.api.CreateExchangedToken(subject, "theActor", []string{"theScope"}, "theBackend", time.Minute)
+
+	clm, ok := t.Claims().(api.TokenClaims)
+	s.Require().True(ok)
+
+	s.Assert().NotEmpty(clm.ID)
+	s.Assert().Equal(jwt.ClaimStrings{"theBackend"}, clm.Audience)
+	s.Assert().Equal("theSubject", clm.Subject)
+	s.Assert().Equal(s.now.Add(time.Minute), clm.ExpiresAt.Time)
+	s.Assert().Equal(api.TokenTypeAccess, clm.Type)
+	s.Assert().Equal([]string{"theScope"}, clm.Scope)
+	s.Assert().Equal("theClient", clm.ClientID)
+	s.Assert().Equal(&api.Actor{Subject: "theActor", Actor: &api.Actor{Subject: "priorActor"}}, clm.Actor)
+}
+
+func (s *TokenTestSuite) TestCreateExchangedTokenNotOutlivingSubject() {
+	subject := api.TokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "theSubject",
+			ExpiresAt: jwt.NewNumericDate(s.now.Add(time.Second * 30)),
+		},
+	}
+	t := s.api.CreateExchangedToken(subject, "", nil, "", time.Minute)
+
+	clm, ok := t.Claims().(api.TokenClaims)
+	s.Require().True(ok)
+
+	s.Assert().Equal(jwt.ClaimStrings{"theAudience"}, clm.Audience)
+	s.Assert().Equal(s.now.Add(time.Second*30), clm.ExpiresAt.Time)
+	s.Assert().Nil(clm.Actor)
+}
+
+// oidcAPI returns an API signing tokens by an asymmetric key and having iss as the issuer.
+func (s *TokenTestSuite) oidcAPI(iss string) (*api.DefaultAPI, api.SigningKey) {
+	k, err := api.GenerateSigningKey("ES256")
+	s.Require().NoError(err)
+
+	a := api.NewDefault(s.db, api.Options{
+		Key:      k,
+		Issuer:   iss,
+		Audience: "theAudience",
+		Now:      func() time.Time { return s.now },
+	})
+
+	return a, k
+}
+
+func (s *TokenTestSuite) TestCreateIDToken() {
+	a, k := s.oidcAPI("https://auth.example.com")
+
+	t, err := a.CreateIDToken("theClient", "theSubject", s.now.Add(-time.Minute), "theNonce", map[string]interface{}{
+		"email": "user@example.com",
+		"iss":   "evilIssuer",
+	}, time.Minute)
+	s.Require().NoError(err)
+
+	clm := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(s.sign(t), clm, func(*jwt.Token) (interface{}, error) {
+		return k.Public, nil
+	}, jwt.WithTimeFunc(func() time.Time { return s.now }))
+	s.Require().NoError(err)
+
+	// Entity claims do not override the standard ones
+	s.Assert().Equal(jwt.MapClaims{
+		"iss":       "https://auth.example.com",
+		"sub":       "theSubject",
+		"aud":       []interface{}{"theClient"},
+		"iat":       float64(s.now.Unix()),
+		"exp":       float64(s.now.Add(time.Minute).Unix()),
+		"auth_time": float64(s.now.Add(-time.Minute).Unix()),
+		"nonce":     "theNonce",
+		"email":     "user@example.com",
+	}, clm)
+}
+
+func (s *TokenTestSuite) TestCreateIDTokenUnsupported() {
+	// Clients cannot verify tokens signed by a shared secret without being able to forge them
+	_, err := s.api.CreateIDToken("theClient", "theSubject", s.now, "", nil, time.Minute)
+	s.Require().ErrorIs(err, api.ErrIDTokenUnsupported)
+
+	for _, iss := range []string{"theIssuer", "http://auth.example.com", "https://example.com/auth"} {
+		a, _ := s.oidcAPI(iss)
+		_, err = a.CreateIDToken("theClient", "theSubject", s.now, "", nil, time.Minute)
+		s.Require().ErrorIs(err, api.ErrIDTokenUnsupported, iss)
+	}
+}
+
+func (s *TokenTestSuite) TestIsRefreshTokenActive() {
+	row := &sqldb.RowMock{}
+	row.On("Scan", mock.Anything).Run(func(args mock.Arguments) {
+		*args.Get(0).(*bool) = true
+	}).Return(nil)
+
+	s.db.On(
+		"QueryRowContext",
+		mock.Anything,
+		"SELECT EXISTS (SELECT 1 FROM refresh_token WHERE hash=$1 AND used_at IS NULL AND revoked_at IS NULL "+
+			"AND expires_at>$2)",
+		mock.MatchedBy(func(args []interface{}) bool { return len(args) == 2 && args[1] == s.now }),
+	).Return(row)
+
+	active, err := s.api.IsRefreshTokenActive(context.Background(), "theToken")
+	s.Require().NoError(err)
+	s.Assert().True(active)
+}
+
+func TestDefaultAPI_Token(t *testing.T) {
+	suite.Run(t, new(TokenTestSuite))
+}
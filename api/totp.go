@@ -0,0 +1,262 @@
+package api
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/google/uuid"
+)
+
+// TOTP parameters defined by RFC 6238 defaults, which are the only ones supported by most authenticator apps
+const (
+	totpDigits       = 6
+	totpModulus      = 1000000 // 10^totpDigits
+	totpPeriod       = 30
+	totpSecretLength = 20
+	// totpSkew is the number of periods before and after the current one whose codes are accepted as well
+	totpSkew = 1
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// TOTPKey describes a TOTP secret to be added to an authenticator app.
+type TOTPKey struct {
+	// Secret is the base32 encoded shared secret
+	Secret string
+	// URI is the otpauth:// URI which is usually shown as a QR code
+	URI string
+}
+
+// totpSecretAD returns the associated data binding an encrypted TOTP secret to its entity.
+func totpSecretAD(id string) []byte {
+	return []byte("totp:" + id)
+}
+
+// totpCode returns the code of a time step according to RFC 4226.
+func totpCode(secret []byte, step int64) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, uint64(step))
+
+	m := hmac.New(sha1.New, secret)
+	m.Write(msg)
+	sum := m.Sum(nil)
+
+	off := sum[len(sum)-1] & 0x0f
+	v := binary.BigEndian.Uint32(sum[off:off+4]) & 0x7fffffff
+
+	return fmt.Sprintf("%0*d", totpDigits, v%totpModulus)
+}
+
+// matchTOTP returns the time step a code has been generated for if it is within the allowed skew.
+func (a *DefaultAPI) matchTOTP(secret []byte, code string) (int64, bool) {
+	if len(code) != totpDigits {
+		return 0, false
+	}
+
+	now := a.now().Unix() / totpPeriod
+	for step := now - totpSkew; step <= now+totpSkew; step++ {
+		if subtle.ConstantTimeCompare([]byte(totpCode(secret, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+
+	return 0, false
+}
+
+// EnrollTOTP generates a new TOTP secret for an entity. The secret takes effect only after it is confirmed by
+// ConfirmTOTP, so enrolling again replaces a previously enabled secret without disabling the second factor meanwhile.
+// Callers must make sure the entity has passed its current second factor, if any, before replacing it. The secret is
+// stored encrypted if an Encrypter is configured.
+func (a *DefaultAPI) EnrollTOTP(ctx context.Context, id string) (TOTPKey, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return TOTPKey{}, ErrInvalidArg{Msg: fmt.Sprintf("invalid id: %s", err.Error())}
+	}
+
+	e, err := a.GetEntity(ctx, id)
+	if err != nil {
+		return TOTPKey{}, err
+	}
+
+	secret := make([]byte, totpSecretLength)
+	if _, err = rand.Read(secret); err != nil {
+		return TOTPKey{}, fmt.Errorf("generate secret: %w", err)
+	}
+
+	stored, err := a.enc.encrypt(secret, totpSecretAD(id))
+	if err != nil {
+		return TOTPKey{}, fmt.Errorf("encrypt secret: %w", err)
+	}
+
+	r, err := a.db.ExecContext(ctx, `UPDATE entity SET totp_pending_secret=$1 WHERE id=$2`, stored, id)
+	if err != nil {
+		return TOTPKey{}, err
+	}
+
+	if ra, err := r.RowsAffected(); err != nil {
+		return TOTPKey{}, err
+	} else if ra == 0 {
+		return TOTPKey{}, ErrNotFound
+	}
+
+	account := e.Login
+	if account == "" {
+		account = e.ID
+	}
+
+	key := TOTPKey{Secret: totpEncoding.EncodeToString(secret)}
+	key.URI = (&url.URL{
+		Scheme: "otpauth",
+		Host:   "totp",
+		Path:   "/" + a.issuer + ":" + account,
+		RawQuery: url.Values{
+			"secret":    {key.Secret},
+			"issuer":    {a.issuer},
+			"algorithm": {"SHA1"},
+			"digits":    {strconv.Itoa(totpDigits)},
+			"period":    {strconv.Itoa(totpPeriod)},
+		}.Encode(),
+	}).String()
+
+	return key, nil
+}
+
+// ConfirmTOTP enables the TOTP secret generated by EnrollTOTP if code matches it. ErrInvalidCredentials is returned
+// if it does not.
+func (a *DefaultAPI) ConfirmTOTP(ctx context.Context, id, code string) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return ErrInvalidArg{Msg: fmt.Sprintf("invalid id: %s", err.Error())}
+	}
+
+	var stored []byte
+
+	err := a.db.QueryRowContext(ctx, `SELECT totp_pending_secret FROM entity WHERE id=$1`, id).Scan(&stored)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	} else if err != nil {
+		return err
+	}
+
+	if len(stored) == 0 {
+		return ErrInvalidArg{Msg: "totp enrollment is not started"}
+	}
+
+	secret, err := a.enc.decrypt(stored, totpSecretAD(id))
+	if err != nil {
+		return fmt.Errorf("decrypt secret: %w", err)
+	}
+
+	step, ok := a.matchTOTP(secret, code)
+	if !ok {
+		return ErrInvalidCredentials
+	}
+
+	// The pending secret is checked once again in case it has been replaced by a concurrent enrollment
+	q := `UPDATE entity SET totp_secret=totp_pending_secret, totp_pending_secret=NULL, totp_last_step=$1, ` +
+		`updated_at=$2 WHERE id=$3 AND totp_pending_secret=$4`
+	r, err := a.db.ExecContext(ctx, q, step, a.now(), id, stored)
+	if err != nil {
+		return err
+	}
+
+	if ra, err := r.RowsAffected(); err != nil {
+		return err
+	} else if ra == 0 {
+		return ErrInvalidCredentials
+	}
+
+	return nil
+}
+
+// VerifyTOTP checks the TOTP code of an entity which has already passed VerifyCredentials. Each code is accepted only
+// once. Wrong codes are counted as failed authentication attempts and lead to lockouts the same way wrong secrets do,
+// and a correct one resets the failures counter of the entity.
+func (a *DefaultAPI) VerifyTOTP(ctx context.Context, id, code, source string) error {
+	var (
+		secret      []byte
+		lastStep    sql.NullInt64
+		lockedUntil sql.NullTime
+	)
+
+	if err := a.checkSourceLock(ctx, source); err != nil {
+		return err
+	}
+
+	q := `SELECT totp_secret, totp_last_step, locked_until FROM entity WHERE id=$1`
+	err := a.db.QueryRowContext(ctx, q, id).Scan(&secret, &lastStep, &lockedUntil)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrInvalidCredentials
+	} else if err != nil {
+		return err
+	}
+
+	if len(secret) == 0 {
+		return ErrInvalidArg{Msg: "totp is not enabled"}
+	}
+
+	if secret, err = a.enc.decrypt(secret, totpSecretAD(id)); err != nil {
+		return fmt.Errorf("decrypt secret: %w", err)
+	}
+
+	if lockedUntil.Valid && lockedUntil.Time.After(a.now()) {
+		return ErrLocked{Until: lockedUntil.Time}
+	}
+
+	step, ok := a.matchTOTP(secret, code)
+	if !ok || (lastStep.Valid && step <= lastStep.Int64) {
+		if err = a.recordEntityFailure(ctx, id); err != nil {
+			return err
+		}
+		if err = a.recordSourceFailure(ctx, source); err != nil {
+			return err
+		}
+
+		return ErrInvalidCredentials
+	}
+
+	// The last step is checked once again in case the same code has been used concurrently
+	q = `UPDATE entity SET totp_last_step=$1, failed_auth_count=0, failed_auth_reset_at=NULL, locked_until=NULL ` +
+		`WHERE id=$2 AND (totp_last_step IS NULL OR totp_last_step<$1)`
+	r, err := a.db.ExecContext(ctx, q, step, id)
+	if err != nil {
+		return err
+	}
+
+	if ra, err := r.RowsAffected(); err != nil {
+		return err
+	} else if ra == 0 {
+		return ErrInvalidCredentials
+	}
+
+	return nil
+}
+
+// DisableTOTP disables the second authentication factor of an entity, e.g. if its authenticator device has been lost.
+func (a *DefaultAPI) DisableTOTP(ctx context.Context, id string) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return ErrInvalidArg{Msg: fmt.Sprintf("invalid id: %s", err.Error())}
+	}
+
+	q := `UPDATE entity SET totp_secret=NULL, totp_pending_secret=NULL, totp_last_step=NULL, updated_at=$1 WHERE id=$2`
+	r, err := a.db.ExecContext(ctx, q, a.now(), id)
+	if err != nil {
+		return err
+	}
+
+	if ra, err := r.RowsAffected(); err != nil {
+		return err
+	} else if ra == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
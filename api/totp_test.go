@@ -0,0 +1,287 @@
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"database/sql"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+
+	"github.com/ashep/a23n/api"
+	"github.com/ashep/a23n/sqldb"
+)
+
+// testTOTPSecret is the secret of RFC 6238 test vectors. Its codes at the time the suite is set to are 609366,
+// 781240 and 995887 for the previous, current and next steps.
+var testTOTPSecret = []byte("12345678901234567890")
+
+const testTOTPStep = int64(123456789 / 30)
+
+// expectTOTPQuery expects the TOTP state of the entity to be queried.
+func (s *EntityTestSuite) expectTOTPQuery(secret []byte, lastStep int64, lockedUntil time.Time) {
+	row := &sqldb.RowMock{}
+	row.On("Scan", mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			*args.Get(0).(*[]byte) = secret
+			*args.Get(1).(*sql.NullInt64) = sql.NullInt64{Int64: lastStep, Valid: lastStep != 0}
+			*args.Get(2).(*sql.NullTime) = sql.NullTime{Time: lockedUntil, Valid: !lockedUntil.IsZero()}
+		}).
+		Return(nil)
+
+	s.db.
+		On("QueryRowContext", mock.Anything, "SELECT totp_secret, totp_last_step, locked_until FROM entity WHERE id=$1",
+			[]interface{}{"2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d"}).
+		Return(row)
+}
+
+// expectPendingTOTPQuery expects the pending TOTP secret of the entity to be queried.
+func (s *EntityTestSuite) expectPendingTOTPQuery(secret []byte) {
+	row := &sqldb.RowMock{}
+	row.On("Scan", mock.Anything).Run(func(args mock.Arguments) { *args.Get(0).(*[]byte) = secret }).Return(nil)
+
+	s.db.
+		On("QueryRowContext", mock.Anything, "SELECT totp_pending_secret FROM entity WHERE id=$1",
+			[]interface{}{"2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d"}).
+		Return(row)
+}
+
+func (s *EntityTestSuite) TestEnrollTOTPInvalidID() {
+	_, err := s.api.EnrollTOTP(context.Background(), "notAUUID")
+	s.Require().ErrorIs(err, api.ErrInvalidArg{})
+}
+
+func (s *EntityTestSuite) TestEnrollTOTPNotFound() {
+	s.expectGetEntity("", false, sql.ErrNoRows)
+
+	_, err := s.api.EnrollTOTP(context.Background(), "2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d")
+	s.Require().ErrorIs(err, api.ErrNotFound)
+}
+
+func (s *EntityTestSuite) TestEnrollTOTPOk() {
+	res := &sqldb.ResultMock{}
+	res.On("RowsAffected").Return(int64(1), nil)
+
+	s.expectGetEntity("theSecretHash", false, nil)
+	s.db.
+		On("ExecContext", mock.Anything, "UPDATE entity SET totp_pending_secret=$1 WHERE id=$2",
+			mock.MatchedBy(func(args []interface{}) bool {
+				return len(args[0].([]byte)) == 20 && args[1] == "2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d"
+			})).
+		Return(res, nil)
+
+	key, err := s.api.EnrollTOTP(context.Background(), "2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d")
+	s.Require().NoError(err)
+	s.Assert().Len(key.Secret, 32)
+	s.Assert().Equal("otpauth://totp/theIssuer:2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d"+
+		"?algorithm=SHA1&digits=6&issuer=theIssuer&period=30&secret="+key.Secret, key.URI)
+}
+
+// totpCodeAt returns the code of a secret at a time step according to RFC 4226.
+func totpCodeAt(secret []byte, step int64) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, uint64(step))
+
+	m := hmac.New(sha1.New, secret)
+	m.Write(msg)
+	sum := m.Sum(nil)
+
+	off := sum[len(sum)-1] & 0x0f
+
+	return fmt.Sprintf("%06d", (binary.BigEndian.Uint32(sum[off:off+4])&0x7fffffff)%1000000)
+}
+
+// enrollEncryptedTOTP enrolls the entity by an API encrypting TOTP secrets, returning the API, the plain secret and
+// the stored value.
+func (s *EntityTestSuite) enrollEncryptedTOTP() (*api.DefaultAPI, []byte, []byte) {
+	enc, err := api.NewEncrypter(bytes.Repeat([]byte{1}, api.EncryptionKeyLength))
+	s.Require().NoError(err)
+
+	a := api.NewDefault(s.db, api.Options{
+		Key:       api.NewHMACKey([]byte("abc")),
+		Encrypter: enc,
+		Hasher:    s.hasher,
+		Issuer:    "theIssuer",
+		Audience:  "theAudience",
+		Now:       func() time.Time { return time.Unix(123456789, 0) },
+	})
+
+	res := &sqldb.ResultMock{}
+	res.On("RowsAffected").Return(int64(1), nil)
+
+	var stored []byte
+	s.expectGetEntity("theSecretHash", false, nil)
+	s.db.
+		On("ExecContext", mock.Anything, "UPDATE entity SET totp_pending_secret=$1 WHERE id=$2",
+			mock.MatchedBy(func(args []interface{}) bool {
+				stored = args[0].([]byte)
+				return true
+			})).
+		Return(res, nil)
+
+	key, err := a.EnrollTOTP(context.Background(), "2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d")
+	s.Require().NoError(err)
+
+	secret, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(key.Secret)
+	s.Require().NoError(err)
+
+	return a, secret, stored
+}
+
+func (s *EntityTestSuite) TestTOTPSecretEncrypted() {
+	a, secret, stored := s.enrollEncryptedTOTP()
+	s.Assert().True(bytes.HasPrefix(stored, []byte("a23n:enc:v1:")))
+	s.Assert().False(bytes.Contains(stored, secret))
+
+	res := &sqldb.ResultMock{}
+	res.On("RowsAffected").Return(int64(1), nil)
+
+	// The stored value is decrypted to check the code and is moved to the enabled secret as is
+	s.expectPendingTOTPQuery(stored)
+	s.db.
+		On("ExecContext", mock.Anything, "UPDATE entity SET totp_secret=totp_pending_secret, "+
+			"totp_pending_secret=NULL, totp_last_step=$1, updated_at=$2 WHERE id=$3 AND totp_pending_secret=$4",
+			[]interface{}{testTOTPStep, time.Unix(123456789, 0), "2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d", stored}).
+		Return(res, nil)
+
+	code := totpCodeAt(secret, testTOTPStep)
+	s.Require().NoError(a.ConfirmTOTP(context.Background(), "2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d", code))
+}
+
+func (s *EntityTestSuite) TestVerifyTOTPEncryptedWithoutKey() {
+	_, _, stored := s.enrollEncryptedTOTP()
+
+	// The suite API has no encryption key configured
+	s.expectTOTPQuery(stored, 0, time.Time{})
+	err := s.api.VerifyTOTP(context.Background(), "2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d", "781240", "")
+	s.Require().ErrorIs(err, api.ErrNoEncryptionKey)
+}
+
+func (s *EntityTestSuite) TestConfirmTOTPNotStarted() {
+	s.expectPendingTOTPQuery(nil)
+
+	err := s.api.ConfirmTOTP(context.Background(), "2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d", "781240")
+	s.Require().EqualError(err, "totp enrollment is not started")
+}
+
+func (s *EntityTestSuite) TestConfirmTOTPInvalidCode() {
+	s.expectPendingTOTPQuery(testTOTPSecret)
+
+	// The code of two steps ago is out of the allowed skew
+	err := s.api.ConfirmTOTP(context.Background(), "2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d", "145229")
+	s.Require().ErrorIs(err, api.ErrInvalidCredentials)
+}
+
+func (s *EntityTestSuite) TestConfirmTOTPOk() {
+	res := &sqldb.ResultMock{}
+	res.On("RowsAffected").Return(int64(1), nil)
+
+	s.expectPendingTOTPQuery(testTOTPSecret)
+	s.db.
+		On("ExecContext", mock.Anything, "UPDATE entity SET totp_secret=totp_pending_secret, "+
+			"totp_pending_secret=NULL, totp_last_step=$1, updated_at=$2 WHERE id=$3 AND totp_pending_secret=$4",
+			[]interface{}{
+				testTOTPStep + 1,
+				time.Unix(123456789, 0),
+				"2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d",
+				testTOTPSecret,
+			}).
+		Return(res, nil)
+
+	s.Require().NoError(s.api.ConfirmTOTP(context.Background(), "2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d", "995887"))
+}
+
+func (s *EntityTestSuite) TestVerifyTOTPNotEnabled() {
+	s.expectTOTPQuery(nil, 0, time.Time{})
+
+	err := s.api.VerifyTOTP(context.Background(), "2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d", "781240", "")
+	s.Require().ErrorIs(err, api.ErrInvalidArg{})
+}
+
+func (s *EntityTestSuite) TestVerifyTOTPLocked() {
+	s.expectTOTPQuery(testTOTPSecret, 0, time.Unix(123456789, 0).Add(time.Minute))
+
+	err := s.api.VerifyTOTP(context.Background(), "2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d", "781240", "")
+	s.Require().Equal(api.ErrLocked{Until: time.Unix(123456789, 0).Add(time.Minute)}, err)
+}
+
+func (s *EntityTestSuite) TestVerifyTOTPInvalidCode() {
+	s.expectSourceLock("192.0.2.1", time.Time{})
+	s.expectTOTPQuery(testTOTPSecret, 0, time.Time{})
+	s.expectEntityFailure(1, nil)
+	s.expectSourceFailure("192.0.2.1", 1)
+
+	err := s.api.VerifyTOTP(context.Background(), "2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d", "000000", "192.0.2.1")
+	s.Require().ErrorIs(err, api.ErrInvalidCredentials)
+}
+
+func (s *EntityTestSuite) TestVerifyTOTPReused() {
+	s.expectTOTPQuery(testTOTPSecret, testTOTPStep, time.Time{})
+	s.expectEntityFailure(1, nil)
+
+	err := s.api.VerifyTOTP(context.Background(), "2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d", "609366", "")
+	s.Require().ErrorIs(err, api.ErrInvalidCredentials)
+}
+
+func (s *EntityTestSuite) TestVerifyTOTPOk() {
+	res := &sqldb.ResultMock{}
+	res.On("RowsAffected").Return(int64(1), nil)
+
+	s.expectTOTPQuery(testTOTPSecret, testTOTPStep-1, time.Time{})
+	s.db.
+		On("ExecContext", mock.Anything, "UPDATE entity SET totp_last_step=$1, failed_auth_count=0, "+
+			"failed_auth_reset_at=NULL, locked_until=NULL WHERE id=$2 AND "+
+			"(totp_last_step IS NULL OR totp_last_step<$1)",
+			[]interface{}{testTOTPStep, "2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d"}).
+		Return(res, nil)
+
+	err := s.api.VerifyTOTP(context.Background(), "2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d", "781240", "")
+	s.Require().NoError(err)
+}
+
+func (s *EntityTestSuite) TestVerifyCredentialsTOTPEnabled() {
+	row := &sqldb.RowMock{}
+	row.On("Scan", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything,
+		mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			*args.Get(0).(*string) = "2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d"
+			*args.Get(2).(*string) = "theSecretHash"
+			*args.Get(4).(*[]byte) = []byte("{}")
+			*args.Get(10).(*int) = 2
+			*args.Get(12).(*bool) = true
+		}).
+		Return(nil)
+	s.db.On("QueryRowContext", mock.Anything, mock.AnythingOfType("string"), mock.Anything).Return(row)
+	s.hasher.On("Verify", "theSecretHash", "theSecret").Return(true, nil)
+	s.hasher.On("NeedsRehash", "theSecretHash").Return(false)
+
+	// Failures are not reset until the TOTP code is verified
+	s.db.
+		On("ExecContext", mock.Anything, "UPDATE entity SET last_authenticated_at=$1 WHERE id=$2",
+			[]interface{}{time.Unix(123456789, 0), "2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d"}).
+		Return(&sqldb.ResultMock{}, nil)
+
+	e, err := s.api.VerifyCredentials(context.Background(), "2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d", "theSecret", "")
+	s.Require().NoError(err)
+	s.Assert().True(e.TOTPEnabled)
+	s.Assert().Equal(2, e.FailedAuthCount)
+}
+
+func (s *EntityTestSuite) TestDisableTOTPNotFound() {
+	res := &sqldb.ResultMock{}
+	res.On("RowsAffected").Return(int64(0), nil)
+
+	s.db.
+		On("ExecContext", mock.Anything, "UPDATE entity SET totp_secret=NULL, totp_pending_secret=NULL, "+
+			"totp_last_step=NULL, updated_at=$1 WHERE id=$2",
+			[]interface{}{time.Unix(123456789, 0), "2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d"}).
+		Return(res, nil)
+
+	err := s.api.DisableTOTP(context.Background(), "2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d")
+	s.Require().ErrorIs(err, api.ErrNotFound)
+}
@@ -0,0 +1,368 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// webAuthnChallengeTTL is the time a WebAuthn ceremony must be finished within
+const webAuthnChallengeTTL = 5 * time.Minute
+
+// WebAuthn ceremonies a challenge can be issued for
+const (
+	webAuthnRegistration = "registration"
+	webAuthnLogin        = "login"
+)
+
+// WebAuthnChallenge describes a started WebAuthn ceremony.
+type WebAuthnChallenge struct {
+	// ID identifies the ceremony when it is being finished
+	ID string
+	// Options is the JSON encoded argument to be passed to navigator.credentials.create() or
+	// navigator.credentials.get()
+	Options json.RawMessage
+}
+
+// webAuthnUser adapts an entity along with its registered credentials to webauthn.User. The entity ID is used as the
+// user handle, so discoverable credentials identify their entity by themselves.
+type webAuthnUser struct {
+	e     Entity
+	creds []webauthn.Credential
+	// mfa holds IDs of the credentials registered by the entity having passed MFA
+	mfa map[string]bool
+}
+
+func (u webAuthnUser) WebAuthnID() []byte {
+	return []byte(u.e.ID)
+}
+
+func (u webAuthnUser) WebAuthnName() string {
+	if u.e.Login != "" {
+		return u.e.Login
+	}
+
+	return u.e.ID
+}
+
+func (u webAuthnUser) WebAuthnDisplayName() string {
+	return u.WebAuthnName()
+}
+
+func (u webAuthnUser) WebAuthnCredentials() []webauthn.Credential {
+	return u.creds
+}
+
+func (u webAuthnUser) WebAuthnIcon() string {
+	return ""
+}
+
+// getWebAuthnUser returns an entity along with its registered credentials.
+func (a *DefaultAPI) getWebAuthnUser(ctx context.Context, id string) (webAuthnUser, error) {
+	e, err := a.GetEntity(ctx, id)
+	if err != nil {
+		return webAuthnUser{}, err
+	}
+
+	q := `SELECT id, public_key, attestation_type, transport, aaguid, sign_count, backup_eligible, backup_state, ` +
+		`mfa FROM webauthn_credential WHERE entity_id=$1`
+	rows, err := a.db.QueryContext(ctx, q, id)
+	if err != nil {
+		return webAuthnUser{}, err
+	}
+	defer rows.Close()
+
+	u := webAuthnUser{e: e, creds: make([]webauthn.Credential, 0), mfa: make(map[string]bool)}
+	for rows.Next() {
+		var (
+			c         webauthn.Credential
+			transport pq.StringArray
+			signCount int64
+			mfa       bool
+		)
+
+		err = rows.Scan(&c.ID, &c.PublicKey, &c.AttestationType, &transport, &c.Authenticator.AAGUID, &signCount,
+			&c.Flags.BackupEligible, &c.Flags.BackupState, &mfa)
+		if err != nil {
+			return webAuthnUser{}, err
+		}
+
+		for _, t := range transport {
+			c.Transport = append(c.Transport, protocol.AuthenticatorTransport(t))
+		}
+		c.Authenticator.SignCount = uint32(signCount)
+
+		u.creds = append(u.creds, c)
+		u.mfa[string(c.ID)] = mfa
+	}
+
+	if err = rows.Err(); err != nil {
+		return webAuthnUser{}, err
+	}
+
+	return u, nil
+}
+
+// useWebAuthnChallenge deletes a challenge issued for a ceremony and returns its session data, so each challenge can
+// be answered only once. ErrNotFound is returned if the challenge is unknown or has expired.
+func (a *DefaultAPI) useWebAuthnChallenge(
+	ctx context.Context,
+	id string,
+	ceremony string,
+) (string, webauthn.SessionData, error) {
+	var (
+		entityID    sql.NullString
+		sessionJSON []byte
+		session     webauthn.SessionData
+	)
+
+	if _, err := uuid.Parse(id); err != nil {
+		return "", session, ErrNotFound
+	}
+
+	q := `DELETE FROM webauthn_challenge WHERE id=$1 AND ceremony=$2 AND expires_at>$3 RETURNING entity_id, session`
+	err := a.db.QueryRowContext(ctx, q, id, ceremony, a.now()).Scan(&entityID, &sessionJSON)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", session, ErrNotFound
+	} else if err != nil {
+		return "", session, err
+	}
+
+	if err = json.Unmarshal(sessionJSON, &session); err != nil {
+		return "", session, fmt.Errorf("invalid session: %w", err)
+	}
+
+	return entityID.String, session, nil
+}
+
+// BeginWebAuthnRegistration starts registering a new passkey for an entity. Only discoverable credentials verifying
+// the user are accepted, so they can replace both the login and the secret of the entity.
+func (a *DefaultAPI) BeginWebAuthnRegistration(ctx context.Context, entityID string) (WebAuthnChallenge, error) {
+	if a.webauthn == nil {
+		return WebAuthnChallenge{}, ErrWebAuthnDisabled
+	}
+
+	if _, err := uuid.Parse(entityID); err != nil {
+		return WebAuthnChallenge{}, ErrInvalidArg{Msg: fmt.Sprintf("invalid id: %s", err.Error())}
+	}
+
+	u, err := a.getWebAuthnUser(ctx, entityID)
+	if err != nil {
+		return WebAuthnChallenge{}, err
+	}
+
+	exclusions := make([]protocol.CredentialDescriptor, 0, len(u.creds))
+	for _, c := range u.creds {
+		exclusions = append(exclusions, c.Descriptor())
+	}
+
+	creation, session, err := a.webauthn.BeginRegistration(u,
+		webauthn.WithExclusions(exclusions),
+		webauthn.WithAuthenticatorSelection(protocol.AuthenticatorSelection{
+			RequireResidentKey: protocol.ResidentKeyRequired(),
+			ResidentKey:        protocol.ResidentKeyRequirementRequired,
+			UserVerification:   protocol.VerificationRequired,
+		}),
+	)
+	if err != nil {
+		return WebAuthnChallenge{}, fmt.Errorf("begin registration: %w", err)
+	}
+
+	return a.webAuthnChallenge(ctx, webAuthnRegistration, entityID, creation, session)
+}
+
+// FinishWebAuthnRegistration verifies the attestation of a new passkey created by an authenticator and registers it
+// for the entity. It returns the base64url encoded ID of the registered credential. The mfa flag tells whether the
+// registration has been made by an entity that passed MFA; only such passkeys serve as a second factor at login.
+func (a *DefaultAPI) FinishWebAuthnRegistration(
+	ctx context.Context,
+	entityID string,
+	challengeID string,
+	response []byte,
+	mfa bool,
+) (string, error) {
+	if a.webauthn == nil {
+		return "", ErrWebAuthnDisabled
+	}
+
+	chEntityID, session, err := a.useWebAuthnChallenge(ctx, challengeID, webAuthnRegistration)
+	if errors.Is(err, ErrNotFound) || (err == nil && chEntityID != entityID) {
+		return "", ErrInvalidArg{Msg: "unknown or expired challenge"}
+	} else if err != nil {
+		return "", err
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBody(bytes.NewReader(response))
+	if err != nil {
+		return "", ErrInvalidArg{Msg: fmt.Sprintf("invalid credential: %s", err.Error())}
+	}
+
+	u, err := a.getWebAuthnUser(ctx, entityID)
+	if err != nil {
+		return "", err
+	}
+
+	c, err := a.webauthn.CreateCredential(u, session, parsed)
+	if err != nil {
+		return "", ErrInvalidArg{Msg: fmt.Sprintf("invalid credential: %s", err.Error())}
+	}
+
+	transport := make(pq.StringArray, 0, len(c.Transport))
+	for _, t := range c.Transport {
+		transport = append(transport, string(t))
+	}
+
+	q := `INSERT INTO webauthn_credential (id, entity_id, public_key, attestation_type, transport, aaguid, ` +
+		`sign_count, backup_eligible, backup_state, mfa, created_at) ` +
+		`VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
+	_, err = a.db.ExecContext(ctx, q, c.ID, entityID, c.PublicKey, c.AttestationType, transport,
+		c.Authenticator.AAGUID, int64(c.Authenticator.SignCount), c.Flags.BackupEligible, c.Flags.BackupState, mfa,
+		a.now())
+	if isUniqueViolation(err) {
+		return "", ErrAlreadyExists
+	} else if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(c.ID), nil
+}
+
+// BeginWebAuthnLogin starts authenticating an entity by a passkey. The entity is not known until the ceremony is
+// finished, so the challenge reveals nothing about registered entities and their credentials.
+func (a *DefaultAPI) BeginWebAuthnLogin(ctx context.Context) (WebAuthnChallenge, error) {
+	if a.webauthn == nil {
+		return WebAuthnChallenge{}, ErrWebAuthnDisabled
+	}
+
+	uv := webauthn.WithUserVerification(protocol.VerificationRequired)
+	assertion, session, err := a.webauthn.BeginDiscoverableLogin(uv)
+	if err != nil {
+		return WebAuthnChallenge{}, fmt.Errorf("begin login: %w", err)
+	}
+
+	return a.webAuthnChallenge(ctx, webAuthnLogin, "", assertion, session)
+}
+
+// FinishWebAuthnLogin verifies the assertion of a passkey and returns the entity it has been registered for. Since
+// the user is verified by the authenticator, a passkey registered by an entity having passed MFA serves as both
+// authentication factors, which is reported by the returned flag. Wrong assertions are counted as failures of the
+// source only, otherwise anyone knowing an entity ID could lock the entity out. ErrLocked and ErrEntityDisabled are
+// returned the same way VerifyCredentials does, but along with the entity, since the caller has no other way to know
+// it. A successful authentication resets the failures counter of the entity.
+func (a *DefaultAPI) FinishWebAuthnLogin(
+	ctx context.Context,
+	challengeID string,
+	response []byte,
+	source string,
+) (Entity, bool, error) {
+	if a.webauthn == nil {
+		return Entity{}, false, ErrWebAuthnDisabled
+	}
+
+	if err := a.checkSourceLock(ctx, source); err != nil {
+		return Entity{}, false, err
+	}
+
+	_, session, err := a.useWebAuthnChallenge(ctx, challengeID, webAuthnLogin)
+	if errors.Is(err, ErrNotFound) {
+		return Entity{}, false, ErrInvalidArg{Msg: "unknown or expired challenge"}
+	} else if err != nil {
+		return Entity{}, false, err
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBody(bytes.NewReader(response))
+	if err != nil {
+		return Entity{}, false, ErrInvalidArg{Msg: fmt.Sprintf("invalid credential: %s", err.Error())}
+	}
+
+	var (
+		u         webAuthnUser
+		lookupErr error
+	)
+
+	c, err := a.webauthn.ValidateDiscoverableLogin(func(_, userHandle []byte) (webauthn.User, error) {
+		u, lookupErr = a.getWebAuthnUser(ctx, string(userHandle))
+		return u, lookupErr
+	}, session, parsed)
+	if lookupErr != nil && !errors.Is(lookupErr, ErrNotFound) {
+		return Entity{}, false, lookupErr
+	} else if err == nil && c.Authenticator.CloneWarning {
+		err = errors.New("signature counter has not increased")
+	}
+
+	if err != nil {
+		if rErr := a.recordSourceFailure(ctx, source); rErr != nil {
+			return Entity{}, false, rErr
+		}
+
+		return Entity{}, false, fmt.Errorf("%w: %s", ErrInvalidCredentials, err.Error())
+	}
+
+	e := u.e
+	if e.LockedUntil.After(a.now()) {
+		return e, false, ErrLocked{Until: e.LockedUntil}
+	}
+
+	if e.Disabled {
+		return e, false, ErrEntityDisabled
+	}
+
+	q := `UPDATE webauthn_credential SET sign_count=$1, backup_state=$2, last_used_at=$3 WHERE id=$4`
+	_, err = a.db.ExecContext(ctx, q, int64(c.Authenticator.SignCount), c.Flags.BackupState, a.now(), c.ID)
+	if err != nil {
+		return Entity{}, false, err
+	}
+
+	e.LastAuthenticatedAt = a.now()
+	e.FailedAuthCount = 0
+	e.LockedUntil = time.Time{}
+
+	q = `UPDATE entity SET last_authenticated_at=$1, failed_auth_count=0, failed_auth_reset_at=NULL, ` +
+		`locked_until=NULL WHERE id=$2`
+	if _, err = a.db.ExecContext(ctx, q, e.LastAuthenticatedAt, e.ID); err != nil {
+		return Entity{}, false, err
+	}
+
+	return e, u.mfa[string(c.ID)], nil
+}
+
+// webAuthnChallenge stores the session data of a started ceremony and returns the challenge to be passed to the
+// client.
+func (a *DefaultAPI) webAuthnChallenge(
+	ctx context.Context,
+	ceremony string,
+	entityID string,
+	options interface{},
+	session *webauthn.SessionData,
+) (WebAuthnChallenge, error) {
+	optionsJSON, err := json.Marshal(options)
+	if err != nil {
+		return WebAuthnChallenge{}, fmt.Errorf("marshal options: %w", err)
+	}
+
+	sessionJSON, err := json.Marshal(session)
+	if err != nil {
+		return WebAuthnChallenge{}, fmt.Errorf("marshal session: %w", err)
+	}
+
+	ch := WebAuthnChallenge{ID: uuid.NewString(), Options: optionsJSON}
+	entity := sql.NullString{String: entityID, Valid: entityID != ""}
+
+	q := `INSERT INTO webauthn_challenge (id, ceremony, entity_id, session, expires_at) VALUES ($1, $2, $3, $4, $5)`
+	_, err = a.db.ExecContext(ctx, q, ch.ID, ceremony, entity, sessionJSON, a.now().Add(webAuthnChallengeTTL))
+	if err != nil {
+		return WebAuthnChallenge{}, err
+	}
+
+	return ch, nil
+}
@@ -0,0 +1,275 @@
+package api_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/ashep/a23n/api"
+	"github.com/ashep/a23n/api/webauthntest"
+	"github.com/ashep/a23n/sqldb"
+)
+
+// expectChallengeInsert expects a challenge of a ceremony to be saved. The saved session data is stored into session.
+func (s *EntityTestSuite) expectChallengeInsert(ceremony string, entityID string, session *[]byte) {
+	s.db.
+		On("ExecContext", mock.Anything, "INSERT INTO webauthn_challenge (id, ceremony, entity_id, session, "+
+			"expires_at) VALUES ($1, $2, $3, $4, $5)",
+			mock.MatchedBy(func(args []interface{}) bool {
+				return args[1] == ceremony &&
+					args[2] == sql.NullString{String: entityID, Valid: entityID != ""} &&
+					args[4] == time.Unix(123456789, 0).Add(5*time.Minute)
+			})).
+		Run(func(args mock.Arguments) { *session = args.Get(2).([]interface{})[3].([]byte) }).
+		Return(&sqldb.ResultMock{}, nil).
+		Once()
+}
+
+// expectChallengeUse expects a challenge of a ceremony to be used. The session data is taken from session at the time
+// the challenge is used.
+func (s *EntityTestSuite) expectChallengeUse(challengeID, ceremony, entityID string, session *[]byte) {
+	row := &sqldb.RowMock{}
+	row.On("Scan", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			*args.Get(0).(*sql.NullString) = sql.NullString{String: entityID, Valid: entityID != ""}
+			*args.Get(1).(*[]byte) = *session
+		}).
+		Return(nil)
+
+	s.db.
+		On("QueryRowContext", mock.Anything, "DELETE FROM webauthn_challenge WHERE id=$1 AND ceremony=$2 AND "+
+			"expires_at>$3 RETURNING entity_id, session",
+			[]interface{}{challengeID, ceremony, time.Unix(123456789, 0)}).
+		Return(row).
+		Once()
+}
+
+// expectCredentialsQuery expects the credentials of the entity to be queried. Each credential is given as the
+// arguments of the query which has inserted it.
+func (s *EntityTestSuite) expectCredentialsQuery(creds ...[]interface{}) {
+	rows := &sqldb.RowsMock{}
+	for _, c := range creds {
+		c := c
+		rows.On("Next").Return(true).Once()
+		rows.On("Scan", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything,
+			mock.Anything, mock.Anything, mock.Anything).
+			Run(func(args mock.Arguments) {
+				*args.Get(0).(*[]byte) = c[0].([]byte)
+				*args.Get(1).(*[]byte) = c[2].([]byte)
+				*args.Get(2).(*string) = c[3].(string)
+				*args.Get(3).(*pq.StringArray) = c[4].(pq.StringArray)
+				*args.Get(4).(*[]byte) = c[5].([]byte)
+				*args.Get(5).(*int64) = c[6].(int64)
+				*args.Get(6).(*bool) = c[7].(bool)
+				*args.Get(7).(*bool) = c[8].(bool)
+				*args.Get(8).(*bool) = c[9].(bool)
+			}).
+			Return(nil).
+			Once()
+	}
+	rows.On("Next").Return(false).Once()
+	rows.On("Err").Return(nil)
+	rows.On("Close").Return(nil)
+
+	s.db.
+		On("QueryContext", mock.Anything, "SELECT id, public_key, attestation_type, transport, aaguid, sign_count, "+
+			"backup_eligible, backup_state, mfa FROM webauthn_credential WHERE entity_id=$1",
+			[]interface{}{"2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d"}).
+		Return(rows, nil).
+		Once()
+}
+
+// registerPasskey registers a passkey created by auth for the entity and returns the arguments of the query which has
+// inserted it.
+func (s *EntityTestSuite) registerPasskey(auth *webauthntest.Authenticator, mfa bool) []interface{} {
+	var (
+		ctx     = context.Background()
+		session []byte
+		cred    []interface{}
+	)
+
+	s.expectGetEntity("theSecretHash", false, nil).Once()
+	s.expectCredentialsQuery()
+	s.expectChallengeInsert("registration", "2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d", &session)
+
+	ch, err := s.api.BeginWebAuthnRegistration(ctx, "2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d")
+	s.Require().NoError(err)
+
+	var opts map[string]map[string]interface{}
+	s.Require().NoError(json.Unmarshal(ch.Options, &opts))
+	s.Assert().Equal(map[string]interface{}{
+		"requireResidentKey": true,
+		"residentKey":        "required",
+		"userVerification":   "required",
+	}, opts["publicKey"]["authenticatorSelection"])
+
+	resp, err := auth.Create(ch.Options)
+	s.Require().NoError(err)
+
+	s.expectChallengeUse(ch.ID, "registration", "2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d", &session)
+	s.expectGetEntity("theSecretHash", false, nil).Once()
+	s.expectCredentialsQuery()
+	s.db.
+		On("ExecContext", mock.Anything, "INSERT INTO webauthn_credential (id, entity_id, public_key, "+
+			"attestation_type, transport, aaguid, sign_count, backup_eligible, backup_state, mfa, created_at) "+
+			"VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)",
+			mock.MatchedBy(func(args []interface{}) bool {
+				return args[1] == "2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d" && args[3] == "none" &&
+					args[6] == int64(0) && args[9] == mfa && args[10] == time.Unix(123456789, 0)
+			})).
+		Run(func(args mock.Arguments) { cred = args.Get(2).([]interface{}) }).
+		Return(&sqldb.ResultMock{}, nil).
+		Once()
+
+	credID, err := s.api.FinishWebAuthnRegistration(ctx, "2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d", ch.ID, resp, mfa)
+	s.Require().NoError(err)
+	s.Assert().NotEmpty(credID)
+	s.Assert().Equal(pq.StringArray{"internal"}, cred[4])
+
+	return cred
+}
+
+// beginPasskeyLogin starts a login and returns the assertion of auth along with the challenge ID.
+func (s *EntityTestSuite) beginPasskeyLogin(auth *webauthntest.Authenticator, session *[]byte) (string, []byte) {
+	s.expectChallengeInsert("login", "", session)
+
+	ch, err := s.api.BeginWebAuthnLogin(context.Background())
+	s.Require().NoError(err)
+
+	resp, err := auth.Get(ch.Options)
+	s.Require().NoError(err)
+
+	return ch.ID, resp
+}
+
+func (s *EntityTestSuite) TestBeginWebAuthnLoginDisabled() {
+	a := api.NewDefault(s.db, api.Options{
+		Key:      api.NewHMACKey([]byte("abc")),
+		Hasher:   s.hasher,
+		Issuer:   "theIssuer",
+		Audience: "theAudience",
+	})
+
+	_, err := a.BeginWebAuthnLogin(context.Background())
+	s.Require().ErrorIs(err, api.ErrWebAuthnDisabled)
+}
+
+func (s *EntityTestSuite) TestFinishWebAuthnRegistrationUnknownChallenge() {
+	row := &sqldb.RowMock{}
+	row.On("Scan", mock.Anything, mock.Anything).Return(sql.ErrNoRows)
+	s.db.On("QueryRowContext", mock.Anything, mock.AnythingOfType("string"), mock.Anything).Return(row)
+
+	_, err := s.api.FinishWebAuthnRegistration(context.Background(), "2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d",
+		"b8f1c2f5-7d0e-4c9a-8f3b-2a1d6e5c4b3a", []byte("{}"), false)
+	s.Require().EqualError(err, "unknown or expired challenge")
+}
+
+func (s *EntityTestSuite) TestFinishWebAuthnRegistrationOtherEntity() {
+	session := []byte("{}")
+	s.expectChallengeUse("b8f1c2f5-7d0e-4c9a-8f3b-2a1d6e5c4b3a", "registration",
+		"0a7f3c9e-5b2d-4e8a-9c1f-6d4b2a8e7f3c", &session)
+
+	_, err := s.api.FinishWebAuthnRegistration(context.Background(), "2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d",
+		"b8f1c2f5-7d0e-4c9a-8f3b-2a1d6e5c4b3a", []byte("{}"), false)
+	s.Require().EqualError(err, "unknown or expired challenge")
+}
+
+// passkeyLogin registers a passkey and authenticates by it, returning whether the login serves as MFA.
+func (s *EntityTestSuite) passkeyLogin(registeredWithMFA bool) bool {
+	auth := webauthntest.New("https://example.com")
+	cred := s.registerPasskey(auth, registeredWithMFA)
+
+	var session []byte
+	challengeID, resp := s.beginPasskeyLogin(auth, &session)
+
+	s.expectSourceLock("192.0.2.1", time.Time{})
+	s.expectChallengeUse(challengeID, "login", "", &session)
+	s.expectGetEntity("theSecretHash", false, nil)
+	s.expectCredentialsQuery(cred)
+	s.db.
+		On("ExecContext", mock.Anything, "UPDATE webauthn_credential SET sign_count=$1, backup_state=$2, "+
+			"last_used_at=$3 WHERE id=$4",
+			[]interface{}{int64(1), false, time.Unix(123456789, 0), cred[0]}).
+		Return(&sqldb.ResultMock{}, nil)
+	s.db.
+		On("ExecContext", mock.Anything, "UPDATE entity SET last_authenticated_at=$1, failed_auth_count=0, "+
+			"failed_auth_reset_at=NULL, locked_until=NULL WHERE id=$2",
+			[]interface{}{time.Unix(123456789, 0), "2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d"}).
+		Return(&sqldb.ResultMock{}, nil)
+
+	e, mfa, err := s.api.FinishWebAuthnLogin(context.Background(), challengeID, resp, "192.0.2.1")
+	s.Require().NoError(err)
+	s.Assert().Equal("2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d", e.ID)
+	s.Assert().Equal(time.Unix(123456789, 0), e.LastAuthenticatedAt)
+
+	return mfa
+}
+
+func (s *EntityTestSuite) TestWebAuthnLoginOk() {
+	s.Assert().True(s.passkeyLogin(true))
+}
+
+func (s *EntityTestSuite) TestWebAuthnLoginRegisteredWithoutMFA() {
+	s.Assert().False(s.passkeyLogin(false))
+}
+
+func (s *EntityTestSuite) TestWebAuthnLoginInvalidSignature() {
+	auth := webauthntest.New("https://example.com")
+	cred := s.registerPasskey(auth, false)
+
+	var session []byte
+	challengeID, resp := s.beginPasskeyLogin(auth, &session)
+
+	// Another authenticator signs the challenge with its own key
+	other := webauthntest.New("https://example.com")
+	otherCred := s.registerPasskey(other, false)
+	cred[2] = otherCred[2]
+
+	s.expectSourceLock("192.0.2.1", time.Time{})
+	s.expectChallengeUse(challengeID, "login", "", &session)
+	s.expectGetEntity("theSecretHash", false, nil)
+	s.expectCredentialsQuery(cred)
+	s.expectSourceFailure("192.0.2.1", 1)
+
+	_, _, err := s.api.FinishWebAuthnLogin(context.Background(), challengeID, resp, "192.0.2.1")
+	s.Require().ErrorIs(err, api.ErrInvalidCredentials)
+}
+
+func (s *EntityTestSuite) TestWebAuthnLoginReplayedCounter() {
+	auth := webauthntest.New("https://example.com")
+	cred := s.registerPasskey(auth, false)
+
+	var session []byte
+	challengeID, resp := s.beginPasskeyLogin(auth, &session)
+
+	// The stored counter is ahead of the authenticator one, so the credential may have been cloned
+	cred[6] = int64(5)
+
+	s.expectChallengeUse(challengeID, "login", "", &session)
+	s.expectGetEntity("theSecretHash", false, nil)
+	s.expectCredentialsQuery(cred)
+
+	_, _, err := s.api.FinishWebAuthnLogin(context.Background(), challengeID, resp, "")
+	s.Require().ErrorIs(err, api.ErrInvalidCredentials)
+	s.Assert().EqualError(err, "invalid credentials: signature counter has not increased")
+}
+
+func (s *EntityTestSuite) TestWebAuthnLoginEntityDisabled() {
+	auth := webauthntest.New("https://example.com")
+	cred := s.registerPasskey(auth, false)
+
+	var session []byte
+	challengeID, resp := s.beginPasskeyLogin(auth, &session)
+
+	s.expectChallengeUse(challengeID, "login", "", &session)
+	s.expectGetEntity("theSecretHash", true, nil)
+	s.expectCredentialsQuery(cred)
+
+	e, _, err := s.api.FinishWebAuthnLogin(context.Background(), challengeID, resp, "")
+	s.Require().ErrorIs(err, api.ErrEntityDisabled)
+	s.Assert().Equal("2bd1b5c9-0c1a-4a3c-9d1b-6b1a7c0b5e2d", e.ID)
+}
@@ -0,0 +1,172 @@
+package webauthntest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+)
+
+// Authenticator is a software WebAuthn authenticator keeping discoverable credentials in memory. It makes "none"
+// attestations and always reports the user as present and verified.
+type Authenticator struct {
+	origin string
+	creds  []*softCredential
+}
+
+type softCredential struct {
+	id         []byte
+	key        *ecdsa.PrivateKey
+	rpID       string
+	userHandle []byte
+	signCount  uint32
+}
+
+// New creates an authenticator used by web pages of an origin.
+func New(origin string) *Authenticator {
+	return &Authenticator{origin: origin}
+}
+
+// Create answers the options returned by BeginWebAuthnRegistration the way navigator.credentials.create() does.
+func (a *Authenticator) Create(options []byte) ([]byte, error) {
+	var cc protocol.CredentialCreation
+	if err := json.Unmarshal(options, &cc); err != nil {
+		return nil, err
+	}
+
+	userID, ok := cc.Response.User.ID.(string)
+	if !ok {
+		return nil, errors.New("invalid user id")
+	}
+	userHandle, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(userID, "="))
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &softCredential{id: make([]byte, 16), key: key, rpID: cc.Response.RelyingParty.ID, userHandle: userHandle}
+	if _, err = rand.Read(c.id); err != nil {
+		return nil, err
+	}
+
+	pubKey, err := webauthncbor.Marshal(map[int]interface{}{
+		1:  2,  // EC2 key type
+		3:  -7, // ES256
+		-1: 1,  // P-256 curve
+		-2: key.PublicKey.X.FillBytes(make([]byte, 32)),
+		-3: key.PublicKey.Y.FillBytes(make([]byte, 32)),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Attested credential data: zero AAGUID, credential ID length, credential ID and public key
+	attData := make([]byte, 18, 18+len(c.id)+len(pubKey))
+	binary.BigEndian.PutUint16(attData[16:], uint16(len(c.id)))
+	attData = append(append(attData, c.id...), pubKey...)
+
+	attObj, err := webauthncbor.Marshal(map[string]interface{}{
+		"fmt":      "none",
+		"attStmt":  map[string]interface{}{},
+		"authData": c.authData(byte(protocol.FlagAttestedCredentialData), attData),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	clientData, err := a.clientData(protocol.CreateCeremony, cc.Response.Challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	a.creds = append(a.creds, c)
+
+	return json.Marshal(map[string]interface{}{
+		"id":    base64.RawURLEncoding.EncodeToString(c.id),
+		"rawId": base64.RawURLEncoding.EncodeToString(c.id),
+		"type":  "public-key",
+		"response": map[string]interface{}{
+			"clientDataJSON":    base64.RawURLEncoding.EncodeToString(clientData),
+			"attestationObject": base64.RawURLEncoding.EncodeToString(attObj),
+			"transports":        []string{"internal"},
+		},
+	})
+}
+
+// Get answers the options returned by BeginWebAuthnLogin the way navigator.credentials.get() does. The most recently
+// created credential of the relying party is used.
+func (a *Authenticator) Get(options []byte) ([]byte, error) {
+	var ca protocol.CredentialAssertion
+	if err := json.Unmarshal(options, &ca); err != nil {
+		return nil, err
+	}
+
+	var c *softCredential
+	for _, cred := range a.creds {
+		if cred.rpID == ca.Response.RelyingPartyID {
+			c = cred
+		}
+	}
+	if c == nil {
+		return nil, errors.New("no credentials")
+	}
+
+	clientData, err := a.clientData(protocol.AssertCeremony, ca.Response.Challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	c.signCount++
+	authData := c.authData(0, nil)
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(authData, clientDataHash[:]...))
+
+	sig, err := ecdsa.SignASN1(rand.Reader, c.key, digest[:])
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"id":    base64.RawURLEncoding.EncodeToString(c.id),
+		"rawId": base64.RawURLEncoding.EncodeToString(c.id),
+		"type":  "public-key",
+		"response": map[string]interface{}{
+			"clientDataJSON":    base64.RawURLEncoding.EncodeToString(clientData),
+			"authenticatorData": base64.RawURLEncoding.EncodeToString(authData),
+			"signature":         base64.RawURLEncoding.EncodeToString(sig),
+			"userHandle":        base64.RawURLEncoding.EncodeToString(c.userHandle),
+		},
+	})
+}
+
+func (a *Authenticator) clientData(typ protocol.CeremonyType, challenge protocol.URLEncodedBase64) ([]byte, error) {
+	return json.Marshal(map[string]string{
+		"type":      string(typ),
+		"challenge": base64.RawURLEncoding.EncodeToString(challenge),
+		"origin":    a.origin,
+	})
+}
+
+// authData returns the authenticator data having the user present and verified flags set.
+func (c *softCredential) authData(flags byte, attData []byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(c.rpID))
+
+	r := make([]byte, 37, 37+len(attData))
+	copy(r, rpIDHash[:])
+	r[32] = flags | byte(protocol.FlagUserPresent) | byte(protocol.FlagUserVerified)
+	binary.BigEndian.PutUint32(r[33:], c.signCount)
+
+	return append(r, attData...)
+}
@@ -0,0 +1,42 @@
+package root
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ashep/a23n/api"
+	"github.com/ashep/a23n/config"
+)
+
+// bootstrapAdmin creates an admin entity described by cfg if it does not exist yet. An existing entity is granted the
+// admin scope if it lacks it, its secret is left intact.
+func bootstrapAdmin(ctx context.Context, a api.API, cfg config.Admin, scope string) error {
+	e, err := a.GetEntity(ctx, cfg.ID)
+	if err == nil {
+		if a.CheckScope(e.Scope, api.Scope{scope}) {
+			return nil
+		}
+		return a.UpdateEntity(ctx, e.ID, "", "", append(e.Scope, scope), e.Attrs)
+	} else if !errors.Is(err, api.ErrNotFound) {
+		return err
+	}
+
+	if cfg.Secret == "" {
+		return errors.New("empty admin secret")
+	}
+
+	return a.CreateEntity(ctx, cfg.ID, "", cfg.Secret, api.Scope{scope}, nil)
+}
+
+// bootstrapClients registers the OAuth 2.0 clients described by cfg which do not exist yet.
+func bootstrapClients(ctx context.Context, a api.API, cfg []config.OAuth2Client) error {
+	for _, c := range cfg {
+		_, err := a.CreateClient(ctx, api.Client{ID: c.ID, Name: c.Name, RedirectURIs: c.RedirectURIs})
+		if err != nil && !errors.Is(err, api.ErrAlreadyExists) {
+			return fmt.Errorf("client %s: %w", c.ID, err)
+		}
+	}
+
+	return nil
+}
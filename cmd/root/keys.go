@@ -0,0 +1,71 @@
+package root
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ashep/a23n/logger"
+	"github.com/ashep/a23n/server"
+)
+
+func newKeysCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "keys",
+		Short: "Manage token signing keys",
+	}
+
+	cmd.AddCommand(newKeysRotateCmd())
+
+	return cmd
+}
+
+func newKeysRotateCmd() *cobra.Command {
+	var alg string
+
+	cmd := &cobra.Command{
+		Use:   "rotate",
+		Short: "Generate a new signing key and promote it to sign new tokens",
+		Long: "Generate a new signing key and promote it to sign new tokens. The key is published right away, " +
+			"but starts signing tokens only after " + server.KeyActivationDelay.String() + ", so clients " +
+			"caching the published keys learn it by then. Previously used keys remain valid for verification " +
+			"until all the tokens signed by them expire. The private key is stored encrypted if the encryption " +
+			"key is configured.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			l := logger.New(debugMode)
+
+			cfg, err := loadConfig(configPath)
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			if alg == "" {
+				alg = cfg.SigningAlg
+			}
+
+			db, err := openDB(cmd.Context(), cfg)
+			if err != nil {
+				return fmt.Errorf("failed to connect to db: %w", err)
+			}
+
+			a, err := newAPI(cfg, db)
+			if err != nil {
+				return fmt.Errorf("failed to initialize api: %w", err)
+			}
+
+			k, err := a.RotateKey(cmd.Context(), alg, server.KeyActivationDelay, maxTokenTTL(cfg))
+			if err != nil {
+				return fmt.Errorf("failed to rotate signing key: %w", err)
+			}
+
+			l.Info().Str("kid", k.ID).Str("alg", k.Method.Alg()).Msg("signing key rotated")
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&alg, "alg", "", "signing algorithm of the new key: RS256, ES256 or EdDSA; "+
+		"defaults to the configured one")
+
+	return cmd
+}
@@ -5,17 +5,13 @@ import (
 	"math/rand"
 	"net/http"
 	"os"
-	"strconv"
 	"time"
 
 	"github.com/spf13/cobra"
 
-	"github.com/ashep/a23n/api"
-	"github.com/ashep/a23n/config"
 	"github.com/ashep/a23n/logger"
 	"github.com/ashep/a23n/migration"
 	"github.com/ashep/a23n/server"
-	"github.com/ashep/a23n/sqldb"
 )
 
 var (
@@ -27,9 +23,8 @@ var (
 
 func New() *cobra.Command {
 	cmd := &cobra.Command{
+		Use: "a23n",
 		Run: func(cmd *cobra.Command, args []string) {
-			var err error
-
 			rand.Seed(time.Now().UnixNano())
 
 			if !debugMode && os.Getenv("A23N_DEBUG") == "1" {
@@ -37,32 +32,16 @@ func New() *cobra.Command {
 			}
 			l := logger.New(debugMode)
 
-			cfg := config.Config{}
-			if configPath != "" {
-				cfg, err = config.ParseFromPath(configPath)
-				if err != nil {
-					l.Fatal().Err(err).Msg("failed to load config")
-					return
-				}
-			}
-
-			dbDSN := os.Getenv("A23N_DB_DSN")
-			if dbDSN != "" {
-				cfg.DB.DSN = dbDSN
-			}
-			if cfg.DB.DSN == "" {
-				l.Fatal().Err(err).Msg("empty db dsn")
-				return
-			}
-
-			db, err := sqldb.NewPostgres(cfg.DB.DSN)
+			cfg, err := loadConfig(configPath)
 			if err != nil {
-				l.Fatal().Err(err).Msg("failed to open db")
+				l.Fatal().Err(err).Msg("failed to load config")
 				return
 			}
 
-			if err = db.PingContext(cmd.Context()); err != nil {
+			db, err := openDB(cmd.Context(), cfg)
+			if err != nil {
 				l.Fatal().Err(err).Msg("failed to connect to db")
+				return
 			}
 			l.Debug().Msg("db connection ok")
 
@@ -80,36 +59,44 @@ func New() *cobra.Command {
 				return
 			}
 
-			secret := os.Getenv("A23N_SECRET")
-			if secret != "" {
-				cfg.Secret = secret
+			a, err := newAPI(cfg, db)
+			if err != nil {
+				l.Fatal().Err(err).Msg("failed to initialize api")
+				return
 			}
 
-			accessTokenTTL := os.Getenv("A23N_ACCESS_TOKEN_TTL")
-			if accessTokenTTL != "" {
-				t, _ := strconv.Atoi(accessTokenTTL)
-				cfg.AccessTokenTTL = uint(t)
+			if err = a.LoadKeys(cmd.Context()); err != nil {
+				l.Fatal().Err(err).Msg("failed to load signing keys")
+				return
 			}
 
-			refreshTokenTTL := os.Getenv("A23N_REFRESH_TOKEN_TTL")
-			if refreshTokenTTL != "" {
-				t, _ := strconv.Atoi(refreshTokenTTL)
-				cfg.RefreshTokenTTL = uint(t)
+			if cfg.Admin.ID != "" {
+				if err := bootstrapAdmin(cmd.Context(), a, cfg.Admin, cfg.AdminScope); err != nil {
+					l.Fatal().Err(err).Msg("failed to bootstrap admin entity")
+				}
 			}
 
-			a := api.NewDefault(db, cfg.Secret, time.Now)
+			if err := bootstrapClients(cmd.Context(), a, cfg.OAuth2.Clients); err != nil {
+				l.Fatal().Err(err).Msg("failed to bootstrap oauth2 clients")
+			}
 
-			addr := os.Getenv("A23N_ADDRESS")
-			if addr != "" {
-				cfg.Address = addr
+			rateLimits, err := rateLimitRules(cfg.RateLimit)
+			if err != nil {
+				l.Fatal().Err(err).Msg("failed to configure rate limits")
+				return
 			}
-			s := server.New(
-				a,
-				cfg.Address,
-				time.Duration(cfg.AccessTokenTTL)*time.Second,
-				time.Duration(cfg.RefreshTokenTTL)*time.Second,
-				l.With().Str("pkg", "server").Logger(),
-			)
+
+			s := server.New(a, server.Options{
+				Addr:                   cfg.Address,
+				AccessTokenTTL:         time.Duration(cfg.AccessTokenTTL) * time.Second,
+				RefreshTokenTTL:        time.Duration(cfg.RefreshTokenTTL) * time.Second,
+				AdminScope:             cfg.AdminScope,
+				IntrospectionScope:     cfg.IntrospectionScope,
+				TokenExchangeScope:     cfg.TokenExchange.Scope,
+				TokenExchangeAudiences: cfg.TokenExchange.Audiences,
+				MFAScope:               mfaScope(cfg),
+				RateLimits:             rateLimits,
+			}, l.With().Str("pkg", "server").Logger())
 
 			if err := s.Run(cmd.Context()); errors.Is(err, http.ErrServerClosed) {
 				l.Info().Msg("server stopped")
@@ -125,5 +112,7 @@ func New() *cobra.Command {
 	cmd.PersistentFlags().BoolVarP(&debugMode, "debug", "d", false, "enable debug mode")
 	cmd.PersistentFlags().StringVarP(&configPath, "config", "c", "", "path to the config file")
 
+	cmd.AddCommand(newKeysCmd())
+
 	return cmd
 }
@@ -0,0 +1,505 @@
+package root
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/ashep/a23n/api"
+	"github.com/ashep/a23n/config"
+	"github.com/ashep/a23n/server/interceptor"
+	"github.com/ashep/a23n/sqldb"
+)
+
+// loadConfig loads the config file, if it is specified, and overrides its values by environment variables.
+func loadConfig(path string) (config.Config, error) {
+	var err error
+
+	cfg := config.Config{}
+	if path != "" {
+		cfg, err = config.ParseFromPath(path)
+		if err != nil {
+			return cfg, err
+		}
+	}
+
+	dbDSN := os.Getenv("A23N_DB_DSN")
+	if dbDSN != "" {
+		cfg.DB.DSN = dbDSN
+	}
+
+	addr := os.Getenv("A23N_ADDRESS")
+	if addr != "" {
+		cfg.Address = addr
+	}
+
+	secret := os.Getenv("A23N_SECRET")
+	if secret != "" {
+		cfg.Secret = secret
+	}
+
+	signingAlg := os.Getenv("A23N_SIGNING_ALG")
+	if signingAlg != "" {
+		cfg.SigningAlg = signingAlg
+	}
+
+	signingKeyPath := os.Getenv("A23N_SIGNING_KEY")
+	if signingKeyPath != "" {
+		cfg.SigningKey = signingKeyPath
+	}
+
+	encryptionKey := os.Getenv("A23N_ENCRYPTION_KEY")
+	if encryptionKey != "" {
+		cfg.EncryptionKey = encryptionKey
+	}
+
+	issuer := os.Getenv("A23N_ISSUER")
+	if issuer != "" {
+		cfg.Issuer = issuer
+	}
+	if cfg.Issuer == "" {
+		cfg.Issuer = config.DefaultIssuer
+	}
+
+	audience := os.Getenv("A23N_AUDIENCE")
+	if audience != "" {
+		cfg.Audience = audience
+	}
+	if cfg.Audience == "" {
+		cfg.Audience = config.DefaultAudience
+	}
+
+	accessTokenTTL := os.Getenv("A23N_ACCESS_TOKEN_TTL")
+	if accessTokenTTL != "" {
+		t, _ := strconv.Atoi(accessTokenTTL)
+		cfg.AccessTokenTTL = uint(t)
+	}
+
+	refreshTokenTTL := os.Getenv("A23N_REFRESH_TOKEN_TTL")
+	if refreshTokenTTL != "" {
+		t, _ := strconv.Atoi(refreshTokenTTL)
+		cfg.RefreshTokenTTL = uint(t)
+	}
+
+	adminScope := os.Getenv("A23N_ADMIN_SCOPE")
+	if adminScope != "" {
+		cfg.AdminScope = adminScope
+	}
+	if cfg.AdminScope == "" {
+		cfg.AdminScope = config.DefaultAdminScope
+	}
+
+	introspectionScope := os.Getenv("A23N_INTROSPECTION_SCOPE")
+	if introspectionScope != "" {
+		cfg.IntrospectionScope = introspectionScope
+	}
+	if cfg.IntrospectionScope == "" {
+		cfg.IntrospectionScope = config.DefaultIntrospectionScope
+	}
+
+	tokenExchangeScope := os.Getenv("A23N_TOKEN_EXCHANGE_SCOPE")
+	if tokenExchangeScope != "" {
+		cfg.TokenExchange.Scope = tokenExchangeScope
+	}
+	if cfg.TokenExchange.Scope == "" {
+		cfg.TokenExchange.Scope = config.DefaultTokenExchangeScope
+	}
+
+	tokenExchangeAudiences := os.Getenv("A23N_TOKEN_EXCHANGE_AUDIENCES")
+	if tokenExchangeAudiences != "" {
+		cfg.TokenExchange.Audiences = strings.Split(tokenExchangeAudiences, ",")
+	}
+
+	passwordHashAlg := os.Getenv("A23N_PASSWORD_HASH_ALG")
+	if passwordHashAlg != "" {
+		cfg.PasswordHash.Alg = passwordHashAlg
+	}
+	if cfg.PasswordHash.Alg == "" {
+		cfg.PasswordHash.Alg = config.DefaultPasswordHashAlg
+	}
+
+	bcryptCost := os.Getenv("A23N_PASSWORD_HASH_BCRYPT_COST")
+	if bcryptCost != "" {
+		cfg.PasswordHash.BcryptCost, _ = strconv.Atoi(bcryptCost)
+	}
+
+	argon2Memory := os.Getenv("A23N_PASSWORD_HASH_ARGON2_MEMORY")
+	if argon2Memory != "" {
+		v, _ := strconv.ParseUint(argon2Memory, 10, 32)
+		cfg.PasswordHash.Argon2Memory = uint32(v)
+	}
+
+	argon2Iterations := os.Getenv("A23N_PASSWORD_HASH_ARGON2_ITERATIONS")
+	if argon2Iterations != "" {
+		v, _ := strconv.ParseUint(argon2Iterations, 10, 32)
+		cfg.PasswordHash.Argon2Iterations = uint32(v)
+	}
+
+	argon2Parallelism := os.Getenv("A23N_PASSWORD_HASH_ARGON2_PARALLELISM")
+	if argon2Parallelism != "" {
+		v, _ := strconv.ParseUint(argon2Parallelism, 10, 8)
+		cfg.PasswordHash.Argon2Parallelism = uint8(v)
+	}
+
+	passwordMinLength := os.Getenv("A23N_PASSWORD_MIN_LENGTH")
+	if passwordMinLength != "" {
+		cfg.PasswordPolicy.MinLength, _ = strconv.Atoi(passwordMinLength)
+	}
+	if cfg.PasswordPolicy.MinLength == 0 {
+		cfg.PasswordPolicy.MinLength = config.DefaultPasswordMinLength
+	}
+
+	passwordMaxLength := os.Getenv("A23N_PASSWORD_MAX_LENGTH")
+	if passwordMaxLength != "" {
+		cfg.PasswordPolicy.MaxLength, _ = strconv.Atoi(passwordMaxLength)
+	}
+	if cfg.PasswordPolicy.MaxLength == 0 {
+		cfg.PasswordPolicy.MaxLength = config.DefaultPasswordMaxLength
+	}
+
+	for env, v := range map[string]*bool{
+		"A23N_PASSWORD_REQUIRE_LOWER":  &cfg.PasswordPolicy.RequireLower,
+		"A23N_PASSWORD_REQUIRE_UPPER":  &cfg.PasswordPolicy.RequireUpper,
+		"A23N_PASSWORD_REQUIRE_DIGIT":  &cfg.PasswordPolicy.RequireDigit,
+		"A23N_PASSWORD_REQUIRE_SYMBOL": &cfg.PasswordPolicy.RequireSymbol,
+		"A23N_PASSWORD_DISALLOW_LOGIN": &cfg.PasswordPolicy.DisallowLogin,
+		"A23N_PASSWORD_DISALLOW_ATTRS": &cfg.PasswordPolicy.DisallowAttrs,
+	} {
+		if s := os.Getenv(env); s != "" {
+			*v, _ = strconv.ParseBool(s)
+		}
+	}
+
+	passwordDenylist := os.Getenv("A23N_PASSWORD_DENYLIST")
+	if passwordDenylist != "" {
+		cfg.PasswordPolicy.Denylist = passwordDenylist
+	}
+
+	for env, v := range map[string]*int{
+		"A23N_LOCKOUT_ENTITY_THRESHOLD": &cfg.Lockout.EntityThreshold,
+		"A23N_LOCKOUT_SOURCE_THRESHOLD": &cfg.Lockout.SourceThreshold,
+	} {
+		if s := os.Getenv(env); s != "" {
+			*v, _ = strconv.Atoi(s)
+		}
+	}
+	if cfg.Lockout.EntityThreshold == 0 {
+		cfg.Lockout.EntityThreshold = config.DefaultLockoutEntityThreshold
+	}
+	if cfg.Lockout.SourceThreshold == 0 {
+		cfg.Lockout.SourceThreshold = config.DefaultLockoutSourceThreshold
+	}
+
+	for env, v := range map[string]*uint{
+		"A23N_LOCKOUT_DURATION":     &cfg.Lockout.Duration,
+		"A23N_LOCKOUT_MAX_DURATION": &cfg.Lockout.MaxDuration,
+		"A23N_LOCKOUT_WINDOW":       &cfg.Lockout.Window,
+	} {
+		if s := os.Getenv(env); s != "" {
+			t, _ := strconv.Atoi(s)
+			*v = uint(t)
+		}
+	}
+	if cfg.Lockout.Duration == 0 {
+		cfg.Lockout.Duration = config.DefaultLockoutDuration
+	}
+	if cfg.Lockout.MaxDuration == 0 {
+		cfg.Lockout.MaxDuration = config.DefaultLockoutMaxDuration
+	}
+	if cfg.Lockout.Window == 0 {
+		cfg.Lockout.Window = config.DefaultLockoutWindow
+	}
+
+	rateLimitDisabled := os.Getenv("A23N_RATE_LIMIT_DISABLED")
+	if rateLimitDisabled != "" {
+		cfg.RateLimit.Disabled, _ = strconv.ParseBool(rateLimitDisabled)
+	}
+	if len(cfg.RateLimit.Rules) == 0 {
+		cfg.RateLimit.Rules = config.DefaultRateLimitRules
+	}
+
+	mfaRequireForAdmin := os.Getenv("A23N_MFA_REQUIRE_FOR_ADMIN")
+	if mfaRequireForAdmin != "" {
+		v, _ := strconv.ParseBool(mfaRequireForAdmin)
+		cfg.MFA.RequireForAdmin = &v
+	}
+	if cfg.MFA.RequireForAdmin == nil {
+		v := config.DefaultMFARequireForAdmin
+		cfg.MFA.RequireForAdmin = &v
+	}
+
+	webAuthnRPID := os.Getenv("A23N_WEBAUTHN_RP_ID")
+	if webAuthnRPID != "" {
+		cfg.WebAuthn.RPID = webAuthnRPID
+	}
+
+	webAuthnRPDisplayName := os.Getenv("A23N_WEBAUTHN_RP_DISPLAY_NAME")
+	if webAuthnRPDisplayName != "" {
+		cfg.WebAuthn.RPDisplayName = webAuthnRPDisplayName
+	}
+	if cfg.WebAuthn.RPDisplayName == "" {
+		cfg.WebAuthn.RPDisplayName = "a23n"
+	}
+
+	webAuthnRPOrigins := os.Getenv("A23N_WEBAUTHN_RP_ORIGINS")
+	if webAuthnRPOrigins != "" {
+		cfg.WebAuthn.RPOrigins = strings.Split(webAuthnRPOrigins, ",")
+	}
+	if len(cfg.WebAuthn.RPOrigins) == 0 && cfg.WebAuthn.RPID != "" {
+		cfg.WebAuthn.RPOrigins = []string{"https://" + cfg.WebAuthn.RPID}
+	}
+
+	adminID := os.Getenv("A23N_ADMIN_ID")
+	if adminID != "" {
+		cfg.Admin.ID = adminID
+	}
+
+	adminSecret := os.Getenv("A23N_ADMIN_SECRET")
+	if adminSecret != "" {
+		cfg.Admin.Secret = adminSecret
+	}
+
+	return cfg, nil
+}
+
+func openDB(ctx context.Context, cfg config.Config) (sqldb.DB, error) {
+	if cfg.DB.DSN == "" {
+		return nil, errors.New("empty db dsn")
+	}
+
+	db, err := sqldb.NewPostgres(cfg.DB.DSN)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = db.PingContext(ctx); err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// newAPI creates an API instance using the signing key defined by the config.
+func newAPI(cfg config.Config, db sqldb.DB) (*api.DefaultAPI, error) {
+	var (
+		signingKey api.SigningKey
+		err        error
+	)
+
+	if cfg.SigningAlg == "" || cfg.SigningAlg == jwt.SigningMethodHS256.Alg() {
+		if cfg.Secret == "" {
+			return nil, errors.New("empty secret")
+		}
+		signingKey = api.NewHMACKey([]byte(cfg.Secret))
+	} else {
+		signingKey, err = api.LoadSigningKey(cfg.SigningAlg, cfg.SigningKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	enc, err := newEncrypter(cfg.EncryptionKey)
+	if err != nil {
+		return nil, err
+	}
+
+	hasher, err := newHasher(cfg.PasswordHash)
+	if err != nil {
+		return nil, err
+	}
+
+	policy, err := newPasswordPolicy(cfg.PasswordPolicy, cfg.PasswordHash.Alg)
+	if err != nil {
+		return nil, err
+	}
+
+	lockout := api.LockoutPolicy{
+		EntityThreshold: cfg.Lockout.EntityThreshold,
+		SourceThreshold: cfg.Lockout.SourceThreshold,
+		Duration:        time.Duration(cfg.Lockout.Duration) * time.Second,
+		MaxDuration:     time.Duration(cfg.Lockout.MaxDuration) * time.Second,
+		Window:          time.Duration(cfg.Lockout.Window) * time.Second,
+	}
+
+	wa, err := newWebAuthn(cfg.WebAuthn)
+	if err != nil {
+		return nil, err
+	}
+
+	return api.NewDefault(db, api.Options{
+		Key:         signingKey,
+		Encrypter:   enc,
+		Hasher:      hasher,
+		Policy:      policy,
+		Lockout:     lockout,
+		WebAuthn:    wa,
+		Issuer:      cfg.Issuer,
+		Audience:    cfg.Audience,
+		MaxTokenTTL: maxTokenTTL(cfg),
+	}), nil
+}
+
+// newEncrypter creates an encrypter from a base64 encoded key. It returns nil if the key is empty.
+func newEncrypter(key string) (*api.Encrypter, error) {
+	if key == "" {
+		return nil, nil
+	}
+
+	b, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryption key: %w", err)
+	}
+
+	enc, err := api.NewEncrypter(b)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryption key: %w", err)
+	}
+
+	return enc, nil
+}
+
+// newWebAuthn creates the WebAuthn relying party. It returns nil if passkeys are disabled.
+func newWebAuthn(cfg config.WebAuthn) (*webauthn.WebAuthn, error) {
+	if cfg.RPID == "" {
+		return nil, nil
+	}
+
+	wa, err := webauthn.New(&webauthn.Config{
+		RPID:          cfg.RPID,
+		RPDisplayName: cfg.RPDisplayName,
+		RPOrigins:     cfg.RPOrigins,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid webauthn config: %w", err)
+	}
+
+	return wa, nil
+}
+
+// newHasher creates a hasher which hashes secrets using the configured algorithm and still verifies the hashes made by
+// other supported ones.
+func newHasher(cfg config.PasswordHash) (api.Hasher, error) {
+	cost := cfg.BcryptCost
+	if cost == 0 {
+		cost = bcrypt.DefaultCost
+	} else if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return nil, fmt.Errorf("invalid bcrypt cost: %d", cost)
+	}
+
+	params := api.DefaultArgon2idParams
+	if cfg.Argon2Memory != 0 {
+		params.Memory = cfg.Argon2Memory
+	}
+	if cfg.Argon2Iterations != 0 {
+		params.Iterations = cfg.Argon2Iterations
+	}
+	if cfg.Argon2Parallelism != 0 {
+		params.Parallelism = cfg.Argon2Parallelism
+	}
+
+	bcryptHasher := api.NewBcryptHasher(cost)
+	argon2Hasher := api.NewArgon2idHasher(params)
+
+	switch cfg.Alg {
+	case api.HashAlgArgon2id:
+		return api.NewMultiHasher(argon2Hasher, bcryptHasher), nil
+	case api.HashAlgBcrypt:
+		return api.NewMultiHasher(bcryptHasher, argon2Hasher), nil
+	default:
+		return nil, fmt.Errorf("unsupported password hash algorithm: %s", cfg.Alg)
+	}
+}
+
+// newPasswordPolicy creates a password policy from the config loading the denylist file if it is specified. Secrets
+// are limited to the length bcrypt accepts if it is the hash algorithm, whatever the configured max length is.
+func newPasswordPolicy(cfg config.PasswordPolicy, hashAlg string) (api.PasswordPolicy, error) {
+	if cfg.MinLength < 0 || cfg.MaxLength < 0 || (cfg.MaxLength != 0 && cfg.MaxLength < cfg.MinLength) {
+		return api.PasswordPolicy{}, fmt.Errorf("invalid password length limits: %d..%d", cfg.MinLength, cfg.MaxLength)
+	}
+
+	var maxBytes int
+	if hashAlg == api.HashAlgBcrypt {
+		if cfg.MinLength > api.BcryptMaxSecretLength {
+			return api.PasswordPolicy{}, fmt.Errorf("password min length exceeds %d bytes bcrypt accepts: %d",
+				api.BcryptMaxSecretLength, cfg.MinLength)
+		}
+		maxBytes = api.BcryptMaxSecretLength
+	}
+
+	policy := api.PasswordPolicy{
+		MinLength:     cfg.MinLength,
+		MaxLength:     cfg.MaxLength,
+		MaxBytes:      maxBytes,
+		RequireLower:  cfg.RequireLower,
+		RequireUpper:  cfg.RequireUpper,
+		RequireDigit:  cfg.RequireDigit,
+		RequireSymbol: cfg.RequireSymbol,
+		DisallowLogin: cfg.DisallowLogin,
+		DisallowAttrs: cfg.DisallowAttrs,
+	}
+
+	if cfg.Denylist != "" {
+		denylist, err := api.LoadPasswordDenylist(cfg.Denylist)
+		if err != nil {
+			return api.PasswordPolicy{}, fmt.Errorf("load password denylist: %w", err)
+		}
+		policy.Denylist = denylist
+	}
+
+	return policy, nil
+}
+
+// rateLimitRules converts the configured rate limits to the form accepted by the rate limiting interceptor.
+func rateLimitRules(cfg config.RateLimit) (map[string][]interceptor.RateLimitRule, error) {
+	r := make(map[string][]interceptor.RateLimitRule)
+	if cfg.Disabled {
+		return r, nil
+	}
+
+	for _, rule := range cfg.Rules {
+		key := interceptor.RateLimitKey(rule.Key)
+		if key != interceptor.RateLimitByPeer && key != interceptor.RateLimitByEntity {
+			return nil, fmt.Errorf("invalid rate limit key of %s: %q", rule.Procedure, rule.Key)
+		}
+
+		if rule.Rate <= 0 || rule.Burst <= 0 {
+			return nil, fmt.Errorf("invalid rate limit of %s: rate and burst must be positive", rule.Procedure)
+		}
+
+		r[rule.Procedure] = append(r[rule.Procedure], interceptor.RateLimitRule{
+			Key:   key,
+			Rate:  rule.Rate,
+			Burst: rule.Burst,
+		})
+	}
+
+	return r, nil
+}
+
+// mfaScope returns the scopes which are granted only to entities having passed the second authentication factor.
+func mfaScope(cfg config.Config) api.Scope {
+	if cfg.MFA.RequireForAdmin != nil && !*cfg.MFA.RequireForAdmin {
+		return nil
+	}
+
+	return api.Scope{cfg.AdminScope}
+}
+
+// maxTokenTTL returns the longest lifetime of tokens issued according to the config. Client overrides are capped to it.
+func maxTokenTTL(cfg config.Config) time.Duration {
+	ttl := cfg.AccessTokenTTL
+	if cfg.RefreshTokenTTL > ttl {
+		ttl = cfg.RefreshTokenTTL
+	}
+
+	return time.Duration(ttl) * time.Second
+}
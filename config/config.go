@@ -7,16 +7,160 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
+const (
+	DefaultAdminScope         = "a23n:admin"
+	DefaultIntrospectionScope = "a23n:introspect"
+	DefaultTokenExchangeScope = "a23n:token_exchange"
+	DefaultIssuer             = "a23n"
+	DefaultAudience           = "a23n"
+	DefaultPasswordHashAlg    = "argon2id"
+	DefaultPasswordMinLength  = 8
+	DefaultPasswordMaxLength  = 128
+	DefaultMFARequireForAdmin = true
+
+	DefaultLockoutEntityThreshold = 5
+	DefaultLockoutSourceThreshold = 20
+	DefaultLockoutDuration        = 60
+	DefaultLockoutMaxDuration     = 3600
+	DefaultLockoutWindow          = 900
+)
+
 type Database struct {
 	DSN string `yaml:"dsn"`
 }
 
+// Admin describes an entity which is created on the first start if it does not exist yet.
+type Admin struct {
+	ID     string `yaml:"id"`
+	Secret string `yaml:"secret"`
+}
+
+// PasswordHash defines how entity secrets are hashed. Secrets hashed by another algorithm or with other parameters are
+// rehashed on successful authentication. Zero parameters are replaced with defaults, argon2 memory is measured in KiB.
+type PasswordHash struct {
+	Alg               string `yaml:"alg"`
+	BcryptCost        int    `yaml:"bcrypt_cost"`
+	Argon2Memory      uint32 `yaml:"argon2_memory"`
+	Argon2Iterations  uint32 `yaml:"argon2_iterations"`
+	Argon2Parallelism uint8  `yaml:"argon2_parallelism"`
+}
+
+// PasswordPolicy defines the requirements entity secrets must meet. Denylist is a path to a file containing common
+// secrets which are not allowed, one per line. Secrets are also limited to 72 bytes if they are hashed by bcrypt.
+type PasswordPolicy struct {
+	MinLength     int    `yaml:"min_length"`
+	MaxLength     int    `yaml:"max_length"`
+	RequireLower  bool   `yaml:"require_lower"`
+	RequireUpper  bool   `yaml:"require_upper"`
+	RequireDigit  bool   `yaml:"require_digit"`
+	RequireSymbol bool   `yaml:"require_symbol"`
+	Denylist      string `yaml:"denylist"`
+	DisallowLogin bool   `yaml:"disallow_login"`
+	DisallowAttrs bool   `yaml:"disallow_attrs"`
+}
+
+// Lockout defines how entities and client IP addresses are locked out after failed authentication attempts. Durations
+// are measured in seconds. A negative threshold disables the lockout.
+type Lockout struct {
+	EntityThreshold int  `yaml:"entity_threshold"`
+	SourceThreshold int  `yaml:"source_threshold"`
+	Duration        uint `yaml:"duration"`
+	MaxDuration     uint `yaml:"max_duration"`
+	Window          uint `yaml:"window"`
+}
+
+// RateLimitRule defines a token bucket limiting requests to a procedure, or to all procedures if it is "*", per key,
+// which is either "peer" or "entity". The OAuth 2.0 endpoints are limited by the "/oauth2/token" and
+// "/oauth2/authorize" rules. Token requests are limited per entity by the username of the password grant or the client
+// of the client_credentials grant, and authorization requests by the login entered on the login page. Rate is
+// measured in requests per second.
+type RateLimitRule struct {
+	Procedure string  `yaml:"procedure"`
+	Key       string  `yaml:"key"`
+	Rate      float64 `yaml:"rate"`
+	Burst     int     `yaml:"burst"`
+}
+
+// RateLimit defines limits of request rates. Default rules are used if none are specified.
+type RateLimit struct {
+	Disabled bool            `yaml:"disabled"`
+	Rules    []RateLimitRule `yaml:"rules"`
+}
+
+// MFA defines requirements of the second authentication factor. Unless RequireForAdmin is explicitly set to false,
+// the admin scope is not granted to entities until they enable TOTP and authenticate with it. They still can enroll
+// TOTP meanwhile, so a freshly created admin has to do it before managing anything.
+type MFA struct {
+	RequireForAdmin *bool `yaml:"require_for_admin"`
+}
+
+// WebAuthn defines the relying party passkeys are registered for. Passkeys are disabled unless RPID is set. RPOrigins
+// are the origins of web pages allowed to run WebAuthn ceremonies, "https://" followed by RPID by default.
+type WebAuthn struct {
+	RPID          string   `yaml:"rp_id"`
+	RPDisplayName string   `yaml:"rp_display_name"`
+	RPOrigins     []string `yaml:"rp_origins"`
+}
+
+// TokenExchange defines who can exchange tokens and for which audiences. Callers must hold Scope and are recorded as
+// actors in exchanged tokens. Audiences lists the audiences tokens can be exchanged for besides the service's own one.
+type TokenExchange struct {
+	Scope     string   `yaml:"scope"`
+	Audiences []string `yaml:"audiences"`
+}
+
+// OAuth2Client describes a public OAuth 2.0 client using the authorization code grant, which is registered on start if
+// it does not exist yet. Other clients are managed by the client RPCs.
+type OAuth2Client struct {
+	ID           string   `yaml:"id"`
+	Name         string   `yaml:"name"`
+	RedirectURIs []string `yaml:"redirect_uris"`
+}
+
+// OAuth2 defines the OAuth 2.0 authorization server. It acts as an OpenID Connect provider as well if the issuer is an
+// https URL with no path the service is reachable at and an asymmetric signing algorithm is used.
+type OAuth2 struct {
+	Clients []OAuth2Client `yaml:"clients"`
+}
+
+// DefaultRateLimitRules are meant to slow down credential stuffing without affecting regular clients.
+var DefaultRateLimitRules = []RateLimitRule{
+	{Procedure: "*", Key: "peer", Rate: 50, Burst: 100},
+	{Procedure: "/a23n.v1.AuthService/Authenticate", Key: "peer", Rate: 0.2, Burst: 10},
+	{Procedure: "/a23n.v1.AuthService/Authenticate", Key: "entity", Rate: 0.1, Burst: 5},
+	{Procedure: "/a23n.v1.AuthService/AuthenticateTOTP", Key: "peer", Rate: 0.2, Burst: 10},
+	{Procedure: "/a23n.v1.AuthService/BeginWebAuthnLogin", Key: "peer", Rate: 1, Burst: 10},
+	{Procedure: "/a23n.v1.AuthService/FinishWebAuthnLogin", Key: "peer", Rate: 1, Burst: 10},
+	{Procedure: "/oauth2/token", Key: "peer", Rate: 1, Burst: 20},
+	{Procedure: "/oauth2/token", Key: "entity", Rate: 0.1, Burst: 5},
+	{Procedure: "/oauth2/authorize", Key: "peer", Rate: 1, Burst: 20},
+	{Procedure: "/oauth2/authorize", Key: "entity", Rate: 0.1, Burst: 5},
+}
+
+// Config is the service configuration. EncryptionKey is a base64 encoded 32 bytes long key used to encrypt sensitive
+// data stored in the database, like rotated signing keys and TOTP secrets. Data is stored unencrypted if it is empty.
 type Config struct {
-	DB              Database `yaml:"db"`
-	Address         string   `yaml:"address"`
-	Secret          string   `yaml:"secret"`
-	AccessTokenTTL  uint     `yaml:"access_token_ttl"`
-	RefreshTokenTTL uint     `yaml:"refresh_token_ttl"`
+	DB                 Database       `yaml:"db"`
+	Address            string         `yaml:"address"`
+	Secret             string         `yaml:"secret"`
+	SigningAlg         string         `yaml:"signing_alg"`
+	SigningKey         string         `yaml:"signing_key"`
+	EncryptionKey      string         `yaml:"encryption_key"`
+	Issuer             string         `yaml:"issuer"`
+	Audience           string         `yaml:"audience"`
+	AccessTokenTTL     uint           `yaml:"access_token_ttl"`
+	RefreshTokenTTL    uint           `yaml:"refresh_token_ttl"`
+	AdminScope         string         `yaml:"admin_scope"`
+	IntrospectionScope string         `yaml:"introspection_scope"`
+	TokenExchange      TokenExchange  `yaml:"token_exchange"`
+	PasswordHash       PasswordHash   `yaml:"password_hash"`
+	PasswordPolicy     PasswordPolicy `yaml:"password_policy"`
+	Lockout            Lockout        `yaml:"lockout"`
+	RateLimit          RateLimit      `yaml:"rate_limit"`
+	MFA                MFA            `yaml:"mfa"`
+	WebAuthn           WebAuthn       `yaml:"webauthn"`
+	OAuth2             OAuth2         `yaml:"oauth2"`
+	Admin              Admin          `yaml:"admin"`
 }
 
 func Parse(in []byte) (Config, error) {
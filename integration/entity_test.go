@@ -0,0 +1,361 @@
+package integration_test
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/ashep/a23n/api"
+	"github.com/ashep/a23n/api/webauthntest"
+	"github.com/ashep/a23n/sqldb"
+)
+
+type EntityTestSuite struct {
+	suite.Suite
+
+	db  sqldb.DB
+	api *api.DefaultAPI
+}
+
+func (s *EntityTestSuite) SetupTest() {
+	s.db = newDB(s.T())
+	s.api = api.NewDefault(s.db, api.Options{
+		Key:      api.NewHMACKey([]byte("theSecret")),
+		Hasher:   newHasher(),
+		Issuer:   "a23n",
+		Audience: "a23n",
+	})
+}
+
+func (s *EntityTestSuite) TestCreateGet() {
+	ctx := context.Background()
+	id := uuid.NewString()
+
+	attrs := api.Attrs{"theAttr": "theValue"}
+	err := s.api.CreateEntity(ctx, id, "", "theSecret", api.Scope{"foo", "bar"}, attrs)
+	s.Require().NoError(err)
+
+	e, err := s.api.GetEntity(ctx, id)
+	s.Require().NoError(err)
+
+	s.Assert().Equal(id, e.ID)
+	s.Assert().Equal(api.Scope{"foo", "bar"}, e.Scope)
+	s.Assert().Equal(api.Attrs{"theAttr": "theValue"}, e.Attrs)
+	s.Assert().False(e.CreatedAt.IsZero())
+	s.Assert().True(e.UpdatedAt.Equal(e.CreatedAt))
+	s.Assert().True(e.SecretChangedAt.Equal(e.CreatedAt))
+	s.Assert().True(e.LastAuthenticatedAt.IsZero())
+
+	_, err = s.api.VerifyCredentials(ctx, id, "theSecret", "")
+	s.Require().NoError(err)
+
+	e, err = s.api.GetEntity(ctx, id)
+	s.Require().NoError(err)
+	s.Assert().False(e.LastAuthenticatedAt.IsZero())
+
+	_, err = s.api.VerifyCredentials(ctx, id, "wrongSecret", "")
+	s.Require().ErrorIs(err, api.ErrInvalidCredentials)
+
+	_, err = s.api.VerifyCredentials(ctx, uuid.NewString(), "theSecret", "")
+	s.Require().ErrorIs(err, api.ErrInvalidCredentials)
+}
+
+func (s *EntityTestSuite) TestCreateDuplicate() {
+	ctx := context.Background()
+	id := uuid.NewString()
+
+	s.Require().NoError(s.api.CreateEntity(ctx, id, "", "theSecret", nil, nil))
+	s.Require().ErrorIs(s.api.CreateEntity(ctx, id, "", "theSecret", nil, nil), api.ErrAlreadyExists)
+}
+
+func (s *EntityTestSuite) TestGetNotFound() {
+	_, err := s.api.GetEntity(context.Background(), uuid.NewString())
+	s.Require().ErrorIs(err, api.ErrNotFound)
+
+	_, err = s.api.GetEntity(context.Background(), "notAUUID")
+	s.Require().ErrorIs(err, api.ErrNotFound)
+}
+
+func (s *EntityTestSuite) TestUpdate() {
+	ctx := context.Background()
+	id := uuid.NewString()
+
+	s.Require().NoError(s.api.CreateEntity(ctx, id, "", "theSecret", api.Scope{"foo"}, nil))
+
+	created, err := s.api.GetEntity(ctx, id)
+	s.Require().NoError(err)
+
+	err = s.api.UpdateEntity(ctx, id, "", "theNewSecret", api.Scope{"bar"}, api.Attrs{"theAttr": "theValue"})
+	s.Require().NoError(err)
+
+	e, err := s.api.GetEntity(ctx, id)
+	s.Require().NoError(err)
+	s.Assert().Equal(api.Scope{"bar"}, e.Scope)
+	s.Assert().Equal(api.Attrs{"theAttr": "theValue"}, e.Attrs)
+
+	_, err = s.api.VerifyCredentials(ctx, id, "theNewSecret", "")
+	s.Require().NoError(err)
+
+	// Empty secret keeps the current one
+	s.Require().NoError(s.api.UpdateEntity(ctx, id, "", "", api.Scope{"baz"}, nil))
+
+	e, err = s.api.GetEntity(ctx, id)
+	s.Require().NoError(err)
+	s.Assert().Equal(api.Scope{"baz"}, e.Scope)
+	s.Assert().True(e.UpdatedAt.After(created.UpdatedAt))
+	s.Assert().True(e.SecretChangedAt.After(created.SecretChangedAt))
+	s.Assert().True(e.SecretChangedAt.Before(e.UpdatedAt))
+
+	_, err = s.api.VerifyCredentials(ctx, id, "theNewSecret", "")
+	s.Require().NoError(err)
+}
+
+func (s *EntityTestSuite) TestUpdateNotFound() {
+	err := s.api.UpdateEntity(context.Background(), uuid.NewString(), "", "", api.Scope{"foo"}, nil)
+	s.Require().ErrorIs(err, api.ErrNotFound)
+}
+
+func (s *EntityTestSuite) TestLogin() {
+	ctx := context.Background()
+	id := uuid.NewString()
+
+	s.Require().NoError(s.api.CreateEntity(ctx, id, "Alice@Example.com", "theSecret", nil, nil))
+
+	e, err := s.api.VerifyCredentials(ctx, "alice@example.com", "theSecret", "")
+	s.Require().NoError(err)
+	s.Assert().Equal(id, e.ID)
+	s.Assert().Equal("Alice@Example.com", e.Login)
+
+	_, err = s.api.VerifyCredentials(ctx, "alice@example.com", "wrongSecret", "")
+	s.Require().ErrorIs(err, api.ErrInvalidCredentials)
+
+	err = s.api.CreateEntity(ctx, uuid.NewString(), "ALICE@example.com", "theSecret", nil, nil)
+	s.Require().ErrorIs(err, api.ErrAlreadyExists)
+
+	otherID := uuid.NewString()
+	s.Require().NoError(s.api.CreateEntity(ctx, otherID, "", "theSecret", nil, nil))
+	s.Require().ErrorIs(s.api.UpdateEntity(ctx, otherID, "alice@EXAMPLE.com", "", nil, nil), api.ErrAlreadyExists)
+	s.Require().NoError(s.api.UpdateEntity(ctx, otherID, "bob", "", nil, nil))
+
+	e, err = s.api.VerifyCredentials(ctx, "Bob", "theSecret", "")
+	s.Require().NoError(err)
+	s.Assert().Equal(otherID, e.ID)
+}
+
+func (s *EntityTestSuite) TestList() {
+	ctx := context.Background()
+	ids := make([]string, 0)
+
+	for i := 0; i < 5; i++ {
+		id := uuid.NewString()
+		ids = append(ids, id)
+
+		scope := api.Scope{"foo"}
+		if i%2 == 0 {
+			scope = append(scope, "bar")
+		}
+
+		attrs := api.Attrs{"n": fmt.Sprint(i)}
+		s.Require().NoError(s.api.CreateEntity(ctx, id, "", "theSecret", scope, attrs))
+	}
+
+	got := make([]string, 0)
+	cursor := ""
+	for {
+		res, next, err := s.api.ListEntities(ctx, api.EntityFilter{}, cursor, 2)
+		s.Require().NoError(err)
+		for _, e := range res {
+			got = append(got, e.ID)
+		}
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	s.Assert().ElementsMatch(ids, got)
+
+	res, _, err := s.api.ListEntities(ctx, api.EntityFilter{Scope: api.Scope{"bar"}}, "", 0)
+	s.Require().NoError(err)
+	s.Assert().Len(res, 3)
+
+	res, _, err = s.api.ListEntities(ctx, api.EntityFilter{Scope: api.Scope{"foo"}, Attrs: api.Attrs{"n": "3"}}, "", 0)
+	s.Require().NoError(err)
+	s.Require().Len(res, 1)
+	s.Assert().Equal(ids[3], res[0].ID)
+}
+
+func (s *EntityTestSuite) TestLockout() {
+	ctx := context.Background()
+	id := uuid.NewString()
+
+	a := api.NewDefault(s.db, api.Options{
+		Key:      api.NewHMACKey([]byte("theSecret")),
+		Hasher:   newHasher(),
+		Lockout:  api.LockoutPolicy{EntityThreshold: 2, SourceThreshold: 3, Duration: time.Hour, Window: time.Hour},
+		Issuer:   "a23n",
+		Audience: "a23n",
+	})
+	s.Require().NoError(a.CreateEntity(ctx, id, "", "theSecret", nil, nil))
+
+	_, err := a.VerifyCredentials(ctx, id, "wrongSecret", "192.0.2.1")
+	s.Require().ErrorIs(err, api.ErrInvalidCredentials)
+	_, err = a.VerifyCredentials(ctx, id, "wrongSecret", "192.0.2.2")
+	s.Require().ErrorIs(err, api.ErrInvalidCredentials)
+
+	// The entity is locked out even for the correct secret, which cannot be told from an unknown entity
+	_, err = a.VerifyCredentials(ctx, id, "theSecret", "192.0.2.3")
+	s.Require().Equal(api.ErrInvalidCredentials, err)
+
+	e, err := a.GetEntity(ctx, id)
+	s.Require().NoError(err)
+	s.Assert().Equal(2, e.FailedAuthCount)
+	s.Assert().True(e.LockedUntil.After(time.Now()))
+
+	s.Require().NoError(a.UnlockEntity(ctx, id))
+	e, err = a.GetEntity(ctx, id)
+	s.Require().NoError(err)
+	s.Assert().Zero(e.FailedAuthCount)
+	s.Assert().True(e.LockedUntil.IsZero())
+
+	_, err = a.VerifyCredentials(ctx, id, "theSecret", "192.0.2.3")
+	s.Require().NoError(err)
+	s.Require().ErrorIs(a.UnlockEntity(ctx, uuid.NewString()), api.ErrNotFound)
+
+	// The source is locked out after failures for any entities
+	for i := 0; i < 3; i++ {
+		_, err = a.VerifyCredentials(ctx, uuid.NewString(), "theSecret", "192.0.2.4")
+		s.Require().ErrorIs(err, api.ErrInvalidCredentials)
+	}
+	_, err = a.VerifyCredentials(ctx, id, "theSecret", "192.0.2.4")
+	s.Require().ErrorIs(err, api.ErrLocked{})
+
+	s.Require().NoError(a.UnlockSource(ctx, "192.0.2.4"))
+	_, err = a.VerifyCredentials(ctx, id, "theSecret", "192.0.2.4")
+	s.Require().NoError(err)
+	s.Require().ErrorIs(a.UnlockSource(ctx, "192.0.2.4"), api.ErrNotFound)
+}
+
+// totpCode returns the current TOTP code of a base32 encoded secret.
+func totpCode(secret string) string {
+	return totpCodeAt(secret, time.Now())
+}
+
+// totpCodeAt returns the TOTP code of a base32 encoded secret at the time.
+func totpCodeAt(secret string, t time.Time) string {
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		panic(err)
+	}
+
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, uint64(t.Unix()/30))
+
+	m := hmac.New(sha1.New, key)
+	m.Write(msg)
+	sum := m.Sum(nil)
+	off := sum[len(sum)-1] & 0x0f
+
+	return fmt.Sprintf("%06d", (binary.BigEndian.Uint32(sum[off:off+4])&0x7fffffff)%1000000)
+}
+
+func (s *EntityTestSuite) TestTOTP() {
+	ctx := context.Background()
+	id := uuid.NewString()
+
+	s.Require().NoError(s.api.CreateEntity(ctx, id, "", "theSecret", nil, nil))
+
+	key, err := s.api.EnrollTOTP(ctx, id)
+	s.Require().NoError(err)
+
+	// The secret is not required until it is confirmed
+	e, err := s.api.VerifyCredentials(ctx, id, "theSecret", "")
+	s.Require().NoError(err)
+	s.Assert().False(e.TOTPEnabled)
+
+	s.Require().ErrorIs(s.api.ConfirmTOTP(ctx, id, "000000"), api.ErrInvalidCredentials)
+	code := totpCode(key.Secret)
+	s.Require().NoError(s.api.ConfirmTOTP(ctx, id, code))
+
+	e, err = s.api.VerifyCredentials(ctx, id, "theSecret", "")
+	s.Require().NoError(err)
+	s.Assert().True(e.TOTPEnabled)
+
+	// The code used for confirmation cannot be used again
+	s.Require().ErrorIs(s.api.VerifyTOTP(ctx, id, code, ""), api.ErrInvalidCredentials)
+
+	s.Require().NoError(s.api.DisableTOTP(ctx, id))
+	e, err = s.api.GetEntity(ctx, id)
+	s.Require().NoError(err)
+	s.Assert().False(e.TOTPEnabled)
+}
+
+func (s *EntityTestSuite) TestWebAuthn() {
+	ctx := context.Background()
+	id := uuid.NewString()
+
+	wa, err := webauthn.New(&webauthn.Config{
+		RPID:          "example.com",
+		RPDisplayName: "Example",
+		RPOrigins:     []string{"https://example.com"},
+	})
+	s.Require().NoError(err)
+
+	a := api.NewDefault(s.db, api.Options{
+		Key:      api.NewHMACKey([]byte("theSecret")),
+		Hasher:   newHasher(),
+		WebAuthn: wa,
+		Issuer:   "a23n",
+		Audience: "a23n",
+	})
+
+	s.Require().NoError(a.CreateEntity(ctx, id, "", "theSecret", nil, nil))
+
+	auth := webauthntest.New("https://example.com")
+
+	ch, err := a.BeginWebAuthnRegistration(ctx, id)
+	s.Require().NoError(err)
+	resp, err := auth.Create(ch.Options)
+	s.Require().NoError(err)
+	_, err = a.FinishWebAuthnRegistration(ctx, id, ch.ID, resp, true)
+	s.Require().NoError(err)
+
+	// Each challenge can be answered only once
+	_, err = a.FinishWebAuthnRegistration(ctx, id, ch.ID, resp, true)
+	s.Require().ErrorIs(err, api.ErrInvalidArg{})
+
+	ch, err = a.BeginWebAuthnLogin(ctx)
+	s.Require().NoError(err)
+	resp, err = auth.Get(ch.Options)
+	s.Require().NoError(err)
+
+	e, mfa, err := a.FinishWebAuthnLogin(ctx, ch.ID, resp, "")
+	s.Require().NoError(err)
+	s.Assert().Equal(id, e.ID)
+	s.Assert().True(mfa)
+
+	// The same assertion cannot be replayed against a new challenge
+	ch, err = a.BeginWebAuthnLogin(ctx)
+	s.Require().NoError(err)
+	_, _, err = a.FinishWebAuthnLogin(ctx, ch.ID, resp, "")
+	s.Require().ErrorIs(err, api.ErrInvalidCredentials)
+
+	ch, err = a.BeginWebAuthnLogin(ctx)
+	s.Require().NoError(err)
+	resp, err = auth.Get(ch.Options)
+	s.Require().NoError(err)
+	_, _, err = a.FinishWebAuthnLogin(ctx, ch.ID, resp, "")
+	s.Require().NoError(err)
+}
+
+func TestEntity(t *testing.T) {
+	suite.Run(t, new(EntityTestSuite))
+}
@@ -0,0 +1,200 @@
+package integration_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/ashep/a23n/api"
+	"github.com/ashep/a23n/migration"
+	"github.com/ashep/a23n/sqldb"
+)
+
+// adminDSN points to a Postgres server used to create a database per test. It is taken from the A23N_TEST_DB_DSN
+// environment variable or, if it is not set, a throwaway server is started using local Postgres binaries. Tests are
+// skipped if neither is available.
+var adminDSN string
+
+func TestMain(m *testing.M) {
+	os.Exit(run(m))
+}
+
+func run(m *testing.M) int {
+	if adminDSN = os.Getenv("A23N_TEST_DB_DSN"); adminDSN != "" {
+		return m.Run()
+	}
+
+	stop, err := startPostgres()
+	if err != nil {
+		fmt.Printf("integration tests will be skipped: %s\n", err)
+		return m.Run()
+	}
+	defer stop()
+
+	return m.Run()
+}
+
+// findPostgres looks for a directory containing the initdb and postgres binaries.
+func findPostgres() (string, error) {
+	if dir := os.Getenv("A23N_TEST_PG_BIN"); dir != "" {
+		return dir, nil
+	}
+
+	if p, err := exec.LookPath("initdb"); err == nil {
+		return filepath.Dir(p), nil
+	}
+
+	for _, pattern := range []string{"/usr/lib/postgresql/*/bin", "/usr/local/pgsql/bin", "/opt/homebrew/bin"} {
+		dirs, _ := filepath.Glob(pattern)
+		for i := len(dirs) - 1; i >= 0; i-- {
+			if _, err := os.Stat(filepath.Join(dirs[i], "initdb")); err == nil {
+				return dirs[i], nil
+			}
+		}
+	}
+
+	return "", errors.New("postgres binaries not found, set A23N_TEST_PG_BIN or A23N_TEST_DB_DSN")
+}
+
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+// startPostgres initializes a cluster in a temporary directory and starts a server on a free port.
+func startPostgres() (func(), error) {
+	if os.Geteuid() == 0 {
+		return nil, errors.New("postgres cannot be run as root")
+	}
+
+	binDir, err := findPostgres()
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := os.MkdirTemp("", "a23n-pg-")
+	if err != nil {
+		return nil, err
+	}
+
+	dataDir := filepath.Join(dir, "data")
+	out, err := exec.Command(filepath.Join(binDir, "initdb"),
+		"-D", dataDir, "-U", "postgres", "-A", "trust", "-E", "UTF8", "--no-sync").CombinedOutput()
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, fmt.Errorf("initdb failed: %w: %s", err, out)
+	}
+
+	port, err := freePort()
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, err
+	}
+
+	cmd := exec.Command(filepath.Join(binDir, "postgres"),
+		"-D", dataDir,
+		"-p", fmt.Sprint(port),
+		"-k", dir,
+		"-c", "listen_addresses=127.0.0.1",
+		"-c", "fsync=off",
+	)
+	if err = cmd.Start(); err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, err
+	}
+
+	stop := func() {
+		_ = cmd.Process.Signal(os.Interrupt)
+		_ = cmd.Wait()
+		_ = os.RemoveAll(dir)
+	}
+
+	adminDSN = fmt.Sprintf("postgres://postgres@127.0.0.1:%d/postgres?sslmode=disable", port)
+
+	db, err := sql.Open("postgres", adminDSN)
+	if err != nil {
+		stop()
+		return nil, err
+	}
+	defer db.Close()
+
+	deadline := time.Now().Add(time.Second * 30)
+	for {
+		if err = db.Ping(); err == nil {
+			return stop, nil
+		} else if time.Now().After(deadline) {
+			stop()
+			return nil, fmt.Errorf("postgres has not started: %w", err)
+		}
+		time.Sleep(time.Millisecond * 100)
+	}
+}
+
+// newDB creates an empty database with all the migrations applied. The database is dropped when the test finishes.
+func newDB(t *testing.T) sqldb.DB {
+	t.Helper()
+
+	if adminDSN == "" {
+		t.Skip("postgres is not available")
+	}
+
+	admin, err := sql.Open("postgres", adminDSN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = admin.Close() })
+
+	name := fmt.Sprintf("a23n_test_%d_%d", time.Now().UnixNano(), rand.Int63())
+	if _, err = admin.Exec("CREATE DATABASE " + name); err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := url.Parse(adminDSN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u.Path = "/" + name
+
+	db, err := sqldb.NewPostgres(u.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		_ = db.DB().Close()
+		if _, err := admin.ExecContext(context.Background(), "DROP DATABASE "+name); err != nil {
+			t.Errorf("failed to drop database: %s", err)
+		}
+	})
+
+	if err = migration.Up(db); err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+// newHasher returns a hasher which is cheap enough for tests and still verifies bcrypt hashes.
+func newHasher() api.Hasher {
+	return api.NewMultiHasher(
+		api.NewArgon2idHasher(api.Argon2idParams{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}),
+		api.NewBcryptHasher(bcrypt.MinCost),
+	)
+}
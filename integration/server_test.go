@@ -0,0 +1,790 @@
+package integration_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/ashep/a23n/api"
+	"github.com/ashep/a23n/sdk/proto/a23n/v1"
+	"github.com/ashep/a23n/sdk/proto/a23n/v1/v1connect"
+	"github.com/ashep/a23n/server"
+)
+
+type ServerTestSuite struct {
+	suite.Suite
+
+	api    *api.DefaultAPI
+	srv    *httptest.Server
+	client v1connect.AuthServiceClient
+}
+
+func (s *ServerTestSuite) SetupTest() {
+	s.setup(api.NewHMACKey([]byte("theSecret")), "a23n")
+}
+
+// setup starts a server signing tokens by key and having iss as the issuer.
+func (s *ServerTestSuite) setup(key api.SigningKey, iss string) {
+	s.api = api.NewDefault(newDB(s.T()), api.Options{
+		Key:      key,
+		Hasher:   newHasher(),
+		Issuer:   iss,
+		Audience: "a23n",
+	})
+
+	srv := server.New(s.api, server.Options{
+		AccessTokenTTL:         time.Minute,
+		RefreshTokenTTL:        time.Hour,
+		AdminScope:             "a23n:admin",
+		IntrospectionScope:     "a23n:introspect",
+		TokenExchangeScope:     "a23n:token_exchange",
+		TokenExchangeAudiences: []string{"theBackend"},
+	}, zerolog.Nop())
+	s.srv = httptest.NewServer(srv.Handler())
+	s.T().Cleanup(s.srv.Close)
+	s.client = v1connect.NewAuthServiceClient(http.DefaultClient, s.srv.URL)
+}
+
+func (s *ServerTestSuite) createEntity(secret string, scope api.Scope) string {
+	id := uuid.NewString()
+	s.Require().NoError(s.api.CreateEntity(context.Background(), id, "", secret, scope, nil))
+
+	return id
+}
+
+func (s *ServerTestSuite) authenticate(id, secret string) (*v1.AuthenticateResponse, error) {
+	req := connect.NewRequest(&v1.AuthenticateRequest{})
+	req.Header().Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(id+":"+secret)))
+
+	res, err := s.client.Authenticate(context.Background(), req)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Msg, nil
+}
+
+func bearer[T any](msg *T, token string) *connect.Request[T] {
+	req := connect.NewRequest(msg)
+	req.Header().Set("Authorization", "Bearer "+token)
+
+	return req
+}
+
+func (s *ServerTestSuite) TestAuthenticateWrongSecret() {
+	id := s.createEntity("theSecret", nil)
+
+	_, err := s.authenticate(id, "wrongSecret")
+	s.Require().Equal(connect.CodeUnauthenticated, connect.CodeOf(err))
+
+	_, err = s.authenticate(uuid.NewString(), "theSecret")
+	s.Require().Equal(connect.CodeUnauthenticated, connect.CodeOf(err))
+}
+
+func (s *ServerTestSuite) TestAuthenticateAndRefresh() {
+	ctx := context.Background()
+	id := s.createEntity("theSecret", api.Scope{"foo"})
+
+	at, err := s.authenticate(id, "theSecret")
+	s.Require().NoError(err)
+	s.Require().NotEmpty(at.AccessToken)
+	s.Require().NotEmpty(at.RefreshToken)
+
+	ge, err := s.client.GetEntity(ctx, bearer(&v1.GetEntityRequest{}, at.AccessToken))
+	s.Require().NoError(err)
+	s.Assert().Equal(id, ge.Msg.Id)
+	s.Assert().Equal([]string{"foo"}, ge.Msg.Scope)
+	s.Assert().False(ge.Msg.Disabled)
+
+	rt, err := s.client.RefreshToken(ctx, bearer(&v1.RefreshTokenRequest{}, at.RefreshToken))
+	s.Require().NoError(err)
+	s.Require().NotEmpty(rt.Msg.Token)
+
+	// Reusing a rotated refresh token revokes the whole family
+	_, err = s.client.RefreshToken(ctx, bearer(&v1.RefreshTokenRequest{}, at.RefreshToken))
+	s.Require().Equal(connect.CodeUnauthenticated, connect.CodeOf(err))
+
+	_, err = s.client.RefreshToken(ctx, bearer(&v1.RefreshTokenRequest{}, rt.Msg.RefreshToken))
+	s.Require().Equal(connect.CodeUnauthenticated, connect.CodeOf(err))
+}
+
+func (s *ServerTestSuite) TestLogout() {
+	ctx := context.Background()
+	id := s.createEntity("theSecret", nil)
+
+	at, err := s.authenticate(id, "theSecret")
+	s.Require().NoError(err)
+
+	_, err = s.client.Logout(ctx, bearer(&v1.LogoutRequest{RefreshToken: at.RefreshToken}, at.AccessToken))
+	s.Require().NoError(err)
+
+	_, err = s.client.GetEntity(ctx, bearer(&v1.GetEntityRequest{}, at.AccessToken))
+	s.Require().Equal(connect.CodeUnauthenticated, connect.CodeOf(err))
+
+	_, err = s.client.RefreshToken(ctx, bearer(&v1.RefreshTokenRequest{}, at.RefreshToken))
+	s.Require().Equal(connect.CodeUnauthenticated, connect.CodeOf(err))
+}
+
+func (s *ServerTestSuite) TestTOTP() {
+	ctx := context.Background()
+	id := s.createEntity("theSecret", nil)
+
+	at, err := s.authenticate(id, "theSecret")
+	s.Require().NoError(err)
+
+	key, err := s.client.EnrollTOTP(ctx, bearer(&v1.EnrollTOTPRequest{}, at.AccessToken))
+	s.Require().NoError(err)
+	confirmReq := bearer(&v1.ConfirmTOTPRequest{TotpCode: totpCode(key.Msg.Secret)}, at.AccessToken)
+	_, err = s.client.ConfirmTOTP(ctx, confirmReq)
+	s.Require().NoError(err)
+
+	// The enabled secret cannot be replaced without its current code
+	_, err = s.client.EnrollTOTP(ctx, bearer(&v1.EnrollTOTPRequest{}, at.AccessToken))
+	s.Require().Equal(connect.CodeInvalidArgument, connect.CodeOf(err))
+	_, err = s.client.EnrollTOTP(ctx, bearer(&v1.EnrollTOTPRequest{TotpCode: "000000"}, at.AccessToken))
+	s.Require().Equal(connect.CodeUnauthenticated, connect.CodeOf(err))
+
+	mfa, err := s.authenticate(id, "theSecret")
+	s.Require().NoError(err)
+	s.Require().True(mfa.MfaRequired)
+
+	// The code of the next period is accepted, as the current one has been used for confirmation
+	code := totpCodeAt(key.Msg.Secret, time.Now().Add(30*time.Second))
+	_, err = s.client.AuthenticateTOTP(ctx, bearer(&v1.AuthenticateTOTPRequest{TotpCode: code}, mfa.MfaToken))
+	s.Require().NoError(err)
+
+	// The MFA token cannot be used again
+	_, err = s.client.AuthenticateTOTP(ctx, bearer(&v1.AuthenticateTOTPRequest{TotpCode: code}, mfa.MfaToken))
+	s.Require().Equal(connect.CodeUnauthenticated, connect.CodeOf(err))
+}
+
+func (s *ServerTestSuite) TestCreateEntityByAdmin() {
+	ctx := context.Background()
+
+	userID := s.createEntity("theSecret", nil)
+	userAT, err := s.authenticate(userID, "theSecret")
+	s.Require().NoError(err)
+
+	_, err = s.client.CreateEntity(ctx, bearer(&v1.CreateEntityRequest{Secret: "newSecret"}, userAT.AccessToken))
+	s.Require().Equal(connect.CodePermissionDenied, connect.CodeOf(err))
+
+	adminID := s.createEntity("adminSecret", api.Scope{"a23n:admin"})
+	adminAT, err := s.authenticate(adminID, "adminSecret")
+	s.Require().NoError(err)
+
+	ce, err := s.client.CreateEntity(ctx, bearer(&v1.CreateEntityRequest{
+		Secret: "newSecret",
+		Scope:  []string{"foo"},
+		Attrs:  map[string]string{"theAttr": "theValue"},
+	}, adminAT.AccessToken))
+	s.Require().NoError(err)
+
+	_, err = s.authenticate(ce.Msg.Id, "newSecret")
+	s.Require().NoError(err)
+
+	e, err := s.api.GetEntity(ctx, ce.Msg.Id)
+	s.Require().NoError(err)
+	s.Assert().Equal(api.Scope{"foo"}, e.Scope)
+	s.Assert().Equal(api.Attrs{"theAttr": "theValue"}, e.Attrs)
+}
+
+func (s *ServerTestSuite) TestDisableAndDeleteEntity() {
+	ctx := context.Background()
+
+	adminID := s.createEntity("adminSecret", api.Scope{"a23n:admin"})
+	adminAT, err := s.authenticate(adminID, "adminSecret")
+	s.Require().NoError(err)
+
+	userID := s.createEntity("theSecret", nil)
+	userAT, err := s.authenticate(userID, "theSecret")
+	s.Require().NoError(err)
+
+	_, err = s.client.DisableEntity(ctx, bearer(&v1.DisableEntityRequest{Id: userID}, adminAT.AccessToken))
+	s.Require().NoError(err)
+
+	_, err = s.client.GetEntity(ctx, bearer(&v1.GetEntityRequest{}, userAT.AccessToken))
+	s.Require().Equal(connect.CodeUnauthenticated, connect.CodeOf(err))
+
+	_, err = s.client.RefreshToken(ctx, bearer(&v1.RefreshTokenRequest{}, userAT.RefreshToken))
+	s.Require().Equal(connect.CodeUnauthenticated, connect.CodeOf(err))
+
+	_, err = s.authenticate(userID, "theSecret")
+	s.Require().Equal(connect.CodePermissionDenied, connect.CodeOf(err))
+
+	_, err = s.authenticate(userID, "wrongSecret")
+	s.Require().Equal(connect.CodeUnauthenticated, connect.CodeOf(err))
+
+	_, err = s.client.EnableEntity(ctx, bearer(&v1.EnableEntityRequest{Id: userID}, adminAT.AccessToken))
+	s.Require().NoError(err)
+
+	// Tokens issued right after enabling are accepted, while the ones issued before disabling remain revoked
+	newUserAT, err := s.authenticate(userID, "theSecret")
+	s.Require().NoError(err)
+
+	_, err = s.client.GetEntity(ctx, bearer(&v1.GetEntityRequest{}, newUserAT.AccessToken))
+	s.Require().NoError(err)
+
+	_, err = s.client.GetEntity(ctx, bearer(&v1.GetEntityRequest{}, userAT.AccessToken))
+	s.Require().Equal(connect.CodeUnauthenticated, connect.CodeOf(err))
+
+	_, err = s.client.DeleteEntity(ctx, bearer(&v1.DeleteEntityRequest{Id: userID}, adminAT.AccessToken))
+	s.Require().NoError(err)
+
+	_, err = s.authenticate(userID, "theSecret")
+	s.Require().Equal(connect.CodeUnauthenticated, connect.CodeOf(err))
+
+	_, err = s.client.DeleteEntity(ctx, bearer(&v1.DeleteEntityRequest{Id: userID}, adminAT.AccessToken))
+	s.Require().Equal(connect.CodeNotFound, connect.CodeOf(err))
+}
+
+func (s *ServerTestSuite) TestAPIKey() {
+	ctx := context.Background()
+	id := s.createEntity("theSecret", api.Scope{"foo", "bar"})
+
+	at, err := s.authenticate(id, "theSecret")
+	s.Require().NoError(err)
+
+	ck, err := s.client.CreateAPIKey(ctx, bearer(&v1.CreateAPIKeyRequest{
+		Name:  "theKey",
+		Scope: []string{"foo"},
+	}, at.AccessToken))
+	s.Require().NoError(err)
+	s.Require().True(strings.HasPrefix(ck.Msg.Key, api.APIKeyPrefix))
+
+	req := connect.NewRequest(&v1.GetEntityRequest{})
+	req.Header().Set("X-API-Key", ck.Msg.Key)
+	ge, err := s.client.GetEntity(ctx, req)
+	s.Require().NoError(err)
+	s.Assert().Equal(id, ge.Msg.Id)
+
+	req = connect.NewRequest(&v1.GetEntityRequest{})
+	req.Header().Set("Authorization", "ApiKey "+ck.Msg.Key)
+	_, err = s.client.GetEntity(ctx, req)
+	s.Require().NoError(err)
+
+	lk, err := s.client.ListAPIKeys(ctx, bearer(&v1.ListAPIKeysRequest{}, at.AccessToken))
+	s.Require().NoError(err)
+	s.Require().Len(lk.Msg.Keys, 1)
+	s.Assert().Equal(ck.Msg.Id, lk.Msg.Keys[0].Id)
+	s.Assert().Equal([]string{"foo"}, lk.Msg.Keys[0].Scope)
+	s.Assert().NotZero(lk.Msg.Keys[0].LastUsedAt)
+
+	_, err = s.client.RevokeAPIKey(ctx, bearer(&v1.RevokeAPIKeyRequest{Id: ck.Msg.Id}, at.AccessToken))
+	s.Require().NoError(err)
+
+	req = connect.NewRequest(&v1.GetEntityRequest{})
+	req.Header().Set("X-API-Key", ck.Msg.Key)
+	_, err = s.client.GetEntity(ctx, req)
+	s.Require().Equal(connect.CodeUnauthenticated, connect.CodeOf(err))
+
+	// Revoking the entity tokens revokes its keys as well, but not the ones created afterwards
+	ck, err = s.client.CreateAPIKey(ctx, bearer(&v1.CreateAPIKeyRequest{Name: "theOldKey"}, at.AccessToken))
+	s.Require().NoError(err)
+
+	adminID := s.createEntity("adminSecret", api.Scope{"a23n:admin"})
+	adminAT, err := s.authenticate(adminID, "adminSecret")
+	s.Require().NoError(err)
+	_, err = s.client.RevokeEntityTokens(ctx, bearer(&v1.RevokeEntityTokensRequest{EntityId: id}, adminAT.AccessToken))
+	s.Require().NoError(err)
+
+	req = connect.NewRequest(&v1.GetEntityRequest{})
+	req.Header().Set("X-API-Key", ck.Msg.Key)
+	_, err = s.client.GetEntity(ctx, req)
+	s.Require().Equal(connect.CodeUnauthenticated, connect.CodeOf(err))
+
+	at, err = s.authenticate(id, "theSecret")
+	s.Require().NoError(err)
+	ck, err = s.client.CreateAPIKey(ctx, bearer(&v1.CreateAPIKeyRequest{Name: "theNewKey"}, at.AccessToken))
+	s.Require().NoError(err)
+
+	req = connect.NewRequest(&v1.GetEntityRequest{})
+	req.Header().Set("X-API-Key", ck.Msg.Key)
+	_, err = s.client.GetEntity(ctx, req)
+	s.Require().NoError(err)
+}
+
+func (s *ServerTestSuite) TestRevokeEntityTokensSameSecond() {
+	ctx := context.Background()
+
+	adminID := s.createEntity("adminSecret", api.Scope{"a23n:admin"})
+	admin, err := s.authenticate(adminID, "adminSecret")
+	s.Require().NoError(err)
+
+	// The token is most likely issued during the same second its entity tokens are revoked
+	id := s.createEntity("theSecret", nil)
+	at, err := s.authenticate(id, "theSecret")
+	s.Require().NoError(err)
+	_, err = s.client.RevokeEntityTokens(ctx, bearer(&v1.RevokeEntityTokensRequest{EntityId: id}, admin.AccessToken))
+	s.Require().NoError(err)
+
+	_, err = s.client.GetEntity(ctx, bearer(&v1.GetEntityRequest{}, at.AccessToken))
+	s.Require().Equal(connect.CodeUnauthenticated, connect.CodeOf(err))
+
+	// The entity can authenticate again right away
+	at, err = s.authenticate(id, "theSecret")
+	s.Require().NoError(err)
+	_, err = s.client.GetEntity(ctx, bearer(&v1.GetEntityRequest{}, at.AccessToken))
+	s.Require().NoError(err)
+}
+
+func (s *ServerTestSuite) TestTokenExchange() {
+	ctx := context.Background()
+	userID := s.createEntity("theSecret", api.Scope{"foo", "bar"})
+	gatewayID := s.createEntity("theSecret", api.Scope{"a23n:token_exchange"})
+
+	userAT, err := s.authenticate(userID, "theSecret")
+	s.Require().NoError(err)
+	gatewayAT, err := s.authenticate(gatewayID, "theSecret")
+	s.Require().NoError(err)
+
+	// Only callers holding the token exchange scope can act on behalf of others
+	_, err = s.client.TokenExchange(ctx, connect.NewRequest(&v1.TokenExchangeRequest{
+		SubjectToken: userAT.AccessToken,
+	}))
+	s.Require().Equal(connect.CodeUnauthenticated, connect.CodeOf(err))
+
+	_, err = s.client.TokenExchange(ctx, bearer(&v1.TokenExchangeRequest{
+		SubjectToken: gatewayAT.AccessToken,
+	}, userAT.AccessToken))
+	s.Require().Equal(connect.CodePermissionDenied, connect.CodeOf(err))
+
+	_, err = s.client.TokenExchange(ctx, bearer(&v1.TokenExchangeRequest{
+		SubjectToken: userAT.AccessToken,
+		Scope:        []string{"foo", "a23n:admin"},
+	}, gatewayAT.AccessToken))
+	s.Require().Equal(connect.CodePermissionDenied, connect.CodeOf(err))
+
+	_, err = s.client.TokenExchange(ctx, bearer(&v1.TokenExchangeRequest{
+		SubjectToken: userAT.AccessToken,
+		Audience:     "theOtherBackend",
+	}, gatewayAT.AccessToken))
+	s.Require().Equal(connect.CodeInvalidArgument, connect.CodeOf(err))
+
+	// The actor token must have been issued to the caller
+	_, err = s.client.TokenExchange(ctx, bearer(&v1.TokenExchangeRequest{
+		SubjectToken: userAT.AccessToken,
+		ActorToken:   userAT.AccessToken,
+	}, gatewayAT.AccessToken))
+	s.Require().Equal(connect.CodePermissionDenied, connect.CodeOf(err))
+
+	te, err := s.client.TokenExchange(ctx, bearer(&v1.TokenExchangeRequest{
+		SubjectToken: userAT.AccessToken,
+		ActorToken:   gatewayAT.AccessToken,
+		Scope:        []string{"foo"},
+		Audience:     "theBackend",
+	}, gatewayAT.AccessToken))
+	s.Require().NoError(err)
+	s.Assert().Equal([]string{"foo"}, te.Msg.Scope)
+	s.Assert().LessOrEqual(te.Msg.AccessTokenExpires, userAT.AccessTokenExpires)
+
+	clm := api.TokenClaims{}
+	_, err = jwt.ParseWithClaims(te.Msg.AccessToken, &clm, func(*jwt.Token) (interface{}, error) {
+		return []byte("theSecret"), nil
+	})
+	s.Require().NoError(err)
+	s.Assert().Equal(userID, clm.Subject)
+	s.Assert().Equal(jwt.ClaimStrings{"theBackend"}, clm.Audience)
+	s.Assert().Equal(&api.Actor{Subject: gatewayID}, clm.Actor)
+
+	// The token is meant for the backend, so the service itself does not accept it
+	_, err = s.client.GetEntity(ctx, bearer(&v1.GetEntityRequest{}, te.Msg.AccessToken))
+	s.Require().Equal(connect.CodeUnauthenticated, connect.CodeOf(err))
+
+	// Still, the backend can introspect it, and it can be revoked before it expires
+	backendID := s.createEntity("theSecret", api.Scope{"a23n:introspect"})
+	backendAT, err := s.authenticate(backendID, "theSecret")
+	s.Require().NoError(err)
+
+	it, err := s.client.IntrospectToken(ctx, bearer(&v1.IntrospectTokenRequest{
+		Token: te.Msg.AccessToken,
+	}, backendAT.AccessToken))
+	s.Require().NoError(err)
+	s.Assert().True(it.Msg.Active)
+	s.Assert().Equal(userID, it.Msg.Subject)
+	s.Assert().Equal(gatewayID, it.Msg.Actor)
+
+	_, err = s.client.RevokeToken(ctx, connect.NewRequest(&v1.RevokeTokenRequest{Token: te.Msg.AccessToken}))
+	s.Require().NoError(err)
+
+	it, err = s.client.IntrospectToken(ctx, bearer(&v1.IntrospectTokenRequest{
+		Token: te.Msg.AccessToken,
+	}, backendAT.AccessToken))
+	s.Require().NoError(err)
+	s.Assert().False(it.Msg.Active)
+}
+
+// oauth2Token posts form to the OAuth 2.0 token endpoint and returns the decoded response.
+func (s *ServerTestSuite) oauth2Token(form url.Values, clientID, clientSecret string) (int, map[string]interface{}) {
+	req, err := http.NewRequest(http.MethodPost, s.srv.URL+"/oauth2/token", strings.NewReader(form.Encode()))
+	s.Require().NoError(err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if clientID != "" {
+		req.SetBasicAuth(clientID, clientSecret)
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	s.Require().NoError(err)
+	defer res.Body.Close()
+
+	body := make(map[string]interface{})
+	s.Require().NoError(json.NewDecoder(res.Body).Decode(&body))
+
+	return res.StatusCode, body
+}
+
+// oauth2Authorize signs in on the login page of the authorization endpoint and returns the location the client is
+// redirected to.
+func (s *ServerTestSuite) oauth2Authorize(params url.Values, username, password string) *url.URL {
+	jar, err := cookiejar.New(nil)
+	s.Require().NoError(err)
+	c := &http.Client{
+		Jar:           jar,
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+
+	res, err := c.Get(s.srv.URL + "/oauth2/authorize?" + params.Encode())
+	s.Require().NoError(err)
+	page, err := io.ReadAll(res.Body)
+	s.Require().NoError(err)
+	s.Require().NoError(res.Body.Close())
+	s.Require().Equal(http.StatusOK, res.StatusCode)
+
+	m := regexp.MustCompile(`name="csrf_token" value="([^"]+)"`).FindSubmatch(page)
+	s.Require().Len(m, 2)
+
+	form := url.Values{"username": {username}, "password": {password}, "csrf_token": {string(m[1])}}
+	for k, v := range params {
+		form[k] = v
+	}
+
+	// The form cannot be posted without the anti-CSRF cookie
+	res, err = http.PostForm(s.srv.URL+"/oauth2/authorize", form)
+	s.Require().NoError(err)
+	s.Require().NoError(res.Body.Close())
+	s.Require().Equal(http.StatusForbidden, res.StatusCode)
+
+	res, err = c.PostForm(s.srv.URL+"/oauth2/authorize", form)
+	s.Require().NoError(err)
+	s.Require().NoError(res.Body.Close())
+	s.Require().Equal(http.StatusSeeOther, res.StatusCode)
+
+	loc, err := url.Parse(res.Header.Get("Location"))
+	s.Require().NoError(err)
+
+	return loc
+}
+
+func (s *ServerTestSuite) TestOAuth2Token() {
+	ctx := context.Background()
+	id := s.createEntity("theSecret", api.Scope{"foo"})
+
+	status, body := s.oauth2Token(url.Values{"grant_type": {"client_credentials"}}, id, "wrongSecret")
+	s.Require().Equal(http.StatusUnauthorized, status)
+	s.Assert().Equal("invalid_client", body["error"])
+
+	status, body = s.oauth2Token(url.Values{"grant_type": {"client_credentials"}, "scope": {"bar"}}, id, "theSecret")
+	s.Require().Equal(http.StatusBadRequest, status)
+	s.Assert().Equal("invalid_scope", body["error"])
+
+	status, body = s.oauth2Token(url.Values{"grant_type": {"client_credentials"}, "scope": {"foo"}}, id, "theSecret")
+	s.Require().Equal(http.StatusOK, status)
+	s.Assert().Equal("Bearer", body["token_type"])
+	s.Assert().Equal(float64(60), body["expires_in"])
+	s.Assert().Nil(body["refresh_token"])
+
+	// Tokens are accepted by AuthService
+	ge, err := s.client.GetEntity(ctx, bearer(&v1.GetEntityRequest{}, body["access_token"].(string)))
+	s.Require().NoError(err)
+	s.Assert().Equal(id, ge.Msg.Id)
+
+	status, body = s.oauth2Token(url.Values{
+		"grant_type": {"password"},
+		"username":   {id},
+		"password":   {"theSecret"},
+	}, "", "")
+	s.Require().Equal(http.StatusOK, status)
+	s.Require().NotEmpty(body["refresh_token"])
+
+	status, body = s.oauth2Token(url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {body["refresh_token"].(string)},
+	}, "", "")
+	s.Require().Equal(http.StatusOK, status)
+	s.Assert().Equal("foo", body["scope"])
+
+	_, err = s.client.RefreshToken(ctx, bearer(&v1.RefreshTokenRequest{}, body["refresh_token"].(string)))
+	s.Require().NoError(err)
+}
+
+func (s *ServerTestSuite) TestOAuth2AuthorizationCode() {
+	ctx := context.Background()
+	id := s.createEntity("theSecret", api.Scope{"foo", "bar"})
+
+	clientID := uuid.NewString()
+	_, err := s.api.CreateClient(ctx, api.Client{
+		ID:           clientID,
+		Name:         "The Client",
+		RedirectURIs: []string{"https://example.com/callback"},
+	})
+	s.Require().NoError(err)
+
+	verifier := strings.Repeat("theVerifier", 5)
+	h := sha256.Sum256([]byte(verifier))
+	form := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {clientID},
+		"scope":                 {"foo baz"},
+		"state":                 {"theState"},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(h[:])},
+		"code_challenge_method": {"S256"},
+	}
+
+	loc := s.oauth2Authorize(form, id, "theSecret")
+	s.Assert().Equal("example.com", loc.Host)
+	s.Assert().Equal("theState", loc.Query().Get("state"))
+
+	tokenForm := url.Values{
+		"grant_type":    {"authorization_code"},
+		"client_id":     {clientID},
+		"code":          {loc.Query().Get("code")},
+		"code_verifier": {verifier},
+	}
+	status, body := s.oauth2Token(tokenForm, "", "")
+	s.Require().Equal(http.StatusOK, status)
+	s.Assert().Equal("foo", body["scope"])
+
+	ge, err := s.client.GetEntity(ctx, bearer(&v1.GetEntityRequest{}, body["access_token"].(string)))
+	s.Require().NoError(err)
+	s.Assert().Equal(id, ge.Msg.Id)
+
+	// Codes are single-use
+	status, body = s.oauth2Token(tokenForm, "", "")
+	s.Require().Equal(http.StatusBadRequest, status)
+	s.Assert().Equal("invalid_grant", body["error"])
+}
+
+func (s *ServerTestSuite) TestOAuth2ClientRegistry() {
+	ctx := context.Background()
+
+	adminID := s.createEntity("adminSecret", api.Scope{"a23n:admin"})
+	admin, err := s.authenticate(adminID, "adminSecret")
+	s.Require().NoError(err)
+
+	id := s.createEntity("theSecret", api.Scope{"foo", "bar"})
+	clientID := uuid.NewString()
+
+	cc, err := s.client.CreateClient(ctx, bearer(&v1.CreateClientRequest{
+		Id:              clientID,
+		GrantTypes:      []string{"password", "refresh_token"},
+		Scope:           []string{"foo"},
+		AccessTokenTtl:  30,
+		RefreshTokenTtl: 60,
+		Confidential:    true,
+	}, admin.AccessToken))
+	s.Require().NoError(err)
+	s.Require().NotEmpty(cc.Msg.Secret)
+
+	// The client cannot be granted the bar scope
+	form := url.Values{"grant_type": {"password"}, "username": {id}, "password": {"theSecret"}}
+	status, body := s.oauth2Token(form, clientID, cc.Msg.Secret)
+	s.Require().Equal(http.StatusOK, status)
+	s.Assert().Equal("foo", body["scope"])
+	s.Assert().Equal(float64(30), body["expires_in"])
+
+	clm := api.TokenClaims{}
+	_, _, err = jwt.NewParser().ParseUnverified(body["access_token"].(string), &clm)
+	s.Require().NoError(err)
+	s.Assert().Equal(clientID, clm.ClientID)
+
+	// Refresh tokens of the client cannot be used by anyone else
+	refreshForm := url.Values{"grant_type": {"refresh_token"}, "refresh_token": {body["refresh_token"].(string)}}
+	status, _ = s.oauth2Token(refreshForm, "", "")
+	s.Require().Equal(http.StatusBadRequest, status)
+	status, body = s.oauth2Token(refreshForm, clientID, cc.Msg.Secret)
+	s.Require().Equal(http.StatusOK, status)
+	s.Assert().Equal("foo", body["scope"])
+
+	uc, err := s.client.UpdateClient(ctx, bearer(&v1.UpdateClientRequest{
+		Id:          clientID,
+		GrantTypes:  []string{"password"},
+		ResetSecret: true,
+		// The scope restriction is lifted, the token TTLs are kept
+		UpdateMask: []string{"grant_types", "scope"},
+	}, admin.AccessToken))
+	s.Require().NoError(err)
+
+	status, body = s.oauth2Token(form, clientID, cc.Msg.Secret)
+	s.Require().Equal(http.StatusUnauthorized, status)
+	s.Assert().Equal("invalid_client", body["error"])
+
+	// The client is not allowed to refresh tokens anymore
+	status, body = s.oauth2Token(form, clientID, uc.Msg.Secret)
+	s.Require().Equal(http.StatusOK, status)
+	s.Assert().Equal("foo bar", body["scope"])
+	s.Assert().Equal(float64(30), body["expires_in"])
+	s.Assert().NotContains(body, "refresh_token")
+
+	lc, err := s.client.ListClients(ctx, bearer(&v1.ListClientsRequest{}, admin.AccessToken))
+	s.Require().NoError(err)
+	s.Assert().NotEmpty(lc.Msg.Clients)
+
+	_, err = s.client.DeleteClient(ctx, bearer(&v1.DeleteClientRequest{Id: clientID}, admin.AccessToken))
+	s.Require().NoError(err)
+
+	status, _ = s.oauth2Token(form, clientID, uc.Msg.Secret)
+	s.Require().Equal(http.StatusUnauthorized, status)
+}
+
+func (s *ServerTestSuite) TestOAuth2ClientCredentials() {
+	ctx := context.Background()
+
+	adminID := s.createEntity("adminSecret", api.Scope{"a23n:admin"})
+	admin, err := s.authenticate(adminID, "adminSecret")
+	s.Require().NoError(err)
+
+	clientID := uuid.NewString()
+	cc, err := s.client.CreateClient(ctx, bearer(&v1.CreateClientRequest{
+		Id:             clientID,
+		GrantTypes:     []string{"client_credentials"},
+		Scope:          []string{"foo"},
+		AccessTokenTtl: 30,
+		Confidential:   true,
+	}, admin.AccessToken))
+	s.Require().NoError(err)
+
+	form := url.Values{"grant_type": {"client_credentials"}}
+	status, body := s.oauth2Token(form, clientID, cc.Msg.Secret)
+	s.Require().Equal(http.StatusOK, status)
+	s.Assert().Equal("foo", body["scope"])
+	s.Assert().Equal(float64(30), body["expires_in"])
+	s.Assert().NotContains(body, "refresh_token")
+
+	// The client is the subject of its own token
+	token := body["access_token"].(string)
+	clm, err := s.api.ParseToken(ctx, token, api.TokenTypeAccess)
+	s.Require().NoError(err)
+	s.Assert().Equal(clientID, clm.Subject)
+	s.Assert().Equal(clientID, clm.ClientID)
+
+	// The client cannot obtain tokens for the scopes it has not been registered with
+	status, body = s.oauth2Token(url.Values{"grant_type": {"client_credentials"}, "scope": {"bar"}}, clientID,
+		cc.Msg.Secret)
+	s.Require().Equal(http.StatusBadRequest, status)
+	s.Assert().Equal("invalid_scope", body["error"])
+
+	// Tokens of the client are revoked along with it
+	_, err = s.client.DeleteClient(ctx, bearer(&v1.DeleteClientRequest{Id: clientID}, admin.AccessToken))
+	s.Require().NoError(err)
+	_, err = s.api.ParseToken(ctx, token, api.TokenTypeAccess)
+	s.Require().ErrorIs(err, api.ErrTokenRevoked)
+}
+
+// authorizeOpenID creates an entity and a client and returns the entity ID along with the response of the token
+// endpoint to the client having been authorized with the openid scope.
+func (s *ServerTestSuite) authorizeOpenID() (string, string, map[string]interface{}) {
+	ctx := context.Background()
+
+	id := uuid.NewString()
+	s.Require().NoError(s.api.CreateEntity(ctx, id, "", "theSecret", api.Scope{"foo"},
+		api.Attrs{"email": "user@example.com", "name": "The User"}))
+
+	clientID := uuid.NewString()
+	_, err := s.api.CreateClient(ctx, api.Client{
+		ID:           clientID,
+		RedirectURIs: []string{"https://example.com/callback"},
+	})
+	s.Require().NoError(err)
+
+	verifier := strings.Repeat("theVerifier", 5)
+	h := sha256.Sum256([]byte(verifier))
+	form := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {clientID},
+		"scope":                 {"openid email foo"},
+		"nonce":                 {"theNonce"},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(h[:])},
+		"code_challenge_method": {"S256"},
+	}
+
+	loc := s.oauth2Authorize(form, id, "theSecret")
+
+	status, body := s.oauth2Token(url.Values{
+		"grant_type":    {"authorization_code"},
+		"client_id":     {clientID},
+		"code":          {loc.Query().Get("code")},
+		"code_verifier": {verifier},
+	}, "", "")
+	s.Require().Equal(http.StatusOK, status)
+	s.Assert().Equal("openid email foo", body["scope"])
+
+	return id, clientID, body
+}
+
+func (s *ServerTestSuite) TestOpenIDConnect() {
+	key, err := api.GenerateSigningKey("ES256")
+	s.Require().NoError(err)
+	s.setup(key, "https://auth.example.com")
+
+	id, clientID, body := s.authorizeOpenID()
+
+	clm := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(body["id_token"].(string), clm, func(*jwt.Token) (interface{}, error) {
+		return key.Public, nil
+	})
+	s.Require().NoError(err)
+	s.Assert().Equal("https://auth.example.com", clm["iss"])
+	s.Assert().Equal(id, clm["sub"])
+	s.Assert().Equal([]interface{}{clientID}, clm["aud"])
+	s.Assert().Equal("theNonce", clm["nonce"])
+	s.Assert().Equal("user@example.com", clm["email"])
+	s.Assert().NotContains(clm, "name")
+
+	req, err := http.NewRequest(http.MethodGet, s.srv.URL+"/userinfo", nil)
+	s.Require().NoError(err)
+	req.Header.Set("Authorization", "Bearer "+body["access_token"].(string))
+
+	res, err := http.DefaultClient.Do(req)
+	s.Require().NoError(err)
+	defer res.Body.Close()
+	s.Require().Equal(http.StatusOK, res.StatusCode)
+
+	info := make(map[string]interface{})
+	s.Require().NoError(json.NewDecoder(res.Body).Decode(&info))
+	s.Assert().Equal(map[string]interface{}{"sub": id, "email": "user@example.com"}, info)
+
+	res, err = http.Get(s.srv.URL + "/.well-known/openid-configuration")
+	s.Require().NoError(err)
+	s.Require().NoError(res.Body.Close())
+	s.Assert().Equal(http.StatusOK, res.StatusCode)
+}
+
+func (s *ServerTestSuite) TestOpenIDConnectNotProvider() {
+	// Tokens are signed by a shared secret and the issuer is not a URL, so the service is not an OpenID provider
+	_, _, body := s.authorizeOpenID()
+	s.Assert().NotContains(body, "id_token")
+
+	res, err := http.Get(s.srv.URL + "/.well-known/openid-configuration")
+	s.Require().NoError(err)
+	s.Require().NoError(res.Body.Close())
+	s.Assert().Equal(http.StatusNotFound, res.StatusCode)
+}
+
+func TestServer(t *testing.T) {
+	suite.Run(t, new(ServerTestSuite))
+}
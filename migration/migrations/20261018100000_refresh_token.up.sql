@@ -0,0 +1,14 @@
+CREATE TABLE refresh_token
+(
+    hash       bytea       NOT NULL,
+    family     uuid        NOT NULL,
+    entity_id  uuid        NOT NULL,
+    expires_at timestamptz NOT NULL,
+    used_at    timestamptz,
+    revoked_at timestamptz,
+
+    PRIMARY KEY (hash),
+    FOREIGN KEY (entity_id) REFERENCES entity (id) ON DELETE CASCADE
+);
+
+CREATE INDEX refresh_token_family_idx ON refresh_token (family);
@@ -0,0 +1,11 @@
+CREATE TABLE signing_key
+(
+    id           varchar     NOT NULL,
+    alg          varchar     NOT NULL,
+    private_key  bytea       NOT NULL,
+    created_at   timestamptz NOT NULL,
+    activates_at timestamptz NOT NULL,
+    expires_at   timestamptz,
+
+    PRIMARY KEY (id)
+);
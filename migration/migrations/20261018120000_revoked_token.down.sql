@@ -0,0 +1,3 @@
+ALTER TABLE entity DROP COLUMN tokens_revoked_at;
+
+DROP TABLE revoked_token;
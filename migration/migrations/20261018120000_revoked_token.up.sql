@@ -0,0 +1,13 @@
+CREATE TABLE revoked_token
+(
+    id         varchar     NOT NULL,
+    entity_id  uuid        NOT NULL,
+    expires_at timestamptz NOT NULL,
+
+    PRIMARY KEY (id),
+    FOREIGN KEY (entity_id) REFERENCES entity (id) ON DELETE CASCADE
+);
+
+CREATE INDEX revoked_token_expires_at_idx ON revoked_token (expires_at);
+
+ALTER TABLE entity ADD COLUMN tokens_revoked_at timestamptz;
@@ -0,0 +1,2 @@
+DROP INDEX entity_attrs_idx;
+DROP INDEX entity_scope_idx;
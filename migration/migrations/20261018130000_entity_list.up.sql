@@ -0,0 +1,2 @@
+CREATE INDEX entity_scope_idx ON entity USING gin (scope);
+CREATE INDEX entity_attrs_idx ON entity USING gin (attrs jsonb_path_ops);
@@ -0,0 +1 @@
+ALTER TABLE entity DROP COLUMN disabled;
@@ -0,0 +1 @@
+ALTER TABLE entity ADD COLUMN disabled boolean NOT NULL DEFAULT false;
@@ -0,0 +1,8 @@
+DROP INDEX entity_last_authenticated_at_idx;
+DROP INDEX entity_created_at_idx;
+
+ALTER TABLE entity
+    DROP COLUMN last_authenticated_at,
+    DROP COLUMN secret_changed_at,
+    DROP COLUMN updated_at,
+    DROP COLUMN created_at;
@@ -0,0 +1,16 @@
+ALTER TABLE entity
+    ADD COLUMN created_at            timestamptz NOT NULL DEFAULT now(),
+    ADD COLUMN updated_at            timestamptz,
+    ADD COLUMN secret_changed_at     timestamptz,
+    ADD COLUMN last_authenticated_at timestamptz;
+
+UPDATE entity SET updated_at=created_at, secret_changed_at=created_at;
+
+ALTER TABLE entity
+    ALTER COLUMN updated_at SET NOT NULL,
+    ALTER COLUMN updated_at SET DEFAULT now(),
+    ALTER COLUMN secret_changed_at SET NOT NULL,
+    ALTER COLUMN secret_changed_at SET DEFAULT now();
+
+CREATE INDEX entity_created_at_idx ON entity (created_at, id);
+CREATE INDEX entity_last_authenticated_at_idx ON entity (last_authenticated_at);
@@ -0,0 +1,3 @@
+DROP INDEX entity_login_idx;
+
+ALTER TABLE entity DROP COLUMN login;
@@ -0,0 +1,3 @@
+ALTER TABLE entity ADD COLUMN login varchar;
+
+CREATE UNIQUE INDEX entity_login_idx ON entity (lower(login));
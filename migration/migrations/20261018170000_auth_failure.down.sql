@@ -0,0 +1,6 @@
+DROP TABLE auth_failure;
+
+ALTER TABLE entity
+    DROP COLUMN locked_until,
+    DROP COLUMN failed_auth_reset_at,
+    DROP COLUMN failed_auth_count;
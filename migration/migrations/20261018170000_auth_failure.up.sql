@@ -0,0 +1,16 @@
+ALTER TABLE entity
+    ADD COLUMN failed_auth_count    integer NOT NULL DEFAULT 0,
+    ADD COLUMN failed_auth_reset_at timestamptz,
+    ADD COLUMN locked_until         timestamptz;
+
+CREATE TABLE auth_failure
+(
+    source       varchar     NOT NULL,
+    count        integer     NOT NULL,
+    reset_at     timestamptz NOT NULL,
+    locked_until timestamptz,
+
+    PRIMARY KEY (source)
+);
+
+CREATE INDEX auth_failure_reset_at_idx ON auth_failure (reset_at);
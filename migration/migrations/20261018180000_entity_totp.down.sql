@@ -0,0 +1,4 @@
+ALTER TABLE entity
+    DROP COLUMN totp_last_step,
+    DROP COLUMN totp_pending_secret,
+    DROP COLUMN totp_secret;
@@ -0,0 +1,4 @@
+ALTER TABLE entity
+    ADD COLUMN totp_secret         bytea,
+    ADD COLUMN totp_pending_secret bytea,
+    ADD COLUMN totp_last_step      bigint;
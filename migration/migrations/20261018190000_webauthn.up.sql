@@ -0,0 +1,34 @@
+CREATE TABLE webauthn_credential
+(
+    id               bytea         NOT NULL,
+    entity_id        uuid          NOT NULL,
+    public_key       bytea         NOT NULL,
+    attestation_type varchar       NOT NULL,
+    transport        varchar array NOT NULL,
+    aaguid           bytea         NOT NULL,
+    sign_count       bigint        NOT NULL,
+    backup_eligible  boolean       NOT NULL,
+    backup_state     boolean       NOT NULL,
+    mfa              boolean       NOT NULL,
+    created_at       timestamptz   NOT NULL,
+    last_used_at     timestamptz,
+
+    PRIMARY KEY (id),
+    FOREIGN KEY (entity_id) REFERENCES entity (id) ON DELETE CASCADE
+);
+
+CREATE INDEX webauthn_credential_entity_id_idx ON webauthn_credential (entity_id);
+
+CREATE TABLE webauthn_challenge
+(
+    id         uuid        NOT NULL,
+    ceremony   varchar     NOT NULL,
+    entity_id  uuid,
+    session    jsonb       NOT NULL,
+    expires_at timestamptz NOT NULL,
+
+    PRIMARY KEY (id),
+    FOREIGN KEY (entity_id) REFERENCES entity (id) ON DELETE CASCADE
+);
+
+CREATE INDEX webauthn_challenge_expires_at_idx ON webauthn_challenge (expires_at);
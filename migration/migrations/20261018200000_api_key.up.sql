@@ -0,0 +1,17 @@
+CREATE TABLE api_key
+(
+    id           uuid          NOT NULL,
+    entity_id    uuid          NOT NULL,
+    name         varchar       NOT NULL,
+    hash         bytea         NOT NULL,
+    scope        varchar array NOT NULL,
+    created_at   timestamptz   NOT NULL,
+    expires_at   timestamptz,
+    last_used_at timestamptz,
+
+    PRIMARY KEY (id),
+    FOREIGN KEY (entity_id) REFERENCES entity (id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX api_key_hash_idx ON api_key (hash);
+CREATE INDEX api_key_entity_id_idx ON api_key (entity_id);
@@ -0,0 +1,26 @@
+CREATE TABLE oauth_client
+(
+    id            varchar       NOT NULL,
+    name          varchar       NOT NULL,
+    redirect_uris varchar array NOT NULL,
+    created_at    timestamptz   NOT NULL,
+
+    PRIMARY KEY (id)
+);
+
+CREATE TABLE oauth_authorization_code
+(
+    hash           bytea         NOT NULL,
+    client_id      varchar       NOT NULL,
+    entity_id      uuid          NOT NULL,
+    redirect_uri   varchar       NOT NULL,
+    scope          varchar array NOT NULL,
+    code_challenge varchar       NOT NULL,
+    expires_at     timestamptz   NOT NULL,
+
+    PRIMARY KEY (hash),
+    FOREIGN KEY (client_id) REFERENCES oauth_client (id) ON DELETE CASCADE,
+    FOREIGN KEY (entity_id) REFERENCES entity (id) ON DELETE CASCADE
+);
+
+CREATE INDEX oauth_authorization_code_expires_at_idx ON oauth_authorization_code (expires_at);
@@ -0,0 +1,3 @@
+ALTER TABLE oauth_authorization_code
+    DROP COLUMN auth_time,
+    DROP COLUMN nonce;
@@ -0,0 +1,5 @@
+DELETE FROM oauth_authorization_code;
+
+ALTER TABLE oauth_authorization_code
+    ADD COLUMN nonce     varchar     NOT NULL,
+    ADD COLUMN auth_time timestamptz NOT NULL;
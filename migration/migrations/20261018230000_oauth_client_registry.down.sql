@@ -0,0 +1,8 @@
+ALTER TABLE oauth_client
+    DROP COLUMN updated_at,
+    DROP COLUMN confidential,
+    DROP COLUMN refresh_token_ttl,
+    DROP COLUMN access_token_ttl,
+    DROP COLUMN scope,
+    DROP COLUMN grant_types,
+    DROP COLUMN secret_hash;
@@ -0,0 +1,12 @@
+ALTER TABLE oauth_client
+    ADD COLUMN secret_hash       bytea,
+    ADD COLUMN grant_types       varchar array NOT NULL DEFAULT '{authorization_code}',
+    ADD COLUMN scope             varchar array NOT NULL DEFAULT '{}',
+    ADD COLUMN access_token_ttl  integer       NOT NULL DEFAULT 0,
+    ADD COLUMN refresh_token_ttl integer       NOT NULL DEFAULT 0,
+    ADD COLUMN confidential      boolean       NOT NULL DEFAULT false,
+    ADD COLUMN updated_at        timestamptz;
+
+UPDATE oauth_client SET updated_at=created_at;
+
+ALTER TABLE oauth_client ALTER COLUMN updated_at SET NOT NULL;
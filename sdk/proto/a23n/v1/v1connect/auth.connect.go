@@ -25,13 +25,134 @@ const (
 	AuthServiceName = "a23n.v1.AuthService"
 )
 
+// These constants are the fully-qualified names of the RPCs defined in this package. They're
+// exposed at runtime as Spec.Procedure and as the final two segments of the HTTP route.
+//
+// Note that these are different from the fully-qualified method names used by
+// google.golang.org/protobuf/reflect/protoreflect. To convert from these constants to
+// reflection-formatted method names, remove the leading slash and convert the remaining slash to a
+// period.
+const (
+	// AuthServiceAuthenticateProcedure is the fully-qualified name of the AuthService's Authenticate
+	// RPC.
+	AuthServiceAuthenticateProcedure = "/a23n.v1.AuthService/Authenticate"
+	// AuthServiceAuthenticateTOTPProcedure is the fully-qualified name of the AuthService's
+	// AuthenticateTOTP RPC.
+	AuthServiceAuthenticateTOTPProcedure = "/a23n.v1.AuthService/AuthenticateTOTP"
+	// AuthServiceRefreshTokenProcedure is the fully-qualified name of the AuthService's RefreshToken
+	// RPC.
+	AuthServiceRefreshTokenProcedure = "/a23n.v1.AuthService/RefreshToken"
+	// AuthServiceCreateEntityProcedure is the fully-qualified name of the AuthService's CreateEntity
+	// RPC.
+	AuthServiceCreateEntityProcedure = "/a23n.v1.AuthService/CreateEntity"
+	// AuthServiceUpdateEntityProcedure is the fully-qualified name of the AuthService's UpdateEntity
+	// RPC.
+	AuthServiceUpdateEntityProcedure = "/a23n.v1.AuthService/UpdateEntity"
+	// AuthServiceGetEntityProcedure is the fully-qualified name of the AuthService's GetEntity RPC.
+	AuthServiceGetEntityProcedure = "/a23n.v1.AuthService/GetEntity"
+	// AuthServiceListEntitiesProcedure is the fully-qualified name of the AuthService's ListEntities
+	// RPC.
+	AuthServiceListEntitiesProcedure = "/a23n.v1.AuthService/ListEntities"
+	// AuthServiceDeleteEntityProcedure is the fully-qualified name of the AuthService's DeleteEntity
+	// RPC.
+	AuthServiceDeleteEntityProcedure = "/a23n.v1.AuthService/DeleteEntity"
+	// AuthServiceDisableEntityProcedure is the fully-qualified name of the AuthService's DisableEntity
+	// RPC.
+	AuthServiceDisableEntityProcedure = "/a23n.v1.AuthService/DisableEntity"
+	// AuthServiceEnableEntityProcedure is the fully-qualified name of the AuthService's EnableEntity
+	// RPC.
+	AuthServiceEnableEntityProcedure = "/a23n.v1.AuthService/EnableEntity"
+	// AuthServiceUnlockEntityProcedure is the fully-qualified name of the AuthService's UnlockEntity
+	// RPC.
+	AuthServiceUnlockEntityProcedure = "/a23n.v1.AuthService/UnlockEntity"
+	// AuthServiceUnlockSourceProcedure is the fully-qualified name of the AuthService's UnlockSource
+	// RPC.
+	AuthServiceUnlockSourceProcedure = "/a23n.v1.AuthService/UnlockSource"
+	// AuthServiceEnrollTOTPProcedure is the fully-qualified name of the AuthService's EnrollTOTP RPC.
+	AuthServiceEnrollTOTPProcedure = "/a23n.v1.AuthService/EnrollTOTP"
+	// AuthServiceConfirmTOTPProcedure is the fully-qualified name of the AuthService's ConfirmTOTP RPC.
+	AuthServiceConfirmTOTPProcedure = "/a23n.v1.AuthService/ConfirmTOTP"
+	// AuthServiceDisableTOTPProcedure is the fully-qualified name of the AuthService's DisableTOTP RPC.
+	AuthServiceDisableTOTPProcedure = "/a23n.v1.AuthService/DisableTOTP"
+	// AuthServiceBeginWebAuthnRegistrationProcedure is the fully-qualified name of the AuthService's
+	// BeginWebAuthnRegistration RPC.
+	AuthServiceBeginWebAuthnRegistrationProcedure = "/a23n.v1.AuthService/BeginWebAuthnRegistration"
+	// AuthServiceFinishWebAuthnRegistrationProcedure is the fully-qualified name of the AuthService's
+	// FinishWebAuthnRegistration RPC.
+	AuthServiceFinishWebAuthnRegistrationProcedure = "/a23n.v1.AuthService/FinishWebAuthnRegistration"
+	// AuthServiceBeginWebAuthnLoginProcedure is the fully-qualified name of the AuthService's
+	// BeginWebAuthnLogin RPC.
+	AuthServiceBeginWebAuthnLoginProcedure = "/a23n.v1.AuthService/BeginWebAuthnLogin"
+	// AuthServiceFinishWebAuthnLoginProcedure is the fully-qualified name of the AuthService's
+	// FinishWebAuthnLogin RPC.
+	AuthServiceFinishWebAuthnLoginProcedure = "/a23n.v1.AuthService/FinishWebAuthnLogin"
+	// AuthServiceCreateAPIKeyProcedure is the fully-qualified name of the AuthService's CreateAPIKey
+	// RPC.
+	AuthServiceCreateAPIKeyProcedure = "/a23n.v1.AuthService/CreateAPIKey"
+	// AuthServiceListAPIKeysProcedure is the fully-qualified name of the AuthService's ListAPIKeys RPC.
+	AuthServiceListAPIKeysProcedure = "/a23n.v1.AuthService/ListAPIKeys"
+	// AuthServiceRevokeAPIKeyProcedure is the fully-qualified name of the AuthService's RevokeAPIKey
+	// RPC.
+	AuthServiceRevokeAPIKeyProcedure = "/a23n.v1.AuthService/RevokeAPIKey"
+	// AuthServiceIntrospectTokenProcedure is the fully-qualified name of the AuthService's
+	// IntrospectToken RPC.
+	AuthServiceIntrospectTokenProcedure = "/a23n.v1.AuthService/IntrospectToken"
+	// AuthServiceTokenExchangeProcedure is the fully-qualified name of the AuthService's TokenExchange
+	// RPC.
+	AuthServiceTokenExchangeProcedure = "/a23n.v1.AuthService/TokenExchange"
+	// AuthServiceRevokeTokenProcedure is the fully-qualified name of the AuthService's RevokeToken RPC.
+	AuthServiceRevokeTokenProcedure = "/a23n.v1.AuthService/RevokeToken"
+	// AuthServiceLogoutProcedure is the fully-qualified name of the AuthService's Logout RPC.
+	AuthServiceLogoutProcedure = "/a23n.v1.AuthService/Logout"
+	// AuthServiceRevokeEntityTokensProcedure is the fully-qualified name of the AuthService's
+	// RevokeEntityTokens RPC.
+	AuthServiceRevokeEntityTokensProcedure = "/a23n.v1.AuthService/RevokeEntityTokens"
+	// AuthServiceCreateClientProcedure is the fully-qualified name of the AuthService's CreateClient
+	// RPC.
+	AuthServiceCreateClientProcedure = "/a23n.v1.AuthService/CreateClient"
+	// AuthServiceListClientsProcedure is the fully-qualified name of the AuthService's ListClients RPC.
+	AuthServiceListClientsProcedure = "/a23n.v1.AuthService/ListClients"
+	// AuthServiceUpdateClientProcedure is the fully-qualified name of the AuthService's UpdateClient
+	// RPC.
+	AuthServiceUpdateClientProcedure = "/a23n.v1.AuthService/UpdateClient"
+	// AuthServiceDeleteClientProcedure is the fully-qualified name of the AuthService's DeleteClient
+	// RPC.
+	AuthServiceDeleteClientProcedure = "/a23n.v1.AuthService/DeleteClient"
+)
+
 // AuthServiceClient is a client for the a23n.v1.AuthService service.
 type AuthServiceClient interface {
 	Authenticate(context.Context, *connect_go.Request[v1.AuthenticateRequest]) (*connect_go.Response[v1.AuthenticateResponse], error)
+	AuthenticateTOTP(context.Context, *connect_go.Request[v1.AuthenticateTOTPRequest]) (*connect_go.Response[v1.AuthenticateTOTPResponse], error)
 	RefreshToken(context.Context, *connect_go.Request[v1.RefreshTokenRequest]) (*connect_go.Response[v1.RefreshTokenResponse], error)
 	CreateEntity(context.Context, *connect_go.Request[v1.CreateEntityRequest]) (*connect_go.Response[v1.CreateEntityResponse], error)
 	UpdateEntity(context.Context, *connect_go.Request[v1.UpdateEntityRequest]) (*connect_go.Response[v1.UpdateEntityResponse], error)
 	GetEntity(context.Context, *connect_go.Request[v1.GetEntityRequest]) (*connect_go.Response[v1.GetEntityResponse], error)
+	ListEntities(context.Context, *connect_go.Request[v1.ListEntitiesRequest]) (*connect_go.Response[v1.ListEntitiesResponse], error)
+	DeleteEntity(context.Context, *connect_go.Request[v1.DeleteEntityRequest]) (*connect_go.Response[v1.DeleteEntityResponse], error)
+	DisableEntity(context.Context, *connect_go.Request[v1.DisableEntityRequest]) (*connect_go.Response[v1.DisableEntityResponse], error)
+	EnableEntity(context.Context, *connect_go.Request[v1.EnableEntityRequest]) (*connect_go.Response[v1.EnableEntityResponse], error)
+	UnlockEntity(context.Context, *connect_go.Request[v1.UnlockEntityRequest]) (*connect_go.Response[v1.UnlockEntityResponse], error)
+	UnlockSource(context.Context, *connect_go.Request[v1.UnlockSourceRequest]) (*connect_go.Response[v1.UnlockSourceResponse], error)
+	EnrollTOTP(context.Context, *connect_go.Request[v1.EnrollTOTPRequest]) (*connect_go.Response[v1.EnrollTOTPResponse], error)
+	ConfirmTOTP(context.Context, *connect_go.Request[v1.ConfirmTOTPRequest]) (*connect_go.Response[v1.ConfirmTOTPResponse], error)
+	DisableTOTP(context.Context, *connect_go.Request[v1.DisableTOTPRequest]) (*connect_go.Response[v1.DisableTOTPResponse], error)
+	BeginWebAuthnRegistration(context.Context, *connect_go.Request[v1.BeginWebAuthnRegistrationRequest]) (*connect_go.Response[v1.BeginWebAuthnRegistrationResponse], error)
+	FinishWebAuthnRegistration(context.Context, *connect_go.Request[v1.FinishWebAuthnRegistrationRequest]) (*connect_go.Response[v1.FinishWebAuthnRegistrationResponse], error)
+	BeginWebAuthnLogin(context.Context, *connect_go.Request[v1.BeginWebAuthnLoginRequest]) (*connect_go.Response[v1.BeginWebAuthnLoginResponse], error)
+	FinishWebAuthnLogin(context.Context, *connect_go.Request[v1.FinishWebAuthnLoginRequest]) (*connect_go.Response[v1.FinishWebAuthnLoginResponse], error)
+	CreateAPIKey(context.Context, *connect_go.Request[v1.CreateAPIKeyRequest]) (*connect_go.Response[v1.CreateAPIKeyResponse], error)
+	ListAPIKeys(context.Context, *connect_go.Request[v1.ListAPIKeysRequest]) (*connect_go.Response[v1.ListAPIKeysResponse], error)
+	RevokeAPIKey(context.Context, *connect_go.Request[v1.RevokeAPIKeyRequest]) (*connect_go.Response[v1.RevokeAPIKeyResponse], error)
+	IntrospectToken(context.Context, *connect_go.Request[v1.IntrospectTokenRequest]) (*connect_go.Response[v1.IntrospectTokenResponse], error)
+	TokenExchange(context.Context, *connect_go.Request[v1.TokenExchangeRequest]) (*connect_go.Response[v1.TokenExchangeResponse], error)
+	RevokeToken(context.Context, *connect_go.Request[v1.RevokeTokenRequest]) (*connect_go.Response[v1.RevokeTokenResponse], error)
+	Logout(context.Context, *connect_go.Request[v1.LogoutRequest]) (*connect_go.Response[v1.LogoutResponse], error)
+	RevokeEntityTokens(context.Context, *connect_go.Request[v1.RevokeEntityTokensRequest]) (*connect_go.Response[v1.RevokeEntityTokensResponse], error)
+	CreateClient(context.Context, *connect_go.Request[v1.CreateClientRequest]) (*connect_go.Response[v1.CreateClientResponse], error)
+	ListClients(context.Context, *connect_go.Request[v1.ListClientsRequest]) (*connect_go.Response[v1.ListClientsResponse], error)
+	UpdateClient(context.Context, *connect_go.Request[v1.UpdateClientRequest]) (*connect_go.Response[v1.UpdateClientResponse], error)
+	DeleteClient(context.Context, *connect_go.Request[v1.DeleteClientRequest]) (*connect_go.Response[v1.DeleteClientResponse], error)
 }
 
 // NewAuthServiceClient constructs a client for the a23n.v1.AuthService service. By default, it uses
@@ -46,27 +167,157 @@ func NewAuthServiceClient(httpClient connect_go.HTTPClient, baseURL string, opts
 	return &authServiceClient{
 		authenticate: connect_go.NewClient[v1.AuthenticateRequest, v1.AuthenticateResponse](
 			httpClient,
-			baseURL+"/a23n.v1.AuthService/Authenticate",
+			baseURL+AuthServiceAuthenticateProcedure,
+			opts...,
+		),
+		authenticateTOTP: connect_go.NewClient[v1.AuthenticateTOTPRequest, v1.AuthenticateTOTPResponse](
+			httpClient,
+			baseURL+AuthServiceAuthenticateTOTPProcedure,
 			opts...,
 		),
 		refreshToken: connect_go.NewClient[v1.RefreshTokenRequest, v1.RefreshTokenResponse](
 			httpClient,
-			baseURL+"/a23n.v1.AuthService/RefreshToken",
+			baseURL+AuthServiceRefreshTokenProcedure,
 			opts...,
 		),
 		createEntity: connect_go.NewClient[v1.CreateEntityRequest, v1.CreateEntityResponse](
 			httpClient,
-			baseURL+"/a23n.v1.AuthService/CreateEntity",
+			baseURL+AuthServiceCreateEntityProcedure,
 			opts...,
 		),
 		updateEntity: connect_go.NewClient[v1.UpdateEntityRequest, v1.UpdateEntityResponse](
 			httpClient,
-			baseURL+"/a23n.v1.AuthService/UpdateEntity",
+			baseURL+AuthServiceUpdateEntityProcedure,
 			opts...,
 		),
 		getEntity: connect_go.NewClient[v1.GetEntityRequest, v1.GetEntityResponse](
 			httpClient,
-			baseURL+"/a23n.v1.AuthService/GetEntity",
+			baseURL+AuthServiceGetEntityProcedure,
+			opts...,
+		),
+		listEntities: connect_go.NewClient[v1.ListEntitiesRequest, v1.ListEntitiesResponse](
+			httpClient,
+			baseURL+AuthServiceListEntitiesProcedure,
+			opts...,
+		),
+		deleteEntity: connect_go.NewClient[v1.DeleteEntityRequest, v1.DeleteEntityResponse](
+			httpClient,
+			baseURL+AuthServiceDeleteEntityProcedure,
+			opts...,
+		),
+		disableEntity: connect_go.NewClient[v1.DisableEntityRequest, v1.DisableEntityResponse](
+			httpClient,
+			baseURL+AuthServiceDisableEntityProcedure,
+			opts...,
+		),
+		enableEntity: connect_go.NewClient[v1.EnableEntityRequest, v1.EnableEntityResponse](
+			httpClient,
+			baseURL+AuthServiceEnableEntityProcedure,
+			opts...,
+		),
+		unlockEntity: connect_go.NewClient[v1.UnlockEntityRequest, v1.UnlockEntityResponse](
+			httpClient,
+			baseURL+AuthServiceUnlockEntityProcedure,
+			opts...,
+		),
+		unlockSource: connect_go.NewClient[v1.UnlockSourceRequest, v1.UnlockSourceResponse](
+			httpClient,
+			baseURL+AuthServiceUnlockSourceProcedure,
+			opts...,
+		),
+		enrollTOTP: connect_go.NewClient[v1.EnrollTOTPRequest, v1.EnrollTOTPResponse](
+			httpClient,
+			baseURL+AuthServiceEnrollTOTPProcedure,
+			opts...,
+		),
+		confirmTOTP: connect_go.NewClient[v1.ConfirmTOTPRequest, v1.ConfirmTOTPResponse](
+			httpClient,
+			baseURL+AuthServiceConfirmTOTPProcedure,
+			opts...,
+		),
+		disableTOTP: connect_go.NewClient[v1.DisableTOTPRequest, v1.DisableTOTPResponse](
+			httpClient,
+			baseURL+AuthServiceDisableTOTPProcedure,
+			opts...,
+		),
+		beginWebAuthnRegistration: connect_go.NewClient[v1.BeginWebAuthnRegistrationRequest, v1.BeginWebAuthnRegistrationResponse](
+			httpClient,
+			baseURL+AuthServiceBeginWebAuthnRegistrationProcedure,
+			opts...,
+		),
+		finishWebAuthnRegistration: connect_go.NewClient[v1.FinishWebAuthnRegistrationRequest, v1.FinishWebAuthnRegistrationResponse](
+			httpClient,
+			baseURL+AuthServiceFinishWebAuthnRegistrationProcedure,
+			opts...,
+		),
+		beginWebAuthnLogin: connect_go.NewClient[v1.BeginWebAuthnLoginRequest, v1.BeginWebAuthnLoginResponse](
+			httpClient,
+			baseURL+AuthServiceBeginWebAuthnLoginProcedure,
+			opts...,
+		),
+		finishWebAuthnLogin: connect_go.NewClient[v1.FinishWebAuthnLoginRequest, v1.FinishWebAuthnLoginResponse](
+			httpClient,
+			baseURL+AuthServiceFinishWebAuthnLoginProcedure,
+			opts...,
+		),
+		createAPIKey: connect_go.NewClient[v1.CreateAPIKeyRequest, v1.CreateAPIKeyResponse](
+			httpClient,
+			baseURL+AuthServiceCreateAPIKeyProcedure,
+			opts...,
+		),
+		listAPIKeys: connect_go.NewClient[v1.ListAPIKeysRequest, v1.ListAPIKeysResponse](
+			httpClient,
+			baseURL+AuthServiceListAPIKeysProcedure,
+			opts...,
+		),
+		revokeAPIKey: connect_go.NewClient[v1.RevokeAPIKeyRequest, v1.RevokeAPIKeyResponse](
+			httpClient,
+			baseURL+AuthServiceRevokeAPIKeyProcedure,
+			opts...,
+		),
+		introspectToken: connect_go.NewClient[v1.IntrospectTokenRequest, v1.IntrospectTokenResponse](
+			httpClient,
+			baseURL+AuthServiceIntrospectTokenProcedure,
+			opts...,
+		),
+		tokenExchange: connect_go.NewClient[v1.TokenExchangeRequest, v1.TokenExchangeResponse](
+			httpClient,
+			baseURL+AuthServiceTokenExchangeProcedure,
+			opts...,
+		),
+		revokeToken: connect_go.NewClient[v1.RevokeTokenRequest, v1.RevokeTokenResponse](
+			httpClient,
+			baseURL+AuthServiceRevokeTokenProcedure,
+			opts...,
+		),
+		logout: connect_go.NewClient[v1.LogoutRequest, v1.LogoutResponse](
+			httpClient,
+			baseURL+AuthServiceLogoutProcedure,
+			opts...,
+		),
+		revokeEntityTokens: connect_go.NewClient[v1.RevokeEntityTokensRequest, v1.RevokeEntityTokensResponse](
+			httpClient,
+			baseURL+AuthServiceRevokeEntityTokensProcedure,
+			opts...,
+		),
+		createClient: connect_go.NewClient[v1.CreateClientRequest, v1.CreateClientResponse](
+			httpClient,
+			baseURL+AuthServiceCreateClientProcedure,
+			opts...,
+		),
+		listClients: connect_go.NewClient[v1.ListClientsRequest, v1.ListClientsResponse](
+			httpClient,
+			baseURL+AuthServiceListClientsProcedure,
+			opts...,
+		),
+		updateClient: connect_go.NewClient[v1.UpdateClientRequest, v1.UpdateClientResponse](
+			httpClient,
+			baseURL+AuthServiceUpdateClientProcedure,
+			opts...,
+		),
+		deleteClient: connect_go.NewClient[v1.DeleteClientRequest, v1.DeleteClientResponse](
+			httpClient,
+			baseURL+AuthServiceDeleteClientProcedure,
 			opts...,
 		),
 	}
@@ -74,11 +325,37 @@ func NewAuthServiceClient(httpClient connect_go.HTTPClient, baseURL string, opts
 
 // authServiceClient implements AuthServiceClient.
 type authServiceClient struct {
-	authenticate *connect_go.Client[v1.AuthenticateRequest, v1.AuthenticateResponse]
-	refreshToken *connect_go.Client[v1.RefreshTokenRequest, v1.RefreshTokenResponse]
-	createEntity *connect_go.Client[v1.CreateEntityRequest, v1.CreateEntityResponse]
-	updateEntity *connect_go.Client[v1.UpdateEntityRequest, v1.UpdateEntityResponse]
-	getEntity    *connect_go.Client[v1.GetEntityRequest, v1.GetEntityResponse]
+	authenticate               *connect_go.Client[v1.AuthenticateRequest, v1.AuthenticateResponse]
+	authenticateTOTP           *connect_go.Client[v1.AuthenticateTOTPRequest, v1.AuthenticateTOTPResponse]
+	refreshToken               *connect_go.Client[v1.RefreshTokenRequest, v1.RefreshTokenResponse]
+	createEntity               *connect_go.Client[v1.CreateEntityRequest, v1.CreateEntityResponse]
+	updateEntity               *connect_go.Client[v1.UpdateEntityRequest, v1.UpdateEntityResponse]
+	getEntity                  *connect_go.Client[v1.GetEntityRequest, v1.GetEntityResponse]
+	listEntities               *connect_go.Client[v1.ListEntitiesRequest, v1.ListEntitiesResponse]
+	deleteEntity               *connect_go.Client[v1.DeleteEntityRequest, v1.DeleteEntityResponse]
+	disableEntity              *connect_go.Client[v1.DisableEntityRequest, v1.DisableEntityResponse]
+	enableEntity               *connect_go.Client[v1.EnableEntityRequest, v1.EnableEntityResponse]
+	unlockEntity               *connect_go.Client[v1.UnlockEntityRequest, v1.UnlockEntityResponse]
+	unlockSource               *connect_go.Client[v1.UnlockSourceRequest, v1.UnlockSourceResponse]
+	enrollTOTP                 *connect_go.Client[v1.EnrollTOTPRequest, v1.EnrollTOTPResponse]
+	confirmTOTP                *connect_go.Client[v1.ConfirmTOTPRequest, v1.ConfirmTOTPResponse]
+	disableTOTP                *connect_go.Client[v1.DisableTOTPRequest, v1.DisableTOTPResponse]
+	beginWebAuthnRegistration  *connect_go.Client[v1.BeginWebAuthnRegistrationRequest, v1.BeginWebAuthnRegistrationResponse]
+	finishWebAuthnRegistration *connect_go.Client[v1.FinishWebAuthnRegistrationRequest, v1.FinishWebAuthnRegistrationResponse]
+	beginWebAuthnLogin         *connect_go.Client[v1.BeginWebAuthnLoginRequest, v1.BeginWebAuthnLoginResponse]
+	finishWebAuthnLogin        *connect_go.Client[v1.FinishWebAuthnLoginRequest, v1.FinishWebAuthnLoginResponse]
+	createAPIKey               *connect_go.Client[v1.CreateAPIKeyRequest, v1.CreateAPIKeyResponse]
+	listAPIKeys                *connect_go.Client[v1.ListAPIKeysRequest, v1.ListAPIKeysResponse]
+	revokeAPIKey               *connect_go.Client[v1.RevokeAPIKeyRequest, v1.RevokeAPIKeyResponse]
+	introspectToken            *connect_go.Client[v1.IntrospectTokenRequest, v1.IntrospectTokenResponse]
+	tokenExchange              *connect_go.Client[v1.TokenExchangeRequest, v1.TokenExchangeResponse]
+	revokeToken                *connect_go.Client[v1.RevokeTokenRequest, v1.RevokeTokenResponse]
+	logout                     *connect_go.Client[v1.LogoutRequest, v1.LogoutResponse]
+	revokeEntityTokens         *connect_go.Client[v1.RevokeEntityTokensRequest, v1.RevokeEntityTokensResponse]
+	createClient               *connect_go.Client[v1.CreateClientRequest, v1.CreateClientResponse]
+	listClients                *connect_go.Client[v1.ListClientsRequest, v1.ListClientsResponse]
+	updateClient               *connect_go.Client[v1.UpdateClientRequest, v1.UpdateClientResponse]
+	deleteClient               *connect_go.Client[v1.DeleteClientRequest, v1.DeleteClientResponse]
 }
 
 // Authenticate calls a23n.v1.AuthService.Authenticate.
@@ -86,6 +363,11 @@ func (c *authServiceClient) Authenticate(ctx context.Context, req *connect_go.Re
 	return c.authenticate.CallUnary(ctx, req)
 }
 
+// AuthenticateTOTP calls a23n.v1.AuthService.AuthenticateTOTP.
+func (c *authServiceClient) AuthenticateTOTP(ctx context.Context, req *connect_go.Request[v1.AuthenticateTOTPRequest]) (*connect_go.Response[v1.AuthenticateTOTPResponse], error) {
+	return c.authenticateTOTP.CallUnary(ctx, req)
+}
+
 // RefreshToken calls a23n.v1.AuthService.RefreshToken.
 func (c *authServiceClient) RefreshToken(ctx context.Context, req *connect_go.Request[v1.RefreshTokenRequest]) (*connect_go.Response[v1.RefreshTokenResponse], error) {
 	return c.refreshToken.CallUnary(ctx, req)
@@ -106,13 +388,164 @@ func (c *authServiceClient) GetEntity(ctx context.Context, req *connect_go.Reque
 	return c.getEntity.CallUnary(ctx, req)
 }
 
+// ListEntities calls a23n.v1.AuthService.ListEntities.
+func (c *authServiceClient) ListEntities(ctx context.Context, req *connect_go.Request[v1.ListEntitiesRequest]) (*connect_go.Response[v1.ListEntitiesResponse], error) {
+	return c.listEntities.CallUnary(ctx, req)
+}
+
+// DeleteEntity calls a23n.v1.AuthService.DeleteEntity.
+func (c *authServiceClient) DeleteEntity(ctx context.Context, req *connect_go.Request[v1.DeleteEntityRequest]) (*connect_go.Response[v1.DeleteEntityResponse], error) {
+	return c.deleteEntity.CallUnary(ctx, req)
+}
+
+// DisableEntity calls a23n.v1.AuthService.DisableEntity.
+func (c *authServiceClient) DisableEntity(ctx context.Context, req *connect_go.Request[v1.DisableEntityRequest]) (*connect_go.Response[v1.DisableEntityResponse], error) {
+	return c.disableEntity.CallUnary(ctx, req)
+}
+
+// EnableEntity calls a23n.v1.AuthService.EnableEntity.
+func (c *authServiceClient) EnableEntity(ctx context.Context, req *connect_go.Request[v1.EnableEntityRequest]) (*connect_go.Response[v1.EnableEntityResponse], error) {
+	return c.enableEntity.CallUnary(ctx, req)
+}
+
+// UnlockEntity calls a23n.v1.AuthService.UnlockEntity.
+func (c *authServiceClient) UnlockEntity(ctx context.Context, req *connect_go.Request[v1.UnlockEntityRequest]) (*connect_go.Response[v1.UnlockEntityResponse], error) {
+	return c.unlockEntity.CallUnary(ctx, req)
+}
+
+// UnlockSource calls a23n.v1.AuthService.UnlockSource.
+func (c *authServiceClient) UnlockSource(ctx context.Context, req *connect_go.Request[v1.UnlockSourceRequest]) (*connect_go.Response[v1.UnlockSourceResponse], error) {
+	return c.unlockSource.CallUnary(ctx, req)
+}
+
+// EnrollTOTP calls a23n.v1.AuthService.EnrollTOTP.
+func (c *authServiceClient) EnrollTOTP(ctx context.Context, req *connect_go.Request[v1.EnrollTOTPRequest]) (*connect_go.Response[v1.EnrollTOTPResponse], error) {
+	return c.enrollTOTP.CallUnary(ctx, req)
+}
+
+// ConfirmTOTP calls a23n.v1.AuthService.ConfirmTOTP.
+func (c *authServiceClient) ConfirmTOTP(ctx context.Context, req *connect_go.Request[v1.ConfirmTOTPRequest]) (*connect_go.Response[v1.ConfirmTOTPResponse], error) {
+	return c.confirmTOTP.CallUnary(ctx, req)
+}
+
+// DisableTOTP calls a23n.v1.AuthService.DisableTOTP.
+func (c *authServiceClient) DisableTOTP(ctx context.Context, req *connect_go.Request[v1.DisableTOTPRequest]) (*connect_go.Response[v1.DisableTOTPResponse], error) {
+	return c.disableTOTP.CallUnary(ctx, req)
+}
+
+// BeginWebAuthnRegistration calls a23n.v1.AuthService.BeginWebAuthnRegistration.
+func (c *authServiceClient) BeginWebAuthnRegistration(ctx context.Context, req *connect_go.Request[v1.BeginWebAuthnRegistrationRequest]) (*connect_go.Response[v1.BeginWebAuthnRegistrationResponse], error) {
+	return c.beginWebAuthnRegistration.CallUnary(ctx, req)
+}
+
+// FinishWebAuthnRegistration calls a23n.v1.AuthService.FinishWebAuthnRegistration.
+func (c *authServiceClient) FinishWebAuthnRegistration(ctx context.Context, req *connect_go.Request[v1.FinishWebAuthnRegistrationRequest]) (*connect_go.Response[v1.FinishWebAuthnRegistrationResponse], error) {
+	return c.finishWebAuthnRegistration.CallUnary(ctx, req)
+}
+
+// BeginWebAuthnLogin calls a23n.v1.AuthService.BeginWebAuthnLogin.
+func (c *authServiceClient) BeginWebAuthnLogin(ctx context.Context, req *connect_go.Request[v1.BeginWebAuthnLoginRequest]) (*connect_go.Response[v1.BeginWebAuthnLoginResponse], error) {
+	return c.beginWebAuthnLogin.CallUnary(ctx, req)
+}
+
+// FinishWebAuthnLogin calls a23n.v1.AuthService.FinishWebAuthnLogin.
+func (c *authServiceClient) FinishWebAuthnLogin(ctx context.Context, req *connect_go.Request[v1.FinishWebAuthnLoginRequest]) (*connect_go.Response[v1.FinishWebAuthnLoginResponse], error) {
+	return c.finishWebAuthnLogin.CallUnary(ctx, req)
+}
+
+// CreateAPIKey calls a23n.v1.AuthService.CreateAPIKey.
+func (c *authServiceClient) CreateAPIKey(ctx context.Context, req *connect_go.Request[v1.CreateAPIKeyRequest]) (*connect_go.Response[v1.CreateAPIKeyResponse], error) {
+	return c.createAPIKey.CallUnary(ctx, req)
+}
+
+// ListAPIKeys calls a23n.v1.AuthService.ListAPIKeys.
+func (c *authServiceClient) ListAPIKeys(ctx context.Context, req *connect_go.Request[v1.ListAPIKeysRequest]) (*connect_go.Response[v1.ListAPIKeysResponse], error) {
+	return c.listAPIKeys.CallUnary(ctx, req)
+}
+
+// RevokeAPIKey calls a23n.v1.AuthService.RevokeAPIKey.
+func (c *authServiceClient) RevokeAPIKey(ctx context.Context, req *connect_go.Request[v1.RevokeAPIKeyRequest]) (*connect_go.Response[v1.RevokeAPIKeyResponse], error) {
+	return c.revokeAPIKey.CallUnary(ctx, req)
+}
+
+// IntrospectToken calls a23n.v1.AuthService.IntrospectToken.
+func (c *authServiceClient) IntrospectToken(ctx context.Context, req *connect_go.Request[v1.IntrospectTokenRequest]) (*connect_go.Response[v1.IntrospectTokenResponse], error) {
+	return c.introspectToken.CallUnary(ctx, req)
+}
+
+// TokenExchange calls a23n.v1.AuthService.TokenExchange.
+func (c *authServiceClient) TokenExchange(ctx context.Context, req *connect_go.Request[v1.TokenExchangeRequest]) (*connect_go.Response[v1.TokenExchangeResponse], error) {
+	return c.tokenExchange.CallUnary(ctx, req)
+}
+
+// RevokeToken calls a23n.v1.AuthService.RevokeToken.
+func (c *authServiceClient) RevokeToken(ctx context.Context, req *connect_go.Request[v1.RevokeTokenRequest]) (*connect_go.Response[v1.RevokeTokenResponse], error) {
+	return c.revokeToken.CallUnary(ctx, req)
+}
+
+// Logout calls a23n.v1.AuthService.Logout.
+func (c *authServiceClient) Logout(ctx context.Context, req *connect_go.Request[v1.LogoutRequest]) (*connect_go.Response[v1.LogoutResponse], error) {
+	return c.logout.CallUnary(ctx, req)
+}
+
+// RevokeEntityTokens calls a23n.v1.AuthService.RevokeEntityTokens.
+func (c *authServiceClient) RevokeEntityTokens(ctx context.Context, req *connect_go.Request[v1.RevokeEntityTokensRequest]) (*connect_go.Response[v1.RevokeEntityTokensResponse], error) {
+	return c.revokeEntityTokens.CallUnary(ctx, req)
+}
+
+// CreateClient calls a23n.v1.AuthService.CreateClient.
+func (c *authServiceClient) CreateClient(ctx context.Context, req *connect_go.Request[v1.CreateClientRequest]) (*connect_go.Response[v1.CreateClientResponse], error) {
+	return c.createClient.CallUnary(ctx, req)
+}
+
+// ListClients calls a23n.v1.AuthService.ListClients.
+func (c *authServiceClient) ListClients(ctx context.Context, req *connect_go.Request[v1.ListClientsRequest]) (*connect_go.Response[v1.ListClientsResponse], error) {
+	return c.listClients.CallUnary(ctx, req)
+}
+
+// UpdateClient calls a23n.v1.AuthService.UpdateClient.
+func (c *authServiceClient) UpdateClient(ctx context.Context, req *connect_go.Request[v1.UpdateClientRequest]) (*connect_go.Response[v1.UpdateClientResponse], error) {
+	return c.updateClient.CallUnary(ctx, req)
+}
+
+// DeleteClient calls a23n.v1.AuthService.DeleteClient.
+func (c *authServiceClient) DeleteClient(ctx context.Context, req *connect_go.Request[v1.DeleteClientRequest]) (*connect_go.Response[v1.DeleteClientResponse], error) {
+	return c.deleteClient.CallUnary(ctx, req)
+}
+
 // AuthServiceHandler is an implementation of the a23n.v1.AuthService service.
 type AuthServiceHandler interface {
 	Authenticate(context.Context, *connect_go.Request[v1.AuthenticateRequest]) (*connect_go.Response[v1.AuthenticateResponse], error)
+	AuthenticateTOTP(context.Context, *connect_go.Request[v1.AuthenticateTOTPRequest]) (*connect_go.Response[v1.AuthenticateTOTPResponse], error)
 	RefreshToken(context.Context, *connect_go.Request[v1.RefreshTokenRequest]) (*connect_go.Response[v1.RefreshTokenResponse], error)
 	CreateEntity(context.Context, *connect_go.Request[v1.CreateEntityRequest]) (*connect_go.Response[v1.CreateEntityResponse], error)
 	UpdateEntity(context.Context, *connect_go.Request[v1.UpdateEntityRequest]) (*connect_go.Response[v1.UpdateEntityResponse], error)
 	GetEntity(context.Context, *connect_go.Request[v1.GetEntityRequest]) (*connect_go.Response[v1.GetEntityResponse], error)
+	ListEntities(context.Context, *connect_go.Request[v1.ListEntitiesRequest]) (*connect_go.Response[v1.ListEntitiesResponse], error)
+	DeleteEntity(context.Context, *connect_go.Request[v1.DeleteEntityRequest]) (*connect_go.Response[v1.DeleteEntityResponse], error)
+	DisableEntity(context.Context, *connect_go.Request[v1.DisableEntityRequest]) (*connect_go.Response[v1.DisableEntityResponse], error)
+	EnableEntity(context.Context, *connect_go.Request[v1.EnableEntityRequest]) (*connect_go.Response[v1.EnableEntityResponse], error)
+	UnlockEntity(context.Context, *connect_go.Request[v1.UnlockEntityRequest]) (*connect_go.Response[v1.UnlockEntityResponse], error)
+	UnlockSource(context.Context, *connect_go.Request[v1.UnlockSourceRequest]) (*connect_go.Response[v1.UnlockSourceResponse], error)
+	EnrollTOTP(context.Context, *connect_go.Request[v1.EnrollTOTPRequest]) (*connect_go.Response[v1.EnrollTOTPResponse], error)
+	ConfirmTOTP(context.Context, *connect_go.Request[v1.ConfirmTOTPRequest]) (*connect_go.Response[v1.ConfirmTOTPResponse], error)
+	DisableTOTP(context.Context, *connect_go.Request[v1.DisableTOTPRequest]) (*connect_go.Response[v1.DisableTOTPResponse], error)
+	BeginWebAuthnRegistration(context.Context, *connect_go.Request[v1.BeginWebAuthnRegistrationRequest]) (*connect_go.Response[v1.BeginWebAuthnRegistrationResponse], error)
+	FinishWebAuthnRegistration(context.Context, *connect_go.Request[v1.FinishWebAuthnRegistrationRequest]) (*connect_go.Response[v1.FinishWebAuthnRegistrationResponse], error)
+	BeginWebAuthnLogin(context.Context, *connect_go.Request[v1.BeginWebAuthnLoginRequest]) (*connect_go.Response[v1.BeginWebAuthnLoginResponse], error)
+	FinishWebAuthnLogin(context.Context, *connect_go.Request[v1.FinishWebAuthnLoginRequest]) (*connect_go.Response[v1.FinishWebAuthnLoginResponse], error)
+	CreateAPIKey(context.Context, *connect_go.Request[v1.CreateAPIKeyRequest]) (*connect_go.Response[v1.CreateAPIKeyResponse], error)
+	ListAPIKeys(context.Context, *connect_go.Request[v1.ListAPIKeysRequest]) (*connect_go.Response[v1.ListAPIKeysResponse], error)
+	RevokeAPIKey(context.Context, *connect_go.Request[v1.RevokeAPIKeyRequest]) (*connect_go.Response[v1.RevokeAPIKeyResponse], error)
+	IntrospectToken(context.Context, *connect_go.Request[v1.IntrospectTokenRequest]) (*connect_go.Response[v1.IntrospectTokenResponse], error)
+	TokenExchange(context.Context, *connect_go.Request[v1.TokenExchangeRequest]) (*connect_go.Response[v1.TokenExchangeResponse], error)
+	RevokeToken(context.Context, *connect_go.Request[v1.RevokeTokenRequest]) (*connect_go.Response[v1.RevokeTokenResponse], error)
+	Logout(context.Context, *connect_go.Request[v1.LogoutRequest]) (*connect_go.Response[v1.LogoutResponse], error)
+	RevokeEntityTokens(context.Context, *connect_go.Request[v1.RevokeEntityTokensRequest]) (*connect_go.Response[v1.RevokeEntityTokensResponse], error)
+	CreateClient(context.Context, *connect_go.Request[v1.CreateClientRequest]) (*connect_go.Response[v1.CreateClientResponse], error)
+	ListClients(context.Context, *connect_go.Request[v1.ListClientsRequest]) (*connect_go.Response[v1.ListClientsResponse], error)
+	UpdateClient(context.Context, *connect_go.Request[v1.UpdateClientRequest]) (*connect_go.Response[v1.UpdateClientResponse], error)
+	DeleteClient(context.Context, *connect_go.Request[v1.DeleteClientRequest]) (*connect_go.Response[v1.DeleteClientResponse], error)
 }
 
 // NewAuthServiceHandler builds an HTTP handler from the service implementation. It returns the path
@@ -122,31 +555,161 @@ type AuthServiceHandler interface {
 // and JSON codecs. They also support gzip compression.
 func NewAuthServiceHandler(svc AuthServiceHandler, opts ...connect_go.HandlerOption) (string, http.Handler) {
 	mux := http.NewServeMux()
-	mux.Handle("/a23n.v1.AuthService/Authenticate", connect_go.NewUnaryHandler(
-		"/a23n.v1.AuthService/Authenticate",
+	mux.Handle(AuthServiceAuthenticateProcedure, connect_go.NewUnaryHandler(
+		AuthServiceAuthenticateProcedure,
 		svc.Authenticate,
 		opts...,
 	))
-	mux.Handle("/a23n.v1.AuthService/RefreshToken", connect_go.NewUnaryHandler(
-		"/a23n.v1.AuthService/RefreshToken",
+	mux.Handle(AuthServiceAuthenticateTOTPProcedure, connect_go.NewUnaryHandler(
+		AuthServiceAuthenticateTOTPProcedure,
+		svc.AuthenticateTOTP,
+		opts...,
+	))
+	mux.Handle(AuthServiceRefreshTokenProcedure, connect_go.NewUnaryHandler(
+		AuthServiceRefreshTokenProcedure,
 		svc.RefreshToken,
 		opts...,
 	))
-	mux.Handle("/a23n.v1.AuthService/CreateEntity", connect_go.NewUnaryHandler(
-		"/a23n.v1.AuthService/CreateEntity",
+	mux.Handle(AuthServiceCreateEntityProcedure, connect_go.NewUnaryHandler(
+		AuthServiceCreateEntityProcedure,
 		svc.CreateEntity,
 		opts...,
 	))
-	mux.Handle("/a23n.v1.AuthService/UpdateEntity", connect_go.NewUnaryHandler(
-		"/a23n.v1.AuthService/UpdateEntity",
+	mux.Handle(AuthServiceUpdateEntityProcedure, connect_go.NewUnaryHandler(
+		AuthServiceUpdateEntityProcedure,
 		svc.UpdateEntity,
 		opts...,
 	))
-	mux.Handle("/a23n.v1.AuthService/GetEntity", connect_go.NewUnaryHandler(
-		"/a23n.v1.AuthService/GetEntity",
+	mux.Handle(AuthServiceGetEntityProcedure, connect_go.NewUnaryHandler(
+		AuthServiceGetEntityProcedure,
 		svc.GetEntity,
 		opts...,
 	))
+	mux.Handle(AuthServiceListEntitiesProcedure, connect_go.NewUnaryHandler(
+		AuthServiceListEntitiesProcedure,
+		svc.ListEntities,
+		opts...,
+	))
+	mux.Handle(AuthServiceDeleteEntityProcedure, connect_go.NewUnaryHandler(
+		AuthServiceDeleteEntityProcedure,
+		svc.DeleteEntity,
+		opts...,
+	))
+	mux.Handle(AuthServiceDisableEntityProcedure, connect_go.NewUnaryHandler(
+		AuthServiceDisableEntityProcedure,
+		svc.DisableEntity,
+		opts...,
+	))
+	mux.Handle(AuthServiceEnableEntityProcedure, connect_go.NewUnaryHandler(
+		AuthServiceEnableEntityProcedure,
+		svc.EnableEntity,
+		opts...,
+	))
+	mux.Handle(AuthServiceUnlockEntityProcedure, connect_go.NewUnaryHandler(
+		AuthServiceUnlockEntityProcedure,
+		svc.UnlockEntity,
+		opts...,
+	))
+	mux.Handle(AuthServiceUnlockSourceProcedure, connect_go.NewUnaryHandler(
+		AuthServiceUnlockSourceProcedure,
+		svc.UnlockSource,
+		opts...,
+	))
+	mux.Handle(AuthServiceEnrollTOTPProcedure, connect_go.NewUnaryHandler(
+		AuthServiceEnrollTOTPProcedure,
+		svc.EnrollTOTP,
+		opts...,
+	))
+	mux.Handle(AuthServiceConfirmTOTPProcedure, connect_go.NewUnaryHandler(
+		AuthServiceConfirmTOTPProcedure,
+		svc.ConfirmTOTP,
+		opts...,
+	))
+	mux.Handle(AuthServiceDisableTOTPProcedure, connect_go.NewUnaryHandler(
+		AuthServiceDisableTOTPProcedure,
+		svc.DisableTOTP,
+		opts...,
+	))
+	mux.Handle(AuthServiceBeginWebAuthnRegistrationProcedure, connect_go.NewUnaryHandler(
+		AuthServiceBeginWebAuthnRegistrationProcedure,
+		svc.BeginWebAuthnRegistration,
+		opts...,
+	))
+	mux.Handle(AuthServiceFinishWebAuthnRegistrationProcedure, connect_go.NewUnaryHandler(
+		AuthServiceFinishWebAuthnRegistrationProcedure,
+		svc.FinishWebAuthnRegistration,
+		opts...,
+	))
+	mux.Handle(AuthServiceBeginWebAuthnLoginProcedure, connect_go.NewUnaryHandler(
+		AuthServiceBeginWebAuthnLoginProcedure,
+		svc.BeginWebAuthnLogin,
+		opts...,
+	))
+	mux.Handle(AuthServiceFinishWebAuthnLoginProcedure, connect_go.NewUnaryHandler(
+		AuthServiceFinishWebAuthnLoginProcedure,
+		svc.FinishWebAuthnLogin,
+		opts...,
+	))
+	mux.Handle(AuthServiceCreateAPIKeyProcedure, connect_go.NewUnaryHandler(
+		AuthServiceCreateAPIKeyProcedure,
+		svc.CreateAPIKey,
+		opts...,
+	))
+	mux.Handle(AuthServiceListAPIKeysProcedure, connect_go.NewUnaryHandler(
+		AuthServiceListAPIKeysProcedure,
+		svc.ListAPIKeys,
+		opts...,
+	))
+	mux.Handle(AuthServiceRevokeAPIKeyProcedure, connect_go.NewUnaryHandler(
+		AuthServiceRevokeAPIKeyProcedure,
+		svc.RevokeAPIKey,
+		opts...,
+	))
+	mux.Handle(AuthServiceIntrospectTokenProcedure, connect_go.NewUnaryHandler(
+		AuthServiceIntrospectTokenProcedure,
+		svc.IntrospectToken,
+		opts...,
+	))
+	mux.Handle(AuthServiceTokenExchangeProcedure, connect_go.NewUnaryHandler(
+		AuthServiceTokenExchangeProcedure,
+		svc.TokenExchange,
+		opts...,
+	))
+	mux.Handle(AuthServiceRevokeTokenProcedure, connect_go.NewUnaryHandler(
+		AuthServiceRevokeTokenProcedure,
+		svc.RevokeToken,
+		opts...,
+	))
+	mux.Handle(AuthServiceLogoutProcedure, connect_go.NewUnaryHandler(
+		AuthServiceLogoutProcedure,
+		svc.Logout,
+		opts...,
+	))
+	mux.Handle(AuthServiceRevokeEntityTokensProcedure, connect_go.NewUnaryHandler(
+		AuthServiceRevokeEntityTokensProcedure,
+		svc.RevokeEntityTokens,
+		opts...,
+	))
+	mux.Handle(AuthServiceCreateClientProcedure, connect_go.NewUnaryHandler(
+		AuthServiceCreateClientProcedure,
+		svc.CreateClient,
+		opts...,
+	))
+	mux.Handle(AuthServiceListClientsProcedure, connect_go.NewUnaryHandler(
+		AuthServiceListClientsProcedure,
+		svc.ListClients,
+		opts...,
+	))
+	mux.Handle(AuthServiceUpdateClientProcedure, connect_go.NewUnaryHandler(
+		AuthServiceUpdateClientProcedure,
+		svc.UpdateClient,
+		opts...,
+	))
+	mux.Handle(AuthServiceDeleteClientProcedure, connect_go.NewUnaryHandler(
+		AuthServiceDeleteClientProcedure,
+		svc.DeleteClient,
+		opts...,
+	))
 	return "/a23n.v1.AuthService/", mux
 }
 
@@ -157,6 +720,10 @@ func (UnimplementedAuthServiceHandler) Authenticate(context.Context, *connect_go
 	return nil, connect_go.NewError(connect_go.CodeUnimplemented, errors.New("a23n.v1.AuthService.Authenticate is not implemented"))
 }
 
+func (UnimplementedAuthServiceHandler) AuthenticateTOTP(context.Context, *connect_go.Request[v1.AuthenticateTOTPRequest]) (*connect_go.Response[v1.AuthenticateTOTPResponse], error) {
+	return nil, connect_go.NewError(connect_go.CodeUnimplemented, errors.New("a23n.v1.AuthService.AuthenticateTOTP is not implemented"))
+}
+
 func (UnimplementedAuthServiceHandler) RefreshToken(context.Context, *connect_go.Request[v1.RefreshTokenRequest]) (*connect_go.Response[v1.RefreshTokenResponse], error) {
 	return nil, connect_go.NewError(connect_go.CodeUnimplemented, errors.New("a23n.v1.AuthService.RefreshToken is not implemented"))
 }
@@ -172,3 +739,103 @@ func (UnimplementedAuthServiceHandler) UpdateEntity(context.Context, *connect_go
 func (UnimplementedAuthServiceHandler) GetEntity(context.Context, *connect_go.Request[v1.GetEntityRequest]) (*connect_go.Response[v1.GetEntityResponse], error) {
 	return nil, connect_go.NewError(connect_go.CodeUnimplemented, errors.New("a23n.v1.AuthService.GetEntity is not implemented"))
 }
+
+func (UnimplementedAuthServiceHandler) ListEntities(context.Context, *connect_go.Request[v1.ListEntitiesRequest]) (*connect_go.Response[v1.ListEntitiesResponse], error) {
+	return nil, connect_go.NewError(connect_go.CodeUnimplemented, errors.New("a23n.v1.AuthService.ListEntities is not implemented"))
+}
+
+func (UnimplementedAuthServiceHandler) DeleteEntity(context.Context, *connect_go.Request[v1.DeleteEntityRequest]) (*connect_go.Response[v1.DeleteEntityResponse], error) {
+	return nil, connect_go.NewError(connect_go.CodeUnimplemented, errors.New("a23n.v1.AuthService.DeleteEntity is not implemented"))
+}
+
+func (UnimplementedAuthServiceHandler) DisableEntity(context.Context, *connect_go.Request[v1.DisableEntityRequest]) (*connect_go.Response[v1.DisableEntityResponse], error) {
+	return nil, connect_go.NewError(connect_go.CodeUnimplemented, errors.New("a23n.v1.AuthService.DisableEntity is not implemented"))
+}
+
+func (UnimplementedAuthServiceHandler) EnableEntity(context.Context, *connect_go.Request[v1.EnableEntityRequest]) (*connect_go.Response[v1.EnableEntityResponse], error) {
+	return nil, connect_go.NewError(connect_go.CodeUnimplemented, errors.New("a23n.v1.AuthService.EnableEntity is not implemented"))
+}
+
+func (UnimplementedAuthServiceHandler) UnlockEntity(context.Context, *connect_go.Request[v1.UnlockEntityRequest]) (*connect_go.Response[v1.UnlockEntityResponse], error) {
+	return nil, connect_go.NewError(connect_go.CodeUnimplemented, errors.New("a23n.v1.AuthService.UnlockEntity is not implemented"))
+}
+
+func (UnimplementedAuthServiceHandler) UnlockSource(context.Context, *connect_go.Request[v1.UnlockSourceRequest]) (*connect_go.Response[v1.UnlockSourceResponse], error) {
+	return nil, connect_go.NewError(connect_go.CodeUnimplemented, errors.New("a23n.v1.AuthService.UnlockSource is not implemented"))
+}
+
+func (UnimplementedAuthServiceHandler) EnrollTOTP(context.Context, *connect_go.Request[v1.EnrollTOTPRequest]) (*connect_go.Response[v1.EnrollTOTPResponse], error) {
+	return nil, connect_go.NewError(connect_go.CodeUnimplemented, errors.New("a23n.v1.AuthService.EnrollTOTP is not implemented"))
+}
+
+func (UnimplementedAuthServiceHandler) ConfirmTOTP(context.Context, *connect_go.Request[v1.ConfirmTOTPRequest]) (*connect_go.Response[v1.ConfirmTOTPResponse], error) {
+	return nil, connect_go.NewError(connect_go.CodeUnimplemented, errors.New("a23n.v1.AuthService.ConfirmTOTP is not implemented"))
+}
+
+func (UnimplementedAuthServiceHandler) DisableTOTP(context.Context, *connect_go.Request[v1.DisableTOTPRequest]) (*connect_go.Response[v1.DisableTOTPResponse], error) {
+	return nil, connect_go.NewError(connect_go.CodeUnimplemented, errors.New("a23n.v1.AuthService.DisableTOTP is not implemented"))
+}
+
+func (UnimplementedAuthServiceHandler) BeginWebAuthnRegistration(context.Context, *connect_go.Request[v1.BeginWebAuthnRegistrationRequest]) (*connect_go.Response[v1.BeginWebAuthnRegistrationResponse], error) {
+	return nil, connect_go.NewError(connect_go.CodeUnimplemented, errors.New("a23n.v1.AuthService.BeginWebAuthnRegistration is not implemented"))
+}
+
+func (UnimplementedAuthServiceHandler) FinishWebAuthnRegistration(context.Context, *connect_go.Request[v1.FinishWebAuthnRegistrationRequest]) (*connect_go.Response[v1.FinishWebAuthnRegistrationResponse], error) {
+	return nil, connect_go.NewError(connect_go.CodeUnimplemented, errors.New("a23n.v1.AuthService.FinishWebAuthnRegistration is not implemented"))
+}
+
+func (UnimplementedAuthServiceHandler) BeginWebAuthnLogin(context.Context, *connect_go.Request[v1.BeginWebAuthnLoginRequest]) (*connect_go.Response[v1.BeginWebAuthnLoginResponse], error) {
+	return nil, connect_go.NewError(connect_go.CodeUnimplemented, errors.New("a23n.v1.AuthService.BeginWebAuthnLogin is not implemented"))
+}
+
+func (UnimplementedAuthServiceHandler) FinishWebAuthnLogin(context.Context, *connect_go.Request[v1.FinishWebAuthnLoginRequest]) (*connect_go.Response[v1.FinishWebAuthnLoginResponse], error) {
+	return nil, connect_go.NewError(connect_go.CodeUnimplemented, errors.New("a23n.v1.AuthService.FinishWebAuthnLogin is not implemented"))
+}
+
+func (UnimplementedAuthServiceHandler) CreateAPIKey(context.Context, *connect_go.Request[v1.CreateAPIKeyRequest]) (*connect_go.Response[v1.CreateAPIKeyResponse], error) {
+	return nil, connect_go.NewError(connect_go.CodeUnimplemented, errors.New("a23n.v1.AuthService.CreateAPIKey is not implemented"))
+}
+
+func (UnimplementedAuthServiceHandler) ListAPIKeys(context.Context, *connect_go.Request[v1.ListAPIKeysRequest]) (*connect_go.Response[v1.ListAPIKeysResponse], error) {
+	return nil, connect_go.NewError(connect_go.CodeUnimplemented, errors.New("a23n.v1.AuthService.ListAPIKeys is not implemented"))
+}
+
+func (UnimplementedAuthServiceHandler) RevokeAPIKey(context.Context, *connect_go.Request[v1.RevokeAPIKeyRequest]) (*connect_go.Response[v1.RevokeAPIKeyResponse], error) {
+	return nil, connect_go.NewError(connect_go.CodeUnimplemented, errors.New("a23n.v1.AuthService.RevokeAPIKey is not implemented"))
+}
+
+func (UnimplementedAuthServiceHandler) IntrospectToken(context.Context, *connect_go.Request[v1.IntrospectTokenRequest]) (*connect_go.Response[v1.IntrospectTokenResponse], error) {
+	return nil, connect_go.NewError(connect_go.CodeUnimplemented, errors.New("a23n.v1.AuthService.IntrospectToken is not implemented"))
+}
+
+func (UnimplementedAuthServiceHandler) TokenExchange(context.Context, *connect_go.Request[v1.TokenExchangeRequest]) (*connect_go.Response[v1.TokenExchangeResponse], error) {
+	return nil, connect_go.NewError(connect_go.CodeUnimplemented, errors.New("a23n.v1.AuthService.TokenExchange is not implemented"))
+}
+
+func (UnimplementedAuthServiceHandler) RevokeToken(context.Context, *connect_go.Request[v1.RevokeTokenRequest]) (*connect_go.Response[v1.RevokeTokenResponse], error) {
+	return nil, connect_go.NewError(connect_go.CodeUnimplemented, errors.New("a23n.v1.AuthService.RevokeToken is not implemented"))
+}
+
+func (UnimplementedAuthServiceHandler) Logout(context.Context, *connect_go.Request[v1.LogoutRequest]) (*connect_go.Response[v1.LogoutResponse], error) {
+	return nil, connect_go.NewError(connect_go.CodeUnimplemented, errors.New("a23n.v1.AuthService.Logout is not implemented"))
+}
+
+func (UnimplementedAuthServiceHandler) RevokeEntityTokens(context.Context, *connect_go.Request[v1.RevokeEntityTokensRequest]) (*connect_go.Response[v1.RevokeEntityTokensResponse], error) {
+	return nil, connect_go.NewError(connect_go.CodeUnimplemented, errors.New("a23n.v1.AuthService.RevokeEntityTokens is not implemented"))
+}
+
+func (UnimplementedAuthServiceHandler) CreateClient(context.Context, *connect_go.Request[v1.CreateClientRequest]) (*connect_go.Response[v1.CreateClientResponse], error) {
+	return nil, connect_go.NewError(connect_go.CodeUnimplemented, errors.New("a23n.v1.AuthService.CreateClient is not implemented"))
+}
+
+func (UnimplementedAuthServiceHandler) ListClients(context.Context, *connect_go.Request[v1.ListClientsRequest]) (*connect_go.Response[v1.ListClientsResponse], error) {
+	return nil, connect_go.NewError(connect_go.CodeUnimplemented, errors.New("a23n.v1.AuthService.ListClients is not implemented"))
+}
+
+func (UnimplementedAuthServiceHandler) UpdateClient(context.Context, *connect_go.Request[v1.UpdateClientRequest]) (*connect_go.Response[v1.UpdateClientResponse], error) {
+	return nil, connect_go.NewError(connect_go.CodeUnimplemented, errors.New("a23n.v1.AuthService.UpdateClient is not implemented"))
+}
+
+func (UnimplementedAuthServiceHandler) DeleteClient(context.Context, *connect_go.Request[v1.DeleteClientRequest]) (*connect_go.Response[v1.DeleteClientResponse], error) {
+	return nil, connect_go.NewError(connect_go.CodeUnimplemented, errors.New("a23n.v1.AuthService.DeleteClient is not implemented"))
+}
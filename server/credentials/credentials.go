@@ -4,4 +4,6 @@ type Credentials struct {
 	ID       string
 	Password string
 	Token    string
+	// APIKey is taken either from the authorization header using the ApiKey scheme or from the X-API-Key header
+	APIKey string
 }
@@ -3,13 +3,23 @@ package handler
 import (
 	"context"
 	"errors"
+	"math"
+	"net"
+	"strconv"
+	"time"
 
 	"github.com/bufbuild/connect-go"
+	"github.com/google/uuid"
 
 	"github.com/ashep/a23n/api"
 	"github.com/ashep/a23n/sdk/proto/a23n/v1"
 )
 
+// mfaTokenTTL defines how long an entity having TOTP enabled has to pass its code after passing the secret
+const mfaTokenTTL = 5 * time.Minute
+
+// Authenticate issues tokens to an entity passing its secret in the Basic authorization header. Entities having TOTP
+// enabled must pass the code as well, either in the request or by AuthenticateTOTP using the returned MFA token.
 func (h *Handler) Authenticate(
 	ctx context.Context,
 	req *connect.Request[v1.AuthenticateRequest],
@@ -23,61 +33,137 @@ func (h *Handler) Authenticate(
 		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("empty entity id"))
 	}
 
-	e, err := h.api.GetEntity(ctx, crd.ID)
-	if errors.Is(err, api.ErrNotFound) {
-		h.l.Warn().Str("entity_id", crd.ID).Msg("entity not found")
-		return nil, connect.NewError(connect.CodeUnauthenticated, nil)
-	} else if err != nil {
-		h.l.Error().Err(err).Str("entity_id", crd.ID).Msg("failed to get entity")
-		return nil, connect.NewError(connect.CodeUnauthenticated, nil)
-	}
+	source := peerHost(req.Peer())
 
-	ok, err = h.api.CheckSecret(crd.ID, crd.Password)
+	e, err := h.verifyCredentials(ctx, crd.ID, crd.Password, source)
 	if err != nil {
-		h.l.Error().Err(err).Str("entity_id", crd.ID).Msg("check secret failed")
-		return nil, connect.NewError(connect.CodeUnauthenticated, nil)
-	} else if !ok {
-		return nil, connect.NewError(connect.CodeUnauthenticated, nil)
+		return nil, err
 	}
 
-	if !h.api.CheckScope(e.Scope, req.Msg.Scope) {
+	if !h.api.CheckScope(h.grantedScope(e, e.TOTPEnabled), req.Msg.Scope) {
 		return nil, connect.NewError(connect.CodePermissionDenied, nil)
 	}
 
-	accessToken := h.api.CreateToken(e.ID, e.Scope, h.accessTokenTTL)
-	accessTokenExp, err := accessToken.Claims().GetExpirationTime()
-	if err != nil {
-		h.l.Error().Err(err).Str("entity_id", crd.ID).Msg("get access token expiration time failed")
-		return nil, connect.NewError(connect.CodeInternal, nil)
+	msg := "authenticated by password"
+	if e.TOTPEnabled {
+		if req.Msg.TotpCode == "" {
+			return h.mfaChallenge(e)
+		}
+
+		if err = h.verifyTOTP(ctx, e.ID, req.Msg.TotpCode, source); err != nil {
+			return nil, err
+		}
+		msg = "authenticated by password and totp"
 	}
-	accessTokenStr, err := accessToken.SignedString(h.api.SecretKey())
+
+	e.Scope = h.grantedScope(e, e.TOTPEnabled)
+
+	tp, err := h.issueTokens(ctx, e, uuid.NewString())
 	if err != nil {
-		h.l.Error().Err(err).Str("entity_id", crd.ID).Msg("get access token signed string failed")
-		return nil, connect.NewError(connect.CodeInternal, nil)
+		return nil, err
+	}
+
+	h.l.Info().
+		Str("entity_id", e.ID).
+		Int64("access_token_expires", tp.accessExpires).
+		Int64("refresh_token_expires", tp.refreshExpires).
+		Msg(msg)
+
+	return connect.NewResponse(&v1.AuthenticateResponse{
+		AccessToken:         tp.access,
+		AccessTokenExpires:  tp.accessExpires,
+		RefreshToken:        tp.refresh,
+		RefreshTokenExpires: tp.refreshExpires,
+	}), nil
+}
+
+// verifyCredentials checks the secret of an entity and converts the errors to the ones returned to clients.
+func (h *Handler) verifyCredentials(ctx context.Context, idOrLogin, secret, source string) (api.Entity, error) {
+	var lockErr api.ErrLocked
+
+	e, err := h.api.VerifyCredentials(ctx, idOrLogin, secret, source)
+	if errors.Is(err, api.ErrInvalidCredentials) {
+		h.l.Warn().Str("entity_id", idOrLogin).Str("source", source).Msg("invalid credentials")
+		return api.Entity{}, connect.NewError(connect.CodeUnauthenticated, nil)
+	} else if errors.As(err, &lockErr) {
+		h.l.Warn().Str("entity_id", idOrLogin).Str("source", source).Time("until", lockErr.Until).Msg("locked out")
+		return api.Entity{}, lockedError(lockErr)
+	} else if errors.Is(err, api.ErrEntityDisabled) {
+		h.l.Warn().Str("entity_id", idOrLogin).Msg("entity disabled")
+		return api.Entity{}, connect.NewError(connect.CodePermissionDenied, err)
+	} else if err != nil {
+		h.l.Error().Err(err).Str("entity_id", idOrLogin).Msg("verify credentials failed")
+		return api.Entity{}, connect.NewError(connect.CodeInternal, nil)
 	}
 
-	refreshToken := h.api.CreateToken(e.ID+"_refresh", e.Scope, h.refreshTokenTTL)
-	refreshTokenExp, err := refreshToken.Claims().GetExpirationTime()
+	return e, nil
+}
+
+// mfaChallenge responds with an MFA token which lets an entity having passed its secret pass the TOTP code by
+// AuthenticateTOTP.
+func (h *Handler) mfaChallenge(e api.Entity) (*connect.Response[v1.AuthenticateResponse], error) {
+	t := h.api.CreateToken(api.TokenTypeMFA, e.ID, "", nil, mfaTokenTTL)
+	exp, err := t.Claims().GetExpirationTime()
 	if err != nil {
-		h.l.Error().Err(err).Str("entity_id", crd.ID).Msg("get refresh token expiration time failed")
+		h.l.Error().Err(err).Str("entity_id", e.ID).Msg("get mfa token expiration time failed")
 		return nil, connect.NewError(connect.CodeInternal, nil)
 	}
-	refreshTokenStr, err := refreshToken.SignedString(h.api.SecretKey())
+	ts, err := t.SignedString()
 	if err != nil {
-		h.l.Error().Err(err).Str("entity_id", crd.ID).Msg("get refresh token signed string failed")
+		h.l.Error().Err(err).Str("entity_id", e.ID).Msg("get mfa token signed string failed")
 		return nil, connect.NewError(connect.CodeInternal, nil)
 	}
 
-	h.l.Info().
-		Str("entity_id", crd.ID).
-		Int64("access_token_expires", accessTokenExp.Unix()).
-		Int64("refresh_token_expires", refreshTokenExp.Unix()).
-		Msg("authenticated by password")
+	h.l.Info().Str("entity_id", e.ID).Msg("mfa required")
 
 	return connect.NewResponse(&v1.AuthenticateResponse{
-		AccessToken:         accessTokenStr,
-		AccessTokenExpires:  accessTokenExp.Unix(),
-		RefreshToken:        refreshTokenStr,
-		RefreshTokenExpires: refreshTokenExp.Unix(),
+		MfaRequired:     true,
+		MfaToken:        ts,
+		MfaTokenExpires: exp.Unix(),
 	}), nil
 }
+
+// verifyTOTP checks the TOTP code of an entity and converts the errors to the ones returned to clients.
+func (h *Handler) verifyTOTP(ctx context.Context, entityID, code, source string) error {
+	var lockErr api.ErrLocked
+
+	err := h.api.VerifyTOTP(ctx, entityID, code, source)
+	if errors.Is(err, api.ErrInvalidCredentials) {
+		h.l.Warn().Str("entity_id", entityID).Str("source", source).Msg("invalid totp code")
+		return connect.NewError(connect.CodeUnauthenticated, nil)
+	} else if errors.As(err, &lockErr) {
+		h.l.Warn().Str("entity_id", entityID).Str("source", source).Time("until", lockErr.Until).Msg("locked out")
+		return lockedError(lockErr)
+	} else if errors.Is(err, api.ErrInvalidArg{}) {
+		return connect.NewError(connect.CodeFailedPrecondition, err)
+	} else if err != nil {
+		h.l.Error().Err(err).Str("entity_id", entityID).Msg("verify totp failed")
+		return connect.NewError(connect.CodeInternal, nil)
+	}
+
+	return nil
+}
+
+// peerHost returns the host part of a peer address, which is the client IP address for HTTP requests.
+func peerHost(p connect.Peer) string {
+	host, _, err := net.SplitHostPort(p.Addr)
+	if err != nil {
+		return p.Addr
+	}
+
+	return host
+}
+
+// lockedError converts err to a ResourceExhausted error telling the client when to retry. The delay is rounded up to
+// whole seconds and is at least one second, even if the lockout has just expired.
+func lockedError(err api.ErrLocked) *connect.Error {
+	retryAfter := int(math.Ceil(time.Until(err.Until).Seconds()))
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+
+	cErr := connect.NewError(connect.CodeResourceExhausted, err)
+	cErr.Meta().Set("Retry-After", strconv.Itoa(retryAfter))
+
+	return cErr
+}
@@ -32,7 +32,7 @@ func (s *AuthenticateTestSuite) SetupTest() {
 	l := lt.Logger().Level(zerolog.DebugLevel)
 
 	s.api = &api.APIMock{}
-	s.handler = handler.New(s.api, time.Second*5, time.Second*10, l)
+	s.handler = handler.New(s.api, time.Second*5, time.Second*10, nil, nil, l)
 	s.logger = lt
 }
 
@@ -58,10 +58,10 @@ func (s *AuthenticateTestSuite) TestRequestEmptyEntityId() {
 	s.Require().Nil(s.logger.LastEntry())
 }
 
-func (s *AuthenticateTestSuite) TestEntityNotFound() {
+func (s *AuthenticateTestSuite) TestInvalidCredentials() {
 	s.api.
-		On("GetEntity", mock.AnythingOfType("*context.valueCtx"), "entityID").
-		Return(api.Entity{}, api.ErrNotFound)
+		On("VerifyCredentials", mock.AnythingOfType("*context.valueCtx"), "entityID", "password", "").
+		Return(api.Entity{}, api.ErrInvalidCredentials)
 
 	ctx := context.WithValue(context.Background(), "crd", credentials.Credentials{
 		ID:       "entityID",
@@ -73,13 +73,14 @@ func (s *AuthenticateTestSuite) TestEntityNotFound() {
 
 	l := s.logger.LastEntry()
 	s.Require().NotNil(l)
-	s.Assert().Equal(`{"level":"warn","entity_id":"entityID","message":"entity not found"}`, l.String())
+	s.Assert().Equal(`{"level":"warn","entity_id":"entityID","source":"","message":"invalid credentials"}`, l.String())
 }
 
-func (s *AuthenticateTestSuite) TestAPIGetEntityError() {
+func (s *AuthenticateTestSuite) TestLocked() {
+	until := time.Now().Add(90 * time.Second)
 	s.api.
-		On("GetEntity", mock.AnythingOfType("*context.valueCtx"), "entityID").
-		Return(api.Entity{}, errors.New("getEntityError"))
+		On("VerifyCredentials", mock.AnythingOfType("*context.valueCtx"), "entityID", "password", "").
+		Return(api.Entity{}, api.ErrLocked{Until: until})
 
 	ctx := context.WithValue(context.Background(), "crd", credentials.Credentials{
 		ID:       "entityID",
@@ -87,21 +88,23 @@ func (s *AuthenticateTestSuite) TestAPIGetEntityError() {
 	})
 
 	_, err := s.handler.Authenticate(ctx, connect.NewRequest(&v1.AuthenticateRequest{}))
-	s.Require().Equal(err, connect.NewError(connect.CodeUnauthenticated, nil))
+
+	var cErr *connect.Error
+	s.Require().True(errors.As(err, &cErr))
+	s.Assert().Equal(connect.CodeResourceExhausted, cErr.Code())
+	s.Assert().Equal("locked out", cErr.Message())
+	s.Assert().Equal("90", cErr.Meta().Get("Retry-After"))
 
 	l := s.logger.LastEntry()
 	s.Require().NotNil(l)
-	s.Assert().Equal(`{"level":"error","error":"getEntityError","entity_id":"entityID","message":"failed to get entity"}`, l.String())
+	l.ExpMsg("locked out")
+	l.ExpStr("entity_id", "entityID")
 }
 
-func (s *AuthenticateTestSuite) TestAPICheckSecretError() {
-	s.api.
-		On("GetEntity", mock.AnythingOfType("*context.valueCtx"), "entityID").
-		Return(api.Entity{}, nil)
-
+func (s *AuthenticateTestSuite) TestEntityDisabled() {
 	s.api.
-		On("CheckSecret", "entityID", "password").
-		Return(false, errors.New("theCheckSecretError"))
+		On("VerifyCredentials", mock.AnythingOfType("*context.valueCtx"), "entityID", "password", "").
+		Return(api.Entity{}, api.ErrEntityDisabled)
 
 	ctx := context.WithValue(context.Background(), "crd", credentials.Credentials{
 		ID:       "entityID",
@@ -109,21 +112,17 @@ func (s *AuthenticateTestSuite) TestAPICheckSecretError() {
 	})
 
 	_, err := s.handler.Authenticate(ctx, connect.NewRequest(&v1.AuthenticateRequest{}))
-	s.Require().Equal(err, connect.NewError(connect.CodeUnauthenticated, nil))
+	s.Require().Equal(err, connect.NewError(connect.CodePermissionDenied, api.ErrEntityDisabled))
 
 	l := s.logger.LastEntry()
 	s.Require().NotNil(l)
-	s.Assert().Equal(`{"level":"error","error":"theCheckSecretError","entity_id":"entityID","message":"check secret failed"}`, l.String())
+	s.Assert().Equal(`{"level":"warn","entity_id":"entityID","message":"entity disabled"}`, l.String())
 }
 
-func (s *AuthenticateTestSuite) TestInvalidSecret() {
-	s.api.
-		On("GetEntity", mock.AnythingOfType("*context.valueCtx"), "entityID").
-		Return(api.Entity{}, nil)
-
+func (s *AuthenticateTestSuite) TestAPIVerifyCredentialsError() {
 	s.api.
-		On("CheckSecret", "entityID", "password").
-		Return(false, nil)
+		On("VerifyCredentials", mock.AnythingOfType("*context.valueCtx"), "entityID", "password", "").
+		Return(api.Entity{}, errors.New("theVerifyCredentialsError"))
 
 	ctx := context.WithValue(context.Background(), "crd", credentials.Credentials{
 		ID:       "entityID",
@@ -131,19 +130,17 @@ func (s *AuthenticateTestSuite) TestInvalidSecret() {
 	})
 
 	_, err := s.handler.Authenticate(ctx, connect.NewRequest(&v1.AuthenticateRequest{}))
-	s.Require().Equal(err, connect.NewError(connect.CodeUnauthenticated, nil))
+	s.Require().Equal(err, connect.NewError(connect.CodeInternal, nil))
 
-	s.Require().Nil(s.logger.LastEntry())
+	l := s.logger.LastEntry()
+	s.Require().NotNil(l)
+	s.Assert().Equal(`{"level":"error","error":"theVerifyCredentialsError","entity_id":"entityID","message":"verify credentials failed"}`, l.String())
 }
 
 func (s *AuthenticateTestSuite) TestOutOfScope() {
 	s.api.
-		On("GetEntity", mock.AnythingOfType("*context.valueCtx"), "entityID").
-		Return(api.Entity{ID: "entityID"}, nil)
-
-	s.api.
-		On("CheckSecret", "entityID", "password").
-		Return(true, nil)
+		On("VerifyCredentials", mock.AnythingOfType("*context.valueCtx"), "entityID", "password", "").
+		Return(api.Entity{ID: "entityID", Secret: "theSecretHash"}, nil)
 
 	s.api.
 		On("CheckScope", api.Scope(nil), api.Scope{"scopeItem"}).
@@ -173,19 +170,15 @@ func (s *AuthenticateTestSuite) TestGetAccessTokenExpirationTimeError() {
 		Return(cl)
 
 	s.api.
-		On("GetEntity", mock.AnythingOfType("*context.valueCtx"), "entityID").
-		Return(api.Entity{ID: "entityID"}, nil)
-
-	s.api.
-		On("CheckSecret", "entityID", "password").
-		Return(true, nil)
+		On("VerifyCredentials", mock.AnythingOfType("*context.valueCtx"), "entityID", "password", "").
+		Return(api.Entity{ID: "entityID", Secret: "theSecretHash"}, nil)
 
 	s.api.
 		On("CheckScope", api.Scope(nil), api.Scope{"scopeItem"}).
 		Return(true)
 
 	s.api.
-		On("CreateToken", "entityID", []string(nil), time.Second*5).
+		On("CreateToken", api.TokenTypeAccess, "entityID", "", []string(nil), time.Second*5).
 		Return(tk)
 
 	ctx := context.WithValue(context.Background(), "crd", credentials.Credentials{
@@ -213,29 +206,21 @@ func (s *AuthenticateTestSuite) TestGetAccessTokenSignedStringError() {
 		On("Claims").
 		Return(cl)
 	tk.
-		On("SignedString", "secretKey").
+		On("SignedString").
 		Return("", errors.New("accessTokenSignedStringError"))
 
 	s.api.
-		On("GetEntity", mock.AnythingOfType("*context.valueCtx"), "entityID").
-		Return(api.Entity{ID: "entityID"}, nil)
-
-	s.api.
-		On("CheckSecret", "entityID", "password").
-		Return(true, nil)
+		On("VerifyCredentials", mock.AnythingOfType("*context.valueCtx"), "entityID", "password", "").
+		Return(api.Entity{ID: "entityID", Secret: "theSecretHash"}, nil)
 
 	s.api.
 		On("CheckScope", api.Scope(nil), api.Scope{"scopeItem"}).
 		Return(true)
 
 	s.api.
-		On("CreateToken", "entityID", []string(nil), time.Second*5).
+		On("CreateToken", api.TokenTypeAccess, "entityID", "", []string(nil), time.Second*5).
 		Return(tk)
 
-	s.api.
-		On("SecretKey").
-		Return("secretKey")
-
 	ctx := context.WithValue(context.Background(), "crd", credentials.Credentials{
 		ID:       "entityID",
 		Password: "password",
@@ -261,7 +246,7 @@ func (s *AuthenticateTestSuite) TestGetRefreshTokenExpirationTimeError() {
 		On("Claims").
 		Return(atCl)
 	at.
-		On("SignedString", "secretKey").
+		On("SignedString").
 		Return("accessTokenSignedString", nil)
 
 	rtCl := &api.ClaimsMock{}
@@ -274,27 +259,19 @@ func (s *AuthenticateTestSuite) TestGetRefreshTokenExpirationTimeError() {
 		Return(rtCl)
 
 	s.api.
-		On("GetEntity", mock.AnythingOfType("*context.valueCtx"), "entityID").
-		Return(api.Entity{ID: "entityID"}, nil)
-
-	s.api.
-		On("CheckSecret", "entityID", "password").
-		Return(true, nil)
+		On("VerifyCredentials", mock.AnythingOfType("*context.valueCtx"), "entityID", "password", "").
+		Return(api.Entity{ID: "entityID", Secret: "theSecretHash"}, nil)
 
 	s.api.
 		On("CheckScope", api.Scope(nil), api.Scope{"scopeItem"}).
 		Return(true)
 
 	s.api.
-		On("SecretKey").
-		Return("secretKey")
-
-	s.api.
-		On("CreateToken", "entityID", []string(nil), time.Second*5).
+		On("CreateToken", api.TokenTypeAccess, "entityID", "", []string(nil), time.Second*5).
 		Return(at)
 
 	s.api.
-		On("CreateToken", "entityID_refresh", []string(nil), time.Second*10).
+		On("CreateToken", api.TokenTypeRefresh, "entityID", "", []string(nil), time.Second*10).
 		Return(rt)
 
 	ctx := context.WithValue(context.Background(), "crd", credentials.Credentials{
@@ -322,7 +299,7 @@ func (s *AuthenticateTestSuite) TestGetRefreshTokenSignedStringError() {
 		On("Claims").
 		Return(atCl)
 	at.
-		On("SignedString", "secretKey").
+		On("SignedString").
 		Return("accessTokenSignedString", nil)
 
 	rtCl := &api.ClaimsMock{}
@@ -334,31 +311,23 @@ func (s *AuthenticateTestSuite) TestGetRefreshTokenSignedStringError() {
 		On("Claims").
 		Return(rtCl)
 	rt.
-		On("SignedString", "secretKey").
+		On("SignedString").
 		Return("", errors.New("refreshTokenSignedStringError"))
 
 	s.api.
-		On("GetEntity", mock.AnythingOfType("*context.valueCtx"), "entityID").
-		Return(api.Entity{ID: "entityID"}, nil)
-
-	s.api.
-		On("CheckSecret", "entityID", "password").
-		Return(true, nil)
+		On("VerifyCredentials", mock.AnythingOfType("*context.valueCtx"), "entityID", "password", "").
+		Return(api.Entity{ID: "entityID", Secret: "theSecretHash"}, nil)
 
 	s.api.
 		On("CheckScope", api.Scope(nil), api.Scope{"scopeItem"}).
 		Return(true)
 
 	s.api.
-		On("SecretKey").
-		Return("secretKey")
-
-	s.api.
-		On("CreateToken", "entityID", []string(nil), time.Second*5).
+		On("CreateToken", api.TokenTypeAccess, "entityID", "", []string(nil), time.Second*5).
 		Return(at)
 
 	s.api.
-		On("CreateToken", "entityID_refresh", []string(nil), time.Second*10).
+		On("CreateToken", api.TokenTypeRefresh, "entityID", "", []string(nil), time.Second*10).
 		Return(rt)
 
 	ctx := context.WithValue(context.Background(), "crd", credentials.Credentials{
@@ -386,7 +355,7 @@ func (s *AuthenticateTestSuite) TestOK() {
 		On("Claims").
 		Return(atCl)
 	at.
-		On("SignedString", "secretKey").
+		On("SignedString").
 		Return("accessTokenSignedString", nil)
 
 	rtCl := &api.ClaimsMock{}
@@ -398,33 +367,30 @@ func (s *AuthenticateTestSuite) TestOK() {
 		On("Claims").
 		Return(rtCl)
 	rt.
-		On("SignedString", "secretKey").
+		On("SignedString").
 		Return("refreshTokenSignedString", nil)
 
 	s.api.
-		On("GetEntity", mock.AnythingOfType("*context.valueCtx"), "entityID").
-		Return(api.Entity{ID: "entityID"}, nil)
-
-	s.api.
-		On("CheckSecret", "entityID", "password").
-		Return(true, nil)
+		On("VerifyCredentials", mock.AnythingOfType("*context.valueCtx"), "entityID", "password", "").
+		Return(api.Entity{ID: "entityID", Secret: "theSecretHash"}, nil)
 
 	s.api.
 		On("CheckScope", api.Scope(nil), api.Scope{"scopeItem"}).
 		Return(true)
 
 	s.api.
-		On("SecretKey").
-		Return("secretKey")
-
-	s.api.
-		On("CreateToken", "entityID", []string(nil), time.Second*5).
+		On("CreateToken", api.TokenTypeAccess, "entityID", "", []string(nil), time.Second*5).
 		Return(at)
 
 	s.api.
-		On("CreateToken", "entityID_refresh", []string(nil), time.Second*10).
+		On("CreateToken", api.TokenTypeRefresh, "entityID", "", []string(nil), time.Second*10).
 		Return(rt)
 
+	s.api.
+		On("SaveRefreshToken", mock.AnythingOfType("*context.valueCtx"), "refreshTokenSignedString", "entityID",
+			mock.AnythingOfType("string"), time.Unix(234567890, 0)).
+		Return(nil)
+
 	ctx := context.WithValue(context.Background(), "crd", credentials.Credentials{
 		ID:       "entityID",
 		Password: "password",
@@ -445,6 +411,96 @@ func (s *AuthenticateTestSuite) TestOK() {
 	s.Assert().Equal(`{"level":"info","entity_id":"entityID","access_token_expires":123456789,"refresh_token_expires":234567890,"message":"authenticated by password"}`, l.String())
 }
 
+func (s *AuthenticateTestSuite) TestMFARequired() {
+	cl := &api.ClaimsMock{}
+	cl.On("GetExpirationTime").
+		Return(&jwt.NumericDate{Time: time.Unix(123456789, 0)}, nil)
+
+	tk := &api.TokenMock{}
+	tk.
+		On("Claims").
+		Return(cl)
+	tk.
+		On("SignedString").
+		Return("mfaTokenSignedString", nil)
+
+	s.api.
+		On("VerifyCredentials", mock.AnythingOfType("*context.valueCtx"), "entityID", "password", "").
+		Return(api.Entity{ID: "entityID", Secret: "theSecretHash", TOTPEnabled: true}, nil)
+
+	s.api.
+		On("CheckScope", api.Scope(nil), api.Scope(nil)).
+		Return(true)
+
+	s.api.
+		On("CreateToken", api.TokenTypeMFA, "entityID", "", []string(nil), time.Minute*5).
+		Return(tk)
+
+	ctx := context.WithValue(context.Background(), "crd", credentials.Credentials{
+		ID:       "entityID",
+		Password: "password",
+	})
+
+	r, err := s.handler.Authenticate(ctx, connect.NewRequest(&v1.AuthenticateRequest{}))
+	s.Require().NoError(err)
+
+	s.Assert().True(r.Msg.MfaRequired)
+	s.Assert().Equal("mfaTokenSignedString", r.Msg.MfaToken)
+	s.Assert().Equal(int64(123456789), r.Msg.MfaTokenExpires)
+	s.Assert().Empty(r.Msg.AccessToken)
+	s.Assert().Empty(r.Msg.RefreshToken)
+
+	l := s.logger.LastEntry()
+	s.Require().NotNil(l)
+	s.Assert().Equal(`{"level":"info","entity_id":"entityID","message":"mfa required"}`, l.String())
+}
+
+func (s *AuthenticateTestSuite) TestInvalidTOTPCode() {
+	s.api.
+		On("VerifyCredentials", mock.AnythingOfType("*context.valueCtx"), "entityID", "password", "").
+		Return(api.Entity{ID: "entityID", Secret: "theSecretHash", TOTPEnabled: true}, nil)
+
+	s.api.
+		On("CheckScope", api.Scope(nil), api.Scope(nil)).
+		Return(true)
+
+	s.api.
+		On("VerifyTOTP", mock.AnythingOfType("*context.valueCtx"), "entityID", "123456", "").
+		Return(api.ErrInvalidCredentials)
+
+	ctx := context.WithValue(context.Background(), "crd", credentials.Credentials{
+		ID:       "entityID",
+		Password: "password",
+	})
+
+	_, err := s.handler.Authenticate(ctx, connect.NewRequest(&v1.AuthenticateRequest{TotpCode: "123456"}))
+	s.Require().Equal(err, connect.NewError(connect.CodeUnauthenticated, nil))
+
+	l := s.logger.LastEntry()
+	s.Require().NotNil(l)
+	s.Assert().Equal(`{"level":"warn","entity_id":"entityID","source":"","message":"invalid totp code"}`, l.String())
+}
+
+func (s *AuthenticateTestSuite) TestMFAScopeNotGranted() {
+	s.handler = handler.New(s.api, time.Second*5, time.Second*10, api.Scope{"admin"}, nil, zerolog.Nop())
+
+	s.api.
+		On("VerifyCredentials", mock.AnythingOfType("*context.valueCtx"), "entityID", "password", "").
+		Return(api.Entity{ID: "entityID", Secret: "theSecretHash", Scope: api.Scope{"admin", "user"}}, nil)
+
+	s.api.
+		On("CheckScope", api.Scope{"user"}, api.Scope{"admin"}).
+		Return(false)
+
+	ctx := context.WithValue(context.Background(), "crd", credentials.Credentials{
+		ID:       "entityID",
+		Password: "password",
+	})
+
+	_, err := s.handler.Authenticate(ctx, connect.NewRequest(&v1.AuthenticateRequest{Scope: []string{"admin"}}))
+	s.Require().Equal(err, connect.NewError(connect.CodePermissionDenied, nil))
+}
+
 func TestHandler_Authenticate(t *testing.T) {
 	suite.Run(t, new(AuthenticateTestSuite))
 }
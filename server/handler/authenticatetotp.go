@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/google/uuid"
+
+	"github.com/ashep/a23n/api"
+	v1 "github.com/ashep/a23n/sdk/proto/a23n/v1"
+)
+
+// AuthenticateTOTP completes the authentication started by Authenticate. The MFA token it has returned is taken from
+// the authorization header and is revoked once a correct code is passed, so it cannot be used again.
+func (h *Handler) AuthenticateTOTP(
+	ctx context.Context,
+	req *connect.Request[v1.AuthenticateTOTPRequest],
+) (*connect.Response[v1.AuthenticateTOTPResponse], error) {
+	crd, ok := h.credentialsFromCtx(ctx)
+	if !ok || crd.Token == "" {
+		return nil, connect.NewError(connect.CodeUnauthenticated, nil)
+	}
+
+	if req.Msg.TotpCode == "" {
+		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("empty totp code"))
+	}
+
+	clm, err := h.api.ParseToken(ctx, crd.Token, api.TokenTypeMFA)
+	if err != nil {
+		h.l.Warn().Err(err).Msg("parse mfa token failed")
+		return nil, connect.NewError(connect.CodeUnauthenticated, nil)
+	}
+	entityID := clm.Subject
+
+	if err = h.verifyTOTP(ctx, entityID, req.Msg.TotpCode, peerHost(req.Peer())); err != nil {
+		return nil, err
+	}
+
+	if err = h.api.RevokeToken(ctx, crd.Token, clm); err != nil {
+		h.l.Error().Err(err).Str("entity_id", entityID).Msg("revoke mfa token failed")
+		return nil, connect.NewError(connect.CodeInternal, nil)
+	}
+
+	e, err := h.api.GetEntity(ctx, entityID)
+	if errors.Is(err, api.ErrNotFound) {
+		h.l.Warn().Str("entity_id", entityID).Msg("entity not found")
+		return nil, connect.NewError(connect.CodeUnauthenticated, nil)
+	} else if err != nil {
+		h.l.Error().Err(err).Str("entity_id", entityID).Msg("failed to get entity")
+		return nil, connect.NewError(connect.CodeInternal, nil)
+	}
+
+	if e.Disabled {
+		h.l.Warn().Str("entity_id", entityID).Msg("entity disabled")
+		return nil, connect.NewError(connect.CodePermissionDenied, api.ErrEntityDisabled)
+	}
+
+	e.Scope = h.grantedScope(e, true)
+	if !h.api.CheckScope(e.Scope, req.Msg.Scope) {
+		return nil, connect.NewError(connect.CodePermissionDenied, nil)
+	}
+
+	tp, err := h.issueTokens(ctx, e, uuid.NewString())
+	if err != nil {
+		return nil, err
+	}
+
+	h.l.Info().
+		Str("entity_id", e.ID).
+		Int64("access_token_expires", tp.accessExpires).
+		Int64("refresh_token_expires", tp.refreshExpires).
+		Msg("authenticated by totp")
+
+	return connect.NewResponse(&v1.AuthenticateTOTPResponse{
+		AccessToken:         tp.access,
+		AccessTokenExpires:  tp.accessExpires,
+		RefreshToken:        tp.refresh,
+		RefreshTokenExpires: tp.refreshExpires,
+	}), nil
+}
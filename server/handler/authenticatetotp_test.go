@@ -0,0 +1,183 @@
+package handler_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/rs/zerolog"
+	"github.com/rzajac/zltest"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/ashep/a23n/api"
+	"github.com/ashep/a23n/sdk/proto/a23n/v1"
+	"github.com/ashep/a23n/server/credentials"
+	"github.com/ashep/a23n/server/handler"
+)
+
+type AuthenticateTOTPTestSuite struct {
+	suite.Suite
+
+	api     *api.APIMock
+	handler *handler.Handler
+	logger  *zltest.Tester
+}
+
+func (s *AuthenticateTOTPTestSuite) SetupTest() {
+	lt := zltest.New(s.T())
+	l := lt.Logger().Level(zerolog.DebugLevel)
+
+	s.api = &api.APIMock{}
+	s.handler = handler.New(s.api, time.Second*5, time.Second*10, api.Scope{"admin"}, nil, l)
+	s.logger = lt
+}
+
+func (s *AuthenticateTOTPTestSuite) TearDownTest() {
+	s.api.AssertExpectations(s.T())
+}
+
+func (s *AuthenticateTOTPTestSuite) ctx() context.Context {
+	return context.WithValue(context.Background(), "crd", credentials.Credentials{Token: "theMFAToken"})
+}
+
+func (s *AuthenticateTOTPTestSuite) TestNoToken() {
+	ctx := context.WithValue(context.Background(), "crd", credentials.Credentials{})
+
+	_, err := s.handler.AuthenticateTOTP(ctx, connect.NewRequest(&v1.AuthenticateTOTPRequest{TotpCode: "123456"}))
+	s.Require().Equal(err, connect.NewError(connect.CodeUnauthenticated, nil))
+	s.Require().Nil(s.logger.LastEntry())
+}
+
+func (s *AuthenticateTOTPTestSuite) TestEmptyCode() {
+	_, err := s.handler.AuthenticateTOTP(s.ctx(), connect.NewRequest(&v1.AuthenticateTOTPRequest{}))
+	s.Require().Equal(err, connect.NewError(connect.CodeInvalidArgument, errors.New("empty totp code")))
+}
+
+func (s *AuthenticateTOTPTestSuite) TestParseTokenError() {
+	s.api.
+		On("ParseToken", mock.Anything, "theMFAToken", api.TokenTypeMFA).
+		Return(api.TokenClaims{}, api.ErrInvalidTokenType)
+
+	_, err := s.handler.AuthenticateTOTP(s.ctx(), connect.NewRequest(&v1.AuthenticateTOTPRequest{TotpCode: "123456"}))
+	s.Require().Equal(err, connect.NewError(connect.CodeUnauthenticated, nil))
+
+	l := s.logger.LastEntry()
+	s.Require().NotNil(l)
+	s.Assert().Equal(`{"level":"warn","error":"invalid token type","message":"parse mfa token failed"}`, l.String())
+}
+
+func (s *AuthenticateTOTPTestSuite) TestLocked() {
+	until := time.Now().Add(90 * time.Second)
+
+	s.api.
+		On("ParseToken", mock.Anything, "theMFAToken", api.TokenTypeMFA).
+		Return(api.TokenClaims{RegisteredClaims: jwt.RegisteredClaims{Subject: "entityID"}}, nil)
+	s.api.
+		On("VerifyTOTP", mock.Anything, "entityID", "123456", "").
+		Return(api.ErrLocked{Until: until})
+
+	_, err := s.handler.AuthenticateTOTP(s.ctx(), connect.NewRequest(&v1.AuthenticateTOTPRequest{TotpCode: "123456"}))
+
+	var cErr *connect.Error
+	s.Require().True(errors.As(err, &cErr))
+	s.Assert().Equal(connect.CodeResourceExhausted, cErr.Code())
+	s.Assert().Equal("90", cErr.Meta().Get("Retry-After"))
+
+	l := s.logger.LastEntry()
+	s.Require().NotNil(l)
+	l.ExpMsg("locked out")
+	l.ExpStr("entity_id", "entityID")
+}
+
+func (s *AuthenticateTOTPTestSuite) TestRevokeTokenError() {
+	mfaClm := api.TokenClaims{RegisteredClaims: jwt.RegisteredClaims{ID: "mfaTokenID", Subject: "entityID"}}
+	s.api.
+		On("ParseToken", mock.Anything, "theMFAToken", api.TokenTypeMFA).
+		Return(mfaClm, nil)
+	s.api.
+		On("VerifyTOTP", mock.Anything, "entityID", "123456", "").
+		Return(nil)
+	s.api.
+		On("RevokeToken", mock.Anything, "theMFAToken", mfaClm).
+		Return(errors.New("theError"))
+
+	_, err := s.handler.AuthenticateTOTP(s.ctx(), connect.NewRequest(&v1.AuthenticateTOTPRequest{TotpCode: "123456"}))
+	s.Require().Equal(err, connect.NewError(connect.CodeInternal, nil))
+
+	l := s.logger.LastEntry()
+	s.Require().NotNil(l)
+	s.Assert().Equal(`{"level":"error","error":"theError","entity_id":"entityID","message":"revoke mfa token failed"}`, l.String())
+}
+
+func (s *AuthenticateTOTPTestSuite) TestOK() {
+	atCl := &api.ClaimsMock{}
+	atCl.On("GetExpirationTime").
+		Return(&jwt.NumericDate{Time: time.Unix(123456789, 0)}, nil)
+
+	at := &api.TokenMock{}
+	at.On("Claims").Return(atCl)
+	at.On("SignedString").Return("accessTokenSignedString", nil)
+
+	rtCl := &api.ClaimsMock{}
+	rtCl.On("GetExpirationTime").
+		Return(&jwt.NumericDate{Time: time.Unix(234567890, 0)}, nil)
+
+	rt := &api.TokenMock{}
+	rt.On("Claims").Return(rtCl)
+	rt.On("SignedString").Return("refreshTokenSignedString", nil)
+
+	mfaClm := api.TokenClaims{RegisteredClaims: jwt.RegisteredClaims{ID: "mfaTokenID", Subject: "entityID"}}
+	s.api.
+		On("ParseToken", mock.Anything, "theMFAToken", api.TokenTypeMFA).
+		Return(mfaClm, nil)
+	s.api.
+		On("VerifyTOTP", mock.Anything, "entityID", "123456", "").
+		Return(nil)
+
+	// The MFA token cannot be used again
+	s.api.
+		On("RevokeToken", mock.Anything, "theMFAToken", mfaClm).
+		Return(nil)
+
+	s.api.
+		On("GetEntity", mock.Anything, "entityID").
+		Return(api.Entity{ID: "entityID", Scope: api.Scope{"admin"}, TOTPEnabled: true}, nil)
+
+	// The scope requiring MFA is granted
+	s.api.
+		On("CheckScope", api.Scope{"admin"}, api.Scope{"admin"}).
+		Return(true)
+	s.api.
+		On("CreateToken", api.TokenTypeAccess, "entityID", "", []string{"admin"}, time.Second*5).
+		Return(at)
+	s.api.
+		On("CreateToken", api.TokenTypeRefresh, "entityID", "", []string{"admin"}, time.Second*10).
+		Return(rt)
+	s.api.
+		On("SaveRefreshToken", mock.Anything, "refreshTokenSignedString", "entityID",
+			mock.AnythingOfType("string"), time.Unix(234567890, 0)).
+		Return(nil)
+
+	r, err := s.handler.AuthenticateTOTP(s.ctx(), connect.NewRequest(&v1.AuthenticateTOTPRequest{
+		TotpCode: "123456",
+		Scope:    []string{"admin"},
+	}))
+	s.Require().NoError(err)
+
+	s.Assert().Equal("accessTokenSignedString", r.Msg.AccessToken)
+	s.Assert().Equal(int64(123456789), r.Msg.AccessTokenExpires)
+	s.Assert().Equal("refreshTokenSignedString", r.Msg.RefreshToken)
+	s.Assert().Equal(int64(234567890), r.Msg.RefreshTokenExpires)
+
+	l := s.logger.LastEntry()
+	s.Require().NotNil(l)
+	s.Assert().Equal(`{"level":"info","entity_id":"entityID","access_token_expires":123456789,"refresh_token_expires":234567890,"message":"authenticated by totp"}`, l.String())
+}
+
+func TestHandler_AuthenticateTOTP(t *testing.T) {
+	suite.Run(t, new(AuthenticateTOTPTestSuite))
+}
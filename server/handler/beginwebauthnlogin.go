@@ -0,0 +1,30 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	"github.com/bufbuild/connect-go"
+
+	"github.com/ashep/a23n/api"
+	v1 "github.com/ashep/a23n/sdk/proto/a23n/v1"
+)
+
+// BeginWebAuthnLogin starts authenticating an entity by a passkey. No credentials are required.
+func (h *Handler) BeginWebAuthnLogin(
+	ctx context.Context,
+	_ *connect.Request[v1.BeginWebAuthnLoginRequest],
+) (*connect.Response[v1.BeginWebAuthnLoginResponse], error) {
+	ch, err := h.api.BeginWebAuthnLogin(ctx)
+	if errors.Is(err, api.ErrWebAuthnDisabled) {
+		return nil, connect.NewError(connect.CodeUnimplemented, err)
+	} else if err != nil {
+		h.l.Error().Err(err).Msg("begin webauthn login failed")
+		return nil, connect.NewError(connect.CodeInternal, nil)
+	}
+
+	return connect.NewResponse(&v1.BeginWebAuthnLoginResponse{
+		ChallengeId: ch.ID,
+		Options:     string(ch.Options),
+	}), nil
+}
@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	"github.com/bufbuild/connect-go"
+
+	"github.com/ashep/a23n/api"
+	v1 "github.com/ashep/a23n/sdk/proto/a23n/v1"
+)
+
+// BeginWebAuthnRegistration starts registering a passkey for the entity the access token has been issued for.
+func (h *Handler) BeginWebAuthnRegistration(
+	ctx context.Context,
+	_ *connect.Request[v1.BeginWebAuthnRegistrationRequest],
+) (*connect.Response[v1.BeginWebAuthnRegistrationResponse], error) {
+	crd, ok := h.credentialsFromCtx(ctx)
+	if !ok || crd.Token == "" {
+		return nil, connect.NewError(connect.CodeUnauthenticated, nil)
+	}
+
+	clm, err := h.api.ParseToken(ctx, crd.Token, api.TokenTypeAccess)
+	if err != nil {
+		h.l.Warn().Err(err).Msg("parse access token failed")
+		return nil, connect.NewError(connect.CodeUnauthenticated, nil)
+	}
+
+	ch, err := h.api.BeginWebAuthnRegistration(ctx, clm.Subject)
+	if errors.Is(err, api.ErrWebAuthnDisabled) {
+		return nil, connect.NewError(connect.CodeUnimplemented, err)
+	} else if errors.Is(err, api.ErrNotFound) {
+		return nil, connect.NewError(connect.CodeNotFound, err)
+	} else if err != nil {
+		h.l.Error().Err(err).Str("entity_id", clm.Subject).Msg("begin webauthn registration failed")
+		return nil, connect.NewError(connect.CodeInternal, nil)
+	}
+
+	return connect.NewResponse(&v1.BeginWebAuthnRegistrationResponse{
+		ChallengeId: ch.ID,
+		Options:     string(ch.Options),
+	}), nil
+}
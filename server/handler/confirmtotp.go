@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	"github.com/bufbuild/connect-go"
+
+	"github.com/ashep/a23n/api"
+	v1 "github.com/ashep/a23n/sdk/proto/a23n/v1"
+)
+
+// ConfirmTOTP enables the TOTP secret enrolled by the entity the access token has been issued for. From now on the
+// entity must pass a TOTP code to authenticate.
+func (h *Handler) ConfirmTOTP(
+	ctx context.Context,
+	req *connect.Request[v1.ConfirmTOTPRequest],
+) (*connect.Response[v1.ConfirmTOTPResponse], error) {
+	crd, ok := h.credentialsFromCtx(ctx)
+	if !ok || crd.Token == "" {
+		return nil, connect.NewError(connect.CodeUnauthenticated, nil)
+	}
+
+	if req.Msg.TotpCode == "" {
+		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("empty totp code"))
+	}
+
+	clm, err := h.api.ParseToken(ctx, crd.Token, api.TokenTypeAccess)
+	if err != nil {
+		h.l.Warn().Err(err).Msg("parse access token failed")
+		return nil, connect.NewError(connect.CodeUnauthenticated, nil)
+	}
+
+	err = h.api.ConfirmTOTP(ctx, clm.Subject, req.Msg.TotpCode)
+	if errors.Is(err, api.ErrInvalidCredentials) {
+		h.l.Warn().Str("entity_id", clm.Subject).Msg("invalid totp code")
+		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("invalid totp code"))
+	} else if errors.Is(err, api.ErrInvalidArg{}) {
+		return nil, connect.NewError(connect.CodeFailedPrecondition, err)
+	} else if errors.Is(err, api.ErrNotFound) {
+		return nil, connect.NewError(connect.CodeNotFound, err)
+	} else if err != nil {
+		h.l.Error().Err(err).Str("entity_id", clm.Subject).Msg("confirm totp failed")
+		return nil, connect.NewError(connect.CodeInternal, nil)
+	}
+
+	h.l.Info().Str("entity_id", clm.Subject).Msg("totp enabled")
+
+	return connect.NewResponse(&v1.ConfirmTOTPResponse{}), nil
+}
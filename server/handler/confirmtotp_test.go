@@ -0,0 +1,124 @@
+package handler_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/rs/zerolog"
+	"github.com/rzajac/zltest"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/ashep/a23n/api"
+	"github.com/ashep/a23n/sdk/proto/a23n/v1"
+	"github.com/ashep/a23n/server/credentials"
+	"github.com/ashep/a23n/server/handler"
+)
+
+type ConfirmTOTPTestSuite struct {
+	suite.Suite
+
+	api     *api.APIMock
+	handler *handler.Handler
+	logger  *zltest.Tester
+}
+
+func (s *ConfirmTOTPTestSuite) SetupTest() {
+	lt := zltest.New(s.T())
+	l := lt.Logger().Level(zerolog.DebugLevel)
+
+	s.api = &api.APIMock{}
+	s.handler = handler.New(s.api, time.Second*5, time.Second*10, api.Scope{"admin"}, nil, l)
+	s.logger = lt
+}
+
+func (s *ConfirmTOTPTestSuite) TearDownTest() {
+	s.api.AssertExpectations(s.T())
+}
+
+func (s *ConfirmTOTPTestSuite) confirm(code string) error {
+	ctx := context.WithValue(context.Background(), "crd", credentials.Credentials{Token: "theAccessToken"})
+	_, err := s.handler.ConfirmTOTP(ctx, connect.NewRequest(&v1.ConfirmTOTPRequest{TotpCode: code}))
+
+	return err
+}
+
+func (s *ConfirmTOTPTestSuite) expectConfirmTOTP(err error) {
+	s.api.
+		On("ParseToken", mock.Anything, "theAccessToken", api.TokenTypeAccess).
+		Return(api.TokenClaims{RegisteredClaims: jwt.RegisteredClaims{Subject: "entityID"}}, nil)
+	s.api.
+		On("ConfirmTOTP", mock.Anything, "entityID", "123456").
+		Return(err)
+}
+
+func (s *ConfirmTOTPTestSuite) TestNoToken() {
+	req := connect.NewRequest(&v1.ConfirmTOTPRequest{TotpCode: "123456"})
+
+	_, err := s.handler.ConfirmTOTP(context.Background(), req)
+	s.Require().Equal(err, connect.NewError(connect.CodeUnauthenticated, nil))
+}
+
+func (s *ConfirmTOTPTestSuite) TestEmptyCode() {
+	s.Require().Equal(s.confirm(""), connect.NewError(connect.CodeInvalidArgument, errors.New("empty totp code")))
+}
+
+func (s *ConfirmTOTPTestSuite) TestParseTokenError() {
+	s.api.
+		On("ParseToken", mock.Anything, "theAccessToken", api.TokenTypeAccess).
+		Return(api.TokenClaims{}, api.ErrInvalidTokenType)
+
+	s.Require().Equal(s.confirm("123456"), connect.NewError(connect.CodeUnauthenticated, nil))
+}
+
+func (s *ConfirmTOTPTestSuite) TestInvalidCode() {
+	s.expectConfirmTOTP(api.ErrInvalidCredentials)
+
+	expErr := connect.NewError(connect.CodeInvalidArgument, errors.New("invalid totp code"))
+	s.Require().Equal(s.confirm("123456"), expErr)
+
+	l := s.logger.LastEntry()
+	s.Require().NotNil(l)
+	s.Assert().Equal(`{"level":"warn","entity_id":"entityID","message":"invalid totp code"}`, l.String())
+}
+
+func (s *ConfirmTOTPTestSuite) TestNotStarted() {
+	apiErr := api.ErrInvalidArg{Msg: "totp enrollment is not started"}
+	s.expectConfirmTOTP(apiErr)
+
+	s.Require().Equal(s.confirm("123456"), connect.NewError(connect.CodeFailedPrecondition, apiErr))
+}
+
+func (s *ConfirmTOTPTestSuite) TestNotFound() {
+	s.expectConfirmTOTP(api.ErrNotFound)
+
+	s.Require().Equal(s.confirm("123456"), connect.NewError(connect.CodeNotFound, api.ErrNotFound))
+}
+
+func (s *ConfirmTOTPTestSuite) TestAPIError() {
+	s.expectConfirmTOTP(errors.New("theError"))
+
+	s.Require().Equal(s.confirm("123456"), connect.NewError(connect.CodeInternal, nil))
+
+	l := s.logger.LastEntry()
+	s.Require().NotNil(l)
+	s.Assert().Equal(`{"level":"error","error":"theError","entity_id":"entityID","message":"confirm totp failed"}`, l.String())
+}
+
+func (s *ConfirmTOTPTestSuite) TestOK() {
+	s.expectConfirmTOTP(nil)
+
+	s.Require().NoError(s.confirm("123456"))
+
+	l := s.logger.LastEntry()
+	s.Require().NotNil(l)
+	s.Assert().Equal(`{"level":"info","entity_id":"entityID","message":"totp enabled"}`, l.String())
+}
+
+func TestHandler_ConfirmTOTP(t *testing.T) {
+	suite.Run(t, new(ConfirmTOTPTestSuite))
+}
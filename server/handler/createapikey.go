@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+
+	"github.com/ashep/a23n/api"
+	v1 "github.com/ashep/a23n/sdk/proto/a23n/v1"
+)
+
+// CreateAPIKey creates an API key for the entity the access token has been issued for. The key cannot grant scopes
+// the token does not have, so scopes requiring MFA can be granted only by the entities which have passed it. Tokens
+// issued to OAuth 2.0 clients and exchanged ones are refused, so delegated access cannot outlive them.
+func (h *Handler) CreateAPIKey(
+	ctx context.Context,
+	req *connect.Request[v1.CreateAPIKeyRequest],
+) (*connect.Response[v1.CreateAPIKeyResponse], error) {
+	crd, ok := h.credentialsFromCtx(ctx)
+	if !ok || crd.Token == "" {
+		return nil, connect.NewError(connect.CodeUnauthenticated, nil)
+	}
+
+	clm, err := h.api.ParseToken(ctx, crd.Token, api.TokenTypeAccess)
+	if err != nil {
+		h.l.Warn().Err(err).Msg("parse access token failed")
+		return nil, connect.NewError(connect.CodeUnauthenticated, nil)
+	}
+
+	if clm.ClientID != "" || clm.Actor != nil {
+		h.l.Warn().Str("entity_id", clm.Subject).Str("client_id", clm.ClientID).Msg("delegated access token refused")
+		return nil, connect.NewError(connect.CodePermissionDenied, nil)
+	}
+
+	if !h.api.CheckScope(clm.Scope, req.Msg.Scope) {
+		return nil, connect.NewError(connect.CodePermissionDenied, nil)
+	}
+
+	var expires time.Time
+	if req.Msg.ExpiresAt != 0 {
+		expires = time.Unix(req.Msg.ExpiresAt, 0)
+	}
+
+	k, key, err := h.api.CreateAPIKey(ctx, clm.Subject, req.Msg.Name, req.Msg.Scope, expires)
+	if errors.Is(err, api.ErrInvalidArg{}) {
+		return nil, invalidArgError(err)
+	} else if errors.Is(err, api.ErrNotFound) {
+		return nil, connect.NewError(connect.CodeNotFound, err)
+	} else if err != nil {
+		h.l.Error().Err(err).Str("entity_id", clm.Subject).Msg("create api key failed")
+		return nil, connect.NewError(connect.CodeInternal, nil)
+	}
+
+	h.l.Info().Str("entity_id", clm.Subject).Str("api_key_id", k.ID).Msg("api key created")
+
+	return connect.NewResponse(&v1.CreateAPIKeyResponse{Id: k.ID, Key: key}), nil
+}
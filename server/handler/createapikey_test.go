@@ -0,0 +1,138 @@
+package handler_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/rs/zerolog"
+	"github.com/rzajac/zltest"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/ashep/a23n/api"
+	"github.com/ashep/a23n/sdk/proto/a23n/v1"
+	"github.com/ashep/a23n/server/credentials"
+	"github.com/ashep/a23n/server/handler"
+)
+
+type CreateAPIKeyTestSuite struct {
+	suite.Suite
+
+	api     *api.APIMock
+	handler *handler.Handler
+	logger  *zltest.Tester
+}
+
+func (s *CreateAPIKeyTestSuite) SetupTest() {
+	lt := zltest.New(s.T())
+	l := lt.Logger().Level(zerolog.DebugLevel)
+
+	s.api = &api.APIMock{}
+	s.handler = handler.New(s.api, time.Second*5, time.Second*10, api.Scope{"admin"}, nil, l)
+	s.logger = lt
+}
+
+func (s *CreateAPIKeyTestSuite) TearDownTest() {
+	s.api.AssertExpectations(s.T())
+}
+
+func (s *CreateAPIKeyTestSuite) ctx() context.Context {
+	return context.WithValue(context.Background(), "crd", credentials.Credentials{Token: "theAccessToken"})
+}
+
+func (s *CreateAPIKeyTestSuite) TestNoToken() {
+	// Keys cannot be used to create other keys
+	ctx := context.WithValue(context.Background(), "crd", credentials.Credentials{APIKey: "a23n_theKey"})
+
+	_, err := s.handler.CreateAPIKey(ctx, connect.NewRequest(&v1.CreateAPIKeyRequest{Name: "theName"}))
+	s.Require().Equal(err, connect.NewError(connect.CodeUnauthenticated, nil))
+	s.Require().Nil(s.logger.LastEntry())
+}
+
+func (s *CreateAPIKeyTestSuite) TestScopeExceedsToken() {
+	s.api.
+		On("ParseToken", mock.Anything, "theAccessToken", api.TokenTypeAccess).
+		Return(api.TokenClaims{RegisteredClaims: jwt.RegisteredClaims{Subject: "entityID"}, Scope: []string{"foo"}}, nil)
+	s.api.
+		On("CheckScope", api.Scope{"foo"}, api.Scope{"admin"}).
+		Return(false)
+
+	_, err := s.handler.CreateAPIKey(s.ctx(), connect.NewRequest(&v1.CreateAPIKeyRequest{
+		Name:  "theName",
+		Scope: []string{"admin"},
+	}))
+	s.Require().Equal(err, connect.NewError(connect.CodePermissionDenied, nil))
+}
+
+func (s *CreateAPIKeyTestSuite) TestClientToken() {
+	s.api.
+		On("ParseToken", mock.Anything, "theAccessToken", api.TokenTypeAccess).
+		Return(api.TokenClaims{
+			RegisteredClaims: jwt.RegisteredClaims{Subject: "entityID"},
+			Scope:            []string{"foo"},
+			ClientID:         "theClient",
+		}, nil)
+
+	_, err := s.handler.CreateAPIKey(s.ctx(), connect.NewRequest(&v1.CreateAPIKeyRequest{
+		Name:  "theName",
+		Scope: []string{"foo"},
+	}))
+	s.Require().Equal(err, connect.NewError(connect.CodePermissionDenied, nil))
+
+	l := s.logger.LastEntry()
+	s.Require().NotNil(l)
+	s.Assert().Equal(`{"level":"warn","entity_id":"entityID","client_id":"theClient","message":"delegated access token refused"}`, l.String())
+}
+
+func (s *CreateAPIKeyTestSuite) TestExchangedToken() {
+	s.api.
+		On("ParseToken", mock.Anything, "theAccessToken", api.TokenTypeAccess).
+		Return(api.TokenClaims{
+			RegisteredClaims: jwt.RegisteredClaims{Subject: "entityID"},
+			Scope:            []string{"foo"},
+			Actor:            &api.Actor{Subject: "actorID"},
+		}, nil)
+
+	_, err := s.handler.CreateAPIKey(s.ctx(), connect.NewRequest(&v1.CreateAPIKeyRequest{
+		Name:  "theName",
+		Scope: []string{"foo"},
+	}))
+	s.Require().Equal(err, connect.NewError(connect.CodePermissionDenied, nil))
+
+	l := s.logger.LastEntry()
+	s.Require().NotNil(l)
+	s.Assert().Equal(`{"level":"warn","entity_id":"entityID","client_id":"","message":"delegated access token refused"}`, l.String())
+}
+
+func (s *CreateAPIKeyTestSuite) TestOK() {
+	s.api.
+		On("ParseToken", mock.Anything, "theAccessToken", api.TokenTypeAccess).
+		Return(api.TokenClaims{RegisteredClaims: jwt.RegisteredClaims{Subject: "entityID"}, Scope: []string{"foo"}}, nil)
+	s.api.
+		On("CheckScope", api.Scope{"foo"}, api.Scope{"foo"}).
+		Return(true)
+	s.api.
+		On("CreateAPIKey", mock.Anything, "entityID", "theName", api.Scope{"foo"}, time.Unix(123456789, 0)).
+		Return(api.APIKey{ID: "theKeyID"}, "a23n_theKey", nil)
+
+	r, err := s.handler.CreateAPIKey(s.ctx(), connect.NewRequest(&v1.CreateAPIKeyRequest{
+		Name:      "theName",
+		Scope:     []string{"foo"},
+		ExpiresAt: 123456789,
+	}))
+	s.Require().NoError(err)
+
+	s.Assert().Equal("theKeyID", r.Msg.Id)
+	s.Assert().Equal("a23n_theKey", r.Msg.Key)
+
+	l := s.logger.LastEntry()
+	s.Require().NotNil(l)
+	s.Assert().Equal(`{"level":"info","entity_id":"entityID","api_key_id":"theKeyID","message":"api key created"}`, l.String())
+}
+
+func TestHandler_CreateAPIKey(t *testing.T) {
+	suite.Run(t, new(CreateAPIKeyTestSuite))
+}
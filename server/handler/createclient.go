@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+
+	"github.com/ashep/a23n/api"
+	v1 "github.com/ashep/a23n/sdk/proto/a23n/v1"
+)
+
+// CreateClient registers an OAuth 2.0 client. The secret of a confidential client is returned only once.
+func (h *Handler) CreateClient(
+	ctx context.Context,
+	req *connect.Request[v1.CreateClientRequest],
+) (*connect.Response[v1.CreateClientResponse], error) {
+	secret, err := h.api.CreateClient(ctx, api.Client{
+		ID:              req.Msg.Id,
+		Name:            req.Msg.Name,
+		RedirectURIs:    req.Msg.RedirectUris,
+		GrantTypes:      req.Msg.GrantTypes,
+		Scope:           req.Msg.Scope,
+		AccessTokenTTL:  time.Duration(req.Msg.AccessTokenTtl) * time.Second,
+		RefreshTokenTTL: time.Duration(req.Msg.RefreshTokenTtl) * time.Second,
+		Confidential:    req.Msg.Confidential,
+	})
+	if errors.Is(err, api.ErrInvalidArg{}) {
+		return nil, connect.NewError(connect.CodeInvalidArgument, err)
+	} else if errors.Is(err, api.ErrAlreadyExists) {
+		return nil, connect.NewError(connect.CodeAlreadyExists, err)
+	} else if err != nil {
+		h.l.Error().Err(err).Str("client_id", req.Msg.Id).Msg("create client failed")
+		return nil, connect.NewError(connect.CodeInternal, nil)
+	}
+
+	h.l.Info().Str("client_id", req.Msg.Id).Bool("confidential", req.Msg.Confidential).Msg("client created")
+
+	return connect.NewResponse(&v1.CreateClientResponse{Secret: secret}), nil
+}
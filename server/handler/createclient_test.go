@@ -0,0 +1,84 @@
+package handler_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/rs/zerolog"
+	"github.com/rzajac/zltest"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/ashep/a23n/api"
+	"github.com/ashep/a23n/sdk/proto/a23n/v1"
+	"github.com/ashep/a23n/server/handler"
+)
+
+type CreateClientTestSuite struct {
+	suite.Suite
+
+	api     *api.APIMock
+	handler *handler.Handler
+	logger  *zltest.Tester
+}
+
+func (s *CreateClientTestSuite) SetupTest() {
+	lt := zltest.New(s.T())
+	l := lt.Logger().Level(zerolog.DebugLevel)
+
+	s.api = &api.APIMock{}
+	s.handler = handler.New(s.api, time.Second*5, time.Second*10, api.Scope{"admin"}, nil, l)
+	s.logger = lt
+}
+
+func (s *CreateClientTestSuite) TearDownTest() {
+	s.api.AssertExpectations(s.T())
+}
+
+func (s *CreateClientTestSuite) TestAlreadyExists() {
+	s.api.
+		On("CreateClient", mock.Anything, mock.Anything).
+		Return("", api.ErrAlreadyExists)
+
+	_, err := s.handler.CreateClient(context.Background(), connect.NewRequest(&v1.CreateClientRequest{
+		Id:           "theClient",
+		RedirectUris: []string{"https://example.com/callback"},
+	}))
+	s.Require().Equal(connect.CodeAlreadyExists, connect.CodeOf(err))
+}
+
+func (s *CreateClientTestSuite) TestOK() {
+	s.api.
+		On("CreateClient", mock.Anything, api.Client{
+			ID:              "theClient",
+			Name:            "The Client",
+			GrantTypes:      []string{"password", "refresh_token"},
+			Scope:           api.Scope{"foo"},
+			AccessTokenTTL:  time.Minute,
+			RefreshTokenTTL: time.Hour,
+			Confidential:    true,
+		}).
+		Return("theSecret", nil)
+
+	res, err := s.handler.CreateClient(context.Background(), connect.NewRequest(&v1.CreateClientRequest{
+		Id:              "theClient",
+		Name:            "The Client",
+		GrantTypes:      []string{"password", "refresh_token"},
+		Scope:           []string{"foo"},
+		AccessTokenTtl:  60,
+		RefreshTokenTtl: 3600,
+		Confidential:    true,
+	}))
+	s.Require().NoError(err)
+	s.Assert().Equal("theSecret", res.Msg.Secret)
+
+	l := s.logger.LastEntry()
+	s.Require().NotNil(l)
+	s.Assert().Equal(`{"level":"info","client_id":"theClient","confidential":true,"message":"client created"}`, l.String())
+}
+
+func TestHandler_CreateClient(t *testing.T) {
+	suite.Run(t, new(CreateClientTestSuite))
+}
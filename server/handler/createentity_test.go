@@ -0,0 +1,86 @@
+package handler_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/rs/zerolog"
+	"github.com/rzajac/zltest"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/ashep/a23n/api"
+	"github.com/ashep/a23n/sdk/proto/a23n/v1"
+	"github.com/ashep/a23n/server/handler"
+)
+
+type CreateEntityTestSuite struct {
+	suite.Suite
+
+	api     *api.APIMock
+	handler *handler.Handler
+	logger  *zltest.Tester
+}
+
+func (s *CreateEntityTestSuite) SetupTest() {
+	lt := zltest.New(s.T())
+	l := lt.Logger().Level(zerolog.DebugLevel)
+
+	s.api = &api.APIMock{}
+	s.handler = handler.New(s.api, time.Second*5, time.Second*10, nil, nil, l)
+	s.logger = lt
+}
+
+func (s *CreateEntityTestSuite) TearDownTest() {
+	s.api.AssertExpectations(s.T())
+}
+
+func (s *CreateEntityTestSuite) TestWeakSecret() {
+	argErr := api.ErrInvalidArg{Msg: "weak secret: digit, min_length", Rules: []string{"digit", "min_length"}}
+	s.api.
+		On("CreateEntity", mock.Anything, mock.AnythingOfType("string"), "", "short", api.Scope(nil), api.Attrs(nil)).
+		Return(argErr)
+
+	_, err := s.handler.CreateEntity(context.Background(), connect.NewRequest(&v1.CreateEntityRequest{Secret: "short"}))
+
+	var cErr *connect.Error
+	s.Require().True(errors.As(err, &cErr))
+	s.Assert().Equal(connect.CodeInvalidArgument, cErr.Code())
+	s.Assert().Equal("weak secret: digit, min_length", cErr.Message())
+	s.Require().Len(cErr.Details(), 1)
+
+	d, err := cErr.Details()[0].Value()
+	s.Require().NoError(err)
+	s.Assert().Equal([]string{"digit", "min_length"}, d.(*v1.PasswordPolicyViolation).Rules)
+}
+
+func (s *CreateEntityTestSuite) TestInvalidArg() {
+	s.api.
+		On("CreateEntity", mock.Anything, mock.AnythingOfType("string"), "", "", api.Scope(nil), api.Attrs(nil)).
+		Return(api.ErrInvalidArg{Msg: "empty secret"})
+
+	_, err := s.handler.CreateEntity(context.Background(), connect.NewRequest(&v1.CreateEntityRequest{}))
+	s.Require().Equal(connect.NewError(connect.CodeInvalidArgument, api.ErrInvalidArg{Msg: "empty secret"}), err)
+}
+
+func (s *CreateEntityTestSuite) TestOK() {
+	s.api.
+		On("CreateEntity", mock.Anything, mock.AnythingOfType("string"), "theLogin", "theSecret", api.Scope{"theScope"},
+			api.Attrs(nil)).
+		Return(nil)
+
+	res, err := s.handler.CreateEntity(context.Background(), connect.NewRequest(&v1.CreateEntityRequest{
+		Login:  "theLogin",
+		Secret: "theSecret",
+		Scope:  []string{"theScope"},
+	}))
+	s.Require().NoError(err)
+	s.Assert().NotEmpty(res.Msg.Id)
+}
+
+func TestHandler_CreateEntity(t *testing.T) {
+	suite.Run(t, new(CreateEntityTestSuite))
+}
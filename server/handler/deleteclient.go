@@ -0,0 +1,30 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	"github.com/bufbuild/connect-go"
+
+	"github.com/ashep/a23n/api"
+	v1 "github.com/ashep/a23n/sdk/proto/a23n/v1"
+)
+
+// DeleteClient deletes an OAuth 2.0 client. Refresh tokens issued to it cannot be used anymore, while access tokens
+// remain valid until they expire.
+func (h *Handler) DeleteClient(
+	ctx context.Context,
+	req *connect.Request[v1.DeleteClientRequest],
+) (*connect.Response[v1.DeleteClientResponse], error) {
+	err := h.api.DeleteClient(ctx, req.Msg.Id)
+	if errors.Is(err, api.ErrNotFound) {
+		return nil, connect.NewError(connect.CodeNotFound, err)
+	} else if err != nil {
+		h.l.Error().Err(err).Str("client_id", req.Msg.Id).Msg("delete client failed")
+		return nil, connect.NewError(connect.CodeInternal, nil)
+	}
+
+	h.l.Info().Str("client_id", req.Msg.Id).Msg("client deleted")
+
+	return connect.NewResponse(&v1.DeleteClientResponse{}), nil
+}
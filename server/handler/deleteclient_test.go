@@ -0,0 +1,73 @@
+package handler_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/rs/zerolog"
+	"github.com/rzajac/zltest"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/ashep/a23n/api"
+	"github.com/ashep/a23n/sdk/proto/a23n/v1"
+	"github.com/ashep/a23n/server/handler"
+)
+
+type DeleteClientTestSuite struct {
+	suite.Suite
+
+	api     *api.APIMock
+	handler *handler.Handler
+	logger  *zltest.Tester
+}
+
+func (s *DeleteClientTestSuite) SetupTest() {
+	lt := zltest.New(s.T())
+	l := lt.Logger().Level(zerolog.DebugLevel)
+
+	s.api = &api.APIMock{}
+	s.handler = handler.New(s.api, time.Second*5, time.Second*10, api.Scope{"admin"}, nil, l)
+	s.logger = lt
+}
+
+func (s *DeleteClientTestSuite) TearDownTest() {
+	s.api.AssertExpectations(s.T())
+}
+
+func (s *DeleteClientTestSuite) delete(err error) error {
+	s.api.On("DeleteClient", mock.Anything, "theClient").Return(err)
+
+	_, dErr := s.handler.DeleteClient(context.Background(), connect.NewRequest(&v1.DeleteClientRequest{
+		Id: "theClient",
+	}))
+
+	return dErr
+}
+
+func (s *DeleteClientTestSuite) TestNotFound() {
+	s.Require().Equal(s.delete(api.ErrNotFound), connect.NewError(connect.CodeNotFound, api.ErrNotFound))
+}
+
+func (s *DeleteClientTestSuite) TestAPIError() {
+	s.Require().Equal(s.delete(errors.New("theError")), connect.NewError(connect.CodeInternal, nil))
+
+	l := s.logger.LastEntry()
+	s.Require().NotNil(l)
+	s.Assert().Equal(`{"level":"error","error":"theError","client_id":"theClient","message":"delete client failed"}`, l.String())
+}
+
+func (s *DeleteClientTestSuite) TestOK() {
+	s.Require().NoError(s.delete(nil))
+
+	l := s.logger.LastEntry()
+	s.Require().NotNil(l)
+	s.Assert().Equal(`{"level":"info","client_id":"theClient","message":"client deleted"}`, l.String())
+}
+
+func TestHandler_DeleteClient(t *testing.T) {
+	suite.Run(t, new(DeleteClientTestSuite))
+}
@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/google/uuid"
+
+	"github.com/ashep/a23n/api"
+	v1 "github.com/ashep/a23n/sdk/proto/a23n/v1"
+)
+
+// DeleteEntity deletes an entity along with its tokens and all other dependent records.
+func (h *Handler) DeleteEntity(
+	ctx context.Context,
+	req *connect.Request[v1.DeleteEntityRequest],
+) (*connect.Response[v1.DeleteEntityResponse], error) {
+	if _, err := uuid.Parse(req.Msg.Id); err != nil {
+		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("invalid entity id"))
+	}
+
+	err := h.api.DeleteEntity(ctx, req.Msg.Id)
+	if errors.Is(err, api.ErrNotFound) {
+		return nil, connect.NewError(connect.CodeNotFound, err)
+	} else if err != nil {
+		h.l.Error().Err(err).Str("entity_id", req.Msg.Id).Msg("delete entity failed")
+		return nil, connect.NewError(connect.CodeInternal, nil)
+	}
+
+	h.l.Info().Str("entity_id", req.Msg.Id).Msg("entity deleted")
+
+	return connect.NewResponse(&v1.DeleteEntityResponse{}), nil
+}
@@ -0,0 +1,89 @@
+package handler_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/rs/zerolog"
+	"github.com/rzajac/zltest"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/ashep/a23n/api"
+	"github.com/ashep/a23n/sdk/proto/a23n/v1"
+	"github.com/ashep/a23n/server/handler"
+)
+
+type DeleteEntityTestSuite struct {
+	suite.Suite
+
+	api     *api.APIMock
+	handler *handler.Handler
+	logger  *zltest.Tester
+}
+
+func (s *DeleteEntityTestSuite) SetupTest() {
+	lt := zltest.New(s.T())
+	l := lt.Logger().Level(zerolog.DebugLevel)
+
+	s.api = &api.APIMock{}
+	s.handler = handler.New(s.api, time.Second*5, time.Second*10, nil, nil, l)
+	s.logger = lt
+}
+
+func (s *DeleteEntityTestSuite) TearDownTest() {
+	s.api.AssertExpectations(s.T())
+}
+
+func (s *DeleteEntityTestSuite) TestInvalidID() {
+	_, err := s.handler.DeleteEntity(context.Background(), connect.NewRequest(&v1.DeleteEntityRequest{Id: "notAUUID"}))
+	s.Require().Equal(err, connect.NewError(connect.CodeInvalidArgument, errors.New("invalid entity id")))
+}
+
+func (s *DeleteEntityTestSuite) TestNotFound() {
+	s.api.
+		On("DeleteEntity", mock.Anything, "de2a6f34-5371-4409-89ec-62bfda13fcb7").
+		Return(api.ErrNotFound)
+
+	_, err := s.handler.DeleteEntity(context.Background(), connect.NewRequest(&v1.DeleteEntityRequest{
+		Id: "de2a6f34-5371-4409-89ec-62bfda13fcb7",
+	}))
+	s.Require().Equal(err, connect.NewError(connect.CodeNotFound, api.ErrNotFound))
+}
+
+func (s *DeleteEntityTestSuite) TestAPIError() {
+	s.api.
+		On("DeleteEntity", mock.Anything, "de2a6f34-5371-4409-89ec-62bfda13fcb7").
+		Return(errors.New("theDeleteEntityError"))
+
+	_, err := s.handler.DeleteEntity(context.Background(), connect.NewRequest(&v1.DeleteEntityRequest{
+		Id: "de2a6f34-5371-4409-89ec-62bfda13fcb7",
+	}))
+	s.Require().Equal(err, connect.NewError(connect.CodeInternal, nil))
+
+	l := s.logger.LastEntry()
+	s.Require().NotNil(l)
+	s.Assert().Equal(`{"level":"error","error":"theDeleteEntityError","entity_id":"de2a6f34-5371-4409-89ec-62bfda13fcb7","message":"delete entity failed"}`, l.String())
+}
+
+func (s *DeleteEntityTestSuite) TestOK() {
+	s.api.
+		On("DeleteEntity", mock.Anything, "de2a6f34-5371-4409-89ec-62bfda13fcb7").
+		Return(nil)
+
+	_, err := s.handler.DeleteEntity(context.Background(), connect.NewRequest(&v1.DeleteEntityRequest{
+		Id: "de2a6f34-5371-4409-89ec-62bfda13fcb7",
+	}))
+	s.Require().NoError(err)
+
+	l := s.logger.LastEntry()
+	s.Require().NotNil(l)
+	s.Assert().Equal(`{"level":"info","entity_id":"de2a6f34-5371-4409-89ec-62bfda13fcb7","message":"entity deleted"}`, l.String())
+}
+
+func TestHandler_DeleteEntity(t *testing.T) {
+	suite.Run(t, new(DeleteEntityTestSuite))
+}
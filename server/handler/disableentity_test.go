@@ -0,0 +1,108 @@
+package handler_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/rs/zerolog"
+	"github.com/rzajac/zltest"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/ashep/a23n/api"
+	"github.com/ashep/a23n/sdk/proto/a23n/v1"
+	"github.com/ashep/a23n/server/handler"
+)
+
+const entityStatusID = "de2a6f34-5371-4409-89ec-62bfda13fcb7"
+
+// EntityStatusTestSuite tests both DisableEntity and EnableEntity.
+type EntityStatusTestSuite struct {
+	suite.Suite
+
+	api     *api.APIMock
+	handler *handler.Handler
+	logger  *zltest.Tester
+}
+
+func (s *EntityStatusTestSuite) SetupTest() {
+	lt := zltest.New(s.T())
+	l := lt.Logger().Level(zerolog.DebugLevel)
+
+	s.api = &api.APIMock{}
+	s.handler = handler.New(s.api, time.Second*5, time.Second*10, nil, nil, l)
+	s.logger = lt
+}
+
+func (s *EntityStatusTestSuite) TearDownTest() {
+	s.api.AssertExpectations(s.T())
+}
+
+func (s *EntityStatusTestSuite) disable(id string) error {
+	_, err := s.handler.DisableEntity(context.Background(), connect.NewRequest(&v1.DisableEntityRequest{Id: id}))
+	return err
+}
+
+func (s *EntityStatusTestSuite) enable(id string) error {
+	_, err := s.handler.EnableEntity(context.Background(), connect.NewRequest(&v1.EnableEntityRequest{Id: id}))
+	return err
+}
+
+func (s *EntityStatusTestSuite) TestInvalidID() {
+	expErr := connect.NewError(connect.CodeInvalidArgument, errors.New("invalid entity id"))
+
+	s.Require().Equal(s.disable("notAUUID"), expErr)
+	s.Require().Equal(s.enable("notAUUID"), expErr)
+}
+
+func (s *EntityStatusTestSuite) TestNotFound() {
+	s.api.On("DisableEntity", mock.Anything, entityStatusID).Return(api.ErrNotFound)
+	s.api.On("EnableEntity", mock.Anything, entityStatusID).Return(api.ErrNotFound)
+
+	s.Require().Equal(s.disable(entityStatusID), connect.NewError(connect.CodeNotFound, api.ErrNotFound))
+	s.Require().Equal(s.enable(entityStatusID), connect.NewError(connect.CodeNotFound, api.ErrNotFound))
+}
+
+func (s *EntityStatusTestSuite) TestDisableAPIError() {
+	s.api.On("DisableEntity", mock.Anything, entityStatusID).Return(errors.New("theError"))
+
+	s.Require().Equal(s.disable(entityStatusID), connect.NewError(connect.CodeInternal, nil))
+
+	l := s.logger.LastEntry()
+	s.Require().NotNil(l)
+	s.Assert().Equal(`{"level":"error","error":"theError","entity_id":"de2a6f34-5371-4409-89ec-62bfda13fcb7","message":"disable entity failed"}`, l.String())
+}
+
+func (s *EntityStatusTestSuite) TestEnableAPIError() {
+	s.api.On("EnableEntity", mock.Anything, entityStatusID).Return(errors.New("theError"))
+
+	s.Require().Equal(s.enable(entityStatusID), connect.NewError(connect.CodeInternal, nil))
+
+	l := s.logger.LastEntry()
+	s.Require().NotNil(l)
+	s.Assert().Equal(`{"level":"error","error":"theError","entity_id":"de2a6f34-5371-4409-89ec-62bfda13fcb7","message":"enable entity failed"}`, l.String())
+}
+
+func (s *EntityStatusTestSuite) TestDisableAndEnable() {
+	s.api.On("DisableEntity", mock.Anything, entityStatusID).Return(nil).Once()
+	s.api.On("EnableEntity", mock.Anything, entityStatusID).Return(nil).Once()
+
+	s.Require().NoError(s.disable(entityStatusID))
+
+	l := s.logger.LastEntry()
+	s.Require().NotNil(l)
+	s.Assert().Equal(`{"level":"info","entity_id":"de2a6f34-5371-4409-89ec-62bfda13fcb7","message":"entity disabled"}`, l.String())
+
+	s.Require().NoError(s.enable(entityStatusID))
+
+	l = s.logger.LastEntry()
+	s.Require().NotNil(l)
+	s.Assert().Equal(`{"level":"info","entity_id":"de2a6f34-5371-4409-89ec-62bfda13fcb7","message":"entity enabled"}`, l.String())
+}
+
+func TestHandler_EntityStatus(t *testing.T) {
+	suite.Run(t, new(EntityStatusTestSuite))
+}
@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/google/uuid"
+
+	"github.com/ashep/a23n/api"
+	v1 "github.com/ashep/a23n/sdk/proto/a23n/v1"
+)
+
+// DisableTOTP disables the second authentication factor of an entity which has lost its authenticator device.
+func (h *Handler) DisableTOTP(
+	ctx context.Context,
+	req *connect.Request[v1.DisableTOTPRequest],
+) (*connect.Response[v1.DisableTOTPResponse], error) {
+	if _, err := uuid.Parse(req.Msg.Id); err != nil {
+		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("invalid entity id"))
+	}
+
+	err := h.api.DisableTOTP(ctx, req.Msg.Id)
+	if errors.Is(err, api.ErrNotFound) {
+		return nil, connect.NewError(connect.CodeNotFound, err)
+	} else if err != nil {
+		h.l.Error().Err(err).Str("entity_id", req.Msg.Id).Msg("disable totp failed")
+		return nil, connect.NewError(connect.CodeInternal, nil)
+	}
+
+	h.l.Info().Str("entity_id", req.Msg.Id).Msg("totp disabled")
+
+	return connect.NewResponse(&v1.DisableTOTPResponse{}), nil
+}
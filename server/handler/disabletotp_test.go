@@ -0,0 +1,81 @@
+package handler_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/rs/zerolog"
+	"github.com/rzajac/zltest"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/ashep/a23n/api"
+	"github.com/ashep/a23n/sdk/proto/a23n/v1"
+	"github.com/ashep/a23n/server/handler"
+)
+
+const totpEntityID = "de2a6f34-5371-4409-89ec-62bfda13fcb7"
+
+type DisableTOTPTestSuite struct {
+	suite.Suite
+
+	api     *api.APIMock
+	handler *handler.Handler
+	logger  *zltest.Tester
+}
+
+func (s *DisableTOTPTestSuite) SetupTest() {
+	lt := zltest.New(s.T())
+	l := lt.Logger().Level(zerolog.DebugLevel)
+
+	s.api = &api.APIMock{}
+	s.handler = handler.New(s.api, time.Second*5, time.Second*10, api.Scope{"admin"}, nil, l)
+	s.logger = lt
+}
+
+func (s *DisableTOTPTestSuite) TearDownTest() {
+	s.api.AssertExpectations(s.T())
+}
+
+func (s *DisableTOTPTestSuite) disable(id string) error {
+	_, err := s.handler.DisableTOTP(context.Background(), connect.NewRequest(&v1.DisableTOTPRequest{Id: id}))
+	return err
+}
+
+func (s *DisableTOTPTestSuite) TestInvalidID() {
+	expErr := connect.NewError(connect.CodeInvalidArgument, errors.New("invalid entity id"))
+	s.Require().Equal(s.disable("notAUUID"), expErr)
+}
+
+func (s *DisableTOTPTestSuite) TestNotFound() {
+	s.api.On("DisableTOTP", mock.Anything, totpEntityID).Return(api.ErrNotFound)
+
+	s.Require().Equal(s.disable(totpEntityID), connect.NewError(connect.CodeNotFound, api.ErrNotFound))
+}
+
+func (s *DisableTOTPTestSuite) TestAPIError() {
+	s.api.On("DisableTOTP", mock.Anything, totpEntityID).Return(errors.New("theError"))
+
+	s.Require().Equal(s.disable(totpEntityID), connect.NewError(connect.CodeInternal, nil))
+
+	l := s.logger.LastEntry()
+	s.Require().NotNil(l)
+	s.Assert().Equal(`{"level":"error","error":"theError","entity_id":"de2a6f34-5371-4409-89ec-62bfda13fcb7","message":"disable totp failed"}`, l.String())
+}
+
+func (s *DisableTOTPTestSuite) TestOK() {
+	s.api.On("DisableTOTP", mock.Anything, totpEntityID).Return(nil)
+
+	s.Require().NoError(s.disable(totpEntityID))
+
+	l := s.logger.LastEntry()
+	s.Require().NotNil(l)
+	s.Assert().Equal(`{"level":"info","entity_id":"de2a6f34-5371-4409-89ec-62bfda13fcb7","message":"totp disabled"}`, l.String())
+}
+
+func TestHandler_DisableTOTP(t *testing.T) {
+	suite.Run(t, new(DisableTOTPTestSuite))
+}
@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	"github.com/bufbuild/connect-go"
+
+	"github.com/ashep/a23n/api"
+	v1 "github.com/ashep/a23n/sdk/proto/a23n/v1"
+)
+
+// EnrollTOTP generates a new TOTP secret for the entity the access token has been issued for. The secret must be
+// confirmed by ConfirmTOTP before it is required to authenticate. An enabled secret is replaced only if the current
+// code is passed, so a stolen access token is not enough to take over the second factor.
+func (h *Handler) EnrollTOTP(
+	ctx context.Context,
+	req *connect.Request[v1.EnrollTOTPRequest],
+) (*connect.Response[v1.EnrollTOTPResponse], error) {
+	crd, ok := h.credentialsFromCtx(ctx)
+	if !ok || crd.Token == "" {
+		return nil, connect.NewError(connect.CodeUnauthenticated, nil)
+	}
+
+	clm, err := h.api.ParseToken(ctx, crd.Token, api.TokenTypeAccess)
+	if err != nil {
+		h.l.Warn().Err(err).Msg("parse access token failed")
+		return nil, connect.NewError(connect.CodeUnauthenticated, nil)
+	}
+
+	e, err := h.api.GetEntity(ctx, clm.Subject)
+	if errors.Is(err, api.ErrNotFound) {
+		return nil, connect.NewError(connect.CodeNotFound, err)
+	} else if err != nil {
+		h.l.Error().Err(err).Str("entity_id", clm.Subject).Msg("get entity failed")
+		return nil, connect.NewError(connect.CodeInternal, nil)
+	}
+
+	if e.TOTPEnabled {
+		if req.Msg.TotpCode == "" {
+			return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("current totp code required"))
+		}
+		if err = h.verifyTOTP(ctx, e.ID, req.Msg.TotpCode, peerHost(req.Peer())); err != nil {
+			return nil, err
+		}
+	}
+
+	key, err := h.api.EnrollTOTP(ctx, clm.Subject)
+	if errors.Is(err, api.ErrNotFound) {
+		return nil, connect.NewError(connect.CodeNotFound, err)
+	} else if err != nil {
+		h.l.Error().Err(err).Str("entity_id", clm.Subject).Msg("enroll totp failed")
+		return nil, connect.NewError(connect.CodeInternal, nil)
+	}
+
+	h.l.Info().Str("entity_id", clm.Subject).Msg("totp enrolled")
+
+	return connect.NewResponse(&v1.EnrollTOTPResponse{
+		Secret: key.Secret,
+		Uri:    key.URI,
+	}), nil
+}
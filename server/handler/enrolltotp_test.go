@@ -0,0 +1,154 @@
+package handler_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/rs/zerolog"
+	"github.com/rzajac/zltest"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/ashep/a23n/api"
+	"github.com/ashep/a23n/sdk/proto/a23n/v1"
+	"github.com/ashep/a23n/server/credentials"
+	"github.com/ashep/a23n/server/handler"
+)
+
+type EnrollTOTPTestSuite struct {
+	suite.Suite
+
+	api     *api.APIMock
+	handler *handler.Handler
+	logger  *zltest.Tester
+}
+
+func (s *EnrollTOTPTestSuite) SetupTest() {
+	lt := zltest.New(s.T())
+	l := lt.Logger().Level(zerolog.DebugLevel)
+
+	s.api = &api.APIMock{}
+	s.handler = handler.New(s.api, time.Second*5, time.Second*10, api.Scope{"admin"}, nil, l)
+	s.logger = lt
+}
+
+func (s *EnrollTOTPTestSuite) TearDownTest() {
+	s.api.AssertExpectations(s.T())
+}
+
+func (s *EnrollTOTPTestSuite) ctx() context.Context {
+	return context.WithValue(context.Background(), "crd", credentials.Credentials{Token: "theAccessToken"})
+}
+
+func (s *EnrollTOTPTestSuite) expectEntity(totpEnabled bool) {
+	s.api.
+		On("ParseToken", mock.Anything, "theAccessToken", api.TokenTypeAccess).
+		Return(api.TokenClaims{RegisteredClaims: jwt.RegisteredClaims{Subject: "entityID"}}, nil)
+	s.api.
+		On("GetEntity", mock.Anything, "entityID").
+		Return(api.Entity{ID: "entityID", TOTPEnabled: totpEnabled}, nil)
+}
+
+func (s *EnrollTOTPTestSuite) expectEnrollTOTP() {
+	s.api.
+		On("EnrollTOTP", mock.Anything, "entityID").
+		Return(api.TOTPKey{Secret: "theSecret", URI: "otpauth://totp/theURI"}, nil)
+}
+
+func (s *EnrollTOTPTestSuite) TestNoToken() {
+	crd := credentials.Credentials{ID: "entityID", Password: "theSecret"}
+	ctx := context.WithValue(context.Background(), "crd", crd)
+
+	_, err := s.handler.EnrollTOTP(ctx, connect.NewRequest(&v1.EnrollTOTPRequest{}))
+	s.Require().Equal(err, connect.NewError(connect.CodeUnauthenticated, nil))
+}
+
+func (s *EnrollTOTPTestSuite) TestParseTokenError() {
+	s.api.
+		On("ParseToken", mock.Anything, "theAccessToken", api.TokenTypeAccess).
+		Return(api.TokenClaims{}, api.ErrTokenRevoked)
+
+	_, err := s.handler.EnrollTOTP(s.ctx(), connect.NewRequest(&v1.EnrollTOTPRequest{}))
+	s.Require().Equal(err, connect.NewError(connect.CodeUnauthenticated, nil))
+}
+
+func (s *EnrollTOTPTestSuite) TestEntityNotFound() {
+	s.api.
+		On("ParseToken", mock.Anything, "theAccessToken", api.TokenTypeAccess).
+		Return(api.TokenClaims{RegisteredClaims: jwt.RegisteredClaims{Subject: "entityID"}}, nil)
+	s.api.
+		On("GetEntity", mock.Anything, "entityID").
+		Return(api.Entity{}, api.ErrNotFound)
+
+	_, err := s.handler.EnrollTOTP(s.ctx(), connect.NewRequest(&v1.EnrollTOTPRequest{}))
+	s.Require().Equal(err, connect.NewError(connect.CodeNotFound, api.ErrNotFound))
+}
+
+func (s *EnrollTOTPTestSuite) TestEnabledWithoutCode() {
+	s.expectEntity(true)
+
+	_, err := s.handler.EnrollTOTP(s.ctx(), connect.NewRequest(&v1.EnrollTOTPRequest{}))
+	s.Require().Equal(err, connect.NewError(connect.CodeInvalidArgument, errors.New("current totp code required")))
+}
+
+func (s *EnrollTOTPTestSuite) TestEnabledInvalidCode() {
+	s.expectEntity(true)
+	s.api.
+		On("VerifyTOTP", mock.Anything, "entityID", "123456", "").
+		Return(api.ErrInvalidCredentials)
+
+	_, err := s.handler.EnrollTOTP(s.ctx(), connect.NewRequest(&v1.EnrollTOTPRequest{TotpCode: "123456"}))
+	s.Require().Equal(err, connect.NewError(connect.CodeUnauthenticated, nil))
+
+	l := s.logger.LastEntry()
+	s.Require().NotNil(l)
+	s.Assert().Equal(`{"level":"warn","entity_id":"entityID","source":"","message":"invalid totp code"}`, l.String())
+}
+
+func (s *EnrollTOTPTestSuite) TestEnabledOK() {
+	s.expectEntity(true)
+	s.api.
+		On("VerifyTOTP", mock.Anything, "entityID", "123456", "").
+		Return(nil)
+	s.expectEnrollTOTP()
+
+	r, err := s.handler.EnrollTOTP(s.ctx(), connect.NewRequest(&v1.EnrollTOTPRequest{TotpCode: "123456"}))
+	s.Require().NoError(err)
+	s.Assert().Equal("theSecret", r.Msg.Secret)
+}
+
+func (s *EnrollTOTPTestSuite) TestAPIError() {
+	s.expectEntity(false)
+	s.api.
+		On("EnrollTOTP", mock.Anything, "entityID").
+		Return(api.TOTPKey{}, errors.New("theError"))
+
+	_, err := s.handler.EnrollTOTP(s.ctx(), connect.NewRequest(&v1.EnrollTOTPRequest{}))
+	s.Require().Equal(err, connect.NewError(connect.CodeInternal, nil))
+
+	l := s.logger.LastEntry()
+	s.Require().NotNil(l)
+	s.Assert().Equal(`{"level":"error","error":"theError","entity_id":"entityID","message":"enroll totp failed"}`, l.String())
+}
+
+func (s *EnrollTOTPTestSuite) TestOK() {
+	s.expectEntity(false)
+	s.expectEnrollTOTP()
+
+	r, err := s.handler.EnrollTOTP(s.ctx(), connect.NewRequest(&v1.EnrollTOTPRequest{}))
+	s.Require().NoError(err)
+	s.Assert().Equal("theSecret", r.Msg.Secret)
+	s.Assert().Equal("otpauth://totp/theURI", r.Msg.Uri)
+
+	l := s.logger.LastEntry()
+	s.Require().NotNil(l)
+	s.Assert().Equal(`{"level":"info","entity_id":"entityID","message":"totp enrolled"}`, l.String())
+}
+
+func TestHandler_EnrollTOTP(t *testing.T) {
+	suite.Run(t, new(EnrollTOTPTestSuite))
+}
@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/google/uuid"
+
+	"github.com/ashep/a23n/api"
+	v1 "github.com/ashep/a23n/sdk/proto/a23n/v1"
+)
+
+// FinishWebAuthnLogin authenticates an entity by the passkey assertion made in response to BeginWebAuthnLogin.
+// Passkeys verify the user by themselves, so scopes requiring MFA are granted as well, provided the passkey has been
+// registered after passing MFA.
+func (h *Handler) FinishWebAuthnLogin(
+	ctx context.Context,
+	req *connect.Request[v1.FinishWebAuthnLoginRequest],
+) (*connect.Response[v1.FinishWebAuthnLoginResponse], error) {
+	if req.Msg.ChallengeId == "" {
+		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("empty challenge id"))
+	}
+	if req.Msg.Credential == "" {
+		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("empty credential"))
+	}
+
+	source := peerHost(req.Peer())
+
+	var lockErr api.ErrLocked
+
+	e, mfa, err := h.api.FinishWebAuthnLogin(ctx, req.Msg.ChallengeId, []byte(req.Msg.Credential), source)
+	if errors.Is(err, api.ErrWebAuthnDisabled) {
+		return nil, connect.NewError(connect.CodeUnimplemented, err)
+	} else if errors.Is(err, api.ErrInvalidArg{}) {
+		return nil, connect.NewError(connect.CodeInvalidArgument, err)
+	} else if errors.Is(err, api.ErrInvalidCredentials) {
+		h.l.Warn().Err(err).Str("source", source).Msg("invalid webauthn assertion")
+		return nil, connect.NewError(connect.CodeUnauthenticated, nil)
+	} else if errors.Is(err, api.ErrEntityDisabled) {
+		h.l.Warn().Str("entity_id", e.ID).Msg("entity disabled")
+		return nil, connect.NewError(connect.CodePermissionDenied, err)
+	} else if errors.As(err, &lockErr) {
+		h.l.Warn().Str("entity_id", e.ID).Str("source", source).Time("until", lockErr.Until).Msg("locked out")
+		return nil, lockedError(lockErr)
+	} else if err != nil {
+		h.l.Error().Err(err).Msg("finish webauthn login failed")
+		return nil, connect.NewError(connect.CodeInternal, nil)
+	}
+
+	e.Scope = h.grantedScope(e, mfa)
+	if !h.api.CheckScope(e.Scope, req.Msg.Scope) {
+		return nil, connect.NewError(connect.CodePermissionDenied, nil)
+	}
+
+	tp, err := h.issueTokens(ctx, e, uuid.NewString())
+	if err != nil {
+		return nil, err
+	}
+
+	h.l.Info().
+		Str("entity_id", e.ID).
+		Int64("access_token_expires", tp.accessExpires).
+		Int64("refresh_token_expires", tp.refreshExpires).
+		Msg("authenticated by webauthn")
+
+	return connect.NewResponse(&v1.FinishWebAuthnLoginResponse{
+		AccessToken:         tp.access,
+		AccessTokenExpires:  tp.accessExpires,
+		RefreshToken:        tp.refresh,
+		RefreshTokenExpires: tp.refreshExpires,
+	}), nil
+}
@@ -0,0 +1,156 @@
+package handler_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/rs/zerolog"
+	"github.com/rzajac/zltest"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/ashep/a23n/api"
+	"github.com/ashep/a23n/sdk/proto/a23n/v1"
+	"github.com/ashep/a23n/server/handler"
+)
+
+type FinishWebAuthnLoginTestSuite struct {
+	suite.Suite
+
+	api     *api.APIMock
+	handler *handler.Handler
+	logger  *zltest.Tester
+}
+
+func (s *FinishWebAuthnLoginTestSuite) SetupTest() {
+	lt := zltest.New(s.T())
+	l := lt.Logger().Level(zerolog.DebugLevel)
+
+	s.api = &api.APIMock{}
+	s.handler = handler.New(s.api, time.Second*5, time.Second*10, api.Scope{"admin"}, nil, l)
+	s.logger = lt
+}
+
+func (s *FinishWebAuthnLoginTestSuite) TearDownTest() {
+	s.api.AssertExpectations(s.T())
+}
+
+func (s *FinishWebAuthnLoginTestSuite) req() *connect.Request[v1.FinishWebAuthnLoginRequest] {
+	return connect.NewRequest(&v1.FinishWebAuthnLoginRequest{
+		ChallengeId: "theChallengeID",
+		Credential:  `{"id":"theCredentialID"}`,
+		Scope:       []string{"admin"},
+	})
+}
+
+func (s *FinishWebAuthnLoginTestSuite) TestEmptyCredential() {
+	_, err := s.handler.FinishWebAuthnLogin(context.Background(), connect.NewRequest(&v1.FinishWebAuthnLoginRequest{
+		ChallengeId: "theChallengeID",
+	}))
+	s.Require().Equal(err, connect.NewError(connect.CodeInvalidArgument, errors.New("empty credential")))
+}
+
+func (s *FinishWebAuthnLoginTestSuite) TestDisabled() {
+	s.api.
+		On("FinishWebAuthnLogin", mock.Anything, "theChallengeID", []byte(`{"id":"theCredentialID"}`), "").
+		Return(api.Entity{}, false, api.ErrWebAuthnDisabled)
+
+	_, err := s.handler.FinishWebAuthnLogin(context.Background(), s.req())
+	s.Require().Equal(err, connect.NewError(connect.CodeUnimplemented, api.ErrWebAuthnDisabled))
+}
+
+func (s *FinishWebAuthnLoginTestSuite) TestInvalidAssertion() {
+	s.api.
+		On("FinishWebAuthnLogin", mock.Anything, "theChallengeID", []byte(`{"id":"theCredentialID"}`), "").
+		Return(api.Entity{}, false, api.ErrInvalidCredentials)
+
+	_, err := s.handler.FinishWebAuthnLogin(context.Background(), s.req())
+	s.Require().Equal(err, connect.NewError(connect.CodeUnauthenticated, nil))
+
+	l := s.logger.LastEntry()
+	s.Require().NotNil(l)
+	s.Assert().Equal(`{"level":"warn","error":"invalid credentials","source":"","message":"invalid webauthn assertion"}`, l.String())
+}
+
+func (s *FinishWebAuthnLoginTestSuite) TestEntityDisabled() {
+	s.api.
+		On("FinishWebAuthnLogin", mock.Anything, "theChallengeID", []byte(`{"id":"theCredentialID"}`), "").
+		Return(api.Entity{ID: "entityID"}, false, api.ErrEntityDisabled)
+
+	_, err := s.handler.FinishWebAuthnLogin(context.Background(), s.req())
+	s.Require().Equal(err, connect.NewError(connect.CodePermissionDenied, api.ErrEntityDisabled))
+
+	l := s.logger.LastEntry()
+	s.Require().NotNil(l)
+	l.ExpMsg("entity disabled")
+	l.ExpStr("entity_id", "entityID")
+}
+
+func (s *FinishWebAuthnLoginTestSuite) TestOK() {
+	atCl := &api.ClaimsMock{}
+	atCl.On("GetExpirationTime").
+		Return(&jwt.NumericDate{Time: time.Unix(123456789, 0)}, nil)
+
+	at := &api.TokenMock{}
+	at.On("Claims").Return(atCl)
+	at.On("SignedString").Return("accessTokenSignedString", nil)
+
+	rtCl := &api.ClaimsMock{}
+	rtCl.On("GetExpirationTime").
+		Return(&jwt.NumericDate{Time: time.Unix(234567890, 0)}, nil)
+
+	rt := &api.TokenMock{}
+	rt.On("Claims").Return(rtCl)
+	rt.On("SignedString").Return("refreshTokenSignedString", nil)
+
+	s.api.
+		On("FinishWebAuthnLogin", mock.Anything, "theChallengeID", []byte(`{"id":"theCredentialID"}`), "").
+		Return(api.Entity{ID: "entityID", Scope: api.Scope{"admin"}}, true, nil)
+
+	// The scope requiring MFA is granted without TOTP, since the passkey has been registered after passing MFA
+	s.api.
+		On("CheckScope", api.Scope{"admin"}, api.Scope{"admin"}).
+		Return(true)
+	s.api.
+		On("CreateToken", api.TokenTypeAccess, "entityID", "", []string{"admin"}, time.Second*5).
+		Return(at)
+	s.api.
+		On("CreateToken", api.TokenTypeRefresh, "entityID", "", []string{"admin"}, time.Second*10).
+		Return(rt)
+	s.api.
+		On("SaveRefreshToken", mock.Anything, "refreshTokenSignedString", "entityID",
+			mock.AnythingOfType("string"), time.Unix(234567890, 0)).
+		Return(nil)
+
+	r, err := s.handler.FinishWebAuthnLogin(context.Background(), s.req())
+	s.Require().NoError(err)
+
+	s.Assert().Equal("accessTokenSignedString", r.Msg.AccessToken)
+	s.Assert().Equal(int64(123456789), r.Msg.AccessTokenExpires)
+	s.Assert().Equal("refreshTokenSignedString", r.Msg.RefreshToken)
+	s.Assert().Equal(int64(234567890), r.Msg.RefreshTokenExpires)
+
+	l := s.logger.LastEntry()
+	s.Require().NotNil(l)
+	s.Assert().Equal(`{"level":"info","entity_id":"entityID","access_token_expires":123456789,"refresh_token_expires":234567890,"message":"authenticated by webauthn"}`, l.String())
+}
+
+func (s *FinishWebAuthnLoginTestSuite) TestRegisteredWithoutMFA() {
+	s.api.
+		On("FinishWebAuthnLogin", mock.Anything, "theChallengeID", []byte(`{"id":"theCredentialID"}`), "").
+		Return(api.Entity{ID: "entityID", Scope: api.Scope{"admin", "user"}}, false, nil)
+	s.api.
+		On("CheckScope", api.Scope{"user"}, api.Scope{"admin"}).
+		Return(false)
+
+	_, err := s.handler.FinishWebAuthnLogin(context.Background(), s.req())
+	s.Require().Equal(err, connect.NewError(connect.CodePermissionDenied, nil))
+}
+
+func TestHandler_FinishWebAuthnLogin(t *testing.T) {
+	suite.Run(t, new(FinishWebAuthnLoginTestSuite))
+}
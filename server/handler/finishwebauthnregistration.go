@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	"github.com/bufbuild/connect-go"
+
+	"github.com/ashep/a23n/api"
+	v1 "github.com/ashep/a23n/sdk/proto/a23n/v1"
+)
+
+// FinishWebAuthnRegistration registers the passkey created by an authenticator in response to
+// BeginWebAuthnRegistration. From now on the entity can authenticate with it by FinishWebAuthnLogin. Only passkeys
+// registered by a token holding scopes requiring MFA grant such scopes at login, otherwise a stolen secret would be
+// enough to get them.
+func (h *Handler) FinishWebAuthnRegistration(
+	ctx context.Context,
+	req *connect.Request[v1.FinishWebAuthnRegistrationRequest],
+) (*connect.Response[v1.FinishWebAuthnRegistrationResponse], error) {
+	crd, ok := h.credentialsFromCtx(ctx)
+	if !ok || crd.Token == "" {
+		return nil, connect.NewError(connect.CodeUnauthenticated, nil)
+	}
+
+	if req.Msg.ChallengeId == "" {
+		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("empty challenge id"))
+	}
+	if req.Msg.Credential == "" {
+		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("empty credential"))
+	}
+
+	clm, err := h.api.ParseToken(ctx, crd.Token, api.TokenTypeAccess)
+	if err != nil {
+		h.l.Warn().Err(err).Msg("parse access token failed")
+		return nil, connect.NewError(connect.CodeUnauthenticated, nil)
+	}
+
+	mfa := h.hasMFAScope(clm.Scope)
+	credID, err := h.api.FinishWebAuthnRegistration(ctx, clm.Subject, req.Msg.ChallengeId, []byte(req.Msg.Credential),
+		mfa)
+	if errors.Is(err, api.ErrWebAuthnDisabled) {
+		return nil, connect.NewError(connect.CodeUnimplemented, err)
+	} else if errors.Is(err, api.ErrInvalidArg{}) {
+		h.l.Warn().Err(err).Str("entity_id", clm.Subject).Msg("invalid webauthn credential")
+		return nil, connect.NewError(connect.CodeInvalidArgument, err)
+	} else if errors.Is(err, api.ErrAlreadyExists) {
+		return nil, connect.NewError(connect.CodeAlreadyExists, err)
+	} else if errors.Is(err, api.ErrNotFound) {
+		return nil, connect.NewError(connect.CodeNotFound, err)
+	} else if err != nil {
+		h.l.Error().Err(err).Str("entity_id", clm.Subject).Msg("finish webauthn registration failed")
+		return nil, connect.NewError(connect.CodeInternal, nil)
+	}
+
+	h.l.Info().Str("entity_id", clm.Subject).Str("credential_id", credID).Bool("mfa", mfa).
+		Msg("webauthn credential registered")
+
+	return connect.NewResponse(&v1.FinishWebAuthnRegistrationResponse{CredentialId: credID}), nil
+}
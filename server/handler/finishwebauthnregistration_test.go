@@ -0,0 +1,87 @@
+package handler_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/rs/zerolog"
+	"github.com/rzajac/zltest"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/ashep/a23n/api"
+	"github.com/ashep/a23n/sdk/proto/a23n/v1"
+	"github.com/ashep/a23n/server/credentials"
+	"github.com/ashep/a23n/server/handler"
+)
+
+type FinishWebAuthnRegistrationTestSuite struct {
+	suite.Suite
+
+	api     *api.APIMock
+	handler *handler.Handler
+	logger  *zltest.Tester
+}
+
+func (s *FinishWebAuthnRegistrationTestSuite) SetupTest() {
+	lt := zltest.New(s.T())
+	l := lt.Logger().Level(zerolog.DebugLevel)
+
+	s.api = &api.APIMock{}
+	s.handler = handler.New(s.api, time.Second*5, time.Second*10, api.Scope{"admin"}, nil, l)
+	s.logger = lt
+}
+
+func (s *FinishWebAuthnRegistrationTestSuite) TearDownTest() {
+	s.api.AssertExpectations(s.T())
+}
+
+// register finishes a registration made by an access token having scope.
+func (s *FinishWebAuthnRegistrationTestSuite) register(scope []string, mfa bool) {
+	clm := api.TokenClaims{RegisteredClaims: jwt.RegisteredClaims{Subject: "entityID"}, Scope: scope}
+	s.api.
+		On("ParseToken", mock.Anything, "theAccessToken", api.TokenTypeAccess).
+		Return(clm, nil)
+	s.api.
+		On("FinishWebAuthnRegistration", mock.Anything, "entityID", "theChallengeID",
+			[]byte(`{"id":"theCredentialID"}`), mfa).
+		Return("theCredentialID", nil)
+
+	ctx := context.WithValue(context.Background(), "crd", credentials.Credentials{Token: "theAccessToken"})
+	r, err := s.handler.FinishWebAuthnRegistration(ctx, connect.NewRequest(&v1.FinishWebAuthnRegistrationRequest{
+		ChallengeId: "theChallengeID",
+		Credential:  `{"id":"theCredentialID"}`,
+	}))
+	s.Require().NoError(err)
+	s.Assert().Equal("theCredentialID", r.Msg.CredentialId)
+}
+
+func (s *FinishWebAuthnRegistrationTestSuite) TestUnauthenticated() {
+	_, err := s.handler.FinishWebAuthnRegistration(context.Background(),
+		connect.NewRequest(&v1.FinishWebAuthnRegistrationRequest{}))
+	s.Require().Equal(err, connect.NewError(connect.CodeUnauthenticated, nil))
+}
+
+func (s *FinishWebAuthnRegistrationTestSuite) TestWithMFA() {
+	s.register([]string{"admin", "user"}, true)
+
+	l := s.logger.LastEntry()
+	s.Require().NotNil(l)
+	s.Assert().Equal(`{"level":"info","entity_id":"entityID","credential_id":"theCredentialID","mfa":true,"message":"webauthn credential registered"}`, l.String())
+}
+
+func (s *FinishWebAuthnRegistrationTestSuite) TestWithoutMFA() {
+	// A token obtained by the secret only cannot register a passkey granting scopes which require MFA
+	s.register([]string{"user"}, false)
+
+	l := s.logger.LastEntry()
+	s.Require().NotNil(l)
+	s.Assert().Equal(`{"level":"info","entity_id":"entityID","credential_id":"theCredentialID","mfa":false,"message":"webauthn credential registered"}`, l.String())
+}
+
+func TestHandler_FinishWebAuthnRegistration(t *testing.T) {
+	suite.Run(t, new(FinishWebAuthnRegistrationTestSuite))
+}
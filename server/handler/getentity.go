@@ -2,26 +2,62 @@ package handler
 
 import (
 	"context"
+	"errors"
 
 	"github.com/bufbuild/connect-go"
 
+	"github.com/ashep/a23n/api"
 	v1 "github.com/ashep/a23n/sdk/proto/a23n/v1"
 )
 
+// GetEntity returns the entity an access token has been issued for. The token is taken from the request or, if it is
+// empty, from the authorization header, which may hold an API key as well.
 func (h *Handler) GetEntity(
 	ctx context.Context,
-	_ *connect.Request[v1.GetEntityRequest],
+	req *connect.Request[v1.GetEntityRequest],
 ) (*connect.Response[v1.GetEntityResponse], error) {
-	crd, ok := h.credentialsFromCtx(ctx)
-	if !ok {
+	var (
+		clm api.TokenClaims
+		err error
+	)
+
+	if req.Msg.Token != "" {
+		clm, err = h.api.ParseToken(ctx, req.Msg.Token, api.TokenTypeAccess)
+	} else {
+		crd, ok := h.credentialsFromCtx(ctx)
+		if !ok || (crd.Token == "" && crd.APIKey == "") {
+			return nil, connect.NewError(connect.CodeUnauthenticated, nil)
+		} else if crd.APIKey != "" {
+			clm, err = h.api.VerifyAPIKey(ctx, crd.APIKey)
+		} else {
+			clm, err = h.api.ParseToken(ctx, crd.Token, api.TokenTypeAccess)
+		}
+	}
+	if err != nil {
+		h.l.Warn().Err(err).Msg("parse access token failed")
 		return nil, connect.NewError(connect.CodeUnauthenticated, nil)
 	}
 
-	e, err := h.api.GetEntity(ctx, crd.Token)
-	if err != nil {
-		h.l.Warn().Err(err).Msg("get entity by token")
+	e, err := h.api.GetEntity(ctx, clm.Subject)
+	if errors.Is(err, api.ErrNotFound) {
+		h.l.Warn().Str("entity_id", clm.Subject).Msg("entity not found")
 		return nil, connect.NewError(connect.CodeUnauthenticated, nil)
+	} else if err != nil {
+		h.l.Error().Err(err).Str("entity_id", clm.Subject).Msg("failed to get entity")
+		return nil, connect.NewError(connect.CodeInternal, nil)
 	}
 
-	return connect.NewResponse(&v1.GetEntityResponse{Id: e.ID, Scope: e.Scope}), nil
+	return connect.NewResponse(&v1.GetEntityResponse{
+		Id:                  e.ID,
+		Login:               e.Login,
+		Scope:               e.Scope,
+		CreatedAt:           e.CreatedAt.Unix(),
+		UpdatedAt:           e.UpdatedAt.Unix(),
+		SecretChangedAt:     e.SecretChangedAt.Unix(),
+		LastAuthenticatedAt: unixTime(e.LastAuthenticatedAt),
+		FailedAuthCount:     int32(e.FailedAuthCount),
+		LockedUntil:         unixTime(e.LockedUntil),
+		TotpEnabled:         e.TOTPEnabled,
+		Disabled:            e.Disabled,
+	}), nil
 }
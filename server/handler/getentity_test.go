@@ -0,0 +1,94 @@
+package handler_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/rs/zerolog"
+	"github.com/rzajac/zltest"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/ashep/a23n/api"
+	"github.com/ashep/a23n/sdk/proto/a23n/v1"
+	"github.com/ashep/a23n/server/handler"
+)
+
+type GetEntityTestSuite struct {
+	suite.Suite
+
+	api     *api.APIMock
+	handler *handler.Handler
+	logger  *zltest.Tester
+}
+
+func (s *GetEntityTestSuite) SetupTest() {
+	lt := zltest.New(s.T())
+	l := lt.Logger().Level(zerolog.DebugLevel)
+
+	s.api = &api.APIMock{}
+	s.handler = handler.New(s.api, time.Second*5, time.Second*10, nil, nil, l)
+	s.logger = lt
+}
+
+func (s *GetEntityTestSuite) TearDownTest() {
+	s.api.AssertExpectations(s.T())
+}
+
+func (s *GetEntityTestSuite) expectToken() {
+	s.api.
+		On("ParseToken", mock.Anything, "theToken", api.TokenTypeAccess).
+		Return(api.TokenClaims{RegisteredClaims: jwt.RegisteredClaims{Subject: "entityID"}}, nil)
+}
+
+func (s *GetEntityTestSuite) TestUnauthenticated() {
+	_, err := s.handler.GetEntity(context.Background(), connect.NewRequest(&v1.GetEntityRequest{}))
+	s.Require().Equal(err, connect.NewError(connect.CodeUnauthenticated, nil))
+}
+
+func (s *GetEntityTestSuite) TestAPIError() {
+	s.expectToken()
+	s.api.On("GetEntity", mock.Anything, "entityID").Return(api.Entity{}, errors.New("theError"))
+
+	_, err := s.handler.GetEntity(context.Background(), connect.NewRequest(&v1.GetEntityRequest{Token: "theToken"}))
+	s.Require().Equal(err, connect.NewError(connect.CodeInternal, nil))
+
+	l := s.logger.LastEntry()
+	s.Require().NotNil(l)
+	s.Assert().Equal(`{"level":"error","error":"theError","entity_id":"entityID","message":"failed to get entity"}`, l.String())
+}
+
+func (s *GetEntityTestSuite) TestOK() {
+	s.expectToken()
+	s.api.On("GetEntity", mock.Anything, "entityID").Return(api.Entity{
+		ID:              "entityID",
+		Login:           "theLogin",
+		Scope:           api.Scope{"foo"},
+		Disabled:        true,
+		CreatedAt:       time.Unix(123456789, 0),
+		UpdatedAt:       time.Unix(123456790, 0),
+		SecretChangedAt: time.Unix(123456789, 0),
+		TOTPEnabled:     true,
+	}, nil)
+
+	r, err := s.handler.GetEntity(context.Background(), connect.NewRequest(&v1.GetEntityRequest{Token: "theToken"}))
+	s.Require().NoError(err)
+
+	s.Assert().Equal("entityID", r.Msg.Id)
+	s.Assert().Equal("theLogin", r.Msg.Login)
+	s.Assert().Equal([]string{"foo"}, r.Msg.Scope)
+	s.Assert().True(r.Msg.Disabled)
+	s.Assert().Equal(int64(123456789), r.Msg.CreatedAt)
+	s.Assert().Equal(int64(123456790), r.Msg.UpdatedAt)
+	s.Assert().Zero(r.Msg.LastAuthenticatedAt)
+	s.Assert().Zero(r.Msg.LockedUntil)
+	s.Assert().True(r.Msg.TotpEnabled)
+}
+
+func TestHandler_GetEntity(t *testing.T) {
+	suite.Run(t, new(GetEntityTestSuite))
+}
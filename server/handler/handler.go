@@ -2,27 +2,44 @@ package handler
 
 import (
 	"context"
+	"errors"
 	"time"
 
+	"github.com/bufbuild/connect-go"
 	"github.com/rs/zerolog"
 
 	"github.com/ashep/a23n/api"
+	v1 "github.com/ashep/a23n/sdk/proto/a23n/v1"
 	"github.com/ashep/a23n/server/credentials"
 )
 
 type Handler struct {
-	api             api.API
-	accessTokenTTL  time.Duration
-	refreshTokenTTL time.Duration
-	l               zerolog.Logger
+	api               api.API
+	accessTokenTTL    time.Duration
+	refreshTokenTTL   time.Duration
+	mfaScope          api.Scope
+	exchangeAudiences []string
+	l                 zerolog.Logger
 }
 
-func New(api api.API, accessTokenTTL, refreshTokenTTL time.Duration, l zerolog.Logger) *Handler {
+// New creates a handler. Scopes listed in mfaScope are granted only to entities which have passed the second
+// authentication factor. Tokens can be exchanged only for the audiences listed in exchangeAudiences besides the
+// service's own one.
+func New(
+	api api.API,
+	accessTokenTTL,
+	refreshTokenTTL time.Duration,
+	mfaScope api.Scope,
+	exchangeAudiences []string,
+	l zerolog.Logger,
+) *Handler {
 	return &Handler{
-		api:             api,
-		accessTokenTTL:  accessTokenTTL,
-		refreshTokenTTL: refreshTokenTTL,
-		l:               l,
+		api:               api,
+		accessTokenTTL:    accessTokenTTL,
+		refreshTokenTTL:   refreshTokenTTL,
+		mfaScope:          mfaScope,
+		exchangeAudiences: exchangeAudiences,
+		l:                 l,
 	}
 }
 
@@ -30,3 +47,29 @@ func (h *Handler) credentialsFromCtx(ctx context.Context) (credentials.Credentia
 	crd, ok := ctx.Value("crd").(credentials.Credentials)
 	return crd, ok
 }
+
+// invalidArgError converts err to an InvalidArgument error. Violated password policy rules, if any, are attached to it
+// as a PasswordPolicyViolation detail.
+func invalidArgError(err error) *connect.Error {
+	cErr := connect.NewError(connect.CodeInvalidArgument, err)
+
+	var argErr api.ErrInvalidArg
+	if !errors.As(err, &argErr) || len(argErr.Rules) == 0 {
+		return cErr
+	}
+
+	if d, dErr := connect.NewErrorDetail(&v1.PasswordPolicyViolation{Rules: argErr.Rules}); dErr == nil {
+		cErr.AddDetail(d)
+	}
+
+	return cErr
+}
+
+// unixTime converts t to a Unix timestamp keeping zero time as zero.
+func unixTime(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+
+	return t.Unix()
+}
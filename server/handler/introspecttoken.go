@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/bufbuild/connect-go"
+
+	"github.com/ashep/a23n/api"
+	v1 "github.com/ashep/a23n/sdk/proto/a23n/v1"
+)
+
+// IntrospectToken tells whether a token is active and describes it, following RFC 7662 semantics: a token which is
+// invalid for any reason is reported as inactive rather than as an error. Refresh tokens which have been used or
+// revoked are inactive as well. API keys and the tokens clients obtain for themselves are introspected too.
+func (h *Handler) IntrospectToken(
+	ctx context.Context,
+	req *connect.Request[v1.IntrospectTokenRequest],
+) (*connect.Response[v1.IntrospectTokenResponse], error) {
+	if req.Msg.Token == "" {
+		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("empty token"))
+	}
+
+	var (
+		clm api.TokenClaims
+		err error
+	)
+
+	if strings.HasPrefix(req.Msg.Token, api.APIKeyPrefix) {
+		clm, err = h.api.VerifyAPIKey(ctx, req.Msg.Token)
+	} else {
+		clm, err = h.parseAnyToken(ctx, req.Msg.Token, req.Msg.TokenTypeHint)
+	}
+	if err != nil {
+		h.l.Debug().Err(err).Msg("inactive token introspected")
+		return connect.NewResponse(&v1.IntrospectTokenResponse{Active: false}), nil
+	}
+
+	// Refresh tokens are valid only until they are used or their family is revoked
+	if clm.Type == api.TokenTypeRefresh {
+		active, err := h.api.IsRefreshTokenActive(ctx, req.Msg.Token)
+		if err != nil {
+			h.l.Error().Err(err).Str("entity_id", clm.Subject).Msg("failed to check refresh token")
+			return nil, connect.NewError(connect.CodeInternal, nil)
+		} else if !active {
+			h.l.Debug().Str("entity_id", clm.Subject).Msg("used or revoked refresh token introspected")
+			return connect.NewResponse(&v1.IntrospectTokenResponse{Active: false}), nil
+		}
+	}
+
+	// Clients are the subjects of the tokens they obtain for themselves, having no attributes
+	var e api.Entity
+	if clm.ClientID == "" || clm.Subject != clm.ClientID {
+		e, err = h.api.GetEntity(ctx, clm.Subject)
+		if errors.Is(err, api.ErrNotFound) {
+			h.l.Debug().Str("entity_id", clm.Subject).Msg("token of a non-existent entity introspected")
+			return connect.NewResponse(&v1.IntrospectTokenResponse{Active: false}), nil
+		} else if err != nil {
+			h.l.Error().Err(err).Str("entity_id", clm.Subject).Msg("failed to get entity")
+			return nil, connect.NewError(connect.CodeInternal, nil)
+		}
+	}
+
+	res := &v1.IntrospectTokenResponse{
+		Active:    true,
+		Subject:   clm.Subject,
+		Scope:     clm.Scope,
+		TokenType: string(clm.Type),
+		Attrs:     e.Attrs,
+		ClientId:  clm.ClientID,
+	}
+	if clm.Actor != nil {
+		res.Actor = clm.Actor.Subject
+	}
+	if clm.ExpiresAt != nil {
+		res.Expires = clm.ExpiresAt.Unix()
+	}
+	if clm.IssuedAt != nil {
+		res.IssuedAt = clm.IssuedAt.Unix()
+	}
+
+	return connect.NewResponse(res), nil
+}
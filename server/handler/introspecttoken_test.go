@@ -0,0 +1,200 @@
+package handler_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/rs/zerolog"
+	"github.com/rzajac/zltest"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/ashep/a23n/api"
+	"github.com/ashep/a23n/sdk/proto/a23n/v1"
+	"github.com/ashep/a23n/server/handler"
+)
+
+type IntrospectTokenTestSuite struct {
+	suite.Suite
+
+	api     *api.APIMock
+	handler *handler.Handler
+	logger  *zltest.Tester
+}
+
+func (s *IntrospectTokenTestSuite) SetupTest() {
+	lt := zltest.New(s.T())
+	l := lt.Logger().Level(zerolog.DebugLevel)
+
+	s.api = &api.APIMock{}
+	s.handler = handler.New(s.api, time.Second*5, time.Second*10, nil, []string{"theBackend"}, l)
+	s.logger = lt
+}
+
+func (s *IntrospectTokenTestSuite) TearDownTest() {
+	s.api.AssertExpectations(s.T())
+}
+
+func (s *IntrospectTokenTestSuite) TestEmptyToken() {
+	_, err := s.handler.IntrospectToken(context.Background(), connect.NewRequest(&v1.IntrospectTokenRequest{}))
+	s.Require().Equal(err, connect.NewError(connect.CodeInvalidArgument, errors.New("empty token")))
+}
+
+func (s *IntrospectTokenTestSuite) TestInvalidToken() {
+	s.api.
+		On("ParseTokenForAudiences", mock.Anything, "theToken", api.TokenTypeAccess, []string{"theBackend"}).
+		Return(api.TokenClaims{}, jwt.ErrTokenExpired)
+
+	r, err := s.handler.IntrospectToken(context.Background(), connect.NewRequest(&v1.IntrospectTokenRequest{
+		Token: "theToken",
+	}))
+	s.Require().NoError(err)
+	s.Assert().False(r.Msg.Active)
+	s.Assert().Empty(r.Msg.Subject)
+}
+
+func (s *IntrospectTokenTestSuite) TestEntityNotFound() {
+	s.api.
+		On("ParseTokenForAudiences", mock.Anything, "theToken", api.TokenTypeAccess, []string{"theBackend"}).
+		Return(api.TokenClaims{RegisteredClaims: jwt.RegisteredClaims{Subject: "entityID"}}, nil)
+
+	s.api.
+		On("GetEntity", mock.Anything, "entityID").
+		Return(api.Entity{}, api.ErrNotFound)
+
+	r, err := s.handler.IntrospectToken(context.Background(), connect.NewRequest(&v1.IntrospectTokenRequest{
+		Token: "theToken",
+	}))
+	s.Require().NoError(err)
+	s.Assert().False(r.Msg.Active)
+}
+
+func (s *IntrospectTokenTestSuite) TestWrongHint() {
+	s.api.
+		On("ParseTokenForAudiences", mock.Anything, "theToken", api.TokenTypeRefresh, []string{"theBackend"}).
+		Return(api.TokenClaims{}, api.ErrInvalidTokenType)
+
+	s.api.
+		On("ParseTokenForAudiences", mock.Anything, "theToken", api.TokenTypeAccess, []string{"theBackend"}).
+		Return(api.TokenClaims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Subject:   "entityID",
+				ExpiresAt: jwt.NewNumericDate(time.Unix(234567890, 0)),
+				IssuedAt:  jwt.NewNumericDate(time.Unix(123456789, 0)),
+			},
+			Type:  api.TokenTypeAccess,
+			Scope: []string{"theScope"},
+		}, nil)
+
+	s.api.
+		On("GetEntity", mock.Anything, "entityID").
+		Return(api.Entity{ID: "entityID", Attrs: api.Attrs{"theAttr": "theValue"}}, nil)
+
+	r, err := s.handler.IntrospectToken(context.Background(), connect.NewRequest(&v1.IntrospectTokenRequest{
+		Token:         "theToken",
+		TokenTypeHint: "refresh_token",
+	}))
+	s.Require().NoError(err)
+
+	s.Assert().True(r.Msg.Active)
+	s.Assert().Equal("entityID", r.Msg.Subject)
+	s.Assert().Equal([]string{"theScope"}, r.Msg.Scope)
+	s.Assert().Equal(int64(234567890), r.Msg.Expires)
+	s.Assert().Equal(int64(123456789), r.Msg.IssuedAt)
+	s.Assert().Equal("access", r.Msg.TokenType)
+	s.Assert().Equal(map[string]string{"theAttr": "theValue"}, r.Msg.Attrs)
+}
+
+func (s *IntrospectTokenTestSuite) TestUsedRefreshToken() {
+	s.api.
+		On("ParseTokenForAudiences", mock.Anything, "theToken", api.TokenTypeRefresh, []string{"theBackend"}).
+		Return(api.TokenClaims{
+			RegisteredClaims: jwt.RegisteredClaims{Subject: "entityID"},
+			Type:             api.TokenTypeRefresh,
+		}, nil)
+	s.api.
+		On("IsRefreshTokenActive", mock.Anything, "theToken").
+		Return(false, nil)
+
+	r, err := s.handler.IntrospectToken(context.Background(), connect.NewRequest(&v1.IntrospectTokenRequest{
+		Token:         "theToken",
+		TokenTypeHint: "refresh_token",
+	}))
+	s.Require().NoError(err)
+	s.Assert().False(r.Msg.Active)
+}
+
+func (s *IntrospectTokenTestSuite) TestActiveRefreshToken() {
+	s.api.
+		On("ParseTokenForAudiences", mock.Anything, "theToken", api.TokenTypeRefresh, []string{"theBackend"}).
+		Return(api.TokenClaims{
+			RegisteredClaims: jwt.RegisteredClaims{Subject: "entityID"},
+			Type:             api.TokenTypeRefresh,
+		}, nil)
+	s.api.
+		On("IsRefreshTokenActive", mock.Anything, "theToken").
+		Return(true, nil)
+	s.api.
+		On("GetEntity", mock.Anything, "entityID").
+		Return(api.Entity{ID: "entityID"}, nil)
+
+	r, err := s.handler.IntrospectToken(context.Background(), connect.NewRequest(&v1.IntrospectTokenRequest{
+		Token:         "theToken",
+		TokenTypeHint: "refresh_token",
+	}))
+	s.Require().NoError(err)
+	s.Assert().True(r.Msg.Active)
+	s.Assert().Equal("refresh", r.Msg.TokenType)
+}
+
+func (s *IntrospectTokenTestSuite) TestExchangedToken() {
+	s.api.
+		On("ParseTokenForAudiences", mock.Anything, "theToken", api.TokenTypeAccess, []string{"theBackend"}).
+		Return(api.TokenClaims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Subject:  "entityID",
+				Audience: jwt.ClaimStrings{"theBackend"},
+			},
+			Type:  api.TokenTypeAccess,
+			Actor: &api.Actor{Subject: "actorID"},
+		}, nil)
+	s.api.
+		On("GetEntity", mock.Anything, "entityID").
+		Return(api.Entity{ID: "entityID"}, nil)
+
+	r, err := s.handler.IntrospectToken(context.Background(), connect.NewRequest(&v1.IntrospectTokenRequest{
+		Token: "theToken",
+	}))
+	s.Require().NoError(err)
+	s.Assert().True(r.Msg.Active)
+	s.Assert().Equal("entityID", r.Msg.Subject)
+	s.Assert().Equal("actorID", r.Msg.Actor)
+}
+
+func (s *IntrospectTokenTestSuite) TestClientToken() {
+	// No entity is looked up for the tokens clients obtain for themselves
+	s.api.
+		On("ParseTokenForAudiences", mock.Anything, "theToken", api.TokenTypeAccess, []string{"theBackend"}).
+		Return(api.TokenClaims{
+			RegisteredClaims: jwt.RegisteredClaims{Subject: "theClient"},
+			Type:             api.TokenTypeAccess,
+			ClientID:         "theClient",
+		}, nil)
+
+	r, err := s.handler.IntrospectToken(context.Background(), connect.NewRequest(&v1.IntrospectTokenRequest{
+		Token: "theToken",
+	}))
+	s.Require().NoError(err)
+	s.Assert().True(r.Msg.Active)
+	s.Assert().Equal("theClient", r.Msg.Subject)
+	s.Assert().Equal("theClient", r.Msg.ClientId)
+	s.Assert().Empty(r.Msg.Attrs)
+}
+
+func TestHandler_IntrospectToken(t *testing.T) {
+	suite.Run(t, new(IntrospectTokenTestSuite))
+}
@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/bufbuild/connect-go"
+
+	"github.com/ashep/a23n/api"
+	v1 "github.com/ashep/a23n/sdk/proto/a23n/v1"
+)
+
+// ListAPIKeys returns the API keys of the entity the access token has been issued for.
+func (h *Handler) ListAPIKeys(
+	ctx context.Context,
+	_ *connect.Request[v1.ListAPIKeysRequest],
+) (*connect.Response[v1.ListAPIKeysResponse], error) {
+	crd, ok := h.credentialsFromCtx(ctx)
+	if !ok || crd.Token == "" {
+		return nil, connect.NewError(connect.CodeUnauthenticated, nil)
+	}
+
+	clm, err := h.api.ParseToken(ctx, crd.Token, api.TokenTypeAccess)
+	if err != nil {
+		h.l.Warn().Err(err).Msg("parse access token failed")
+		return nil, connect.NewError(connect.CodeUnauthenticated, nil)
+	}
+
+	keys, err := h.api.ListAPIKeys(ctx, clm.Subject)
+	if err != nil {
+		h.l.Error().Err(err).Str("entity_id", clm.Subject).Msg("list api keys failed")
+		return nil, connect.NewError(connect.CodeInternal, nil)
+	}
+
+	res := &v1.ListAPIKeysResponse{Keys: make([]*v1.APIKey, 0, len(keys))}
+	for _, k := range keys {
+		res.Keys = append(res.Keys, &v1.APIKey{
+			Id:         k.ID,
+			Name:       k.Name,
+			Scope:      k.Scope,
+			CreatedAt:  k.CreatedAt.Unix(),
+			ExpiresAt:  unixTime(k.ExpiresAt),
+			LastUsedAt: unixTime(k.LastUsedAt),
+		})
+	}
+
+	return connect.NewResponse(res), nil
+}
@@ -0,0 +1,125 @@
+package handler_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/rs/zerolog"
+	"github.com/rzajac/zltest"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/ashep/a23n/api"
+	"github.com/ashep/a23n/sdk/proto/a23n/v1"
+	"github.com/ashep/a23n/server/credentials"
+	"github.com/ashep/a23n/server/handler"
+)
+
+type ListAPIKeysTestSuite struct {
+	suite.Suite
+
+	api     *api.APIMock
+	handler *handler.Handler
+	logger  *zltest.Tester
+}
+
+func (s *ListAPIKeysTestSuite) SetupTest() {
+	lt := zltest.New(s.T())
+	l := lt.Logger().Level(zerolog.DebugLevel)
+
+	s.api = &api.APIMock{}
+	s.handler = handler.New(s.api, time.Second*5, time.Second*10, nil, nil, l)
+	s.logger = lt
+}
+
+func (s *ListAPIKeysTestSuite) TearDownTest() {
+	s.api.AssertExpectations(s.T())
+}
+
+func (s *ListAPIKeysTestSuite) list() (*connect.Response[v1.ListAPIKeysResponse], error) {
+	ctx := context.WithValue(context.Background(), "crd", credentials.Credentials{Token: "theAccessToken"})
+	return s.handler.ListAPIKeys(ctx, connect.NewRequest(&v1.ListAPIKeysRequest{}))
+}
+
+func (s *ListAPIKeysTestSuite) expectToken() {
+	s.api.
+		On("ParseToken", mock.Anything, "theAccessToken", api.TokenTypeAccess).
+		Return(api.TokenClaims{RegisteredClaims: jwt.RegisteredClaims{Subject: "entityID"}}, nil)
+}
+
+func (s *ListAPIKeysTestSuite) TestNoToken() {
+	// Keys cannot be used to list keys
+	ctx := context.WithValue(context.Background(), "crd", credentials.Credentials{APIKey: "a23n_theKey"})
+
+	_, err := s.handler.ListAPIKeys(ctx, connect.NewRequest(&v1.ListAPIKeysRequest{}))
+	s.Require().Equal(err, connect.NewError(connect.CodeUnauthenticated, nil))
+}
+
+func (s *ListAPIKeysTestSuite) TestInvalidToken() {
+	s.api.
+		On("ParseToken", mock.Anything, "theAccessToken", api.TokenTypeAccess).
+		Return(api.TokenClaims{}, errors.New("theError"))
+
+	_, err := s.list()
+	s.Require().Equal(err, connect.NewError(connect.CodeUnauthenticated, nil))
+
+	l := s.logger.LastEntry()
+	s.Require().NotNil(l)
+	s.Assert().Equal(`{"level":"warn","error":"theError","message":"parse access token failed"}`, l.String())
+}
+
+func (s *ListAPIKeysTestSuite) TestAPIError() {
+	s.expectToken()
+	s.api.On("ListAPIKeys", mock.Anything, "entityID").Return([]api.APIKey(nil), errors.New("theError"))
+
+	_, err := s.list()
+	s.Require().Equal(err, connect.NewError(connect.CodeInternal, nil))
+
+	l := s.logger.LastEntry()
+	s.Require().NotNil(l)
+	s.Assert().Equal(`{"level":"error","error":"theError","entity_id":"entityID","message":"list api keys failed"}`, l.String())
+}
+
+func (s *ListAPIKeysTestSuite) TestOK() {
+	s.expectToken()
+	s.api.On("ListAPIKeys", mock.Anything, "entityID").Return([]api.APIKey{
+		{
+			ID:         "theKeyID",
+			EntityID:   "entityID",
+			Name:       "theName",
+			Scope:      api.Scope{"foo"},
+			CreatedAt:  time.Unix(123456789, 0),
+			ExpiresAt:  time.Unix(234567890, 0),
+			LastUsedAt: time.Unix(123456790, 0),
+		},
+		{
+			ID:        "theOtherKeyID",
+			EntityID:  "entityID",
+			CreatedAt: time.Unix(123456789, 0),
+		},
+	}, nil)
+
+	r, err := s.list()
+	s.Require().NoError(err)
+
+	s.Require().Len(r.Msg.Keys, 2)
+	s.Assert().Equal("theKeyID", r.Msg.Keys[0].Id)
+	s.Assert().Equal("theName", r.Msg.Keys[0].Name)
+	s.Assert().Equal([]string{"foo"}, r.Msg.Keys[0].Scope)
+	s.Assert().Equal(int64(123456789), r.Msg.Keys[0].CreatedAt)
+	s.Assert().Equal(int64(234567890), r.Msg.Keys[0].ExpiresAt)
+	s.Assert().Equal(int64(123456790), r.Msg.Keys[0].LastUsedAt)
+
+	// Keys which never expire and have never been used have the times unset
+	s.Assert().Equal("theOtherKeyID", r.Msg.Keys[1].Id)
+	s.Assert().Zero(r.Msg.Keys[1].ExpiresAt)
+	s.Assert().Zero(r.Msg.Keys[1].LastUsedAt)
+}
+
+func TestHandler_ListAPIKeys(t *testing.T) {
+	suite.Run(t, new(ListAPIKeysTestSuite))
+}
@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/bufbuild/connect-go"
+
+	v1 "github.com/ashep/a23n/sdk/proto/a23n/v1"
+)
+
+// ListClients returns all the registered OAuth 2.0 clients.
+func (h *Handler) ListClients(
+	ctx context.Context,
+	_ *connect.Request[v1.ListClientsRequest],
+) (*connect.Response[v1.ListClientsResponse], error) {
+	clients, err := h.api.ListClients(ctx)
+	if err != nil {
+		h.l.Error().Err(err).Msg("list clients failed")
+		return nil, connect.NewError(connect.CodeInternal, nil)
+	}
+
+	res := &v1.ListClientsResponse{Clients: make([]*v1.OAuthClient, 0, len(clients))}
+	for _, c := range clients {
+		res.Clients = append(res.Clients, &v1.OAuthClient{
+			Id:              c.ID,
+			Name:            c.Name,
+			RedirectUris:    c.RedirectURIs,
+			GrantTypes:      c.GrantTypes,
+			Scope:           c.Scope,
+			AccessTokenTtl:  uint32(c.AccessTokenTTL.Seconds()),
+			RefreshTokenTtl: uint32(c.RefreshTokenTTL.Seconds()),
+			Confidential:    c.Confidential,
+			CreatedAt:       c.CreatedAt.Unix(),
+			UpdatedAt:       c.UpdatedAt.Unix(),
+		})
+	}
+
+	return connect.NewResponse(res), nil
+}
@@ -0,0 +1,109 @@
+package handler_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/rs/zerolog"
+	"github.com/rzajac/zltest"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/ashep/a23n/api"
+	"github.com/ashep/a23n/sdk/proto/a23n/v1"
+	"github.com/ashep/a23n/server/handler"
+)
+
+type ListClientsTestSuite struct {
+	suite.Suite
+
+	api     *api.APIMock
+	handler *handler.Handler
+	logger  *zltest.Tester
+}
+
+func (s *ListClientsTestSuite) SetupTest() {
+	lt := zltest.New(s.T())
+	l := lt.Logger().Level(zerolog.DebugLevel)
+
+	s.api = &api.APIMock{}
+	s.handler = handler.New(s.api, time.Second*5, time.Second*10, api.Scope{"admin"}, nil, l)
+	s.logger = lt
+}
+
+func (s *ListClientsTestSuite) TearDownTest() {
+	s.api.AssertExpectations(s.T())
+}
+
+func (s *ListClientsTestSuite) TestAPIError() {
+	s.api.On("ListClients", mock.Anything).Return([]api.Client(nil), errors.New("theError"))
+
+	_, err := s.handler.ListClients(context.Background(), connect.NewRequest(&v1.ListClientsRequest{}))
+	s.Require().Equal(err, connect.NewError(connect.CodeInternal, nil))
+
+	l := s.logger.LastEntry()
+	s.Require().NotNil(l)
+	s.Assert().Equal(`{"level":"error","error":"theError","message":"list clients failed"}`, l.String())
+}
+
+func (s *ListClientsTestSuite) TestEmpty() {
+	s.api.On("ListClients", mock.Anything).Return([]api.Client{}, nil)
+
+	res, err := s.handler.ListClients(context.Background(), connect.NewRequest(&v1.ListClientsRequest{}))
+	s.Require().NoError(err)
+	s.Assert().NotNil(res.Msg.Clients)
+	s.Assert().Empty(res.Msg.Clients)
+}
+
+func (s *ListClientsTestSuite) TestOK() {
+	s.api.On("ListClients", mock.Anything).Return([]api.Client{
+		{
+			ID:              "theClient",
+			Name:            "The Client",
+			RedirectURIs:    []string{"https://example.com/callback"},
+			GrantTypes:      []string{"authorization_code", "refresh_token"},
+			Scope:           api.Scope{"foo"},
+			AccessTokenTTL:  time.Minute,
+			RefreshTokenTTL: time.Hour,
+			Confidential:    true,
+			CreatedAt:       time.Unix(123456789, 0),
+			UpdatedAt:       time.Unix(123456790, 0),
+		},
+		{
+			ID:         "theOtherClient",
+			GrantTypes: []string{"password"},
+			CreatedAt:  time.Unix(123456789, 0),
+			UpdatedAt:  time.Unix(123456789, 0),
+		},
+	}, nil)
+
+	res, err := s.handler.ListClients(context.Background(), connect.NewRequest(&v1.ListClientsRequest{}))
+	s.Require().NoError(err)
+	s.Require().Len(res.Msg.Clients, 2)
+
+	c := res.Msg.Clients[0]
+	s.Assert().Equal("theClient", c.Id)
+	s.Assert().Equal("The Client", c.Name)
+	s.Assert().Equal([]string{"https://example.com/callback"}, c.RedirectUris)
+	s.Assert().Equal([]string{"authorization_code", "refresh_token"}, c.GrantTypes)
+	s.Assert().Equal([]string{"foo"}, c.Scope)
+	s.Assert().Equal(uint32(60), c.AccessTokenTtl)
+	s.Assert().Equal(uint32(3600), c.RefreshTokenTtl)
+	s.Assert().True(c.Confidential)
+	s.Assert().Equal(int64(123456789), c.CreatedAt)
+	s.Assert().Equal(int64(123456790), c.UpdatedAt)
+
+	// Public clients using the default token lifetimes
+	c = res.Msg.Clients[1]
+	s.Assert().Equal("theOtherClient", c.Id)
+	s.Assert().False(c.Confidential)
+	s.Assert().Zero(c.AccessTokenTtl)
+	s.Assert().Zero(c.RefreshTokenTtl)
+}
+
+func TestHandler_ListClients(t *testing.T) {
+	suite.Run(t, new(ListClientsTestSuite))
+}
@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	"github.com/bufbuild/connect-go"
+
+	"github.com/ashep/a23n/api"
+	v1 "github.com/ashep/a23n/sdk/proto/a23n/v1"
+)
+
+// ListEntities returns a page of entities matching the request filters ordered by creation time.
+func (h *Handler) ListEntities(
+	ctx context.Context,
+	req *connect.Request[v1.ListEntitiesRequest],
+) (*connect.Response[v1.ListEntitiesResponse], error) {
+	if req.Msg.Limit < 0 {
+		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("negative limit"))
+	}
+
+	filter := api.EntityFilter{Scope: req.Msg.Scope, Attrs: req.Msg.Attrs}
+
+	entities, next, err := h.api.ListEntities(ctx, filter, req.Msg.Cursor, int(req.Msg.Limit))
+	if errors.Is(err, api.ErrInvalidArg{}) {
+		return nil, connect.NewError(connect.CodeInvalidArgument, err)
+	} else if err != nil {
+		h.l.Error().Err(err).Msg("failed to list entities")
+		return nil, connect.NewError(connect.CodeInternal, nil)
+	}
+
+	res := &v1.ListEntitiesResponse{
+		Entities:   make([]*v1.Entity, 0, len(entities)),
+		NextCursor: next,
+	}
+
+	for _, e := range entities {
+		res.Entities = append(res.Entities, &v1.Entity{
+			Id:                  e.ID,
+			Login:               e.Login,
+			Scope:               e.Scope,
+			Attrs:               e.Attrs,
+			Disabled:            e.Disabled,
+			CreatedAt:           e.CreatedAt.Unix(),
+			UpdatedAt:           e.UpdatedAt.Unix(),
+			SecretChangedAt:     e.SecretChangedAt.Unix(),
+			LastAuthenticatedAt: unixTime(e.LastAuthenticatedAt),
+			FailedAuthCount:     int32(e.FailedAuthCount),
+			LockedUntil:         unixTime(e.LockedUntil),
+			TotpEnabled:         e.TOTPEnabled,
+		})
+	}
+
+	return connect.NewResponse(res), nil
+}
@@ -0,0 +1,105 @@
+package handler_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/rs/zerolog"
+	"github.com/rzajac/zltest"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/ashep/a23n/api"
+	"github.com/ashep/a23n/sdk/proto/a23n/v1"
+	"github.com/ashep/a23n/server/handler"
+)
+
+type ListEntitiesTestSuite struct {
+	suite.Suite
+
+	api     *api.APIMock
+	handler *handler.Handler
+	logger  *zltest.Tester
+}
+
+func (s *ListEntitiesTestSuite) SetupTest() {
+	lt := zltest.New(s.T())
+	l := lt.Logger().Level(zerolog.DebugLevel)
+
+	s.api = &api.APIMock{}
+	s.handler = handler.New(s.api, time.Second*5, time.Second*10, nil, nil, l)
+	s.logger = lt
+}
+
+func (s *ListEntitiesTestSuite) TearDownTest() {
+	s.api.AssertExpectations(s.T())
+}
+
+func (s *ListEntitiesTestSuite) TestNegativeLimit() {
+	_, err := s.handler.ListEntities(context.Background(), connect.NewRequest(&v1.ListEntitiesRequest{Limit: -1}))
+	s.Require().Equal(err, connect.NewError(connect.CodeInvalidArgument, errors.New("negative limit")))
+}
+
+func (s *ListEntitiesTestSuite) TestInvalidCursor() {
+	s.api.
+		On("ListEntities", mock.Anything, api.EntityFilter{}, "theCursor", 0).
+		Return([]api.Entity(nil), "", api.ErrInvalidArg{Msg: "invalid cursor"})
+
+	_, err := s.handler.ListEntities(context.Background(), connect.NewRequest(&v1.ListEntitiesRequest{
+		Cursor: "theCursor",
+	}))
+	s.Require().Equal(err, connect.NewError(connect.CodeInvalidArgument, api.ErrInvalidArg{Msg: "invalid cursor"}))
+}
+
+func (s *ListEntitiesTestSuite) TestAPIError() {
+	s.api.
+		On("ListEntities", mock.Anything, api.EntityFilter{}, "", 0).
+		Return([]api.Entity(nil), "", errors.New("theListEntitiesError"))
+
+	_, err := s.handler.ListEntities(context.Background(), connect.NewRequest(&v1.ListEntitiesRequest{}))
+	s.Require().Equal(err, connect.NewError(connect.CodeInternal, nil))
+
+	l := s.logger.LastEntry()
+	s.Require().NotNil(l)
+	s.Assert().Equal(`{"level":"error","error":"theListEntitiesError","message":"failed to list entities"}`, l.String())
+}
+
+func (s *ListEntitiesTestSuite) TestOK() {
+	filter := api.EntityFilter{Scope: api.Scope{"theScope"}, Attrs: api.Attrs{"theAttr": "theValue"}}
+
+	s.api.
+		On("ListEntities", mock.Anything, filter, "theCursor", 10).
+		Return([]api.Entity{{
+			ID:              "entityID",
+			Scope:           api.Scope{"theScope"},
+			Attrs:           api.Attrs{"theAttr": "theValue"},
+			CreatedAt:       time.Unix(123456789, 0),
+			UpdatedAt:       time.Unix(123456790, 0),
+			SecretChangedAt: time.Unix(123456791, 0),
+		}}, "theNextCursor", nil)
+
+	r, err := s.handler.ListEntities(context.Background(), connect.NewRequest(&v1.ListEntitiesRequest{
+		Scope:  []string{"theScope"},
+		Attrs:  map[string]string{"theAttr": "theValue"},
+		Cursor: "theCursor",
+		Limit:  10,
+	}))
+	s.Require().NoError(err)
+
+	s.Assert().Equal("theNextCursor", r.Msg.NextCursor)
+	s.Require().Len(r.Msg.Entities, 1)
+	s.Assert().Equal("entityID", r.Msg.Entities[0].Id)
+	s.Assert().Equal([]string{"theScope"}, r.Msg.Entities[0].Scope)
+	s.Assert().Equal(map[string]string{"theAttr": "theValue"}, r.Msg.Entities[0].Attrs)
+	s.Assert().Equal(int64(123456789), r.Msg.Entities[0].CreatedAt)
+	s.Assert().Equal(int64(123456790), r.Msg.Entities[0].UpdatedAt)
+	s.Assert().Equal(int64(123456791), r.Msg.Entities[0].SecretChangedAt)
+	s.Assert().Zero(r.Msg.Entities[0].LastAuthenticatedAt)
+}
+
+func TestHandler_ListEntities(t *testing.T) {
+	suite.Run(t, new(ListEntitiesTestSuite))
+}
@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	"github.com/bufbuild/connect-go"
+
+	"github.com/ashep/a23n/api"
+	v1 "github.com/ashep/a23n/sdk/proto/a23n/v1"
+)
+
+// Logout revokes the access token the request is authorized with and, optionally, a refresh token of the same entity.
+// Access tokens exchanged for any of the allowed audiences can be revoked as well.
+func (h *Handler) Logout(
+	ctx context.Context,
+	req *connect.Request[v1.LogoutRequest],
+) (*connect.Response[v1.LogoutResponse], error) {
+	crd, ok := h.credentialsFromCtx(ctx)
+	if !ok || crd.Token == "" {
+		return nil, connect.NewError(connect.CodeUnauthenticated, nil)
+	}
+
+	clm, err := h.api.ParseTokenForAudiences(ctx, crd.Token, api.TokenTypeAccess, h.exchangeAudiences)
+	if err != nil {
+		h.l.Warn().Err(err).Msg("parse access token failed")
+		return nil, connect.NewError(connect.CodeUnauthenticated, nil)
+	}
+
+	var rtClm api.TokenClaims
+	if req.Msg.RefreshToken != "" {
+		rtClm, err = h.api.ParseToken(ctx, req.Msg.RefreshToken, api.TokenTypeRefresh)
+		if err != nil {
+			return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("invalid refresh token"))
+		}
+		if rtClm.Subject != clm.Subject {
+			return nil, connect.NewError(connect.CodePermissionDenied, nil)
+		}
+	}
+
+	if err = h.api.RevokeToken(ctx, crd.Token, clm); err != nil {
+		h.l.Error().Err(err).Str("entity_id", clm.Subject).Msg("revoke access token failed")
+		return nil, connect.NewError(connect.CodeInternal, nil)
+	}
+
+	if req.Msg.RefreshToken != "" {
+		if err = h.api.RevokeToken(ctx, req.Msg.RefreshToken, rtClm); err != nil {
+			h.l.Error().Err(err).Str("entity_id", clm.Subject).Msg("revoke refresh token failed")
+			return nil, connect.NewError(connect.CodeInternal, nil)
+		}
+	}
+
+	h.l.Info().Str("entity_id", clm.Subject).Msg("logged out")
+
+	return connect.NewResponse(&v1.LogoutResponse{}), nil
+}
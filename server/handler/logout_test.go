@@ -0,0 +1,104 @@
+package handler_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/rs/zerolog"
+	"github.com/rzajac/zltest"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/ashep/a23n/api"
+	"github.com/ashep/a23n/sdk/proto/a23n/v1"
+	"github.com/ashep/a23n/server/credentials"
+	"github.com/ashep/a23n/server/handler"
+)
+
+type LogoutTestSuite struct {
+	suite.Suite
+
+	api     *api.APIMock
+	handler *handler.Handler
+	logger  *zltest.Tester
+}
+
+func (s *LogoutTestSuite) SetupTest() {
+	lt := zltest.New(s.T())
+	l := lt.Logger().Level(zerolog.DebugLevel)
+
+	s.api = &api.APIMock{}
+	s.handler = handler.New(s.api, time.Second*5, time.Second*10, nil, []string{"theBackend"}, l)
+	s.logger = lt
+}
+
+func (s *LogoutTestSuite) TearDownTest() {
+	s.api.AssertExpectations(s.T())
+}
+
+func (s *LogoutTestSuite) ctx() context.Context {
+	return context.WithValue(context.Background(), "crd", credentials.Credentials{Token: "theAccessToken"})
+}
+
+func (s *LogoutTestSuite) TestNoAuthorizationHeader() {
+	_, err := s.handler.Logout(context.Background(), connect.NewRequest(&v1.LogoutRequest{}))
+	s.Require().Equal(err, connect.NewError(connect.CodeUnauthenticated, nil))
+}
+
+func (s *LogoutTestSuite) TestInvalidAccessToken() {
+	s.api.
+		On("ParseTokenForAudiences", mock.Anything, "theAccessToken", api.TokenTypeAccess, []string{"theBackend"}).
+		Return(api.TokenClaims{}, errors.New("theParseTokenError"))
+
+	_, err := s.handler.Logout(s.ctx(), connect.NewRequest(&v1.LogoutRequest{}))
+	s.Require().Equal(err, connect.NewError(connect.CodeUnauthenticated, nil))
+}
+
+func (s *LogoutTestSuite) TestForeignRefreshToken() {
+	s.api.
+		On("ParseTokenForAudiences", mock.Anything, "theAccessToken", api.TokenTypeAccess, []string{"theBackend"}).
+		Return(api.TokenClaims{RegisteredClaims: jwt.RegisteredClaims{Subject: "entityID"}}, nil)
+
+	s.api.
+		On("ParseToken", mock.Anything, "theRefreshToken", api.TokenTypeRefresh).
+		Return(api.TokenClaims{RegisteredClaims: jwt.RegisteredClaims{Subject: "otherEntityID"}}, nil)
+
+	_, err := s.handler.Logout(s.ctx(), connect.NewRequest(&v1.LogoutRequest{RefreshToken: "theRefreshToken"}))
+	s.Require().Equal(err, connect.NewError(connect.CodePermissionDenied, nil))
+}
+
+func (s *LogoutTestSuite) TestOK() {
+	atClm := api.TokenClaims{RegisteredClaims: jwt.RegisteredClaims{ID: "atID", Subject: "entityID"}}
+	rtClm := api.TokenClaims{RegisteredClaims: jwt.RegisteredClaims{ID: "rtID", Subject: "entityID"}}
+
+	s.api.
+		On("ParseTokenForAudiences", mock.Anything, "theAccessToken", api.TokenTypeAccess, []string{"theBackend"}).
+		Return(atClm, nil)
+
+	s.api.
+		On("ParseToken", mock.Anything, "theRefreshToken", api.TokenTypeRefresh).
+		Return(rtClm, nil)
+
+	s.api.
+		On("RevokeToken", mock.Anything, "theAccessToken", atClm).
+		Return(nil)
+
+	s.api.
+		On("RevokeToken", mock.Anything, "theRefreshToken", rtClm).
+		Return(nil)
+
+	_, err := s.handler.Logout(s.ctx(), connect.NewRequest(&v1.LogoutRequest{RefreshToken: "theRefreshToken"}))
+	s.Require().NoError(err)
+
+	l := s.logger.LastEntry()
+	s.Require().NotNil(l)
+	s.Assert().Equal(`{"level":"info","entity_id":"entityID","message":"logged out"}`, l.String())
+}
+
+func TestHandler_Logout(t *testing.T) {
+	suite.Run(t, new(LogoutTestSuite))
+}
@@ -0,0 +1,343 @@
+package handler
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/bufbuild/connect-go"
+
+	"github.com/ashep/a23n/api"
+)
+
+// oauth2AuthorizeParams are the parameters of an authorization request which are passed through the login page
+var oauth2AuthorizeParams = []string{
+	"response_type",
+	"client_id",
+	"redirect_uri",
+	"scope",
+	"state",
+	"code_challenge",
+	"code_challenge_method",
+	"nonce",
+}
+
+// oauth2CSRFCookie holds the anti-CSRF token of the login page, which must be posted back along with the form, so
+// other sites cannot sign users in with credentials of their choice. A new token is issued on every render.
+const oauth2CSRFCookie = "a23n_oauth2_csrf"
+
+// oauth2CodeChallengeLength is the length of a base64url encoded SHA-256 digest, which S256 code challenges are
+const oauth2CodeChallengeLength = 43
+
+var oauth2AuthorizeTmpl = template.Must(template.New("authorize").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Sign in</title>
+<style>
+body { font-family: sans-serif; max-width: 24em; margin: 4em auto; padding: 0 1em; }
+label, input, button { display: block; width: 100%; box-sizing: border-box; margin-bottom: .5em; }
+.error { color: #b00020; }
+</style>
+</head>
+<body>
+{{- if .Fatal}}
+<p class="error">{{.Error}}</p>
+{{- else}}
+<h1>Sign in to {{.ClientName}}</h1>
+{{- if .Scope}}
+<p>The application requests access to:{{range .Scope}} <code>{{.}}</code>{{end}}</p>
+{{- end}}
+{{- if .Error}}
+<p class="error">{{.Error}}</p>
+{{- end}}
+<form method="post">
+<input type="hidden" name="csrf_token" value="{{.CSRFToken}}">
+{{- range $k, $v := .Params}}
+<input type="hidden" name="{{$k}}" value="{{$v}}">
+{{- end}}
+<label>Login or ID <input name="username" autocomplete="username" required></label>
+<label>Secret <input name="password" type="password" autocomplete="current-password" required></label>
+<label>TOTP code, if enabled <input name="totp_code" inputmode="numeric" autocomplete="one-time-code"></label>
+<button name="action" value="allow">Sign in and allow</button>
+<button name="action" value="deny" formnovalidate>Deny</button>
+</form>
+{{- end}}
+</body>
+</html>
+`))
+
+type oauth2AuthorizePage struct {
+	// Fatal is set if the request cannot be redirected back to the client
+	Fatal      bool
+	Error      string
+	ClientName string
+	Scope      []string
+	Params     map[string]string
+	CSRFToken  string
+}
+
+// OAuth2Authorize serves the OAuth 2.0 authorization endpoint supporting the authorization code grant, see RFC 6749,
+// section 4.1. Clients are public, so PKCE with the S256 method is required, see RFC 7636. Entities sign in on a login
+// page and are redirected back to the client with a code, which the client exchanges for tokens at the token endpoint.
+// The granted scope is the intersection of the requested scope and the entity scope, plus the requested OpenID Connect
+// scopes, limited by the scope the client is allowed. The login form is protected against CSRF by a token bound to a
+// cookie.
+func (h *Handler) OAuth2Authorize(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var params url.Values
+	switch r.Method {
+	case http.MethodGet:
+		params = r.URL.Query()
+	case http.MethodPost:
+		if err := r.ParseForm(); err != nil {
+			h.renderOAuth2AuthorizeError(w, r, http.StatusBadRequest, "Invalid request.")
+			return
+		}
+		params = r.PostForm
+	default:
+		w.Header().Set("Allow", http.MethodGet+", "+http.MethodPost)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	clientID := params.Get("client_id")
+	c, err := h.api.GetClient(ctx, clientID)
+	if errors.Is(err, api.ErrNotFound) {
+		h.renderOAuth2AuthorizeError(w, r, http.StatusBadRequest, "Unknown client.")
+		return
+	} else if err != nil {
+		h.l.Error().Err(err).Str("client_id", clientID).Msg("get client failed")
+		h.renderOAuth2AuthorizeError(w, r, http.StatusInternalServerError, "Internal error, try again later.")
+		return
+	}
+
+	// Requests having an invalid redirect URI must not be redirected anywhere, see RFC 6749, section 4.1.2.1
+	redirectURI, ok := oauth2RedirectURI(c, params.Get("redirect_uri"))
+	if !ok {
+		h.renderOAuth2AuthorizeError(w, r, http.StatusBadRequest, "Invalid redirect URI.")
+		return
+	}
+
+	state := params.Get("state")
+	if params.Get("response_type") != "code" {
+		oauth2Redirect(w, r, redirectURI, state, url.Values{"error": {"unsupported_response_type"}})
+		return
+	}
+	if !c.AllowsGrantType(api.GrantTypeAuthorizationCode) {
+		oauth2Redirect(w, r, redirectURI, state, url.Values{"error": {"unauthorized_client"}})
+		return
+	}
+	if params.Get("code_challenge") == "" || params.Get("code_challenge_method") != "S256" {
+		oauth2Redirect(w, r, redirectURI, state, url.Values{
+			"error":             {"invalid_request"},
+			"error_description": {"pkce with the S256 method required"},
+		})
+		return
+	}
+	if !validCodeChallenge(params.Get("code_challenge")) {
+		oauth2Redirect(w, r, redirectURI, state, url.Values{
+			"error":             {"invalid_request"},
+			"error_description": {"invalid code_challenge"},
+		})
+		return
+	}
+
+	page := oauth2AuthorizePage{
+		ClientName: c.Name,
+		Scope:      strings.Fields(params.Get("scope")),
+		Params:     make(map[string]string),
+	}
+	if page.ClientName == "" {
+		page.ClientName = c.ID
+	}
+	for _, k := range oauth2AuthorizeParams {
+		if v := params.Get(k); v != "" {
+			page.Params[k] = v
+		}
+	}
+
+	if r.Method == http.MethodGet {
+		h.renderOAuth2Authorize(w, r, http.StatusOK, page)
+		return
+	}
+
+	if !validCSRFToken(r, params.Get("csrf_token")) {
+		page.Error = "The page has expired, try again."
+		h.renderOAuth2Authorize(w, r, http.StatusForbidden, page)
+		return
+	}
+
+	if params.Get("action") == "deny" {
+		oauth2Redirect(w, r, redirectURI, state, url.Values{"error": {"access_denied"}})
+		return
+	}
+
+	source := peerHost(connect.Peer{Addr: r.RemoteAddr})
+
+	e, err := h.verifyCredentials(ctx, params.Get("username"), params.Get("password"), source)
+	if err == nil && e.TOTPEnabled {
+		if code := params.Get("totp_code"); code == "" {
+			page.Error = "Enter the TOTP code."
+		} else {
+			err = h.verifyTOTP(ctx, e.ID, code, source)
+		}
+	}
+	if err != nil {
+		page.Error = oauth2AuthorizeErrorMessage(err)
+	}
+	if page.Error != "" {
+		h.renderOAuth2Authorize(w, r, http.StatusOK, page)
+		return
+	}
+
+	granted := api.Scope(page.Scope).Intersect(withOIDCScopes(h.grantedScope(e, e.TOTPEnabled)))
+	code, err := h.api.CreateAuthorizationCode(ctx, api.AuthorizationCode{
+		ClientID:      c.ID,
+		EntityID:      e.ID,
+		RedirectURI:   params.Get("redirect_uri"),
+		Scope:         c.RestrictScope(granted),
+		CodeChallenge: params.Get("code_challenge"),
+		Nonce:         params.Get("nonce"),
+	})
+	if err != nil {
+		h.l.Error().Err(err).Str("entity_id", e.ID).Str("client_id", c.ID).Msg("create authorization code failed")
+		oauth2Redirect(w, r, redirectURI, state, url.Values{"error": {"server_error"}})
+		return
+	}
+
+	h.l.Info().Str("entity_id", e.ID).Str("client_id", c.ID).Msg("oauth2 authorization granted")
+
+	oauth2Redirect(w, r, redirectURI, state, url.Values{"code": {code}})
+}
+
+// oauth2RedirectURI returns the URI to redirect the client to. It must be one of the registered ones and can be omitted
+// only if the client has a single one.
+func oauth2RedirectURI(c api.Client, uri string) (string, bool) {
+	if uri == "" {
+		if len(c.RedirectURIs) == 1 {
+			return c.RedirectURIs[0], true
+		}
+		return "", false
+	}
+
+	for _, u := range c.RedirectURIs {
+		if u == uri {
+			return uri, true
+		}
+	}
+
+	return "", false
+}
+
+// oauth2Redirect sends an authorization response to the client adding params and state to the query of redirectURI.
+func oauth2Redirect(w http.ResponseWriter, r *http.Request, redirectURI, state string, params url.Values) {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	q := u.Query()
+	for k, v := range params {
+		q[k] = v
+	}
+	if state != "" {
+		q.Set("state", state)
+	}
+	u.RawQuery = q.Encode()
+
+	http.Redirect(w, r, u.String(), http.StatusSeeOther)
+}
+
+// OAuth2AuthorizeEntity returns the login an entity signs in with on the page of OAuth2Authorize, so requests can be
+// rate limited per entity.
+func OAuth2AuthorizeEntity(r *http.Request) string {
+	if r.Method != http.MethodPost || !parseRateLimitedForm(r) {
+		return ""
+	}
+
+	return r.PostForm.Get("username")
+}
+
+// validCodeChallenge checks whether c is a base64url encoded SHA-256 digest.
+func validCodeChallenge(c string) bool {
+	if len(c) != oauth2CodeChallengeLength {
+		return false
+	}
+
+	_, err := base64.RawURLEncoding.Strict().DecodeString(c)
+
+	return err == nil
+}
+
+// validCSRFToken checks whether token matches the anti-CSRF cookie set when the login page has been rendered.
+func validCSRFToken(r *http.Request, token string) bool {
+	c, err := r.Cookie(oauth2CSRFCookie)
+	if err != nil || c.Value == "" || token == "" {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(c.Value), []byte(token)) == 1
+}
+
+// oauth2AuthorizeErrorMessage converts errors of the credentials verification to messages shown on the login page.
+func oauth2AuthorizeErrorMessage(err error) string {
+	switch connect.CodeOf(err) {
+	case connect.CodeUnauthenticated:
+		return "Invalid credentials."
+	case connect.CodeResourceExhausted:
+		return "Too many failed attempts, try again later."
+	case connect.CodePermissionDenied:
+		return "Access denied."
+	case connect.CodeFailedPrecondition:
+		return "TOTP is not enabled."
+	default:
+		return "Internal error, try again later."
+	}
+}
+
+// renderOAuth2AuthorizeError renders a page telling about an error which cannot be reported to the client.
+func (h *Handler) renderOAuth2AuthorizeError(w http.ResponseWriter, r *http.Request, status int, msg string) {
+	h.renderOAuth2Authorize(w, r, status, oauth2AuthorizePage{Fatal: true, Error: msg})
+}
+
+// renderOAuth2Authorize renders the login page issuing a new anti-CSRF token for its form.
+func (h *Handler) renderOAuth2Authorize(w http.ResponseWriter, r *http.Request, status int, page oauth2AuthorizePage) {
+	if !page.Fatal {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			h.l.Error().Err(err).Msg("generate csrf token failed")
+			h.renderOAuth2AuthorizeError(w, r, http.StatusInternalServerError, "Internal error, try again later.")
+			return
+		}
+		page.CSRFToken = base64.RawURLEncoding.EncodeToString(b)
+
+		http.SetCookie(w, &http.Cookie{
+			Name:     oauth2CSRFCookie,
+			Value:    page.CSRFToken,
+			Path:     r.URL.Path,
+			Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+			HttpOnly: true,
+			SameSite: http.SameSiteStrictMode,
+		})
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	// The page must not be framed to prevent clickjacking
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; frame-ancestors 'none'")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.WriteHeader(status)
+
+	if err := oauth2AuthorizeTmpl.Execute(w, page); err != nil {
+		h.l.Error().Err(err).Msg("failed to render authorization page")
+	}
+}
@@ -0,0 +1,282 @@
+package handler_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rzajac/zltest"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/ashep/a23n/api"
+	"github.com/ashep/a23n/server/handler"
+)
+
+// oauth2CodeChallenge is the S256 code challenge of the "theCodeVerifier" code verifier
+const oauth2CodeChallenge = "zSUaN1Bz2z9nQSx_yO34FcqLhZ99vmJ7Tavml0Zv2EQ"
+
+type OAuth2AuthorizeTestSuite struct {
+	suite.Suite
+
+	api     *api.APIMock
+	handler *handler.Handler
+	logger  *zltest.Tester
+}
+
+func (s *OAuth2AuthorizeTestSuite) SetupTest() {
+	lt := zltest.New(s.T())
+	l := lt.Logger().Level(zerolog.DebugLevel)
+
+	s.api = &api.APIMock{}
+	s.handler = handler.New(s.api, time.Second*5, time.Second*10, api.Scope{"admin"}, nil, l)
+	s.logger = lt
+}
+
+func (s *OAuth2AuthorizeTestSuite) TearDownTest() {
+	s.api.AssertExpectations(s.T())
+}
+
+// params returns the parameters of a valid authorization request.
+func (s *OAuth2AuthorizeTestSuite) params() url.Values {
+	return url.Values{
+		"response_type":         {"code"},
+		"client_id":             {"theClient"},
+		"redirect_uri":          {"https://example.com/callback"},
+		"scope":                 {"foo admin"},
+		"state":                 {"theState"},
+		"code_challenge":        {oauth2CodeChallenge},
+		"code_challenge_method": {"S256"},
+	}
+}
+
+func (s *OAuth2AuthorizeTestSuite) expectClient() {
+	s.api.
+		On("GetClient", mock.Anything, "theClient").
+		Return(api.Client{
+			ID:           "theClient",
+			Name:         "The Client",
+			RedirectURIs: []string{"https://example.com/callback", "https://example.com/other"},
+			GrantTypes:   []string{"authorization_code"},
+		}, nil)
+}
+
+func (s *OAuth2AuthorizeTestSuite) get(params url.Values) (*http.Response, string) {
+	w := httptest.NewRecorder()
+	s.handler.OAuth2Authorize(w, httptest.NewRequest(http.MethodGet, "/oauth2/authorize?"+params.Encode(), nil))
+
+	res := w.Result()
+	b, err := io.ReadAll(res.Body)
+	s.Require().NoError(err)
+
+	return res, string(b)
+}
+
+// post submits the login form along with a valid anti-CSRF token.
+func (s *OAuth2AuthorizeTestSuite) post(params url.Values) (*http.Response, string) {
+	params.Set("csrf_token", "theCSRFToken")
+	return s.postWithCookie(params, "theCSRFToken")
+}
+
+func (s *OAuth2AuthorizeTestSuite) postWithCookie(params url.Values, csrfCookie string) (*http.Response, string) {
+	req := httptest.NewRequest(http.MethodPost, "/oauth2/authorize", strings.NewReader(params.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if csrfCookie != "" {
+		req.AddCookie(&http.Cookie{Name: "a23n_oauth2_csrf", Value: csrfCookie})
+	}
+
+	w := httptest.NewRecorder()
+	s.handler.OAuth2Authorize(w, req)
+
+	res := w.Result()
+	b, err := io.ReadAll(res.Body)
+	s.Require().NoError(err)
+
+	return res, string(b)
+}
+
+func (s *OAuth2AuthorizeTestSuite) TestUnknownClient() {
+	s.api.
+		On("GetClient", mock.Anything, "theClient").
+		Return(api.Client{}, api.ErrNotFound)
+
+	res, body := s.get(s.params())
+	s.Assert().Equal(http.StatusBadRequest, res.StatusCode)
+	s.Assert().Contains(body, "Unknown client.")
+}
+
+func (s *OAuth2AuthorizeTestSuite) TestUnregisteredRedirectURI() {
+	s.expectClient()
+
+	params := s.params()
+	params.Set("redirect_uri", "https://evil.example.com/callback")
+
+	// The request is not redirected to the unregistered URI
+	res, body := s.get(params)
+	s.Assert().Equal(http.StatusBadRequest, res.StatusCode)
+	s.Assert().Empty(res.Header.Get("Location"))
+	s.Assert().Contains(body, "Invalid redirect URI.")
+}
+
+func (s *OAuth2AuthorizeTestSuite) TestUnauthorizedClient() {
+	s.api.
+		On("GetClient", mock.Anything, "theClient").
+		Return(api.Client{
+			ID:           "theClient",
+			RedirectURIs: []string{"https://example.com/callback"},
+			GrantTypes:   []string{"password"},
+		}, nil)
+
+	res, _ := s.get(s.params())
+	s.Assert().Equal(http.StatusSeeOther, res.StatusCode)
+	s.Assert().Equal("https://example.com/callback?error=unauthorized_client&state=theState",
+		res.Header.Get("Location"))
+}
+
+func (s *OAuth2AuthorizeTestSuite) TestPKCERequired() {
+	s.expectClient()
+
+	params := s.params()
+	params.Set("code_challenge_method", "plain")
+
+	res, _ := s.get(params)
+	s.Assert().Equal(http.StatusSeeOther, res.StatusCode)
+	s.Assert().Equal("https://example.com/callback?error=invalid_request&"+
+		"error_description=pkce+with+the+S256+method+required&state=theState", res.Header.Get("Location"))
+}
+
+func (s *OAuth2AuthorizeTestSuite) TestInvalidCodeChallenge() {
+	s.expectClient()
+
+	for _, c := range []string{"theCodeChallenge", oauth2CodeChallenge + "A", oauth2CodeChallenge[:42] + "+"} {
+		params := s.params()
+		params.Set("code_challenge", c)
+
+		res, _ := s.get(params)
+		s.Assert().Equal(http.StatusSeeOther, res.StatusCode)
+		s.Assert().Equal("https://example.com/callback?error=invalid_request&"+
+			"error_description=invalid+code_challenge&state=theState", res.Header.Get("Location"))
+	}
+}
+
+func (s *OAuth2AuthorizeTestSuite) TestLoginPage() {
+	s.expectClient()
+
+	res, body := s.get(s.params())
+	s.Assert().Equal(http.StatusOK, res.StatusCode)
+	s.Assert().Equal("DENY", res.Header.Get("X-Frame-Options"))
+
+	// Every render issues a new anti-CSRF token
+	cookies := res.Cookies()
+	s.Require().Len(cookies, 1)
+	s.Assert().Equal("a23n_oauth2_csrf", cookies[0].Name)
+	s.Assert().Len(cookies[0].Value, 43)
+	s.Assert().True(cookies[0].HttpOnly)
+	s.Assert().Equal(http.SameSiteStrictMode, cookies[0].SameSite)
+	s.Assert().Contains(body, `<input type="hidden" name="csrf_token" value="`+cookies[0].Value+`">`)
+
+	res, _ = s.get(s.params())
+	s.Require().Len(res.Cookies(), 1)
+	s.Assert().NotEqual(cookies[0].Value, res.Cookies()[0].Value)
+	s.Assert().Contains(body, "Sign in to The Client")
+	s.Assert().Contains(body, `<input type="hidden" name="code_challenge" value="`+oauth2CodeChallenge+`">`)
+	s.Assert().Contains(body, `<input type="hidden" name="state" value="theState">`)
+}
+
+func (s *OAuth2AuthorizeTestSuite) TestCSRF() {
+	s.expectClient()
+
+	params := s.params()
+	params.Set("username", "theLogin")
+	params.Set("password", "theSecret")
+
+	// Forms posted by other sites have no cookie or do not know its token
+	for _, c := range []struct{ token, cookie string }{
+		{"", ""},
+		{"theCSRFToken", ""},
+		{"", "theCSRFToken"},
+		{"theCSRFToken", "otherCSRFToken"},
+	} {
+		params.Set("csrf_token", c.token)
+
+		res, body := s.postWithCookie(params, c.cookie)
+		s.Assert().Equal(http.StatusForbidden, res.StatusCode)
+		s.Assert().Contains(body, "The page has expired, try again.")
+		s.Assert().Len(res.Cookies(), 1)
+	}
+}
+
+func (s *OAuth2AuthorizeTestSuite) TestEntity() {
+	req := httptest.NewRequest(http.MethodPost, "/oauth2/authorize", strings.NewReader("username=theLogin"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	s.Assert().Equal("theLogin", handler.OAuth2AuthorizeEntity(req))
+
+	req = httptest.NewRequest(http.MethodGet, "/oauth2/authorize?username=theLogin", nil)
+	s.Assert().Empty(handler.OAuth2AuthorizeEntity(req))
+}
+
+func (s *OAuth2AuthorizeTestSuite) TestDeny() {
+	s.expectClient()
+
+	params := s.params()
+	params.Set("action", "deny")
+
+	res, _ := s.post(params)
+	s.Assert().Equal(http.StatusSeeOther, res.StatusCode)
+	s.Assert().Equal("https://example.com/callback?error=access_denied&state=theState", res.Header.Get("Location"))
+}
+
+func (s *OAuth2AuthorizeTestSuite) TestInvalidCredentials() {
+	s.expectClient()
+	s.api.
+		On("VerifyCredentials", mock.Anything, "theLogin", "wrongSecret", "192.0.2.1").
+		Return(api.Entity{}, api.ErrInvalidCredentials)
+
+	params := s.params()
+	params.Set("username", "theLogin")
+	params.Set("password", "wrongSecret")
+
+	res, body := s.post(params)
+	s.Assert().Equal(http.StatusOK, res.StatusCode)
+	s.Assert().Contains(body, "Invalid credentials.")
+	s.Assert().NotContains(body, "wrongSecret")
+}
+
+func (s *OAuth2AuthorizeTestSuite) TestOK() {
+	s.expectClient()
+	s.api.
+		On("VerifyCredentials", mock.Anything, "theLogin", "theSecret", "192.0.2.1").
+		Return(api.Entity{ID: "entityID", Scope: api.Scope{"foo", "bar"}}, nil)
+
+	// Only the requested scopes the entity has are granted
+	s.api.
+		On("CreateAuthorizationCode", mock.Anything, api.AuthorizationCode{
+			ClientID:      "theClient",
+			EntityID:      "entityID",
+			RedirectURI:   "https://example.com/callback",
+			Scope:         api.Scope{"foo"},
+			CodeChallenge: oauth2CodeChallenge,
+		}).
+		Return("theCode", nil)
+
+	params := s.params()
+	params.Set("username", "theLogin")
+	params.Set("password", "theSecret")
+
+	res, _ := s.post(params)
+	s.Assert().Equal(http.StatusSeeOther, res.StatusCode)
+	s.Assert().Equal("https://example.com/callback?code=theCode&state=theState", res.Header.Get("Location"))
+
+	l := s.logger.LastEntry()
+	s.Require().NotNil(l)
+	s.Assert().Equal(`{"level":"info","entity_id":"entityID","client_id":"theClient","message":"oauth2 authorization granted"}`, l.String())
+}
+
+func TestHandler_OAuth2Authorize(t *testing.T) {
+	suite.Run(t, new(OAuth2AuthorizeTestSuite))
+}
@@ -0,0 +1,460 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/google/uuid"
+
+	"github.com/ashep/a23n/api"
+)
+
+// oauth2Error is an error response of the OAuth 2.0 token endpoint, see RFC 6749, section 5.2.
+type oauth2Error struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+
+	retryAfter string
+}
+
+func (e oauth2Error) Error() string {
+	if e.Description == "" {
+		return e.Code
+	}
+
+	return e.Code + ": " + e.Description
+}
+
+// status returns the HTTP status code of the error response.
+func (e oauth2Error) status() int {
+	switch e.Code {
+	case "invalid_client":
+		return http.StatusUnauthorized
+	case "server_error":
+		return http.StatusInternalServerError
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+// toOAuth2Error converts errors returned by the parts of the handler shared with AuthService to OAuth 2.0 errors.
+// Authentication failures are reported with code.
+func toOAuth2Error(err error, code string) oauth2Error {
+	var oErr oauth2Error
+	if errors.As(err, &oErr) {
+		return oErr
+	}
+
+	var cErr *connect.Error
+	if !errors.As(err, &cErr) || cErr.Code() == connect.CodeInternal {
+		return oauth2Error{Code: "server_error"}
+	}
+
+	if cErr.Code() == connect.CodeInvalidArgument {
+		return oauth2Error{Code: "invalid_request", Description: cErr.Message()}
+	}
+
+	return oauth2Error{Code: code, Description: cErr.Message(), retryAfter: cErr.Meta().Get("Retry-After")}
+}
+
+type oauth2TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+}
+
+// OAuth2Token serves the OAuth 2.0 token endpoint supporting the client_credentials, password, refresh_token and
+// authorization_code grants, see RFC 6749. With the client_credentials grant, registered confidential clients obtain
+// tokens of their own, having the client as the subject, while other requests are made by entities authenticating by
+// themselves with their ID or login and secret either in the Basic authorization header or in the client_id and
+// client_secret parameters. Entities having TOTP enabled pass the code in the totp_code parameter. Registered clients
+// exchange codes issued by OAuth2Authorize with the authorization_code grant, getting an OpenID Connect ID token as
+// well if the openid scope has been granted. They can use the password and refresh_token grants as well, if they are
+// allowed to, while requests of these grants identifying no client obtain tokens issued to no client.
+func (h *Handler) OAuth2Token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	res, err := h.oauth2Token(r)
+	if err != nil {
+		h.writeOAuth2Error(w, r, toOAuth2Error(err, "server_error"))
+		return
+	}
+
+	h.writeOAuth2Response(w, http.StatusOK, res)
+}
+
+// OAuth2TokenEntity returns the entity a request to OAuth2Token authenticates, which is the user of the password grant
+// or the client of the client_credentials grant, so requests can be rate limited per entity. It is empty for other
+// grants, which are authenticated by codes and tokens too long to guess.
+func OAuth2TokenEntity(r *http.Request) string {
+	if !parseRateLimitedForm(r) {
+		return ""
+	}
+
+	switch r.PostForm.Get("grant_type") {
+	case "password":
+		return r.PostForm.Get("username")
+	case "client_credentials":
+		id, _, err := oauth2ClientCredentials(r)
+		if err != nil {
+			return ""
+		}
+		return id
+	default:
+		return ""
+	}
+}
+
+// parseRateLimitedForm parses the form of a request before it is passed to the handler, so it can be rate limited by
+// its parameters. The body is read anyway, so requests having invalid forms are left with no parameters to be rejected
+// by the handler.
+func parseRateLimitedForm(r *http.Request) bool {
+	if err := r.ParseForm(); err != nil {
+		r.Form, r.PostForm = nil, make(url.Values)
+		return false
+	}
+
+	return true
+}
+
+func (h *Handler) oauth2Token(r *http.Request) (oauth2TokenResponse, error) {
+	if err := r.ParseForm(); err != nil {
+		return oauth2TokenResponse{}, oauth2Error{Code: "invalid_request", Description: "invalid request body"}
+	}
+
+	for k, v := range r.PostForm {
+		if len(v) > 1 {
+			return oauth2TokenResponse{}, oauth2Error{Code: "invalid_request", Description: "repeated " + k}
+		}
+	}
+
+	var (
+		ctx    = r.Context()
+		grant  = r.PostForm.Get("grant_type")
+		scope  = strings.Fields(r.PostForm.Get("scope"))
+		source = peerHost(connect.Peer{Addr: r.RemoteAddr})
+		c      api.Client
+		e      api.Entity
+		ac     api.AuthorizationCode
+		family string
+		err    error
+	)
+
+	switch grant {
+	case "client_credentials":
+		id, secret, cErr := oauth2ClientCredentials(r)
+		if cErr != nil {
+			return oauth2TokenResponse{}, cErr
+		} else if id == "" {
+			return oauth2TokenResponse{}, oauth2Error{Code: "invalid_client", Description: "missing client credentials"}
+		}
+		if c, err = h.oauth2OwnClient(ctx, r, id, scope); err != nil {
+			return oauth2TokenResponse{}, err
+		} else if c.ID != "" {
+			e = api.Entity{ID: c.ID, Scope: c.Scope}
+			break
+		}
+		e, err = h.oauth2VerifyEntity(ctx, r, id, secret, scope, source)
+		if err != nil {
+			return oauth2TokenResponse{}, toOAuth2Error(err, "invalid_client")
+		}
+	case "password":
+		if c, err = h.oauth2VerifyClient(ctx, r, api.GrantTypePassword, false); err != nil {
+			return oauth2TokenResponse{}, err
+		}
+		username := r.PostForm.Get("username")
+		if username == "" {
+			return oauth2TokenResponse{}, oauth2Error{Code: "invalid_request", Description: "missing username"}
+		}
+		e, err = h.oauth2VerifyEntity(ctx, r, username, r.PostForm.Get("password"), scope, source)
+		if err != nil {
+			return oauth2TokenResponse{}, toOAuth2Error(err, "invalid_grant")
+		}
+		if c.ID != "" {
+			e.Scope = c.RestrictScope(e.Scope)
+			if !h.api.CheckScope(e.Scope, scope) {
+				return oauth2TokenResponse{}, oauth2Error{Code: "invalid_scope"}
+			}
+		}
+		family = uuid.NewString()
+	case "refresh_token":
+		if c, err = h.oauth2VerifyClient(ctx, r, api.GrantTypeRefreshToken, false); err != nil {
+			return oauth2TokenResponse{}, err
+		}
+		token := r.PostForm.Get("refresh_token")
+		if token == "" {
+			return oauth2TokenResponse{}, oauth2Error{Code: "invalid_request", Description: "missing refresh_token"}
+		}
+		// The scope is checked before the token is used up, invalid tokens are reported by useRefreshToken
+		if len(scope) > 0 {
+			clm, pErr := h.api.ParseToken(ctx, token, api.TokenTypeRefresh)
+			if pErr == nil && !h.api.CheckScope(clm.Scope, scope) {
+				return oauth2TokenResponse{}, oauth2Error{Code: "invalid_scope"}
+			}
+		}
+		e, family, err = h.useRefreshToken(ctx, token, c.ID)
+		if err != nil {
+			return oauth2TokenResponse{}, toOAuth2Error(err, "invalid_grant")
+		}
+		e.Scope = c.RestrictScope(e.Scope)
+	case "authorization_code":
+		if c, err = h.oauth2VerifyClient(ctx, r, api.GrantTypeAuthorizationCode, true); err != nil {
+			return oauth2TokenResponse{}, err
+		}
+		e, ac, err = h.oauth2UseAuthorizationCode(ctx, r, c.ID)
+		if err != nil {
+			return oauth2TokenResponse{}, toOAuth2Error(err, "invalid_grant")
+		}
+		e.Scope = c.RestrictScope(e.Scope)
+		family = uuid.NewString()
+	case "":
+		return oauth2TokenResponse{}, oauth2Error{Code: "invalid_request", Description: "missing grant_type"}
+	default:
+		return oauth2TokenResponse{}, oauth2Error{Code: "unsupported_grant_type"}
+	}
+
+	accessTTL, _ := h.tokenTTLs(c)
+	res := oauth2TokenResponse{
+		TokenType: "Bearer",
+		ExpiresIn: int64(accessTTL.Seconds()),
+		Scope:     strings.Join(e.Scope, " "),
+	}
+
+	// Refresh tokens are not issued to entities which can always authenticate by themselves, see RFC 6749, section
+	// 4.4.3, nor to clients not allowed to use them
+	if grant == "client_credentials" || c.ID != "" && !c.AllowsGrantType(api.GrantTypeRefreshToken) {
+		res.AccessToken, _, err = h.issueToken(api.TokenTypeAccess, e, c.ID, accessTTL)
+		if err != nil {
+			return oauth2TokenResponse{}, toOAuth2Error(err, "server_error")
+		}
+	} else {
+		tp, tErr := h.issueClientTokens(ctx, e, c, family)
+		if tErr != nil {
+			return oauth2TokenResponse{}, toOAuth2Error(tErr, "server_error")
+		}
+		res.AccessToken, res.RefreshToken = tp.access, tp.refresh
+	}
+
+	// ID tokens are not issued unless the service can act as an OpenID provider, see OpenIDConfiguration
+	if grant == "authorization_code" && h.api.CheckScope(e.Scope, api.Scope{"openid"}) {
+		t, tErr := h.api.CreateIDToken(c.ID, e.ID, ac.AuthTime, ac.Nonce, oidcClaims(e, e.Scope), accessTTL)
+		if tErr == nil {
+			res.IDToken, err = t.SignedString()
+		} else if !errors.Is(tErr, api.ErrIDTokenUnsupported) {
+			err = tErr
+		}
+		if err != nil {
+			h.l.Error().Err(err).Str("entity_id", e.ID).Msg("sign id token failed")
+			return oauth2TokenResponse{}, oauth2Error{Code: "server_error"}
+		}
+	}
+
+	l := h.l.Info().Str("entity_id", e.ID).Str("grant_type", grant)
+	if c.ID != "" {
+		l = l.Str("client_id", c.ID)
+	}
+	l.Msg("oauth2 token issued")
+
+	return res, nil
+}
+
+// oauth2VerifyClient identifies the OAuth 2.0 client making a request of a grant type. Confidential clients must
+// authenticate, public ones only pass their ID. If the client is not required, the zero client is returned for
+// requests identifying no client.
+func (h *Handler) oauth2VerifyClient(
+	ctx context.Context,
+	r *http.Request,
+	grant string,
+	required bool,
+) (api.Client, error) {
+	id, secret, err := oauth2ClientCredentials(r)
+	if err != nil {
+		return api.Client{}, err
+	} else if id == "" {
+		if required {
+			return api.Client{}, oauth2Error{Code: "invalid_request", Description: "missing client_id"}
+		}
+		return api.Client{}, nil
+	}
+
+	c, err := h.api.VerifyClient(ctx, id, secret)
+	if errors.Is(err, api.ErrInvalidCredentials) {
+		h.l.Warn().Str("client_id", id).Msg("invalid client credentials")
+		return api.Client{}, oauth2Error{Code: "invalid_client"}
+	} else if err != nil {
+		h.l.Error().Err(err).Str("client_id", id).Msg("verify client failed")
+		return api.Client{}, oauth2Error{Code: "server_error"}
+	}
+
+	if !c.AllowsGrantType(grant) {
+		return api.Client{}, oauth2Error{Code: "unauthorized_client"}
+	}
+
+	return c, nil
+}
+
+// oauth2OwnClient authenticates a registered client obtaining a token of its own by the client_credentials grant and
+// checks the requested scope. The zero client is returned if id does not refer to a registered client, so the request
+// is made by an entity authenticating by itself.
+func (h *Handler) oauth2OwnClient(ctx context.Context, r *http.Request, id string, scope []string) (api.Client, error) {
+	_, err := h.api.GetClient(ctx, id)
+	if errors.Is(err, api.ErrNotFound) {
+		return api.Client{}, nil
+	} else if err != nil {
+		h.l.Error().Err(err).Str("client_id", id).Msg("failed to get client")
+		return api.Client{}, oauth2Error{Code: "server_error"}
+	}
+
+	c, err := h.oauth2VerifyClient(ctx, r, api.GrantTypeClientCredentials, true)
+	if err != nil {
+		return api.Client{}, err
+	}
+
+	if !h.api.CheckScope(c.Scope, scope) {
+		return api.Client{}, oauth2Error{Code: "invalid_scope"}
+	}
+
+	return c, nil
+}
+
+// oauth2UseAuthorizationCode exchanges an authorization code issued to a client and returns the entity which has
+// granted it, with the scope to grant set, and the authorization the code describes.
+func (h *Handler) oauth2UseAuthorizationCode(
+	ctx context.Context,
+	r *http.Request,
+	clientID string,
+) (api.Entity, api.AuthorizationCode, error) {
+	code := r.PostForm.Get("code")
+	if code == "" {
+		return api.Entity{}, api.AuthorizationCode{}, oauth2Error{Code: "invalid_request", Description: "missing code"}
+	}
+
+	c, err := h.api.UseAuthorizationCode(ctx, code, clientID, r.PostForm.Get("redirect_uri"),
+		r.PostForm.Get("code_verifier"))
+	if errors.Is(err, api.ErrInvalidArg{}) {
+		return api.Entity{}, api.AuthorizationCode{}, oauth2Error{Code: "invalid_request", Description: err.Error()}
+	} else if errors.Is(err, api.ErrInvalidCredentials) {
+		h.l.Warn().Str("client_id", clientID).Msg("invalid authorization code")
+		return api.Entity{}, api.AuthorizationCode{}, oauth2Error{Code: "invalid_grant"}
+	} else if err != nil {
+		h.l.Error().Err(err).Str("client_id", clientID).Msg("use authorization code failed")
+		return api.Entity{}, api.AuthorizationCode{}, oauth2Error{Code: "server_error"}
+	}
+
+	e, err := h.api.GetEntity(ctx, c.EntityID)
+	if errors.Is(err, api.ErrNotFound) {
+		return api.Entity{}, api.AuthorizationCode{}, oauth2Error{Code: "invalid_grant"}
+	} else if err != nil {
+		h.l.Error().Err(err).Str("entity_id", c.EntityID).Msg("failed to get entity")
+		return api.Entity{}, api.AuthorizationCode{}, oauth2Error{Code: "server_error"}
+	}
+
+	if e.Disabled {
+		h.l.Warn().Str("entity_id", e.ID).Msg("entity disabled")
+		return api.Entity{}, api.AuthorizationCode{}, oauth2Error{
+			Code:        "invalid_grant",
+			Description: api.ErrEntityDisabled.Error(),
+		}
+	}
+
+	// The entity may have lost some scopes since the code has been issued
+	e.Scope = c.Scope.Intersect(withOIDCScopes(e.Scope))
+
+	return e, c, nil
+}
+
+// oauth2VerifyEntity checks the credentials of an entity and the requested scope, and returns the entity with the
+// scope to grant set.
+func (h *Handler) oauth2VerifyEntity(
+	ctx context.Context,
+	r *http.Request,
+	idOrLogin string,
+	secret string,
+	scope []string,
+	source string,
+) (api.Entity, error) {
+	e, err := h.verifyCredentials(ctx, idOrLogin, secret, source)
+	if err != nil {
+		return api.Entity{}, err
+	}
+
+	if !h.api.CheckScope(h.grantedScope(e, e.TOTPEnabled), scope) {
+		return api.Entity{}, oauth2Error{Code: "invalid_scope"}
+	}
+
+	if e.TOTPEnabled {
+		code := r.PostForm.Get("totp_code")
+		if code == "" {
+			return api.Entity{}, oauth2Error{Code: "invalid_grant", Description: "totp code required"}
+		}
+		if err = h.verifyTOTP(ctx, e.ID, code, source); err != nil {
+			return api.Entity{}, toOAuth2Error(err, "invalid_grant")
+		}
+	}
+
+	e.Scope = h.grantedScope(e, e.TOTPEnabled)
+
+	return e, nil
+}
+
+// oauth2ClientCredentials returns the client credentials passed either by the client_secret_basic or by the
+// client_secret_post authentication method.
+func oauth2ClientCredentials(r *http.Request) (string, string, error) {
+	id, secret, ok := r.BasicAuth()
+	if !ok {
+		return r.PostForm.Get("client_id"), r.PostForm.Get("client_secret"), nil
+	}
+
+	if r.PostForm.Has("client_secret") {
+		return "", "", oauth2Error{Code: "invalid_request", Description: "multiple client authentication methods"}
+	}
+
+	// Credentials are form-encoded before being put into the header, see RFC 6749, section 2.3.1
+	id, err := url.QueryUnescape(id)
+	if err != nil {
+		return "", "", oauth2Error{Code: "invalid_client", Description: "invalid client id encoding"}
+	}
+	secret, err = url.QueryUnescape(secret)
+	if err != nil {
+		return "", "", oauth2Error{Code: "invalid_client", Description: "invalid client secret encoding"}
+	}
+
+	return id, secret, nil
+}
+
+func (h *Handler) writeOAuth2Error(w http.ResponseWriter, r *http.Request, err oauth2Error) {
+	if err.Code == "invalid_client" {
+		if _, _, ok := r.BasicAuth(); ok {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf("Basic realm=%q", "a23n"))
+		}
+	}
+	if err.retryAfter != "" {
+		w.Header().Set("Retry-After", err.retryAfter)
+	}
+
+	h.writeOAuth2Response(w, err.status(), err)
+}
+
+func (h *Handler) writeOAuth2Response(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		h.l.Error().Err(err).Msg("failed to write oauth2 response")
+	}
+}
@@ -0,0 +1,631 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/rs/zerolog"
+	"github.com/rzajac/zltest"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/ashep/a23n/api"
+	"github.com/ashep/a23n/server/handler"
+)
+
+type OAuth2TokenTestSuite struct {
+	suite.Suite
+
+	api     *api.APIMock
+	handler *handler.Handler
+	logger  *zltest.Tester
+}
+
+func (s *OAuth2TokenTestSuite) SetupTest() {
+	lt := zltest.New(s.T())
+	l := lt.Logger().Level(zerolog.DebugLevel)
+
+	s.api = &api.APIMock{}
+	s.handler = handler.New(s.api, time.Second*5, time.Second*10, api.Scope{"admin"}, nil, l)
+	s.logger = lt
+}
+
+func (s *OAuth2TokenTestSuite) TearDownTest() {
+	s.api.AssertExpectations(s.T())
+}
+
+// request posts form to the token endpoint and returns the response along with its decoded body.
+func (s *OAuth2TokenTestSuite) request(
+	form url.Values,
+	clientID string,
+	clientSecret string,
+) (*http.Response, map[string]interface{}) {
+	req := httptest.NewRequest(http.MethodPost, "/oauth2/token", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if clientID != "" {
+		req.SetBasicAuth(url.QueryEscape(clientID), url.QueryEscape(clientSecret))
+	}
+
+	w := httptest.NewRecorder()
+	s.handler.OAuth2Token(w, req)
+
+	res := w.Result()
+	s.Assert().Equal("no-store", res.Header.Get("Cache-Control"))
+
+	body := make(map[string]interface{})
+	s.Require().NoError(json.NewDecoder(res.Body).Decode(&body))
+
+	return res, body
+}
+
+// expectToken expects a token of typ to be issued to the entity.
+func (s *OAuth2TokenTestSuite) expectToken(typ api.TokenType, scope []string, ttl time.Duration, token string) {
+	s.expectClientToken(typ, "", scope, ttl, token)
+}
+
+// expectClientToken expects a token of typ to be issued to the entity through a client.
+func (s *OAuth2TokenTestSuite) expectClientToken(
+	typ api.TokenType,
+	clientID string,
+	scope []string,
+	ttl time.Duration,
+	token string,
+) {
+	cl := &api.ClaimsMock{}
+	cl.On("GetExpirationTime").Return(&jwt.NumericDate{Time: time.Unix(123456789, 0).Add(ttl)}, nil)
+
+	t := &api.TokenMock{}
+	t.On("Claims").Return(cl)
+	t.On("SignedString").Return(token, nil)
+
+	s.api.On("CreateToken", typ, "entityID", clientID, scope, ttl).Return(t)
+}
+
+// expectClient expects a public client allowed to use grant types to identify itself.
+func (s *OAuth2TokenTestSuite) expectClient(grantTypes ...string) {
+	s.api.
+		On("VerifyClient", mock.Anything, "theClient", "").
+		Return(api.Client{ID: "theClient", GrantTypes: grantTypes}, nil)
+}
+
+func (s *OAuth2TokenTestSuite) TestMethodNotAllowed() {
+	w := httptest.NewRecorder()
+	s.handler.OAuth2Token(w, httptest.NewRequest(http.MethodGet, "/oauth2/token", nil))
+
+	s.Assert().Equal(http.StatusMethodNotAllowed, w.Code)
+	s.Assert().Equal(http.MethodPost, w.Header().Get("Allow"))
+}
+
+func (s *OAuth2TokenTestSuite) TestUnsupportedGrantType() {
+	res, body := s.request(url.Values{"grant_type": {"implicit"}}, "", "")
+
+	s.Assert().Equal(http.StatusBadRequest, res.StatusCode)
+	s.Assert().Equal(map[string]interface{}{"error": "unsupported_grant_type"}, body)
+}
+
+func (s *OAuth2TokenTestSuite) TestRepeatedParameter() {
+	res, body := s.request(url.Values{"grant_type": {"password", "password"}}, "", "")
+
+	s.Assert().Equal(http.StatusBadRequest, res.StatusCode)
+	s.Assert().Equal("invalid_request", body["error"])
+}
+
+func (s *OAuth2TokenTestSuite) TestClientCredentialsMissing() {
+	res, body := s.request(url.Values{"grant_type": {"client_credentials"}}, "", "")
+
+	s.Assert().Equal(http.StatusUnauthorized, res.StatusCode)
+	s.Assert().Equal("invalid_client", body["error"])
+	s.Assert().Empty(res.Header.Get("WWW-Authenticate"))
+}
+
+func (s *OAuth2TokenTestSuite) TestClientCredentialsMultipleMethods() {
+	res, body := s.request(url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_secret": {"theSecret"},
+	}, "entityID", "theSecret")
+
+	s.Assert().Equal(http.StatusBadRequest, res.StatusCode)
+	s.Assert().Equal("invalid_request", body["error"])
+}
+
+func (s *OAuth2TokenTestSuite) TestClientCredentialsInvalid() {
+	s.api.On("GetClient", mock.Anything, "entityID").Return(api.Client{}, api.ErrNotFound)
+	s.api.
+		On("VerifyCredentials", mock.Anything, "entityID", "the:Secret", "192.0.2.1").
+		Return(api.Entity{}, api.ErrInvalidCredentials)
+
+	res, body := s.request(url.Values{"grant_type": {"client_credentials"}}, "entityID", "the:Secret")
+
+	s.Assert().Equal(http.StatusUnauthorized, res.StatusCode)
+	s.Assert().Equal(map[string]interface{}{"error": "invalid_client"}, body)
+	s.Assert().Equal(`Basic realm="a23n"`, res.Header.Get("WWW-Authenticate"))
+}
+
+func (s *OAuth2TokenTestSuite) TestClientCredentialsOK() {
+	s.api.On("GetClient", mock.Anything, "entityID").Return(api.Client{}, api.ErrNotFound)
+	s.api.
+		On("VerifyCredentials", mock.Anything, "entityID", "theSecret", "192.0.2.1").
+		Return(api.Entity{ID: "entityID", Scope: api.Scope{"foo", "bar"}}, nil)
+	s.api.
+		On("CheckScope", api.Scope{"foo", "bar"}, api.Scope{"foo"}).
+		Return(true)
+	s.expectToken(api.TokenTypeAccess, []string{"foo", "bar"}, time.Second*5, "accessTokenSignedString")
+
+	res, body := s.request(url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {"entityID"},
+		"client_secret": {"theSecret"},
+		"scope":         {"foo"},
+	}, "", "")
+
+	// Refresh tokens are not issued for this grant
+	s.Assert().Equal(http.StatusOK, res.StatusCode)
+	s.Assert().Equal(map[string]interface{}{
+		"access_token": "accessTokenSignedString",
+		"token_type":   "Bearer",
+		"expires_in":   float64(5),
+		"scope":        "foo bar",
+	}, body)
+
+	l := s.logger.LastEntry()
+	s.Require().NotNil(l)
+	s.Assert().Equal(`{"level":"info","entity_id":"entityID","grant_type":"client_credentials","message":"oauth2 token issued"}`, l.String())
+}
+
+// expectOwnClient expects a registered confidential client allowed to use grant types to authenticate.
+func (s *OAuth2TokenTestSuite) expectOwnClient(grantTypes ...string) {
+	c := api.Client{
+		ID:             "theClient",
+		GrantTypes:     grantTypes,
+		Scope:          api.Scope{"foo", "bar"},
+		AccessTokenTTL: time.Minute,
+		Confidential:   true,
+	}
+	s.api.On("GetClient", mock.Anything, "theClient").Return(c, nil)
+	s.api.On("VerifyClient", mock.Anything, "theClient", "theClientSecret").Return(c, nil)
+}
+
+func (s *OAuth2TokenTestSuite) TestClientCredentialsOwnClientUnauthorized() {
+	s.expectOwnClient("authorization_code")
+
+	res, body := s.request(url.Values{"grant_type": {"client_credentials"}}, "theClient", "theClientSecret")
+
+	s.Assert().Equal(http.StatusBadRequest, res.StatusCode)
+	s.Assert().Equal(map[string]interface{}{"error": "unauthorized_client"}, body)
+}
+
+func (s *OAuth2TokenTestSuite) TestClientCredentialsOwnClientInvalidScope() {
+	s.expectOwnClient("client_credentials")
+	s.api.
+		On("CheckScope", api.Scope{"foo", "bar"}, api.Scope{"admin"}).
+		Return(false)
+
+	res, body := s.request(url.Values{
+		"grant_type": {"client_credentials"},
+		"scope":      {"admin"},
+	}, "theClient", "theClientSecret")
+
+	s.Assert().Equal(http.StatusBadRequest, res.StatusCode)
+	s.Assert().Equal(map[string]interface{}{"error": "invalid_scope"}, body)
+}
+
+func (s *OAuth2TokenTestSuite) TestClientCredentialsOwnClientOK() {
+	s.expectOwnClient("client_credentials")
+	s.api.
+		On("CheckScope", api.Scope{"foo", "bar"}, api.Scope{"foo"}).
+		Return(true)
+
+	// The client is the subject of its own token, which lives as long as configured for the client
+	cl := &api.ClaimsMock{}
+	cl.On("GetExpirationTime").Return(&jwt.NumericDate{Time: time.Unix(123456849, 0)}, nil)
+	t := &api.TokenMock{}
+	t.On("Claims").Return(cl)
+	t.On("SignedString").Return("accessTokenSignedString", nil)
+	s.api.
+		On("CreateToken", api.TokenTypeAccess, "theClient", "theClient", []string{"foo", "bar"}, time.Minute).
+		Return(t)
+
+	res, body := s.request(url.Values{
+		"grant_type": {"client_credentials"},
+		"scope":      {"foo"},
+	}, "theClient", "theClientSecret")
+
+	s.Assert().Equal(http.StatusOK, res.StatusCode)
+	s.Assert().Equal(map[string]interface{}{
+		"access_token": "accessTokenSignedString",
+		"token_type":   "Bearer",
+		"expires_in":   float64(60),
+		"scope":        "foo bar",
+	}, body)
+
+	l := s.logger.LastEntry()
+	s.Require().NotNil(l)
+	s.Assert().Equal(`{"level":"info","entity_id":"theClient","grant_type":"client_credentials","client_id":"theClient","message":"oauth2 token issued"}`, l.String())
+}
+
+func (s *OAuth2TokenTestSuite) TestPasswordLocked() {
+	s.api.
+		On("VerifyCredentials", mock.Anything, "theLogin", "theSecret", "192.0.2.1").
+		Return(api.Entity{}, api.ErrLocked{Until: time.Now().Add(90 * time.Second)})
+
+	res, body := s.request(url.Values{
+		"grant_type": {"password"},
+		"username":   {"theLogin"},
+		"password":   {"theSecret"},
+	}, "", "")
+
+	s.Assert().Equal(http.StatusBadRequest, res.StatusCode)
+	s.Assert().Equal(map[string]interface{}{"error": "invalid_grant", "error_description": "locked out"}, body)
+	s.Assert().Equal("90", res.Header.Get("Retry-After"))
+}
+
+func (s *OAuth2TokenTestSuite) TestPasswordInvalidScope() {
+	s.api.
+		On("VerifyCredentials", mock.Anything, "theLogin", "theSecret", "192.0.2.1").
+		Return(api.Entity{ID: "entityID", Scope: api.Scope{"foo"}}, nil)
+	s.api.
+		On("CheckScope", api.Scope{"foo"}, api.Scope{"bar"}).
+		Return(false)
+
+	res, body := s.request(url.Values{
+		"grant_type": {"password"},
+		"username":   {"theLogin"},
+		"password":   {"theSecret"},
+		"scope":      {"bar"},
+	}, "", "")
+
+	s.Assert().Equal(http.StatusBadRequest, res.StatusCode)
+	s.Assert().Equal(map[string]interface{}{"error": "invalid_scope"}, body)
+}
+
+func (s *OAuth2TokenTestSuite) TestPasswordTOTPRequired() {
+	s.api.
+		On("VerifyCredentials", mock.Anything, "theLogin", "theSecret", "192.0.2.1").
+		Return(api.Entity{ID: "entityID", Scope: api.Scope{"admin"}, TOTPEnabled: true}, nil)
+	s.api.
+		On("CheckScope", api.Scope{"admin"}, api.Scope{}).
+		Return(true)
+
+	res, body := s.request(url.Values{
+		"grant_type": {"password"},
+		"username":   {"theLogin"},
+		"password":   {"theSecret"},
+	}, "", "")
+
+	s.Assert().Equal(http.StatusBadRequest, res.StatusCode)
+	s.Assert().Equal(map[string]interface{}{"error": "invalid_grant", "error_description": "totp code required"}, body)
+}
+
+func (s *OAuth2TokenTestSuite) TestPasswordOK() {
+	s.api.
+		On("VerifyCredentials", mock.Anything, "theLogin", "theSecret", "192.0.2.1").
+		Return(api.Entity{ID: "entityID", Scope: api.Scope{"admin"}, TOTPEnabled: true}, nil)
+	s.api.
+		On("CheckScope", api.Scope{"admin"}, api.Scope{}).
+		Return(true)
+	s.api.
+		On("VerifyTOTP", mock.Anything, "entityID", "123456", "192.0.2.1").
+		Return(nil)
+	s.expectToken(api.TokenTypeAccess, []string{"admin"}, time.Second*5, "accessTokenSignedString")
+	s.expectToken(api.TokenTypeRefresh, []string{"admin"}, time.Second*10, "refreshTokenSignedString")
+	s.api.
+		On("SaveRefreshToken", mock.Anything, "refreshTokenSignedString", "entityID",
+			mock.AnythingOfType("string"), time.Unix(123456799, 0)).
+		Return(nil)
+
+	res, body := s.request(url.Values{
+		"grant_type": {"password"},
+		"username":   {"theLogin"},
+		"password":   {"theSecret"},
+		"totp_code":  {"123456"},
+	}, "", "")
+
+	s.Assert().Equal(http.StatusOK, res.StatusCode)
+	s.Assert().Equal(map[string]interface{}{
+		"access_token":  "accessTokenSignedString",
+		"token_type":    "Bearer",
+		"expires_in":    float64(5),
+		"refresh_token": "refreshTokenSignedString",
+		"scope":         "admin",
+	}, body)
+}
+
+func (s *OAuth2TokenTestSuite) TestRefreshTokenInvalidScope() {
+	s.api.
+		On("ParseToken", mock.Anything, "theRefreshToken", api.TokenTypeRefresh).
+		Return(api.TokenClaims{
+			RegisteredClaims: jwt.RegisteredClaims{Subject: "entityID"},
+			Scope:            []string{"foo"},
+		}, nil)
+	s.api.
+		On("CheckScope", api.Scope{"foo"}, api.Scope{"bar"}).
+		Return(false)
+
+	// The token is not used up
+	res, body := s.request(url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {"theRefreshToken"},
+		"scope":         {"bar"},
+	}, "", "")
+
+	s.Assert().Equal(http.StatusBadRequest, res.StatusCode)
+	s.Assert().Equal(map[string]interface{}{"error": "invalid_scope"}, body)
+}
+
+func (s *OAuth2TokenTestSuite) TestRefreshTokenReused() {
+	s.api.
+		On("ParseToken", mock.Anything, "theRefreshToken", api.TokenTypeRefresh).
+		Return(api.TokenClaims{RegisteredClaims: jwt.RegisteredClaims{Subject: "entityID"}}, nil)
+	s.api.
+		On("UseRefreshToken", mock.Anything, "theRefreshToken").
+		Return(api.RefreshTokenInfo{EntityID: "entityID", Family: "theFamily"}, api.ErrTokenReused)
+
+	res, body := s.request(url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {"theRefreshToken"},
+	}, "", "")
+
+	s.Assert().Equal(http.StatusBadRequest, res.StatusCode)
+	s.Assert().Equal(map[string]interface{}{"error": "invalid_grant"}, body)
+}
+
+func (s *OAuth2TokenTestSuite) TestAuthorizationCodeInvalid() {
+	s.expectClient("authorization_code")
+	s.api.
+		On("UseAuthorizationCode", mock.Anything, "theCode", "theClient", "https://example.com/callback",
+			"theVerifier").
+		Return(api.AuthorizationCode{}, api.ErrInvalidCredentials)
+
+	res, body := s.request(url.Values{
+		"grant_type":    {"authorization_code"},
+		"client_id":     {"theClient"},
+		"code":          {"theCode"},
+		"redirect_uri":  {"https://example.com/callback"},
+		"code_verifier": {"theVerifier"},
+	}, "", "")
+
+	s.Assert().Equal(http.StatusBadRequest, res.StatusCode)
+	s.Assert().Equal(map[string]interface{}{"error": "invalid_grant"}, body)
+}
+
+func (s *OAuth2TokenTestSuite) TestAuthorizationCodeOK() {
+	s.expectClient("authorization_code")
+	s.api.
+		On("UseAuthorizationCode", mock.Anything, "theCode", "theClient", "", "theVerifier").
+		Return(api.AuthorizationCode{EntityID: "entityID", Scope: api.Scope{"foo", "bar"}}, nil)
+	s.api.
+		On("GetEntity", mock.Anything, "entityID").
+		Return(api.Entity{ID: "entityID", Scope: api.Scope{"foo"}}, nil)
+
+	// The scope the entity has lost is not granted
+	s.expectClientToken(api.TokenTypeAccess, "theClient", []string{"foo"}, time.Second*5, "accessTokenSignedString")
+	s.api.On("CheckScope", api.Scope{"foo"}, api.Scope{"openid"}).Return(false)
+
+	res, body := s.request(url.Values{
+		"grant_type":    {"authorization_code"},
+		"client_id":     {"theClient"},
+		"code":          {"theCode"},
+		"code_verifier": {"theVerifier"},
+	}, "", "")
+
+	s.Assert().Equal(http.StatusOK, res.StatusCode)
+	s.Assert().Equal(map[string]interface{}{
+		"access_token": "accessTokenSignedString",
+		"token_type":   "Bearer",
+		"expires_in":   float64(5),
+		"scope":        "foo",
+	}, body)
+}
+
+// authorizationCodeOpenID exchanges a code having the openid scope for tokens, the ID token being created as idToken
+// along with idTokenErr.
+func (s *OAuth2TokenTestSuite) authorizationCodeOpenID(
+	idToken api.Token,
+	idTokenErr error,
+) (*http.Response, map[string]interface{}) {
+	authTime := time.Unix(123456700, 0)
+
+	s.expectClient("authorization_code")
+	s.api.
+		On("UseAuthorizationCode", mock.Anything, "theCode", "theClient", "", "theVerifier").
+		Return(api.AuthorizationCode{
+			ClientID: "theClient",
+			EntityID: "entityID",
+			Scope:    api.Scope{"openid", "email", "foo"},
+			Nonce:    "theNonce",
+			AuthTime: authTime,
+		}, nil)
+	s.api.
+		On("GetEntity", mock.Anything, "entityID").
+		Return(api.Entity{
+			ID:    "entityID",
+			Scope: api.Scope{"foo"},
+			Attrs: api.Attrs{"email": "user@example.com", "email_verified": "true", "name": "The User"},
+		}, nil)
+
+	// OpenID Connect scopes are granted regardless of the entity scope
+	s.expectClientToken(api.TokenTypeAccess, "theClient", []string{"openid", "email", "foo"}, time.Second*5,
+		"accessTokenSignedString")
+	s.api.On("CheckScope", api.Scope{"openid", "email", "foo"}, api.Scope{"openid"}).Return(true)
+
+	// Only the claims of the granted scopes are included
+	s.api.
+		On("CreateIDToken", "theClient", "entityID", authTime, "theNonce", map[string]interface{}{
+			"email":          "user@example.com",
+			"email_verified": true,
+		}, time.Second*5).
+		Return(idToken, idTokenErr)
+
+	return s.request(url.Values{
+		"grant_type":    {"authorization_code"},
+		"client_id":     {"theClient"},
+		"code":          {"theCode"},
+		"code_verifier": {"theVerifier"},
+	}, "", "")
+}
+
+func (s *OAuth2TokenTestSuite) TestAuthorizationCodeOpenID() {
+	idToken := &api.TokenMock{}
+	idToken.On("SignedString").Return("idTokenSignedString", nil)
+
+	res, body := s.authorizationCodeOpenID(idToken, nil)
+
+	s.Assert().Equal(http.StatusOK, res.StatusCode)
+	s.Assert().Equal(map[string]interface{}{
+		"access_token": "accessTokenSignedString",
+		"token_type":   "Bearer",
+		"expires_in":   float64(5),
+		"scope":        "openid email foo",
+		"id_token":     "idTokenSignedString",
+	}, body)
+}
+
+func (s *OAuth2TokenTestSuite) TestAuthorizationCodeOpenIDUnsupported() {
+	// The service cannot act as an OpenID provider, so only the access token is issued
+	res, body := s.authorizationCodeOpenID(nil, api.ErrIDTokenUnsupported)
+
+	s.Assert().Equal(http.StatusOK, res.StatusCode)
+	s.Assert().Equal(map[string]interface{}{
+		"access_token": "accessTokenSignedString",
+		"token_type":   "Bearer",
+		"expires_in":   float64(5),
+		"scope":        "openid email foo",
+	}, body)
+}
+
+func (s *OAuth2TokenTestSuite) TestClientInvalid() {
+	s.api.
+		On("VerifyClient", mock.Anything, "theClient", "wrongSecret").
+		Return(api.Client{}, api.ErrInvalidCredentials)
+
+	res, body := s.request(url.Values{
+		"grant_type": {"password"},
+		"username":   {"theLogin"},
+		"password":   {"theSecret"},
+	}, "theClient", "wrongSecret")
+
+	s.Assert().Equal(http.StatusUnauthorized, res.StatusCode)
+	s.Assert().Equal(map[string]interface{}{"error": "invalid_client"}, body)
+}
+
+func (s *OAuth2TokenTestSuite) TestClientUnauthorizedGrant() {
+	s.expectClient("authorization_code")
+
+	res, body := s.request(url.Values{
+		"grant_type": {"password"},
+		"client_id":  {"theClient"},
+		"username":   {"theLogin"},
+		"password":   {"theSecret"},
+	}, "", "")
+
+	s.Assert().Equal(http.StatusBadRequest, res.StatusCode)
+	s.Assert().Equal(map[string]interface{}{"error": "unauthorized_client"}, body)
+}
+
+func (s *OAuth2TokenTestSuite) TestRefreshTokenOtherClient() {
+	s.expectClient("refresh_token")
+	s.api.
+		On("ParseToken", mock.Anything, "theRefreshToken", api.TokenTypeRefresh).
+		Return(api.TokenClaims{
+			RegisteredClaims: jwt.RegisteredClaims{Subject: "entityID"},
+			ClientID:         "otherClient",
+		}, nil)
+
+	// The token is not used up
+	res, body := s.request(url.Values{
+		"grant_type":    {"refresh_token"},
+		"client_id":     {"theClient"},
+		"refresh_token": {"theRefreshToken"},
+	}, "", "")
+
+	s.Assert().Equal(http.StatusBadRequest, res.StatusCode)
+	s.Assert().Equal(map[string]interface{}{"error": "invalid_grant"}, body)
+}
+
+func (s *OAuth2TokenTestSuite) TestConfidentialClientOK() {
+	s.api.
+		On("VerifyClient", mock.Anything, "theClient", "theClientSecret").
+		Return(api.Client{
+			ID:             "theClient",
+			GrantTypes:     []string{"authorization_code", "refresh_token"},
+			Scope:          api.Scope{"foo", "openid"},
+			AccessTokenTTL: time.Minute,
+			Confidential:   true,
+		}, nil)
+	s.api.
+		On("UseAuthorizationCode", mock.Anything, "theCode", "theClient", "", "theVerifier").
+		Return(api.AuthorizationCode{EntityID: "entityID", Scope: api.Scope{"foo", "bar"}}, nil)
+	s.api.
+		On("GetEntity", mock.Anything, "entityID").
+		Return(api.Entity{ID: "entityID", Scope: api.Scope{"foo", "bar"}}, nil)
+
+	// The client is not allowed the bar scope anymore, its tokens live as long as configured for it
+	s.expectClientToken(api.TokenTypeAccess, "theClient", []string{"foo"}, time.Minute, "accessTokenSignedString")
+	s.expectClientToken(api.TokenTypeRefresh, "theClient", []string{"foo"}, time.Second*10,
+		"refreshTokenSignedString")
+	s.api.
+		On("SaveRefreshToken", mock.Anything, "refreshTokenSignedString", "entityID",
+			mock.AnythingOfType("string"), time.Unix(123456799, 0)).
+		Return(nil)
+	s.api.On("CheckScope", api.Scope{"foo"}, api.Scope{"openid"}).Return(false)
+
+	res, body := s.request(url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {"theCode"},
+		"code_verifier": {"theVerifier"},
+	}, "theClient", "theClientSecret")
+
+	s.Assert().Equal(http.StatusOK, res.StatusCode)
+	s.Assert().Equal(map[string]interface{}{
+		"access_token":  "accessTokenSignedString",
+		"token_type":    "Bearer",
+		"expires_in":    float64(60),
+		"refresh_token": "refreshTokenSignedString",
+		"scope":         "foo",
+	}, body)
+
+	l := s.logger.LastEntry()
+	s.Require().NotNil(l)
+	s.Assert().Equal(`{"level":"info","entity_id":"entityID","grant_type":"authorization_code","client_id":"theClient","message":"oauth2 token issued"}`, l.String())
+}
+
+func (s *OAuth2TokenTestSuite) TestEntity() {
+	entity := func(body, clientID string) string {
+		req := httptest.NewRequest(http.MethodPost, "/oauth2/token", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if clientID != "" {
+			req.SetBasicAuth(url.QueryEscape(clientID), "theSecret")
+		}
+
+		return handler.OAuth2TokenEntity(req)
+	}
+
+	s.Assert().Equal("theLogin", entity("grant_type=password&username=theLogin&client_id=theClient", ""))
+	s.Assert().Equal("the:Client", entity("grant_type=client_credentials", "the:Client"))
+	s.Assert().Equal("theClient", entity("grant_type=client_credentials&client_id=theClient", ""))
+	s.Assert().Empty(entity("grant_type=refresh_token&client_id=theClient", ""))
+	s.Assert().Empty(entity("grant_type=password&username=theLogin%zz", ""))
+}
+
+func (s *OAuth2TokenTestSuite) TestInvalidBodyAfterEntity() {
+	// The form is parsed to limit the rate of requests first, which must not make invalid requests pass
+	req := httptest.NewRequest(http.MethodPost, "/oauth2/token",
+		strings.NewReader("grant_type=client_credentials&client_id=%zz"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	s.Assert().Empty(handler.OAuth2TokenEntity(req))
+
+	w := httptest.NewRecorder()
+	s.handler.OAuth2Token(w, req)
+	s.Assert().Equal(http.StatusBadRequest, w.Code)
+	s.Assert().Contains(w.Body.String(), `"invalid_request"`)
+}
+
+func TestHandler_OAuth2Token(t *testing.T) {
+	suite.Run(t, new(OAuth2TokenTestSuite))
+}
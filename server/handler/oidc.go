@@ -0,0 +1,201 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ashep/a23n/api"
+)
+
+// oidcScopes are the OpenID Connect scopes, see OpenID Connect Core 1.0, section 5.4. Unlike other scopes, they are
+// granted to clients on request since they only allow reading the entity attributes.
+var oidcScopes = api.Scope{"openid", "profile", "email"}
+
+// oidcScopeClaims are the claims made available by the OpenID Connect scopes. Their values are taken from the entity
+// attributes having the same names.
+var oidcScopeClaims = map[string][]string{
+	"profile": {
+		"name", "family_name", "given_name", "middle_name", "nickname", "preferred_username", "profile", "picture",
+		"website", "gender", "birthdate", "zoneinfo", "locale",
+	},
+	"email": {"email", "email_verified"},
+}
+
+// withOIDCScopes returns s extended with the OpenID Connect scopes.
+func withOIDCScopes(s api.Scope) api.Scope {
+	r := make(api.Scope, 0, len(s)+len(oidcScopes))
+	r = append(r, s...)
+
+	return append(r, oidcScopes...)
+}
+
+// oidcClaims returns the claims about an entity made available by scope.
+func oidcClaims(e api.Entity, scope api.Scope) map[string]interface{} {
+	r := make(map[string]interface{})
+
+	for _, s := range scope {
+		for _, c := range oidcScopeClaims[s] {
+			v, ok := e.Attrs[c]
+			if c == "preferred_username" && !ok && e.Login != "" {
+				v, ok = e.Login, true
+			}
+			if !ok {
+				continue
+			}
+
+			if c == "email_verified" {
+				b, err := strconv.ParseBool(v)
+				if err != nil {
+					continue
+				}
+				r[c] = b
+			} else {
+				r[c] = v
+			}
+		}
+	}
+
+	return r
+}
+
+// UserInfo serves the OpenID Connect userinfo endpoint, see OpenID Connect Core 1.0, section 5.3. It returns the
+// claims about the entity an access token having the openid scope has been issued to.
+func (h *Handler) UserInfo(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodGet+", "+http.MethodPost)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx := r.Context()
+
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == r.Header.Get("Authorization") || token == "" {
+		// No error code is returned if the request lacks any authentication information, see RFC 6750, section 3.1
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	clm, err := h.api.ParseToken(ctx, token, api.TokenTypeAccess)
+	if err != nil {
+		h.l.Warn().Err(err).Msg("invalid userinfo token")
+		writeUserInfoError(w, http.StatusUnauthorized, "invalid_token")
+		return
+	}
+
+	if !h.api.CheckScope(clm.Scope, api.Scope{"openid"}) {
+		writeUserInfoError(w, http.StatusForbidden, "insufficient_scope")
+		return
+	}
+
+	e, err := h.api.GetEntity(ctx, clm.Subject)
+	if errors.Is(err, api.ErrNotFound) {
+		writeUserInfoError(w, http.StatusUnauthorized, "invalid_token")
+		return
+	} else if err != nil {
+		h.l.Error().Err(err).Str("entity_id", clm.Subject).Msg("failed to get entity")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if e.Disabled {
+		h.l.Warn().Str("entity_id", e.ID).Msg("entity disabled")
+		writeUserInfoError(w, http.StatusUnauthorized, "invalid_token")
+		return
+	}
+
+	res := oidcClaims(e, clm.Scope)
+	res["sub"] = e.ID
+
+	h.writeOAuth2Response(w, http.StatusOK, res)
+}
+
+// writeUserInfoError reports an error in the WWW-Authenticate header, see RFC 6750, section 3.
+func writeUserInfoError(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf("Bearer error=%q", code))
+	w.WriteHeader(status)
+}
+
+type openIDConfiguration struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserInfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+}
+
+// OpenIDConfiguration serves the OpenID Connect discovery document, see OpenID Connect Discovery 1.0, section 4. The
+// issuer must be an https URL with no path the service is reachable at, see api.ValidOIDCIssuer, and the signing keys
+// must be published in the JWKS, otherwise the service cannot act as an OpenID provider and the document is not
+// found.
+func (h *Handler) OpenIDConfiguration(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	iss := h.api.Issuer()
+	if !api.ValidOIDCIssuer(iss) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	var (
+		algs = make([]string, 0)
+		seen = make(map[string]bool)
+	)
+	for _, k := range h.api.JWKS().Keys {
+		if !seen[k.Alg] {
+			algs = append(algs, k.Alg)
+			seen[k.Alg] = true
+		}
+	}
+	if len(algs) == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	claims := []string{"sub"}
+	for _, s := range oidcScopes {
+		claims = append(claims, oidcScopeClaims[s]...)
+	}
+
+	base := strings.TrimSuffix(iss, "/")
+	grants := []string{"authorization_code", "client_credentials", "password", "refresh_token"}
+	res := openIDConfiguration{
+		Issuer:                            iss,
+		AuthorizationEndpoint:             base + "/oauth2/authorize",
+		TokenEndpoint:                     base + "/oauth2/token",
+		UserInfoEndpoint:                  base + "/userinfo",
+		JWKSURI:                           base + "/.well-known/jwks.json",
+		ScopesSupported:                   oidcScopes,
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               grants,
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  algs,
+		TokenEndpointAuthMethodsSupported: []string{"none", "client_secret_basic", "client_secret_post"},
+		CodeChallengeMethodsSupported:     []string{"S256"},
+		ClaimsSupported:                   claims,
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		h.l.Error().Err(err).Msg("failed to write openid configuration response")
+	}
+}
@@ -0,0 +1,145 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/rs/zerolog"
+	"github.com/rzajac/zltest"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/ashep/a23n/api"
+	"github.com/ashep/a23n/server/handler"
+)
+
+type OIDCTestSuite struct {
+	suite.Suite
+
+	api     *api.APIMock
+	handler *handler.Handler
+}
+
+func (s *OIDCTestSuite) SetupTest() {
+	l := zltest.New(s.T()).Logger().Level(zerolog.DebugLevel)
+
+	s.api = &api.APIMock{}
+	s.handler = handler.New(s.api, time.Second*5, time.Second*10, api.Scope{"admin"}, nil, l)
+}
+
+func (s *OIDCTestSuite) TearDownTest() {
+	s.api.AssertExpectations(s.T())
+}
+
+func (s *OIDCTestSuite) userInfo(token string) *http.Response {
+	req := httptest.NewRequest(http.MethodGet, "/userinfo", nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	w := httptest.NewRecorder()
+	s.handler.UserInfo(w, req)
+
+	return w.Result()
+}
+
+func (s *OIDCTestSuite) TestUserInfoNoToken() {
+	res := s.userInfo("")
+	s.Assert().Equal(http.StatusUnauthorized, res.StatusCode)
+	s.Assert().Equal("Bearer", res.Header.Get("WWW-Authenticate"))
+}
+
+func (s *OIDCTestSuite) TestUserInfoInvalidToken() {
+	s.api.
+		On("ParseToken", mock.Anything, "theToken", api.TokenTypeAccess).
+		Return(api.TokenClaims{}, errors.New("theError"))
+
+	res := s.userInfo("theToken")
+	s.Assert().Equal(http.StatusUnauthorized, res.StatusCode)
+	s.Assert().Equal(`Bearer error="invalid_token"`, res.Header.Get("WWW-Authenticate"))
+}
+
+func (s *OIDCTestSuite) TestUserInfoInsufficientScope() {
+	s.api.
+		On("ParseToken", mock.Anything, "theToken", api.TokenTypeAccess).
+		Return(api.TokenClaims{Scope: []string{"foo"}}, nil)
+	s.api.On("CheckScope", api.Scope{"foo"}, api.Scope{"openid"}).Return(false)
+
+	res := s.userInfo("theToken")
+	s.Assert().Equal(http.StatusForbidden, res.StatusCode)
+	s.Assert().Equal(`Bearer error="insufficient_scope"`, res.Header.Get("WWW-Authenticate"))
+}
+
+func (s *OIDCTestSuite) TestUserInfoOK() {
+	s.api.
+		On("ParseToken", mock.Anything, "theToken", api.TokenTypeAccess).
+		Return(api.TokenClaims{
+			RegisteredClaims: jwt.RegisteredClaims{Subject: "entityID"},
+			Scope:            []string{"openid", "profile"},
+		}, nil)
+	s.api.On("CheckScope", api.Scope{"openid", "profile"}, api.Scope{"openid"}).Return(true)
+	s.api.
+		On("GetEntity", mock.Anything, "entityID").
+		Return(api.Entity{
+			ID:    "entityID",
+			Login: "theLogin",
+			Attrs: api.Attrs{"name": "The User", "email": "user@example.com", "foo": "bar"},
+		}, nil)
+
+	res := s.userInfo("theToken")
+	s.Require().Equal(http.StatusOK, res.StatusCode)
+
+	// The email claim is not returned without the email scope, the login is the preferred username
+	body := make(map[string]interface{})
+	s.Require().NoError(json.NewDecoder(res.Body).Decode(&body))
+	s.Assert().Equal(map[string]interface{}{
+		"sub":                "entityID",
+		"name":               "The User",
+		"preferred_username": "theLogin",
+	}, body)
+}
+
+func (s *OIDCTestSuite) TestOpenIDConfigurationNotURLIssuer() {
+	s.api.On("Issuer").Return("a23n")
+
+	w := httptest.NewRecorder()
+	s.handler.OpenIDConfiguration(w, httptest.NewRequest(http.MethodGet, "/.well-known/openid-configuration", nil))
+	s.Assert().Equal(http.StatusNotFound, w.Code)
+}
+
+func (s *OIDCTestSuite) TestOpenIDConfigurationIssuerWithPath() {
+	// The endpoints are served at the root only, so they cannot be found relative to the issuer
+	s.api.On("Issuer").Return("https://example.com/auth")
+
+	w := httptest.NewRecorder()
+	s.handler.OpenIDConfiguration(w, httptest.NewRequest(http.MethodGet, "/.well-known/openid-configuration", nil))
+	s.Assert().Equal(http.StatusNotFound, w.Code)
+}
+
+func (s *OIDCTestSuite) TestOpenIDConfigurationOK() {
+	s.api.On("Issuer").Return("https://auth.example.com/")
+	s.api.On("JWKS").Return(api.JWKSet{Keys: []api.JWK{{Kid: "k1", Alg: "ES256"}, {Kid: "k2", Alg: "ES256"}}})
+
+	w := httptest.NewRecorder()
+	s.handler.OpenIDConfiguration(w, httptest.NewRequest(http.MethodGet, "/.well-known/openid-configuration", nil))
+	s.Require().Equal(http.StatusOK, w.Code)
+
+	body := make(map[string]interface{})
+	s.Require().NoError(json.NewDecoder(w.Body).Decode(&body))
+	s.Assert().Equal("https://auth.example.com/", body["issuer"])
+	s.Assert().Equal("https://auth.example.com/oauth2/authorize", body["authorization_endpoint"])
+	s.Assert().Equal("https://auth.example.com/oauth2/token", body["token_endpoint"])
+	s.Assert().Equal("https://auth.example.com/userinfo", body["userinfo_endpoint"])
+	s.Assert().Equal("https://auth.example.com/.well-known/jwks.json", body["jwks_uri"])
+	s.Assert().Equal([]interface{}{"ES256"}, body["id_token_signing_alg_values_supported"])
+	s.Assert().Equal([]interface{}{"S256"}, body["code_challenge_methods_supported"])
+}
+
+func TestHandler_OIDC(t *testing.T) {
+	suite.Run(t, new(OIDCTestSuite))
+}
@@ -2,15 +2,17 @@ package handler
 
 import (
 	"context"
+	"errors"
 
 	"github.com/bufbuild/connect-go"
 
+	"github.com/ashep/a23n/api"
 	v1 "github.com/ashep/a23n/sdk/proto/a23n/v1"
 )
 
 func (h *Handler) RefreshToken(
 	ctx context.Context,
-	req *connect.Request[v1.RefreshTokenRequest],
+	_ *connect.Request[v1.RefreshTokenRequest],
 ) (*connect.Response[v1.RefreshTokenResponse], error) {
 	crd, ok := h.credentialsFromCtx(ctx)
 	if !ok {
@@ -21,31 +23,87 @@ func (h *Handler) RefreshToken(
 		return nil, connect.NewError(connect.CodeUnauthenticated, nil)
 	}
 
-	e, err := h.api.GetEntity(ctx, "") // FIXME: wip
+	// Tokens issued to OAuth 2.0 clients are refreshed at the token endpoint, where clients authenticate
+	e, family, err := h.useRefreshToken(ctx, crd.Token, "")
 	if err != nil {
-		h.l.Error().Err(err).Msg("api.GetEntityByToken failed")
-		return nil, connect.NewError(connect.CodeUnauthenticated, nil)
+		return nil, err
 	}
 
-	// TODO: pass scope
-	t := h.api.CreateToken(e.ID, []string{}, 123)
-	ts, err := t.SignedString(h.api.SecretKey())
+	tp, err := h.issueTokens(ctx, e, family)
 	if err != nil {
-		h.l.Error().Err(err).Str("entity_id", e.ID).Msg("api.GetTokenSignedString failed")
-		return nil, connect.NewError(connect.CodeUnauthenticated, nil)
+		return nil, err
 	}
 
-	exp, err := t.Claims().GetExpirationTime()
+	h.l.Info().
+		Str("entity_id", e.ID).
+		Int64("access_token_expires", tp.accessExpires).
+		Int64("refresh_token_expires", tp.refreshExpires).
+		Msg("authenticated by refresh token")
+
+	return connect.NewResponse(&v1.RefreshTokenResponse{
+		Token:               tp.access,
+		TokenExpires:        tp.accessExpires,
+		RefreshToken:        tp.refresh,
+		RefreshTokenExpires: tp.refreshExpires,
+	}), nil
+}
+
+// useRefreshToken consumes a refresh token and returns the entity it has been issued for, with the scope to grant set,
+// along with the token family. The token must have been issued to the OAuth 2.0 client identified by clientID, which is
+// empty for tokens issued to no client. The errors are converted to the ones returned to clients.
+func (h *Handler) useRefreshToken(ctx context.Context, token, clientID string) (api.Entity, string, error) {
+	clm, err := h.api.ParseToken(ctx, token, api.TokenTypeRefresh)
 	if err != nil {
-		h.l.Error().Err(err).Str("entity_id", e.ID).Msg("token GetExpirationTime failed")
-		return nil, connect.NewError(connect.CodeUnauthenticated, nil)
+		h.l.Warn().Err(err).Msg("parse refresh token failed")
+		return api.Entity{}, "", connect.NewError(connect.CodeUnauthenticated, nil)
 	}
+	entityID := clm.Subject
 
-	h.l.Info().
-		Str("entity_id", e.ID).
-		Str("expires", exp.String()).
-		Msg("authenticated by token")
+	// Checked before the token is used up, so a client cannot burn tokens of other clients
+	if clm.ClientID != clientID {
+		h.l.Warn().Str("entity_id", entityID).Str("client_id", clientID).Msg("refresh token client mismatch")
+		return api.Entity{}, "", connect.NewError(connect.CodeUnauthenticated, nil)
+	}
 
-	return connect.NewResponse(&v1.RefreshTokenResponse{Token: ts, TokenExpires: exp.Unix()}), nil
+	rt, err := h.api.UseRefreshToken(ctx, token)
+	if errors.Is(err, api.ErrTokenReused) {
+		h.l.Warn().Str("entity_id", entityID).Str("family", rt.Family).Msg("refresh token reused, token family revoked")
+		return api.Entity{}, "", connect.NewError(connect.CodeUnauthenticated, nil)
+	} else if errors.Is(err, api.ErrNotFound) {
+		h.l.Warn().Str("entity_id", entityID).Msg("refresh token not found")
+		return api.Entity{}, "", connect.NewError(connect.CodeUnauthenticated, nil)
+	} else if err != nil {
+		h.l.Error().Err(err).Str("entity_id", entityID).Msg("use refresh token failed")
+		return api.Entity{}, "", connect.NewError(connect.CodeInternal, nil)
+	}
+
+	if rt.EntityID != entityID {
+		h.l.Warn().Str("entity_id", entityID).Msg("refresh token entity mismatch")
+		return api.Entity{}, "", connect.NewError(connect.CodeUnauthenticated, nil)
+	}
+
+	e, err := h.api.GetEntity(ctx, entityID)
+	if errors.Is(err, api.ErrNotFound) {
+		h.l.Warn().Str("entity_id", entityID).Msg("entity not found")
+		return api.Entity{}, "", connect.NewError(connect.CodeUnauthenticated, nil)
+	} else if err != nil {
+		h.l.Error().Err(err).Str("entity_id", entityID).Msg("failed to get entity")
+		return api.Entity{}, "", connect.NewError(connect.CodeUnauthenticated, nil)
+	}
+
+	if e.Disabled {
+		h.l.Warn().Str("entity_id", entityID).Msg("entity disabled")
+		return api.Entity{}, "", connect.NewError(connect.CodePermissionDenied, api.ErrEntityDisabled)
+	}
+
+	// Scopes requiring MFA are kept only if the session has been established with the second factor, be it TOTP or a
+	// passkey verifying the user
+	e.Scope = h.grantedScope(e, h.hasMFAScope(clm.Scope))
+
+	// Clients keep the scope the entity has granted them, as long as the entity still has it
+	if clientID != "" {
+		e.Scope = api.Scope(clm.Scope).Intersect(withOIDCScopes(e.Scope))
+	}
 
+	return e, rt.Family, nil
 }
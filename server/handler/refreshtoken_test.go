@@ -0,0 +1,287 @@
+package handler_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/rs/zerolog"
+	"github.com/rzajac/zltest"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/ashep/a23n/api"
+	"github.com/ashep/a23n/sdk/proto/a23n/v1"
+	"github.com/ashep/a23n/server/credentials"
+	"github.com/ashep/a23n/server/handler"
+)
+
+type RefreshTokenTestSuite struct {
+	suite.Suite
+
+	api     *api.APIMock
+	handler *handler.Handler
+	logger  *zltest.Tester
+}
+
+func (s *RefreshTokenTestSuite) SetupTest() {
+	lt := zltest.New(s.T())
+	l := lt.Logger().Level(zerolog.DebugLevel)
+
+	s.api = &api.APIMock{}
+	s.handler = handler.New(s.api, time.Second*5, time.Second*10, api.Scope{"admin"}, nil, l)
+	s.logger = lt
+}
+
+func (s *RefreshTokenTestSuite) TearDownTest() {
+	s.api.AssertExpectations(s.T())
+}
+
+func (s *RefreshTokenTestSuite) ctx() context.Context {
+	return context.WithValue(context.Background(), "crd", credentials.Credentials{Token: "theRefreshToken"})
+}
+
+func (s *RefreshTokenTestSuite) TestNoAuthorizationHeader() {
+	_, err := s.handler.RefreshToken(context.Background(), connect.NewRequest(&v1.RefreshTokenRequest{}))
+	s.Require().Equal(err, connect.NewError(connect.CodeUnauthenticated, nil))
+	s.Require().Nil(s.logger.LastEntry())
+}
+
+func (s *RefreshTokenTestSuite) TestEmptyToken() {
+	ctx := context.WithValue(context.Background(), "crd", credentials.Credentials{})
+
+	_, err := s.handler.RefreshToken(ctx, connect.NewRequest(&v1.RefreshTokenRequest{}))
+	s.Require().Equal(err, connect.NewError(connect.CodeUnauthenticated, nil))
+	s.Require().Nil(s.logger.LastEntry())
+}
+
+func (s *RefreshTokenTestSuite) TestParseTokenError() {
+	s.api.
+		On("ParseToken", mock.Anything, "theRefreshToken", api.TokenTypeRefresh).
+		Return(api.TokenClaims{}, errors.New("theParseTokenError"))
+
+	_, err := s.handler.RefreshToken(s.ctx(), connect.NewRequest(&v1.RefreshTokenRequest{}))
+	s.Require().Equal(err, connect.NewError(connect.CodeUnauthenticated, nil))
+
+	l := s.logger.LastEntry()
+	s.Require().NotNil(l)
+	s.Assert().Equal(`{"level":"warn","error":"theParseTokenError","message":"parse refresh token failed"}`, l.String())
+}
+
+func (s *RefreshTokenTestSuite) TestTokenReused() {
+	s.api.
+		On("ParseToken", mock.Anything, "theRefreshToken", api.TokenTypeRefresh).
+		Return(api.TokenClaims{RegisteredClaims: jwt.RegisteredClaims{Subject: "entityID"}}, nil)
+
+	s.api.
+		On("UseRefreshToken", mock.AnythingOfType("*context.valueCtx"), "theRefreshToken").
+		Return(api.RefreshTokenInfo{Family: "theFamily", EntityID: "entityID"}, api.ErrTokenReused)
+
+	_, err := s.handler.RefreshToken(s.ctx(), connect.NewRequest(&v1.RefreshTokenRequest{}))
+	s.Require().Equal(err, connect.NewError(connect.CodeUnauthenticated, nil))
+
+	l := s.logger.LastEntry()
+	s.Require().NotNil(l)
+	s.Assert().Equal(`{"level":"warn","entity_id":"entityID","family":"theFamily","message":"refresh token reused, token family revoked"}`, l.String())
+}
+
+func (s *RefreshTokenTestSuite) TestTokenNotFound() {
+	s.api.
+		On("ParseToken", mock.Anything, "theRefreshToken", api.TokenTypeRefresh).
+		Return(api.TokenClaims{RegisteredClaims: jwt.RegisteredClaims{Subject: "entityID"}}, nil)
+
+	s.api.
+		On("UseRefreshToken", mock.AnythingOfType("*context.valueCtx"), "theRefreshToken").
+		Return(api.RefreshTokenInfo{}, api.ErrNotFound)
+
+	_, err := s.handler.RefreshToken(s.ctx(), connect.NewRequest(&v1.RefreshTokenRequest{}))
+	s.Require().Equal(err, connect.NewError(connect.CodeUnauthenticated, nil))
+
+	l := s.logger.LastEntry()
+	s.Require().NotNil(l)
+	s.Assert().Equal(`{"level":"warn","entity_id":"entityID","message":"refresh token not found"}`, l.String())
+}
+
+func (s *RefreshTokenTestSuite) TestEntityDisabled() {
+	s.api.
+		On("ParseToken", mock.Anything, "theRefreshToken", api.TokenTypeRefresh).
+		Return(api.TokenClaims{RegisteredClaims: jwt.RegisteredClaims{Subject: "entityID"}}, nil)
+
+	s.api.
+		On("UseRefreshToken", mock.AnythingOfType("*context.valueCtx"), "theRefreshToken").
+		Return(api.RefreshTokenInfo{Family: "theFamily", EntityID: "entityID"}, nil)
+
+	s.api.
+		On("GetEntity", mock.AnythingOfType("*context.valueCtx"), "entityID").
+		Return(api.Entity{ID: "entityID", Disabled: true}, nil)
+
+	_, err := s.handler.RefreshToken(s.ctx(), connect.NewRequest(&v1.RefreshTokenRequest{}))
+	s.Require().Equal(err, connect.NewError(connect.CodePermissionDenied, api.ErrEntityDisabled))
+
+	l := s.logger.LastEntry()
+	s.Require().NotNil(l)
+	s.Assert().Equal(`{"level":"warn","entity_id":"entityID","message":"entity disabled"}`, l.String())
+}
+
+func (s *RefreshTokenTestSuite) TestOK() {
+	atCl := &api.ClaimsMock{}
+	atCl.On("GetExpirationTime").
+		Return(&jwt.NumericDate{Time: time.Unix(123456789, 0)}, nil)
+
+	at := &api.TokenMock{}
+	at.On("Claims").Return(atCl)
+	at.On("SignedString").Return("accessTokenSignedString", nil)
+
+	rtCl := &api.ClaimsMock{}
+	rtCl.On("GetExpirationTime").
+		Return(&jwt.NumericDate{Time: time.Unix(234567890, 0)}, nil)
+
+	rt := &api.TokenMock{}
+	rt.On("Claims").Return(rtCl)
+	rt.On("SignedString").Return("refreshTokenSignedString", nil)
+
+	s.api.
+		On("ParseToken", mock.Anything, "theRefreshToken", api.TokenTypeRefresh).
+		Return(api.TokenClaims{RegisteredClaims: jwt.RegisteredClaims{Subject: "entityID"}}, nil)
+
+	s.api.
+		On("UseRefreshToken", mock.AnythingOfType("*context.valueCtx"), "theRefreshToken").
+		Return(api.RefreshTokenInfo{Family: "theFamily", EntityID: "entityID"}, nil)
+
+	s.api.
+		On("GetEntity", mock.AnythingOfType("*context.valueCtx"), "entityID").
+		Return(api.Entity{ID: "entityID", Scope: api.Scope{"theScope"}}, nil)
+
+	s.api.
+		On("CreateToken", api.TokenTypeAccess, "entityID", "", []string{"theScope"}, time.Second*5).
+		Return(at)
+
+	s.api.
+		On("CreateToken", api.TokenTypeRefresh, "entityID", "", []string{"theScope"}, time.Second*10).
+		Return(rt)
+
+	s.api.
+		On("SaveRefreshToken", mock.AnythingOfType("*context.valueCtx"), "refreshTokenSignedString", "entityID",
+			"theFamily", time.Unix(234567890, 0)).
+		Return(nil)
+
+	r, err := s.handler.RefreshToken(s.ctx(), connect.NewRequest(&v1.RefreshTokenRequest{}))
+	s.Require().NoError(err)
+
+	s.Assert().Equal("accessTokenSignedString", r.Msg.Token)
+	s.Assert().Equal(int64(123456789), r.Msg.TokenExpires)
+	s.Assert().Equal("refreshTokenSignedString", r.Msg.RefreshToken)
+	s.Assert().Equal(int64(234567890), r.Msg.RefreshTokenExpires)
+
+	l := s.logger.LastEntry()
+	s.Require().NotNil(l)
+	s.Assert().Equal(`{"level":"info","entity_id":"entityID","access_token_expires":123456789,"refresh_token_expires":234567890,"message":"authenticated by refresh token"}`, l.String())
+}
+
+func (s *RefreshTokenTestSuite) TestMFAPasskeySession() {
+	atCl := &api.ClaimsMock{}
+	atCl.On("GetExpirationTime").
+		Return(&jwt.NumericDate{Time: time.Unix(123456789, 0)}, nil)
+
+	at := &api.TokenMock{}
+	at.On("Claims").Return(atCl)
+	at.On("SignedString").Return("accessTokenSignedString", nil)
+
+	rtCl := &api.ClaimsMock{}
+	rtCl.On("GetExpirationTime").
+		Return(&jwt.NumericDate{Time: time.Unix(234567890, 0)}, nil)
+
+	rt := &api.TokenMock{}
+	rt.On("Claims").Return(rtCl)
+	rt.On("SignedString").Return("refreshTokenSignedString", nil)
+
+	// The session has been established with a passkey verifying the user, so the entity has no TOTP enabled
+	s.api.
+		On("ParseToken", mock.Anything, "theRefreshToken", api.TokenTypeRefresh).
+		Return(api.TokenClaims{
+			RegisteredClaims: jwt.RegisteredClaims{Subject: "entityID"},
+			Scope:            []string{"theScope", "admin"},
+		}, nil)
+
+	s.api.
+		On("UseRefreshToken", mock.AnythingOfType("*context.valueCtx"), "theRefreshToken").
+		Return(api.RefreshTokenInfo{Family: "theFamily", EntityID: "entityID"}, nil)
+
+	s.api.
+		On("GetEntity", mock.AnythingOfType("*context.valueCtx"), "entityID").
+		Return(api.Entity{ID: "entityID", Scope: api.Scope{"theScope", "admin"}}, nil)
+
+	s.api.
+		On("CreateToken", api.TokenTypeAccess, "entityID", "", []string{"theScope", "admin"}, time.Second*5).
+		Return(at)
+
+	s.api.
+		On("CreateToken", api.TokenTypeRefresh, "entityID", "", []string{"theScope", "admin"}, time.Second*10).
+		Return(rt)
+
+	s.api.
+		On("SaveRefreshToken", mock.AnythingOfType("*context.valueCtx"), "refreshTokenSignedString", "entityID",
+			"theFamily", time.Unix(234567890, 0)).
+		Return(nil)
+
+	r, err := s.handler.RefreshToken(s.ctx(), connect.NewRequest(&v1.RefreshTokenRequest{}))
+	s.Require().NoError(err)
+	s.Assert().Equal("accessTokenSignedString", r.Msg.Token)
+}
+
+func (s *RefreshTokenTestSuite) TestNoMFASession() {
+	atCl := &api.ClaimsMock{}
+	atCl.On("GetExpirationTime").
+		Return(&jwt.NumericDate{Time: time.Unix(123456789, 0)}, nil)
+
+	at := &api.TokenMock{}
+	at.On("Claims").Return(atCl)
+	at.On("SignedString").Return("accessTokenSignedString", nil)
+
+	rtCl := &api.ClaimsMock{}
+	rtCl.On("GetExpirationTime").
+		Return(&jwt.NumericDate{Time: time.Unix(234567890, 0)}, nil)
+
+	rt := &api.TokenMock{}
+	rt.On("Claims").Return(rtCl)
+	rt.On("SignedString").Return("refreshTokenSignedString", nil)
+
+	s.api.
+		On("ParseToken", mock.Anything, "theRefreshToken", api.TokenTypeRefresh).
+		Return(api.TokenClaims{
+			RegisteredClaims: jwt.RegisteredClaims{Subject: "entityID"},
+			Scope:            []string{"theScope"},
+		}, nil)
+
+	s.api.
+		On("UseRefreshToken", mock.AnythingOfType("*context.valueCtx"), "theRefreshToken").
+		Return(api.RefreshTokenInfo{Family: "theFamily", EntityID: "entityID"}, nil)
+
+	// Enabling TOTP later does not upgrade a session established without it
+	s.api.
+		On("GetEntity", mock.AnythingOfType("*context.valueCtx"), "entityID").
+		Return(api.Entity{ID: "entityID", Scope: api.Scope{"theScope", "admin"}, TOTPEnabled: true}, nil)
+
+	s.api.
+		On("CreateToken", api.TokenTypeAccess, "entityID", "", []string{"theScope"}, time.Second*5).
+		Return(at)
+
+	s.api.
+		On("CreateToken", api.TokenTypeRefresh, "entityID", "", []string{"theScope"}, time.Second*10).
+		Return(rt)
+
+	s.api.
+		On("SaveRefreshToken", mock.AnythingOfType("*context.valueCtx"), "refreshTokenSignedString", "entityID",
+			"theFamily", time.Unix(234567890, 0)).
+		Return(nil)
+
+	_, err := s.handler.RefreshToken(s.ctx(), connect.NewRequest(&v1.RefreshTokenRequest{}))
+	s.Require().NoError(err)
+}
+
+func TestHandler_RefreshToken(t *testing.T) {
+	suite.Run(t, new(RefreshTokenTestSuite))
+}
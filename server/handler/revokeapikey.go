@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	"github.com/bufbuild/connect-go"
+
+	"github.com/ashep/a23n/api"
+	v1 "github.com/ashep/a23n/sdk/proto/a23n/v1"
+)
+
+// RevokeAPIKey revokes an API key of the entity the access token has been issued for.
+func (h *Handler) RevokeAPIKey(
+	ctx context.Context,
+	req *connect.Request[v1.RevokeAPIKeyRequest],
+) (*connect.Response[v1.RevokeAPIKeyResponse], error) {
+	crd, ok := h.credentialsFromCtx(ctx)
+	if !ok || crd.Token == "" {
+		return nil, connect.NewError(connect.CodeUnauthenticated, nil)
+	}
+
+	clm, err := h.api.ParseToken(ctx, crd.Token, api.TokenTypeAccess)
+	if err != nil {
+		h.l.Warn().Err(err).Msg("parse access token failed")
+		return nil, connect.NewError(connect.CodeUnauthenticated, nil)
+	}
+
+	err = h.api.RevokeAPIKey(ctx, clm.Subject, req.Msg.Id)
+	if errors.Is(err, api.ErrInvalidArg{}) {
+		return nil, connect.NewError(connect.CodeInvalidArgument, err)
+	} else if errors.Is(err, api.ErrNotFound) {
+		return nil, connect.NewError(connect.CodeNotFound, err)
+	} else if err != nil {
+		h.l.Error().Err(err).Str("entity_id", clm.Subject).Msg("revoke api key failed")
+		return nil, connect.NewError(connect.CodeInternal, nil)
+	}
+
+	h.l.Info().Str("entity_id", clm.Subject).Str("api_key_id", req.Msg.Id).Msg("api key revoked")
+
+	return connect.NewResponse(&v1.RevokeAPIKeyResponse{}), nil
+}
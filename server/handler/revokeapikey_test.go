@@ -0,0 +1,101 @@
+package handler_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/rs/zerolog"
+	"github.com/rzajac/zltest"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/ashep/a23n/api"
+	"github.com/ashep/a23n/sdk/proto/a23n/v1"
+	"github.com/ashep/a23n/server/credentials"
+	"github.com/ashep/a23n/server/handler"
+)
+
+type RevokeAPIKeyTestSuite struct {
+	suite.Suite
+
+	api     *api.APIMock
+	handler *handler.Handler
+	logger  *zltest.Tester
+}
+
+func (s *RevokeAPIKeyTestSuite) SetupTest() {
+	lt := zltest.New(s.T())
+	l := lt.Logger().Level(zerolog.DebugLevel)
+
+	s.api = &api.APIMock{}
+	s.handler = handler.New(s.api, time.Second*5, time.Second*10, nil, nil, l)
+	s.logger = lt
+}
+
+func (s *RevokeAPIKeyTestSuite) TearDownTest() {
+	s.api.AssertExpectations(s.T())
+}
+
+func (s *RevokeAPIKeyTestSuite) revoke(id string) error {
+	ctx := context.WithValue(context.Background(), "crd", credentials.Credentials{Token: "theAccessToken"})
+	_, err := s.handler.RevokeAPIKey(ctx, connect.NewRequest(&v1.RevokeAPIKeyRequest{Id: id}))
+
+	return err
+}
+
+func (s *RevokeAPIKeyTestSuite) expectRevoke(err error) {
+	s.api.
+		On("ParseToken", mock.Anything, "theAccessToken", api.TokenTypeAccess).
+		Return(api.TokenClaims{RegisteredClaims: jwt.RegisteredClaims{Subject: "entityID"}}, nil)
+	s.api.On("RevokeAPIKey", mock.Anything, "entityID", "theKeyID").Return(err)
+}
+
+func (s *RevokeAPIKeyTestSuite) TestNoToken() {
+	// Keys cannot be used to revoke keys
+	ctx := context.WithValue(context.Background(), "crd", credentials.Credentials{APIKey: "a23n_theKey"})
+
+	_, err := s.handler.RevokeAPIKey(ctx, connect.NewRequest(&v1.RevokeAPIKeyRequest{Id: "theKeyID"}))
+	s.Require().Equal(err, connect.NewError(connect.CodeUnauthenticated, nil))
+}
+
+func (s *RevokeAPIKeyTestSuite) TestInvalidID() {
+	expErr := api.ErrInvalidArg{Msg: "invalid id"}
+	s.expectRevoke(expErr)
+
+	s.Require().Equal(s.revoke("theKeyID"), connect.NewError(connect.CodeInvalidArgument, expErr))
+}
+
+func (s *RevokeAPIKeyTestSuite) TestNotFound() {
+	// Keys of other entities are not found as well
+	s.expectRevoke(api.ErrNotFound)
+
+	s.Require().Equal(s.revoke("theKeyID"), connect.NewError(connect.CodeNotFound, api.ErrNotFound))
+}
+
+func (s *RevokeAPIKeyTestSuite) TestAPIError() {
+	s.expectRevoke(errors.New("theError"))
+
+	s.Require().Equal(s.revoke("theKeyID"), connect.NewError(connect.CodeInternal, nil))
+
+	l := s.logger.LastEntry()
+	s.Require().NotNil(l)
+	s.Assert().Equal(`{"level":"error","error":"theError","entity_id":"entityID","message":"revoke api key failed"}`, l.String())
+}
+
+func (s *RevokeAPIKeyTestSuite) TestOK() {
+	s.expectRevoke(nil)
+
+	s.Require().NoError(s.revoke("theKeyID"))
+
+	l := s.logger.LastEntry()
+	s.Require().NotNil(l)
+	s.Assert().Equal(`{"level":"info","entity_id":"entityID","api_key_id":"theKeyID","message":"api key revoked"}`, l.String())
+}
+
+func TestHandler_RevokeAPIKey(t *testing.T) {
+	suite.Run(t, new(RevokeAPIKeyTestSuite))
+}
@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/google/uuid"
+
+	"github.com/ashep/a23n/api"
+	v1 "github.com/ashep/a23n/sdk/proto/a23n/v1"
+)
+
+// RevokeEntityTokens revokes all the tokens issued and the API keys created for an entity so far, e.g. when its
+// credentials are compromised.
+func (h *Handler) RevokeEntityTokens(
+	ctx context.Context,
+	req *connect.Request[v1.RevokeEntityTokensRequest],
+) (*connect.Response[v1.RevokeEntityTokensResponse], error) {
+	if _, err := uuid.Parse(req.Msg.EntityId); err != nil {
+		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("invalid entity id"))
+	}
+
+	err := h.api.RevokeEntityTokens(ctx, req.Msg.EntityId)
+	if errors.Is(err, api.ErrNotFound) {
+		return nil, connect.NewError(connect.CodeNotFound, err)
+	} else if err != nil {
+		h.l.Error().Err(err).Str("entity_id", req.Msg.EntityId).Msg("revoke entity tokens failed")
+		return nil, connect.NewError(connect.CodeInternal, nil)
+	}
+
+	h.l.Info().Str("entity_id", req.Msg.EntityId).Msg("entity tokens revoked")
+
+	return connect.NewResponse(&v1.RevokeEntityTokensResponse{}), nil
+}
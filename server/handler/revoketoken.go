@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	"github.com/bufbuild/connect-go"
+
+	v1 "github.com/ashep/a23n/sdk/proto/a23n/v1"
+)
+
+// RevokeToken revokes a token. Possession of a token is enough to revoke it. Following RFC 7009, invalid tokens are
+// not reported as errors, since there is nothing to revoke.
+func (h *Handler) RevokeToken(
+	ctx context.Context,
+	req *connect.Request[v1.RevokeTokenRequest],
+) (*connect.Response[v1.RevokeTokenResponse], error) {
+	if req.Msg.Token == "" {
+		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("empty token"))
+	}
+
+	clm, err := h.parseAnyToken(ctx, req.Msg.Token, req.Msg.TokenTypeHint)
+	if err != nil {
+		h.l.Debug().Err(err).Msg("invalid token revocation requested")
+		return connect.NewResponse(&v1.RevokeTokenResponse{}), nil
+	}
+
+	if err = h.api.RevokeToken(ctx, req.Msg.Token, clm); err != nil {
+		h.l.Error().Err(err).Str("entity_id", clm.Subject).Msg("revoke token failed")
+		return nil, connect.NewError(connect.CodeInternal, nil)
+	}
+
+	h.l.Info().
+		Str("entity_id", clm.Subject).
+		Str("token_id", clm.ID).
+		Str("token_type", string(clm.Type)).
+		Msg("token revoked")
+
+	return connect.NewResponse(&v1.RevokeTokenResponse{}), nil
+}
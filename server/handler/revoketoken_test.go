@@ -0,0 +1,88 @@
+package handler_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/rs/zerolog"
+	"github.com/rzajac/zltest"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/ashep/a23n/api"
+	"github.com/ashep/a23n/sdk/proto/a23n/v1"
+	"github.com/ashep/a23n/server/handler"
+)
+
+type RevokeTokenTestSuite struct {
+	suite.Suite
+
+	api     *api.APIMock
+	handler *handler.Handler
+	logger  *zltest.Tester
+}
+
+func (s *RevokeTokenTestSuite) SetupTest() {
+	lt := zltest.New(s.T())
+	l := lt.Logger().Level(zerolog.DebugLevel)
+
+	s.api = &api.APIMock{}
+	s.handler = handler.New(s.api, time.Second*5, time.Second*10, nil, []string{"theBackend"}, l)
+	s.logger = lt
+}
+
+func (s *RevokeTokenTestSuite) TearDownTest() {
+	s.api.AssertExpectations(s.T())
+}
+
+func (s *RevokeTokenTestSuite) TestEmptyToken() {
+	_, err := s.handler.RevokeToken(context.Background(), connect.NewRequest(&v1.RevokeTokenRequest{}))
+	s.Require().Equal(err, connect.NewError(connect.CodeInvalidArgument, errors.New("empty token")))
+}
+
+func (s *RevokeTokenTestSuite) TestInvalidToken() {
+	s.api.
+		On("ParseTokenForAudiences", mock.Anything, "theToken", api.TokenTypeAccess, []string{"theBackend"}).
+		Return(api.TokenClaims{}, jwt.ErrTokenInvalidAudience)
+
+	// Invalid tokens are not reported, since there is nothing to revoke
+	_, err := s.handler.RevokeToken(context.Background(), connect.NewRequest(&v1.RevokeTokenRequest{
+		Token: "theToken",
+	}))
+	s.Require().NoError(err)
+}
+
+func (s *RevokeTokenTestSuite) TestExchangedToken() {
+	clm := api.TokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:       "theTokenID",
+			Subject:  "entityID",
+			Audience: jwt.ClaimStrings{"theBackend"},
+		},
+		Type:  api.TokenTypeAccess,
+		Actor: &api.Actor{Subject: "actorID"},
+	}
+	s.api.
+		On("ParseTokenForAudiences", mock.Anything, "theToken", api.TokenTypeAccess, []string{"theBackend"}).
+		Return(clm, nil)
+	s.api.
+		On("RevokeToken", mock.Anything, "theToken", clm).
+		Return(nil)
+
+	_, err := s.handler.RevokeToken(context.Background(), connect.NewRequest(&v1.RevokeTokenRequest{
+		Token: "theToken",
+	}))
+	s.Require().NoError(err)
+
+	l := s.logger.LastEntry()
+	s.Require().NotNil(l)
+	s.Assert().Equal(`{"level":"info","entity_id":"entityID","token_id":"theTokenID","token_type":"access","message":"token revoked"}`, l.String())
+}
+
+func TestHandler_RevokeToken(t *testing.T) {
+	suite.Run(t, new(RevokeTokenTestSuite))
+}
@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/bufbuild/connect-go"
+
+	"github.com/ashep/a23n/api"
+	v1 "github.com/ashep/a23n/sdk/proto/a23n/v1"
+)
+
+// TokenExchange issues an access token for the subject of another one, see RFC 8693. It lets a service holding an
+// entity's token call another service on behalf of the entity with a narrower scope or a different audience. The caller
+// is the service, which is identified by its credentials and recorded in the act claim of the new token. It may pass
+// an actor token as well, which must have been issued to the caller itself. The audience must be either the service's
+// own one or one of the allowed ones.
+func (h *Handler) TokenExchange(
+	ctx context.Context,
+	req *connect.Request[v1.TokenExchangeRequest],
+) (*connect.Response[v1.TokenExchangeResponse], error) {
+	crd, ok := h.credentialsFromCtx(ctx)
+	if !ok || (crd.Token == "" && crd.APIKey == "") {
+		return nil, connect.NewError(connect.CodeUnauthenticated, nil)
+	}
+
+	var (
+		actClm api.TokenClaims
+		err    error
+	)
+	if crd.APIKey != "" {
+		actClm, err = h.api.VerifyAPIKey(ctx, crd.APIKey)
+	} else {
+		actClm, err = h.api.ParseToken(ctx, crd.Token, api.TokenTypeAccess)
+	}
+	if err != nil {
+		h.l.Warn().Err(err).Msg("parse actor credentials failed")
+		return nil, connect.NewError(connect.CodeUnauthenticated, nil)
+	}
+
+	// Exchanged tokens must always tell who acts on behalf of the subject
+	actor := actClm.Subject
+	if actor == "" {
+		h.l.Warn().Msg("token exchange actor is empty")
+		return nil, connect.NewError(connect.CodeUnauthenticated, nil)
+	}
+
+	if req.Msg.SubjectToken == "" {
+		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("empty subject token"))
+	}
+
+	// The actor token must have been issued to the caller, so nobody else can be made the actor
+	if req.Msg.ActorToken != "" {
+		atClm, err := h.api.ParseToken(ctx, req.Msg.ActorToken, api.TokenTypeAccess)
+		if err != nil {
+			h.l.Warn().Err(err).Str("actor_id", actor).Msg("parse actor token failed")
+			return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("invalid actor token"))
+		}
+		if atClm.Subject != actor {
+			h.l.Warn().Str("actor_id", actor).Str("actor_token_subject", atClm.Subject).Msg("actor token mismatch")
+			return nil, connect.NewError(connect.CodePermissionDenied, nil)
+		}
+	}
+
+	if req.Msg.Audience != "" && !h.exchangeAudienceAllowed(req.Msg.Audience) {
+		h.l.Warn().Str("actor_id", actor).Str("audience", req.Msg.Audience).Msg("token exchange audience not allowed")
+		return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("invalid audience: %s", req.Msg.Audience))
+	}
+
+	clm, err := h.api.ParseToken(ctx, req.Msg.SubjectToken, api.TokenTypeAccess)
+	if err != nil {
+		h.l.Warn().Err(err).Str("actor_id", actor).Msg("parse subject token failed")
+		return nil, connect.NewError(connect.CodeUnauthenticated, nil)
+	}
+
+	scope := req.Msg.Scope
+	if len(scope) == 0 {
+		scope = clm.Scope
+	} else if !h.api.CheckScope(clm.Scope, scope) {
+		h.l.Warn().Str("entity_id", clm.Subject).Strs("scope", scope).Msg("token exchange scope not granted")
+		return nil, connect.NewError(connect.CodePermissionDenied, nil)
+	}
+
+	t := h.api.CreateExchangedToken(clm, actor, scope, req.Msg.Audience, h.accessTokenTTL)
+	exp, err := t.Claims().GetExpirationTime()
+	if err != nil {
+		h.l.Error().Err(err).Str("entity_id", clm.Subject).Msg("get exchanged token expiration time failed")
+		return nil, connect.NewError(connect.CodeInternal, nil)
+	}
+	ts, err := t.SignedString()
+	if err != nil {
+		h.l.Error().Err(err).Str("entity_id", clm.Subject).Msg("get exchanged token signed string failed")
+		return nil, connect.NewError(connect.CodeInternal, nil)
+	}
+
+	h.l.Info().
+		Str("entity_id", clm.Subject).
+		Str("actor_id", actor).
+		Str("audience", req.Msg.Audience).
+		Int64("access_token_expires", exp.Unix()).
+		Msg("token exchanged")
+
+	return connect.NewResponse(&v1.TokenExchangeResponse{
+		AccessToken:        ts,
+		AccessTokenExpires: exp.Unix(),
+		Scope:              scope,
+	}), nil
+}
+
+// exchangeAudienceAllowed checks whether tokens can be exchanged for an audience other than the service's own one.
+func (h *Handler) exchangeAudienceAllowed(aud string) bool {
+	for _, a := range h.exchangeAudiences {
+		if a == aud {
+			return true
+		}
+	}
+
+	return false
+}
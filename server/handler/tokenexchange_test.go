@@ -0,0 +1,245 @@
+package handler_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/rs/zerolog"
+	"github.com/rzajac/zltest"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/ashep/a23n/api"
+	"github.com/ashep/a23n/sdk/proto/a23n/v1"
+	"github.com/ashep/a23n/server/credentials"
+	"github.com/ashep/a23n/server/handler"
+)
+
+type TokenExchangeTestSuite struct {
+	suite.Suite
+
+	api     *api.APIMock
+	handler *handler.Handler
+	logger  *zltest.Tester
+}
+
+func (s *TokenExchangeTestSuite) SetupTest() {
+	lt := zltest.New(s.T())
+	l := lt.Logger().Level(zerolog.DebugLevel)
+
+	s.api = &api.APIMock{}
+	s.handler = handler.New(s.api, time.Second*5, time.Second*10, api.Scope{"admin"}, []string{"theBackend"}, l)
+	s.logger = lt
+}
+
+func (s *TokenExchangeTestSuite) TearDownTest() {
+	s.api.AssertExpectations(s.T())
+}
+
+func (s *TokenExchangeTestSuite) subjectClaims() api.TokenClaims {
+	return api.TokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: "entityID"},
+		Scope:            []string{"foo", "bar"},
+	}
+}
+
+// exchange calls the handler on behalf of the gateway authenticated by an access token.
+func (s *TokenExchangeTestSuite) exchange(
+	req *v1.TokenExchangeRequest,
+) (*connect.Response[v1.TokenExchangeResponse], error) {
+	ctx := context.WithValue(context.Background(), "crd", credentials.Credentials{Token: "theCallerToken"})
+	return s.handler.TokenExchange(ctx, connect.NewRequest(req))
+}
+
+func (s *TokenExchangeTestSuite) expectActor() {
+	s.api.
+		On("ParseToken", mock.Anything, "theCallerToken", api.TokenTypeAccess).
+		Return(api.TokenClaims{RegisteredClaims: jwt.RegisteredClaims{Subject: "gatewayID"}}, nil)
+}
+
+func (s *TokenExchangeTestSuite) expectSubject() {
+	s.api.
+		On("ParseToken", mock.Anything, "theSubjectToken", api.TokenTypeAccess).
+		Return(s.subjectClaims(), nil)
+}
+
+func (s *TokenExchangeTestSuite) expectExchangedToken(scope []string, audience string) {
+	cl := &api.ClaimsMock{}
+	cl.On("GetExpirationTime").Return(jwt.NewNumericDate(time.Unix(123456789, 0)), nil)
+
+	t := &api.TokenMock{}
+	t.On("Claims").Return(cl)
+	t.On("SignedString").Return("theExchangedToken", nil)
+
+	s.api.
+		On("CreateExchangedToken", s.subjectClaims(), "gatewayID", scope, audience, time.Second*5).
+		Return(t)
+}
+
+func (s *TokenExchangeTestSuite) TestUnauthenticated() {
+	_, err := s.handler.TokenExchange(context.Background(), connect.NewRequest(&v1.TokenExchangeRequest{
+		SubjectToken: "theSubjectToken",
+	}))
+	s.Require().Equal(err, connect.NewError(connect.CodeUnauthenticated, nil))
+}
+
+func (s *TokenExchangeTestSuite) TestInvalidCredentials() {
+	s.api.
+		On("ParseToken", mock.Anything, "theCallerToken", api.TokenTypeAccess).
+		Return(api.TokenClaims{}, errors.New("theError"))
+
+	_, err := s.exchange(&v1.TokenExchangeRequest{SubjectToken: "theSubjectToken"})
+	s.Require().Equal(err, connect.NewError(connect.CodeUnauthenticated, nil))
+
+	l := s.logger.LastEntry()
+	s.Require().NotNil(l)
+	s.Assert().Equal(`{"level":"warn","error":"theError","message":"parse actor credentials failed"}`, l.String())
+}
+
+func (s *TokenExchangeTestSuite) TestEmptyActor() {
+	// Tokens without an actor are never issued
+	s.api.
+		On("ParseToken", mock.Anything, "theCallerToken", api.TokenTypeAccess).
+		Return(api.TokenClaims{}, nil)
+
+	_, err := s.exchange(&v1.TokenExchangeRequest{SubjectToken: "theSubjectToken"})
+	s.Require().Equal(err, connect.NewError(connect.CodeUnauthenticated, nil))
+}
+
+func (s *TokenExchangeTestSuite) TestEmptySubjectToken() {
+	s.expectActor()
+
+	_, err := s.exchange(&v1.TokenExchangeRequest{})
+	s.Require().Equal(connect.CodeInvalidArgument, connect.CodeOf(err))
+}
+
+func (s *TokenExchangeTestSuite) TestInvalidSubjectToken() {
+	s.expectActor()
+	s.api.
+		On("ParseToken", mock.Anything, "theSubjectToken", api.TokenTypeAccess).
+		Return(api.TokenClaims{}, errors.New("theError"))
+
+	_, err := s.exchange(&v1.TokenExchangeRequest{SubjectToken: "theSubjectToken"})
+	s.Require().Equal(err, connect.NewError(connect.CodeUnauthenticated, nil))
+}
+
+func (s *TokenExchangeTestSuite) TestInvalidActorToken() {
+	s.expectActor()
+	s.api.
+		On("ParseToken", mock.Anything, "theActorToken", api.TokenTypeAccess).
+		Return(api.TokenClaims{}, errors.New("theError"))
+
+	_, err := s.exchange(&v1.TokenExchangeRequest{SubjectToken: "theSubjectToken", ActorToken: "theActorToken"})
+	s.Require().Equal(err, connect.NewError(connect.CodeInvalidArgument, errors.New("invalid actor token")))
+}
+
+func (s *TokenExchangeTestSuite) TestActorTokenOfOtherEntity() {
+	s.expectActor()
+	s.api.
+		On("ParseToken", mock.Anything, "theActorToken", api.TokenTypeAccess).
+		Return(api.TokenClaims{RegisteredClaims: jwt.RegisteredClaims{Subject: "otherID"}}, nil)
+
+	_, err := s.exchange(&v1.TokenExchangeRequest{SubjectToken: "theSubjectToken", ActorToken: "theActorToken"})
+	s.Require().Equal(err, connect.NewError(connect.CodePermissionDenied, nil))
+
+	l := s.logger.LastEntry()
+	s.Require().NotNil(l)
+	s.Assert().Equal(`{"level":"warn","actor_id":"gatewayID","actor_token_subject":"otherID","message":"actor token mismatch"}`, l.String())
+}
+
+func (s *TokenExchangeTestSuite) TestActorToken() {
+	s.expectActor()
+	s.api.
+		On("ParseToken", mock.Anything, "theActorToken", api.TokenTypeAccess).
+		Return(api.TokenClaims{RegisteredClaims: jwt.RegisteredClaims{Subject: "gatewayID"}}, nil)
+	s.expectSubject()
+	s.expectExchangedToken([]string{"foo", "bar"}, "")
+
+	r, err := s.exchange(&v1.TokenExchangeRequest{SubjectToken: "theSubjectToken", ActorToken: "theActorToken"})
+	s.Require().NoError(err)
+	s.Assert().Equal("theExchangedToken", r.Msg.AccessToken)
+}
+
+func (s *TokenExchangeTestSuite) TestAudienceNotAllowed() {
+	s.expectActor()
+
+	_, err := s.exchange(&v1.TokenExchangeRequest{SubjectToken: "theSubjectToken", Audience: "theOtherBackend"})
+	s.Require().Equal(connect.CodeInvalidArgument, connect.CodeOf(err))
+	s.Assert().EqualError(err, "invalid_argument: invalid audience: theOtherBackend")
+
+	l := s.logger.LastEntry()
+	s.Require().NotNil(l)
+	s.Assert().Equal(`{"level":"warn","actor_id":"gatewayID","audience":"theOtherBackend","message":"token exchange audience not allowed"}`, l.String())
+}
+
+func (s *TokenExchangeTestSuite) TestScopeExceedsSubjectToken() {
+	s.expectActor()
+	s.expectSubject()
+	s.api.
+		On("CheckScope", api.Scope{"foo", "bar"}, api.Scope{"foo", "admin"}).
+		Return(false)
+
+	_, err := s.exchange(&v1.TokenExchangeRequest{
+		SubjectToken: "theSubjectToken",
+		Scope:        []string{"foo", "admin"},
+	})
+	s.Require().Equal(err, connect.NewError(connect.CodePermissionDenied, nil))
+}
+
+func (s *TokenExchangeTestSuite) TestSameScope() {
+	s.expectActor()
+	s.expectSubject()
+	s.expectExchangedToken([]string{"foo", "bar"}, "")
+
+	r, err := s.exchange(&v1.TokenExchangeRequest{SubjectToken: "theSubjectToken"})
+	s.Require().NoError(err)
+
+	s.Assert().Equal("theExchangedToken", r.Msg.AccessToken)
+	s.Assert().Equal([]string{"foo", "bar"}, r.Msg.Scope)
+}
+
+func (s *TokenExchangeTestSuite) TestAPIKey() {
+	s.api.
+		On("VerifyAPIKey", mock.Anything, "a23n_theKey").
+		Return(api.TokenClaims{RegisteredClaims: jwt.RegisteredClaims{Subject: "gatewayID"}}, nil)
+	s.expectSubject()
+	s.expectExchangedToken([]string{"foo", "bar"}, "")
+
+	ctx := context.WithValue(context.Background(), "crd", credentials.Credentials{APIKey: "a23n_theKey"})
+	_, err := s.handler.TokenExchange(ctx, connect.NewRequest(&v1.TokenExchangeRequest{
+		SubjectToken: "theSubjectToken",
+	}))
+	s.Require().NoError(err)
+}
+
+func (s *TokenExchangeTestSuite) TestOK() {
+	s.expectActor()
+	s.expectSubject()
+	s.api.
+		On("CheckScope", api.Scope{"foo", "bar"}, api.Scope{"foo"}).
+		Return(true)
+	s.expectExchangedToken([]string{"foo"}, "theBackend")
+
+	r, err := s.exchange(&v1.TokenExchangeRequest{
+		SubjectToken: "theSubjectToken",
+		Scope:        []string{"foo"},
+		Audience:     "theBackend",
+	})
+	s.Require().NoError(err)
+
+	s.Assert().Equal("theExchangedToken", r.Msg.AccessToken)
+	s.Assert().Equal(int64(123456789), r.Msg.AccessTokenExpires)
+	s.Assert().Equal([]string{"foo"}, r.Msg.Scope)
+
+	l := s.logger.LastEntry()
+	s.Require().NotNil(l)
+	s.Assert().Equal(`{"level":"info","entity_id":"entityID","actor_id":"gatewayID","audience":"theBackend","access_token_expires":123456789,"message":"token exchanged"}`, l.String())
+}
+
+func TestHandler_TokenExchange(t *testing.T) {
+	suite.Run(t, new(TokenExchangeTestSuite))
+}
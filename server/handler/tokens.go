@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+
+	"github.com/ashep/a23n/api"
+)
+
+// parseAnyToken parses either an access or a refresh token, accepting the tokens exchanged for any of the allowed
+// audiences as well. The hint, "access_token" or "refresh_token", defines which type is tried first.
+func (h *Handler) parseAnyToken(ctx context.Context, token, hint string) (api.TokenClaims, error) {
+	typ, otherTyp := api.TokenTypeAccess, api.TokenTypeRefresh
+	if hint == "refresh_token" {
+		typ, otherTyp = otherTyp, typ
+	}
+
+	clm, err := h.api.ParseTokenForAudiences(ctx, token, typ, h.exchangeAudiences)
+	if errors.Is(err, api.ErrInvalidTokenType) {
+		clm, err = h.api.ParseTokenForAudiences(ctx, token, otherTyp, h.exchangeAudiences)
+	}
+
+	return clm, err
+}
+
+type tokenPair struct {
+	access         string
+	accessExpires  int64
+	refresh        string
+	refreshExpires int64
+}
+
+// issueTokens creates a new access/refresh token pair for an entity and registers the refresh token within a family.
+func (h *Handler) issueTokens(ctx context.Context, e api.Entity, family string) (tokenPair, error) {
+	return h.issueClientTokens(ctx, e, api.Client{}, family)
+}
+
+// issueClientTokens is like issueTokens, but the tokens are issued to an OAuth 2.0 client, which is recorded in them.
+// The zero client stands for no client.
+func (h *Handler) issueClientTokens(ctx context.Context, e api.Entity, c api.Client, family string) (tokenPair, error) {
+	accessTTL, refreshTTL := h.tokenTTLs(c)
+
+	accessToken, accessTokenExp, err := h.issueToken(api.TokenTypeAccess, e, c.ID, accessTTL)
+	if err != nil {
+		return tokenPair{}, err
+	}
+
+	refreshToken, refreshTokenExp, err := h.issueToken(api.TokenTypeRefresh, e, c.ID, refreshTTL)
+	if err != nil {
+		return tokenPair{}, err
+	}
+
+	if err = h.api.SaveRefreshToken(ctx, refreshToken, e.ID, family, refreshTokenExp); err != nil {
+		h.l.Error().Err(err).Str("entity_id", e.ID).Msg("save refresh token failed")
+		return tokenPair{}, connect.NewError(connect.CodeInternal, nil)
+	}
+
+	return tokenPair{
+		access:         accessToken,
+		accessExpires:  accessTokenExp.Unix(),
+		refresh:        refreshToken,
+		refreshExpires: refreshTokenExp.Unix(),
+	}, nil
+}
+
+// tokenTTLs returns the lifetimes of access and refresh tokens issued to a client.
+func (h *Handler) tokenTTLs(c api.Client) (time.Duration, time.Duration) {
+	accessTTL, refreshTTL := h.accessTokenTTL, h.refreshTokenTTL
+	if c.AccessTokenTTL != 0 {
+		accessTTL = c.AccessTokenTTL
+	}
+	if c.RefreshTokenTTL != 0 {
+		refreshTTL = c.RefreshTokenTTL
+	}
+
+	return accessTTL, refreshTTL
+}
+
+// issueToken creates a signed token for an entity and returns it along with its expiration time. The client ID is
+// empty unless the token is issued to an OAuth 2.0 client.
+func (h *Handler) issueToken(
+	typ api.TokenType,
+	e api.Entity,
+	clientID string,
+	ttl time.Duration,
+) (string, time.Time, error) {
+	t := h.api.CreateToken(typ, e.ID, clientID, e.Scope, ttl)
+	exp, err := t.Claims().GetExpirationTime()
+	if err != nil {
+		h.l.Error().Err(err).Str("entity_id", e.ID).Msgf("get %s token expiration time failed", typ)
+		return "", time.Time{}, connect.NewError(connect.CodeInternal, nil)
+	}
+	ts, err := t.SignedString()
+	if err != nil {
+		h.l.Error().Err(err).Str("entity_id", e.ID).Msgf("get %s token signed string failed", typ)
+		return "", time.Time{}, connect.NewError(connect.CodeInternal, nil)
+	}
+
+	return ts, exp.Time, nil
+}
+
+// grantedScope returns the scope of tokens issued to an entity. Scopes requiring MFA are dropped unless the entity
+// has passed the second authentication factor.
+func (h *Handler) grantedScope(e api.Entity, mfa bool) api.Scope {
+	if mfa || !h.hasMFAScope(e.Scope) {
+		return e.Scope
+	}
+
+	r := make(api.Scope, 0, len(e.Scope))
+	for _, s := range e.Scope {
+		if !h.hasMFAScope(api.Scope{s}) {
+			r = append(r, s)
+		}
+	}
+
+	return r
+}
+
+// hasMFAScope checks whether scope contains any of the scopes requiring MFA.
+func (h *Handler) hasMFAScope(scope []string) bool {
+	for _, s := range scope {
+		for _, ms := range h.mfaScope {
+			if s == ms {
+				return true
+			}
+		}
+	}
+
+	return false
+}
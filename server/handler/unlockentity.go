@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/google/uuid"
+
+	"github.com/ashep/a23n/api"
+	v1 "github.com/ashep/a23n/sdk/proto/a23n/v1"
+)
+
+// UnlockEntity lifts the lockout of an entity caused by failed authentication attempts and resets their counter.
+func (h *Handler) UnlockEntity(
+	ctx context.Context,
+	req *connect.Request[v1.UnlockEntityRequest],
+) (*connect.Response[v1.UnlockEntityResponse], error) {
+	if _, err := uuid.Parse(req.Msg.Id); err != nil {
+		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("invalid entity id"))
+	}
+
+	err := h.api.UnlockEntity(ctx, req.Msg.Id)
+	if errors.Is(err, api.ErrNotFound) {
+		return nil, connect.NewError(connect.CodeNotFound, err)
+	} else if err != nil {
+		h.l.Error().Err(err).Str("entity_id", req.Msg.Id).Msg("unlock entity failed")
+		return nil, connect.NewError(connect.CodeInternal, nil)
+	}
+
+	h.l.Info().Str("entity_id", req.Msg.Id).Msg("entity unlocked")
+
+	return connect.NewResponse(&v1.UnlockEntityResponse{}), nil
+}
@@ -0,0 +1,120 @@
+package handler_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/rs/zerolog"
+	"github.com/rzajac/zltest"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/ashep/a23n/api"
+	"github.com/ashep/a23n/sdk/proto/a23n/v1"
+	"github.com/ashep/a23n/server/credentials"
+	"github.com/ashep/a23n/server/handler"
+)
+
+type UnlockEntityTestSuite struct {
+	suite.Suite
+
+	api     *api.APIMock
+	handler *handler.Handler
+	logger  *zltest.Tester
+}
+
+func (s *UnlockEntityTestSuite) SetupTest() {
+	lt := zltest.New(s.T())
+	l := lt.Logger().Level(zerolog.DebugLevel)
+
+	s.api = &api.APIMock{}
+	s.handler = handler.New(s.api, time.Second*5, time.Second*10, nil, nil, l)
+	s.logger = lt
+}
+
+func (s *UnlockEntityTestSuite) TearDownTest() {
+	s.api.AssertExpectations(s.T())
+}
+
+// authenticate authenticates the entity with a wrong secret returning the error code and the Retry-After value.
+func (s *UnlockEntityTestSuite) authenticate() (connect.Code, string) {
+	ctx := context.WithValue(context.Background(), "crd", credentials.Credentials{
+		ID:       "de2a6f34-5371-4409-89ec-62bfda13fcb7",
+		Password: "wrongSecret",
+	})
+
+	_, err := s.handler.Authenticate(ctx, connect.NewRequest(&v1.AuthenticateRequest{}))
+
+	var cErr *connect.Error
+	s.Require().True(errors.As(err, &cErr))
+
+	return cErr.Code(), cErr.Meta().Get("Retry-After")
+}
+
+func (s *UnlockEntityTestSuite) expectVerifyCredentials(err error) {
+	s.api.
+		On("VerifyCredentials", mock.Anything, "de2a6f34-5371-4409-89ec-62bfda13fcb7", "wrongSecret", "").
+		Return(api.Entity{}, err).
+		Once()
+}
+
+func (s *UnlockEntityTestSuite) TestInvalidID() {
+	_, err := s.handler.UnlockEntity(context.Background(), connect.NewRequest(&v1.UnlockEntityRequest{Id: "notAUUID"}))
+	s.Require().Equal(err, connect.NewError(connect.CodeInvalidArgument, errors.New("invalid entity id")))
+}
+
+func (s *UnlockEntityTestSuite) TestNotFound() {
+	s.api.
+		On("UnlockEntity", mock.Anything, "de2a6f34-5371-4409-89ec-62bfda13fcb7").
+		Return(api.ErrNotFound)
+
+	_, err := s.handler.UnlockEntity(context.Background(), connect.NewRequest(&v1.UnlockEntityRequest{
+		Id: "de2a6f34-5371-4409-89ec-62bfda13fcb7",
+	}))
+	s.Require().Equal(err, connect.NewError(connect.CodeNotFound, api.ErrNotFound))
+
+	l := s.logger.LastEntry()
+	s.Assert().Nil(l)
+}
+
+func (s *UnlockEntityTestSuite) TestLockoutLifted() {
+	// The remaining lockout time is rounded up to whole seconds
+	s.expectVerifyCredentials(api.ErrLocked{Until: time.Now().Add(30*time.Second + 200*time.Millisecond)})
+	code, retryAfter := s.authenticate()
+	s.Require().Equal(connect.CodeResourceExhausted, code)
+	s.Assert().Equal("31", retryAfter)
+
+	s.api.
+		On("UnlockEntity", mock.Anything, "de2a6f34-5371-4409-89ec-62bfda13fcb7").
+		Return(nil)
+
+	_, err := s.handler.UnlockEntity(context.Background(), connect.NewRequest(&v1.UnlockEntityRequest{
+		Id: "de2a6f34-5371-4409-89ec-62bfda13fcb7",
+	}))
+	s.Require().NoError(err)
+
+	l := s.logger.LastEntry()
+	s.Require().NotNil(l)
+	s.Assert().Equal(`{"level":"info","entity_id":"de2a6f34-5371-4409-89ec-62bfda13fcb7","message":"entity unlocked"}`, l.String())
+
+	// Wrong secrets are reported as such again instead of the lockout
+	s.expectVerifyCredentials(api.ErrInvalidCredentials)
+	code, retryAfter = s.authenticate()
+	s.Require().Equal(connect.CodeUnauthenticated, code)
+	s.Assert().Empty(retryAfter)
+}
+
+func (s *UnlockEntityTestSuite) TestRetryAfterExpiredLockout() {
+	// The lockout expired between checking and responding, the client still has to wait
+	s.expectVerifyCredentials(api.ErrLocked{Until: time.Now().Add(-time.Second)})
+	code, retryAfter := s.authenticate()
+	s.Require().Equal(connect.CodeResourceExhausted, code)
+	s.Assert().Equal("1", retryAfter)
+}
+
+func TestHandler_UnlockEntity(t *testing.T) {
+	suite.Run(t, new(UnlockEntityTestSuite))
+}
@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	"github.com/bufbuild/connect-go"
+
+	"github.com/ashep/a23n/api"
+	v1 "github.com/ashep/a23n/sdk/proto/a23n/v1"
+)
+
+// UnlockSource lifts the lockout of a source of requests, e.g. an IP address, caused by failed authentication attempts
+// and resets their counter.
+func (h *Handler) UnlockSource(
+	ctx context.Context,
+	req *connect.Request[v1.UnlockSourceRequest],
+) (*connect.Response[v1.UnlockSourceResponse], error) {
+	if req.Msg.Source == "" {
+		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("empty source"))
+	}
+
+	err := h.api.UnlockSource(ctx, req.Msg.Source)
+	if errors.Is(err, api.ErrNotFound) {
+		return nil, connect.NewError(connect.CodeNotFound, err)
+	} else if err != nil {
+		h.l.Error().Err(err).Str("source", req.Msg.Source).Msg("unlock source failed")
+		return nil, connect.NewError(connect.CodeInternal, nil)
+	}
+
+	h.l.Info().Str("source", req.Msg.Source).Msg("source unlocked")
+
+	return connect.NewResponse(&v1.UnlockSourceResponse{}), nil
+}
@@ -0,0 +1,83 @@
+package handler_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/rs/zerolog"
+	"github.com/rzajac/zltest"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/ashep/a23n/api"
+	"github.com/ashep/a23n/sdk/proto/a23n/v1"
+	"github.com/ashep/a23n/server/handler"
+)
+
+type UnlockSourceTestSuite struct {
+	suite.Suite
+
+	api     *api.APIMock
+	handler *handler.Handler
+	logger  *zltest.Tester
+}
+
+func (s *UnlockSourceTestSuite) SetupTest() {
+	lt := zltest.New(s.T())
+	l := lt.Logger().Level(zerolog.DebugLevel)
+
+	s.api = &api.APIMock{}
+	s.handler = handler.New(s.api, time.Second*5, time.Second*10, nil, nil, l)
+	s.logger = lt
+}
+
+func (s *UnlockSourceTestSuite) TearDownTest() {
+	s.api.AssertExpectations(s.T())
+}
+
+func (s *UnlockSourceTestSuite) TestEmptySource() {
+	_, err := s.handler.UnlockSource(context.Background(), connect.NewRequest(&v1.UnlockSourceRequest{}))
+	s.Require().Equal(err, connect.NewError(connect.CodeInvalidArgument, errors.New("empty source")))
+}
+
+func (s *UnlockSourceTestSuite) TestNotFound() {
+	s.api.On("UnlockSource", mock.Anything, "192.0.2.1").Return(api.ErrNotFound)
+
+	_, err := s.handler.UnlockSource(context.Background(), connect.NewRequest(&v1.UnlockSourceRequest{
+		Source: "192.0.2.1",
+	}))
+	s.Require().Equal(err, connect.NewError(connect.CodeNotFound, api.ErrNotFound))
+}
+
+func (s *UnlockSourceTestSuite) TestAPIError() {
+	s.api.On("UnlockSource", mock.Anything, "192.0.2.1").Return(errors.New("theError"))
+
+	_, err := s.handler.UnlockSource(context.Background(), connect.NewRequest(&v1.UnlockSourceRequest{
+		Source: "192.0.2.1",
+	}))
+	s.Require().Equal(err, connect.NewError(connect.CodeInternal, nil))
+
+	l := s.logger.LastEntry()
+	s.Require().NotNil(l)
+	s.Assert().Equal(`{"level":"error","error":"theError","source":"192.0.2.1","message":"unlock source failed"}`, l.String())
+}
+
+func (s *UnlockSourceTestSuite) TestOK() {
+	s.api.On("UnlockSource", mock.Anything, "192.0.2.1").Return(nil)
+
+	_, err := s.handler.UnlockSource(context.Background(), connect.NewRequest(&v1.UnlockSourceRequest{
+		Source: "192.0.2.1",
+	}))
+	s.Require().NoError(err)
+
+	l := s.logger.LastEntry()
+	s.Require().NotNil(l)
+	s.Assert().Equal(`{"level":"info","source":"192.0.2.1","message":"source unlocked"}`, l.String())
+}
+
+func TestHandler_UnlockSource(t *testing.T) {
+	suite.Run(t, new(UnlockSourceTestSuite))
+}
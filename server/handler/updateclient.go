@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+
+	"github.com/ashep/a23n/api"
+	v1 "github.com/ashep/a23n/sdk/proto/a23n/v1"
+)
+
+// UpdateClient updates the fields of an OAuth 2.0 client listed in the update mask, resetting the secret of a
+// confidential client if requested. Tokens which have been issued to the client are not affected, but the ones issued
+// since follow the new settings.
+func (h *Handler) UpdateClient(
+	ctx context.Context,
+	req *connect.Request[v1.UpdateClientRequest],
+) (*connect.Response[v1.UpdateClientResponse], error) {
+	secret, err := h.api.UpdateClient(ctx, api.Client{
+		ID:              req.Msg.Id,
+		Name:            req.Msg.Name,
+		RedirectURIs:    req.Msg.RedirectUris,
+		GrantTypes:      req.Msg.GrantTypes,
+		Scope:           req.Msg.Scope,
+		AccessTokenTTL:  time.Duration(req.Msg.AccessTokenTtl) * time.Second,
+		RefreshTokenTTL: time.Duration(req.Msg.RefreshTokenTtl) * time.Second,
+	}, req.Msg.UpdateMask, req.Msg.ResetSecret)
+	if errors.Is(err, api.ErrInvalidArg{}) {
+		return nil, connect.NewError(connect.CodeInvalidArgument, err)
+	} else if errors.Is(err, api.ErrNotFound) {
+		return nil, connect.NewError(connect.CodeNotFound, err)
+	} else if err != nil {
+		h.l.Error().Err(err).Str("client_id", req.Msg.Id).Msg("update client failed")
+		return nil, connect.NewError(connect.CodeInternal, nil)
+	}
+
+	h.l.Info().Str("client_id", req.Msg.Id).Bool("secret_reset", req.Msg.ResetSecret).Msg("client updated")
+
+	return connect.NewResponse(&v1.UpdateClientResponse{Secret: secret}), nil
+}
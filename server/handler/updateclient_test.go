@@ -0,0 +1,104 @@
+package handler_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/rs/zerolog"
+	"github.com/rzajac/zltest"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/ashep/a23n/api"
+	"github.com/ashep/a23n/sdk/proto/a23n/v1"
+	"github.com/ashep/a23n/server/handler"
+)
+
+type UpdateClientTestSuite struct {
+	suite.Suite
+
+	api     *api.APIMock
+	handler *handler.Handler
+	logger  *zltest.Tester
+}
+
+func (s *UpdateClientTestSuite) SetupTest() {
+	lt := zltest.New(s.T())
+	l := lt.Logger().Level(zerolog.DebugLevel)
+
+	s.api = &api.APIMock{}
+	s.handler = handler.New(s.api, time.Second*5, time.Second*10, api.Scope{"admin"}, nil, l)
+	s.logger = lt
+}
+
+func (s *UpdateClientTestSuite) TearDownTest() {
+	s.api.AssertExpectations(s.T())
+}
+
+func (s *UpdateClientTestSuite) update(err error) (*connect.Response[v1.UpdateClientResponse], error) {
+	s.api.
+		On("UpdateClient", mock.Anything, api.Client{ID: "theClient", Scope: api.Scope{"foo"}},
+			[]string{"scope"}, false).
+		Return("", err)
+
+	return s.handler.UpdateClient(context.Background(), connect.NewRequest(&v1.UpdateClientRequest{
+		Id:         "theClient",
+		Scope:      []string{"foo"},
+		UpdateMask: []string{"scope"},
+	}))
+}
+
+func (s *UpdateClientTestSuite) TestInvalidArg() {
+	expErr := api.ErrInvalidArg{Msg: "invalid update mask: unknown field confidential"}
+
+	_, err := s.update(expErr)
+	s.Require().Equal(err, connect.NewError(connect.CodeInvalidArgument, expErr))
+}
+
+func (s *UpdateClientTestSuite) TestNotFound() {
+	_, err := s.update(api.ErrNotFound)
+	s.Require().Equal(err, connect.NewError(connect.CodeNotFound, api.ErrNotFound))
+}
+
+func (s *UpdateClientTestSuite) TestAPIError() {
+	_, err := s.update(errors.New("theError"))
+	s.Require().Equal(err, connect.NewError(connect.CodeInternal, nil))
+
+	l := s.logger.LastEntry()
+	s.Require().NotNil(l)
+	s.Assert().Equal(`{"level":"error","error":"theError","client_id":"theClient","message":"update client failed"}`, l.String())
+}
+
+func (s *UpdateClientTestSuite) TestOK() {
+	res, err := s.update(nil)
+	s.Require().NoError(err)
+	s.Assert().Empty(res.Msg.Secret)
+
+	l := s.logger.LastEntry()
+	s.Require().NotNil(l)
+	s.Assert().Equal(`{"level":"info","client_id":"theClient","secret_reset":false,"message":"client updated"}`, l.String())
+}
+
+func (s *UpdateClientTestSuite) TestResetSecret() {
+	s.api.
+		On("UpdateClient", mock.Anything, api.Client{ID: "theClient"}, []string(nil), true).
+		Return("theSecret", nil)
+
+	res, err := s.handler.UpdateClient(context.Background(), connect.NewRequest(&v1.UpdateClientRequest{
+		Id:          "theClient",
+		ResetSecret: true,
+	}))
+	s.Require().NoError(err)
+	s.Assert().Equal("theSecret", res.Msg.Secret)
+
+	l := s.logger.LastEntry()
+	s.Require().NotNil(l)
+	s.Assert().Equal(`{"level":"info","client_id":"theClient","secret_reset":true,"message":"client updated"}`, l.String())
+}
+
+func TestHandler_UpdateClient(t *testing.T) {
+	suite.Run(t, new(UpdateClientTestSuite))
+}
@@ -34,6 +34,10 @@ func Auth(l zerolog.Logger) connect.UnaryInterceptorFunc {
 				crd.Password = basicSplit[1]
 			} else if strings.HasPrefix(authHdr, "Bearer") {
 				crd.Token = strings.TrimPrefix(authHdr, "Bearer ")
+			} else if strings.HasPrefix(authHdr, "ApiKey ") {
+				crd.APIKey = strings.TrimPrefix(authHdr, "ApiKey ")
+			} else if key := req.Header().Get("X-API-Key"); key != "" {
+				crd.APIKey = key
 			}
 
 			return next(context.WithValue(ctx, "crd", crd), req)
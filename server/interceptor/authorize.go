@@ -0,0 +1,54 @@
+package interceptor
+
+import (
+	"context"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/rs/zerolog"
+
+	"github.com/ashep/a23n/api"
+	"github.com/ashep/a23n/server/credentials"
+)
+
+// Authorize checks that the bearer token or the API key of a request grants all the scopes required by the called
+// procedure. Procedures which are not listed in rules are passed through without any checks. Must be chained after Auth.
+func Authorize(a api.API, rules map[string]api.Scope, l zerolog.Logger) connect.UnaryInterceptorFunc {
+	return func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			required, ok := rules[req.Spec().Procedure]
+			if !ok {
+				return next(ctx, req)
+			}
+
+			crd, ok := ctx.Value("crd").(credentials.Credentials)
+			if !ok || (crd.Token == "" && crd.APIKey == "") {
+				return nil, connect.NewError(connect.CodeUnauthenticated, nil)
+			}
+
+			var (
+				clm api.TokenClaims
+				err error
+			)
+
+			if crd.APIKey != "" {
+				clm, err = a.VerifyAPIKey(ctx, crd.APIKey)
+			} else {
+				clm, err = a.ParseToken(ctx, crd.Token, api.TokenTypeAccess)
+			}
+			if err != nil {
+				l.Warn().Err(err).Str("proc", req.Spec().Procedure).Msg("failed to parse token")
+				return nil, connect.NewError(connect.CodeUnauthenticated, nil)
+			}
+
+			if !a.CheckScope(clm.Scope, required) {
+				l.Warn().
+					Str("entity_id", clm.Subject).
+					Str("proc", req.Spec().Procedure).
+					Msg("permission denied")
+				return nil, connect.NewError(connect.CodePermissionDenied, nil)
+			}
+
+			return next(ctx, req)
+		}
+	}
+}
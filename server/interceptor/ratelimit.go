@@ -0,0 +1,281 @@
+package interceptor
+
+import (
+	"context"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/rs/zerolog"
+	"golang.org/x/time/rate"
+
+	"github.com/ashep/a23n/server/credentials"
+)
+
+// RateLimitKey defines which requests share a rate limit.
+type RateLimitKey string
+
+const (
+	// RateLimitByPeer limits requests made from the same IP address
+	RateLimitByPeer RateLimitKey = "peer"
+	// RateLimitByEntity limits requests made with credentials of the same entity, logins are compared regardless of
+	// the case. Requests authenticated by a bearer token or an API key are limited per token or key, and requests
+	// without credentials are not limited at all.
+	RateLimitByEntity RateLimitKey = "entity"
+)
+
+// RateLimitAllProcedures is a procedure name used to define rate limits applied to every procedure.
+const RateLimitAllProcedures = "*"
+
+// rateLimitIdleTimeout defines how long a limiter is kept after its last use
+const rateLimitIdleTimeout = 10 * time.Minute
+
+// rateLimitMaxKeys limits the number of limiters kept in memory. Requests needing a new limiter are rejected while
+// there are that many limiters in use, and are told to retry after rateLimitFullDelay, which is also the minimum
+// interval between purges made because of that.
+const (
+	rateLimitMaxKeys   = 100000
+	rateLimitFullDelay = time.Second
+)
+
+// RateLimitRule defines a token bucket which allows Rate requests per second on average and up to Burst requests at
+// once.
+type RateLimitRule struct {
+	Key   RateLimitKey
+	Rate  float64
+	Burst int
+}
+
+type rateLimiter struct {
+	lim      *rate.Limiter
+	lastUsed time.Time
+}
+
+type rateLimiterKey struct {
+	proc string
+	rule int
+	key  string
+}
+
+// rateLimits keeps the token buckets of rate limit rules.
+type rateLimits struct {
+	mux       sync.Mutex
+	rules     map[string][]RateLimitRule
+	limiters  map[rateLimiterKey]*rateLimiter
+	maxKeys   int
+	lastPurge time.Time
+}
+
+func newRateLimits(rules map[string][]RateLimitRule, maxKeys int) *rateLimits {
+	return &rateLimits{
+		rules:     rules,
+		limiters:  make(map[rateLimiterKey]*rateLimiter),
+		maxKeys:   maxKeys,
+		lastPurge: time.Now(),
+	}
+}
+
+// reserve takes a token from the buckets of every rule applying to a procedure. key returns the value a request is
+// limited by for a rule key, empty if it is not limited by it. Peer rules are evaluated first and evaluation stops at
+// the first rejection, so requests rejected per peer do not create limiters for arbitrary credentials. The returned
+// delay is zero if the request is allowed.
+func (rl *rateLimits) reserve(proc string, key func(RateLimitKey) string, now time.Time) time.Duration {
+	rl.mux.Lock()
+	defer rl.mux.Unlock()
+
+	if now.Sub(rl.lastPurge) > rateLimitIdleTimeout {
+		rl.purge(now)
+	}
+
+	var reserved []*rate.Reservation
+
+	for _, peer := range []bool{true, false} {
+		for _, p := range []string{RateLimitAllProcedures, proc} {
+			for i, rule := range rl.rules[p] {
+				if (rule.Key == RateLimitByPeer) != peer {
+					continue
+				}
+
+				k := key(rule.Key)
+				if k == "" {
+					continue
+				}
+
+				var delay time.Duration
+				if lim := rl.limiter(rateLimiterKey{proc: p, rule: i, key: k}, rule, now); lim == nil {
+					delay = rateLimitFullDelay
+				} else if r := lim.ReserveN(now, 1); !r.OK() {
+					delay = rateLimitIdleTimeout
+				} else {
+					reserved = append(reserved, r)
+					delay = r.DelayFrom(now)
+				}
+
+				if delay > 0 {
+					// Rejected requests should not consume tokens
+					for _, r := range reserved {
+						r.CancelAt(now)
+					}
+
+					return delay
+				}
+			}
+		}
+	}
+
+	return 0
+}
+
+// limiter returns the limiter of a key creating it if it does not exist yet. It returns nil if there are too many
+// limiters even after purging.
+func (rl *rateLimits) limiter(k rateLimiterKey, rule RateLimitRule, now time.Time) *rate.Limiter {
+	lim, ok := rl.limiters[k]
+	if !ok {
+		if len(rl.limiters) >= rl.maxKeys && now.Sub(rl.lastPurge) > rateLimitFullDelay {
+			rl.purge(now)
+		}
+		if len(rl.limiters) >= rl.maxKeys {
+			return nil
+		}
+
+		lim = &rateLimiter{lim: rate.NewLimiter(rate.Limit(rule.Rate), rule.Burst)}
+		rl.limiters[k] = lim
+	}
+	lim.lastUsed = now
+
+	return lim.lim
+}
+
+// purge removes the limiters which are idle for rateLimitIdleTimeout or have their buckets full again, as the latter
+// do not differ from new ones.
+func (rl *rateLimits) purge(now time.Time) {
+	for k, lim := range rl.limiters {
+		if now.Sub(lim.lastUsed) > rateLimitIdleTimeout || lim.lim.TokensAt(now) >= float64(lim.lim.Burst()) {
+			delete(rl.limiters, k)
+		}
+	}
+	rl.lastPurge = now
+}
+
+// RateLimiter limits the rate of requests per procedure according to rules, which are keyed by procedure names. Rules
+// of RateLimitAllProcedures apply to every procedure in addition to its own ones. Both the connect interceptor and
+// the HTTP middleware of a limiter share its buckets.
+type RateLimiter struct {
+	limits *rateLimits
+	l      zerolog.Logger
+}
+
+// NewRateLimiter creates a rate limiter.
+func NewRateLimiter(rules map[string][]RateLimitRule, l zerolog.Logger) *RateLimiter {
+	return &RateLimiter{limits: newRateLimits(rules, rateLimitMaxKeys), l: l}
+}
+
+// RateLimit limits the rate of requests the way RateLimiter does. Exceeded limits result in ResourceExhausted errors
+// with the Retry-After header telling the number of seconds to wait. Must be chained after Auth.
+func RateLimit(rules map[string][]RateLimitRule, l zerolog.Logger) connect.UnaryInterceptorFunc {
+	return NewRateLimiter(rules, l).Interceptor()
+}
+
+// Interceptor returns the interceptor limiting the rate of connect requests, see RateLimit.
+func (rl *RateLimiter) Interceptor() connect.UnaryInterceptorFunc {
+	return func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			proc := req.Spec().Procedure
+
+			key := func(k RateLimitKey) string { return rateLimitKey(ctx, req, k) }
+			if delay := rl.limits.reserve(proc, key, time.Now()); delay > 0 {
+				rl.logExceeded(req.Peer().Addr, proc, delay)
+
+				err := connect.NewError(connect.CodeResourceExhausted, nil)
+				err.Meta().Set("Retry-After", retryAfter(delay))
+
+				return nil, err
+			}
+
+			return next(ctx, req)
+		}
+	}
+}
+
+// HTTP returns the middleware limiting the rate of requests to an HTTP route, proc being the name rules of the route
+// are keyed by. Requests are limited by RateLimitByEntity rules according to the value entity returns, and are not
+// limited by them if it is empty. Exceeded limits result in 429 Too Many Requests responses with the Retry-After
+// header.
+func (rl *RateLimiter) HTTP(proc string, entity func(r *http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := func(k RateLimitKey) string {
+				switch k {
+				case RateLimitByPeer:
+					return peerHost(r.RemoteAddr)
+				case RateLimitByEntity:
+					if id := entity(r); id != "" {
+						return "id:" + strings.ToLower(id)
+					}
+					return ""
+				default:
+					return ""
+				}
+			}
+
+			if delay := rl.limits.reserve(proc, key, time.Now()); delay > 0 {
+				rl.logExceeded(r.RemoteAddr, proc, delay)
+
+				w.Header().Set("Retry-After", retryAfter(delay))
+				http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (rl *RateLimiter) logExceeded(addr, proc string, delay time.Duration) {
+	rl.l.Warn().
+		Str("addr", addr).
+		Str("proc", proc).
+		Dur("retry_after", delay).
+		Msg("rate limit exceeded")
+}
+
+// retryAfter returns the value of the Retry-After header telling the number of seconds to wait.
+func retryAfter(delay time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(delay.Seconds())))
+}
+
+// peerHost returns the host part of a peer address.
+func peerHost(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+
+	return host
+}
+
+// rateLimitKey returns the value of the request limited by k, empty if the request is not limited by it.
+func rateLimitKey(ctx context.Context, req connect.AnyRequest, k RateLimitKey) string {
+	switch k {
+	case RateLimitByPeer:
+		return peerHost(req.Peer().Addr)
+	case RateLimitByEntity:
+		crd, _ := ctx.Value("crd").(credentials.Credentials)
+		if crd.ID != "" {
+			return "id:" + strings.ToLower(crd.ID)
+		} else if crd.Token != "" {
+			return "token:" + crd.Token
+		} else if crd.APIKey != "" {
+			return "api_key:" + crd.APIKey
+		}
+		return ""
+	default:
+		return ""
+	}
+}
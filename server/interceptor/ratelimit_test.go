@@ -0,0 +1,240 @@
+package interceptor_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/bufbuild/connect-go"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/ashep/a23n/sdk/proto/a23n/v1"
+	"github.com/ashep/a23n/sdk/proto/a23n/v1/v1connect"
+	"github.com/ashep/a23n/server/credentials"
+	"github.com/ashep/a23n/server/interceptor"
+)
+
+type RateLimitTestSuite struct {
+	suite.Suite
+
+	next  connect.UnaryFunc
+	calls int
+}
+
+func (s *RateLimitTestSuite) SetupTest() {
+	s.calls = 0
+	s.next = func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		s.calls++
+		return connect.NewResponse(&v1.AuthenticateResponse{}), nil
+	}
+}
+
+func (s *RateLimitTestSuite) call(f connect.UnaryFunc, crd credentials.Credentials) error {
+	ctx := context.WithValue(context.Background(), "crd", crd)
+	_, err := f(ctx, connect.NewRequest(&v1.AuthenticateRequest{}))
+
+	return err
+}
+
+func (s *RateLimitTestSuite) TestByEntity() {
+	f := interceptor.RateLimit(map[string][]interceptor.RateLimitRule{
+		interceptor.RateLimitAllProcedures: {{Key: interceptor.RateLimitByEntity, Rate: 1.0 / 60, Burst: 2}},
+	}, zerolog.Nop())(s.next)
+
+	s.Require().NoError(s.call(f, credentials.Credentials{ID: "theLogin"}))
+	s.Require().NoError(s.call(f, credentials.Credentials{ID: "TheLogin"}))
+
+	err := s.call(f, credentials.Credentials{ID: "THELOGIN"})
+
+	var cErr *connect.Error
+	s.Require().True(errors.As(err, &cErr))
+	s.Assert().Equal(connect.CodeResourceExhausted, cErr.Code())
+	s.Assert().Equal("60", cErr.Meta().Get("Retry-After"))
+
+	// Other entities and requests without credentials are not affected
+	s.Require().NoError(s.call(f, credentials.Credentials{ID: "otherLogin"}))
+	s.Require().NoError(s.call(f, credentials.Credentials{Token: "theToken"}))
+	s.Require().NoError(s.call(f, credentials.Credentials{}))
+	s.Require().NoError(s.call(f, credentials.Credentials{}))
+	s.Require().NoError(s.call(f, credentials.Credentials{}))
+
+	s.Assert().Equal(7, s.calls)
+}
+
+func (s *RateLimitTestSuite) TestByAPIKey() {
+	f := interceptor.RateLimit(map[string][]interceptor.RateLimitRule{
+		interceptor.RateLimitAllProcedures: {{Key: interceptor.RateLimitByEntity, Rate: 1.0 / 60, Burst: 1}},
+	}, zerolog.Nop())(s.next)
+
+	s.Require().NoError(s.call(f, credentials.Credentials{APIKey: "a23n_theKey"}))
+	s.Require().Error(s.call(f, credentials.Credentials{APIKey: "a23n_theKey"}))
+
+	// A token equal to the key does not share its bucket
+	s.Require().NoError(s.call(f, credentials.Credentials{Token: "a23n_theKey"}))
+	s.Require().NoError(s.call(f, credentials.Credentials{APIKey: "a23n_otherKey"}))
+
+	s.Assert().Equal(3, s.calls)
+}
+
+func (s *RateLimitTestSuite) TestRejectedRequestsDoNotConsumeTokens() {
+	f := interceptor.RateLimit(map[string][]interceptor.RateLimitRule{
+		interceptor.RateLimitAllProcedures: {
+			{Key: interceptor.RateLimitByEntity, Rate: 1.0 / 60, Burst: 3},
+			{Key: interceptor.RateLimitByEntity, Rate: 1.0 / 60, Burst: 1},
+		},
+	}, zerolog.Nop())(s.next)
+
+	s.Require().NoError(s.call(f, credentials.Credentials{ID: "theLogin"}))
+
+	// The second rule rejects these requests, so they do not drain the bucket of the first one
+	for i := 0; i < 5; i++ {
+		s.Require().Error(s.call(f, credentials.Credentials{ID: "theLogin"}))
+	}
+
+	s.Assert().Equal(1, s.calls)
+}
+
+func (s *RateLimitTestSuite) TestOtherProcedure() {
+	f := interceptor.RateLimit(map[string][]interceptor.RateLimitRule{
+		"/a23n.v1.AuthService/Authenticate": {{Key: interceptor.RateLimitByEntity, Rate: 1.0 / 60, Burst: 1}},
+	}, zerolog.Nop())(s.next)
+
+	for i := 0; i < 3; i++ {
+		s.Require().NoError(s.call(f, credentials.Credentials{ID: "theLogin"}))
+	}
+}
+
+// serve makes a request to a handler limited by rules from addr with a bearer token, returning the response status.
+func (s *RateLimitTestSuite) serve(h http.Handler, addr, token string) int {
+	req := httptest.NewRequest(http.MethodPost, v1connect.AuthServiceAuthenticateProcedure, strings.NewReader("{}"))
+	req.RemoteAddr = addr
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	return w.Code
+}
+
+func (s *RateLimitTestSuite) handleAuthenticate(
+	context.Context,
+	*connect.Request[v1.AuthenticateRequest],
+) (*connect.Response[v1.AuthenticateResponse], error) {
+	s.calls++
+	return connect.NewResponse(&v1.AuthenticateResponse{}), nil
+}
+
+func (s *RateLimitTestSuite) TestPeerRulesFirst() {
+	h := connect.NewUnaryHandler(
+		v1connect.AuthServiceAuthenticateProcedure,
+		s.handleAuthenticate,
+		connect.WithInterceptors(
+			interceptor.Auth(zerolog.Nop()),
+			interceptor.RateLimit(map[string][]interceptor.RateLimitRule{
+				interceptor.RateLimitAllProcedures: {
+					{Key: interceptor.RateLimitByEntity, Rate: 1.0 / 60, Burst: 1},
+					{Key: interceptor.RateLimitByPeer, Rate: 1.0 / 60, Burst: 1},
+				},
+			}, zerolog.Nop()),
+		),
+	)
+
+	s.Require().Equal(http.StatusOK, s.serve(h, "192.0.2.1:1234", "theToken"))
+
+	// Requests rejected per peer do not create limiters for their tokens, so they cannot exhaust the limiters
+	for i := 0; i < 100001; i++ {
+		s.Require().Equal(http.StatusTooManyRequests, s.serve(h, "192.0.2.1:1234", "token"+strconv.Itoa(i)))
+	}
+
+	s.Require().Equal(http.StatusOK, s.serve(h, "192.0.2.2:1234", "otherToken"))
+	s.Require().Equal(http.StatusOK, s.serve(h, "192.0.2.3:1234", "token0"))
+	s.Assert().Equal(3, s.calls)
+}
+
+func (s *RateLimitTestSuite) TestMaxKeys() {
+	f := interceptor.RateLimit(map[string][]interceptor.RateLimitRule{
+		interceptor.RateLimitAllProcedures: {{Key: interceptor.RateLimitByEntity, Rate: 1.0 / 60, Burst: 2}},
+	}, zerolog.Nop())(s.next)
+
+	for i := 0; i < 100000; i++ {
+		s.Require().NoError(s.call(f, credentials.Credentials{Token: "token" + strconv.Itoa(i)}))
+	}
+
+	// Known keys are still limited as usual while new ones are rejected
+	s.Require().NoError(s.call(f, credentials.Credentials{Token: "token0"}))
+
+	err := s.call(f, credentials.Credentials{Token: "otherToken"})
+
+	var cErr *connect.Error
+	s.Require().True(errors.As(err, &cErr))
+	s.Assert().Equal(connect.CodeResourceExhausted, cErr.Code())
+	s.Assert().Equal("1", cErr.Meta().Get("Retry-After"))
+}
+
+// serveHTTP makes a request to an HTTP route from addr with a form, returning the response.
+func (s *RateLimitTestSuite) serveHTTP(h http.Handler, addr, form string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/oauth2/token", strings.NewReader(form))
+	req.RemoteAddr = addr
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	return w
+}
+
+func (s *RateLimitTestSuite) TestHTTP() {
+	rl := interceptor.NewRateLimiter(map[string][]interceptor.RateLimitRule{
+		"/oauth2/token": {
+			{Key: interceptor.RateLimitByPeer, Rate: 1.0 / 60, Burst: 3},
+			{Key: interceptor.RateLimitByEntity, Rate: 1.0 / 60, Burst: 1},
+		},
+	}, zerolog.Nop())
+
+	entity := func(r *http.Request) string { return r.PostFormValue("username") }
+	h := rl.HTTP("/oauth2/token", entity)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.calls++
+	}))
+
+	s.Require().Equal(http.StatusOK, s.serveHTTP(h, "192.0.2.1:1234", "username=theLogin").Code)
+
+	w := s.serveHTTP(h, "192.0.2.2:1234", "username=THELOGIN")
+	s.Require().Equal(http.StatusTooManyRequests, w.Code)
+	s.Assert().Equal("60", w.Header().Get("Retry-After"))
+
+	// Requests not limited per entity are still limited per peer
+	s.Require().Equal(http.StatusOK, s.serveHTTP(h, "192.0.2.1:1234", "").Code)
+	s.Require().Equal(http.StatusOK, s.serveHTTP(h, "192.0.2.1:1234", "username=otherLogin").Code)
+	s.Require().Equal(http.StatusTooManyRequests, s.serveHTTP(h, "192.0.2.1:1234", "").Code)
+
+	s.Assert().Equal(3, s.calls)
+}
+
+func (s *RateLimitTestSuite) TestHTTPSharesBuckets() {
+	rl := interceptor.NewRateLimiter(map[string][]interceptor.RateLimitRule{
+		interceptor.RateLimitAllProcedures: {{Key: interceptor.RateLimitByPeer, Rate: 1.0 / 60, Burst: 1}},
+	}, zerolog.Nop())
+
+	h := connect.NewUnaryHandler(
+		v1connect.AuthServiceAuthenticateProcedure,
+		s.handleAuthenticate,
+		connect.WithInterceptors(interceptor.Auth(zerolog.Nop()), rl.Interceptor()),
+	)
+	s.Require().Equal(http.StatusOK, s.serve(h, "192.0.2.1:1234", "theToken"))
+
+	// The rules of all procedures apply to HTTP routes as well
+	entity := func(r *http.Request) string { return "" }
+	hh := rl.HTTP("/oauth2/token", entity)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	s.Require().Equal(http.StatusTooManyRequests, s.serveHTTP(hh, "192.0.2.1:1234", "").Code)
+	s.Require().Equal(http.StatusOK, s.serveHTTP(hh, "192.0.2.2:1234", "").Code)
+}
+
+func TestRateLimit(t *testing.T) {
+	suite.Run(t, new(RateLimitTestSuite))
+}
@@ -0,0 +1,30 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// jwksMaxAge defines how long clients may cache the published keys
+const jwksMaxAge = 5 * time.Minute
+
+// KeyActivationDelay is how long a rotated key is published before it starts signing tokens. Servers pick up new keys
+// within keysReloadInterval and clients may keep the keys published before for up to jwksMaxAge.
+const KeyActivationDelay = keysReloadInterval + jwksMaxAge
+
+func (s *Server) handleJWKS(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(jwksMaxAge.Seconds())))
+
+	if err := json.NewEncoder(w).Encode(s.api.JWKS()); err != nil {
+		s.l.Error().Err(err).Msg("failed to write jwks response")
+	}
+}
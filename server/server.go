@@ -14,21 +14,37 @@ import (
 	"github.com/ashep/a23n/server/interceptor"
 )
 
+// keysReloadInterval defines how often signing keys are reloaded to pick up keys rotated by other processes
+const keysReloadInterval = time.Minute
+
+// purgeInterval defines how often records of expired tokens are deleted
+const purgeInterval = time.Hour
+
+// Options configure Server. Token TTLs are the defaults which registered OAuth 2.0 clients can override, scopes are
+// the ones required to call the procedures restricted by them.
+type Options struct {
+	Addr                   string
+	AccessTokenTTL         time.Duration
+	RefreshTokenTTL        time.Duration
+	AdminScope             string
+	IntrospectionScope     string
+	TokenExchangeScope     string
+	TokenExchangeAudiences []string
+	MFAScope               api.Scope
+	RateLimits             map[string][]interceptor.RateLimitRule
+}
+
 type Server struct {
-	api             api.API
-	addr            string
-	accessTokenTTL  time.Duration
-	refreshTokenTTL time.Duration
-	l               zerolog.Logger
+	api  api.API
+	opts Options
+	l    zerolog.Logger
 }
 
-func New(api api.API, addr string, accessTokenTTL, refreshTokenTTL time.Duration, l zerolog.Logger) *Server {
+func New(api api.API, opts Options, l zerolog.Logger) *Server {
 	return &Server{
-		api:             api,
-		addr:            addr,
-		accessTokenTTL:  accessTokenTTL,
-		refreshTokenTTL: refreshTokenTTL,
-		l:               l,
+		api:  api,
+		opts: opts,
+		l:    l,
 	}
 }
 
@@ -36,7 +52,8 @@ func corsHandler(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", r.Header.Get("Origin"))
 		w.Header().Set("Access-Control-Allow-Methods", "POST")
-		w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, Authorization, ResponseType")
+		w.Header().Set("Access-Control-Expose-Headers", "Retry-After")
+		w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, Authorization, X-API-Key, ResponseType")
 
 		if r.Method == "OPTIONS" {
 			return
@@ -46,18 +63,60 @@ func corsHandler(h http.Handler) http.Handler {
 	})
 }
 
-func (s *Server) Run(ctx context.Context) error {
+// Handler returns an HTTP handler serving all the routes of the service.
+func (s *Server) Handler() http.Handler {
+	authzRules := map[string]api.Scope{
+		v1connect.AuthServiceCreateEntityProcedure:       {s.opts.AdminScope},
+		v1connect.AuthServiceUpdateEntityProcedure:       {s.opts.AdminScope},
+		v1connect.AuthServiceListEntitiesProcedure:       {s.opts.AdminScope},
+		v1connect.AuthServiceDeleteEntityProcedure:       {s.opts.AdminScope},
+		v1connect.AuthServiceDisableEntityProcedure:      {s.opts.AdminScope},
+		v1connect.AuthServiceEnableEntityProcedure:       {s.opts.AdminScope},
+		v1connect.AuthServiceUnlockEntityProcedure:       {s.opts.AdminScope},
+		v1connect.AuthServiceUnlockSourceProcedure:       {s.opts.AdminScope},
+		v1connect.AuthServiceDisableTOTPProcedure:        {s.opts.AdminScope},
+		v1connect.AuthServiceIntrospectTokenProcedure:    {s.opts.IntrospectionScope},
+		v1connect.AuthServiceTokenExchangeProcedure:      {s.opts.TokenExchangeScope},
+		v1connect.AuthServiceRevokeEntityTokensProcedure: {s.opts.AdminScope},
+		v1connect.AuthServiceCreateClientProcedure:       {s.opts.AdminScope},
+		v1connect.AuthServiceListClientsProcedure:        {s.opts.AdminScope},
+		v1connect.AuthServiceUpdateClientProcedure:       {s.opts.AdminScope},
+		v1connect.AuthServiceDeleteClientProcedure:       {s.opts.AdminScope},
+	}
+
+	rl := interceptor.NewRateLimiter(s.opts.RateLimits, s.l)
 	interceptors := connect.WithInterceptors(
 		interceptor.Auth(s.l),
+		rl.Interceptor(),
+		interceptor.Authorize(s.api, authzRules, s.l),
 		interceptor.Log(s.l),
 	)
 
-	p, h := v1connect.NewAuthServiceHandler(handler.New(s.api, s.accessTokenTTL, s.refreshTokenTTL, s.l), interceptors)
+	hdl := handler.New(
+		s.api,
+		s.opts.AccessTokenTTL,
+		s.opts.RefreshTokenTTL,
+		s.opts.MFAScope,
+		s.opts.TokenExchangeAudiences,
+		s.l,
+	)
+	p, h := v1connect.NewAuthServiceHandler(hdl, interceptors)
 
 	mux := http.NewServeMux()
 	mux.Handle(p, corsHandler(h))
+	mux.Handle("/oauth2/authorize", rl.HTTP("/oauth2/authorize", handler.OAuth2AuthorizeEntity)(
+		http.HandlerFunc(hdl.OAuth2Authorize)))
+	mux.Handle("/oauth2/token", corsHandler(rl.HTTP("/oauth2/token", handler.OAuth2TokenEntity)(
+		http.HandlerFunc(hdl.OAuth2Token))))
+	mux.Handle("/userinfo", corsHandler(http.HandlerFunc(hdl.UserInfo)))
+	mux.HandleFunc("/.well-known/openid-configuration", hdl.OpenIDConfiguration)
+	mux.HandleFunc("/.well-known/jwks.json", s.handleJWKS)
+
+	return mux
+}
 
-	srv := &http.Server{Addr: s.addr, Handler: mux}
+func (s *Server) Run(ctx context.Context) error {
+	srv := &http.Server{Addr: s.opts.Addr, Handler: s.Handler()}
 
 	go func() {
 		<-ctx.Done()
@@ -66,6 +125,44 @@ func (s *Server) Run(ctx context.Context) error {
 		}
 	}()
 
-	s.l.Debug().Str("addr", s.addr).Msg("starting server")
+	go s.reloadKeys(ctx)
+	go s.purgeExpiredTokens(ctx)
+
+	s.l.Debug().Str("addr", s.opts.Addr).Msg("starting server")
 	return srv.ListenAndServe()
 }
+
+func (s *Server) reloadKeys(ctx context.Context) {
+	t := time.NewTicker(keysReloadInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if err := s.api.LoadKeys(ctx); err != nil {
+				s.l.Error().Err(err).Msg("failed to reload signing keys")
+			}
+		}
+	}
+}
+
+func (s *Server) purgeExpiredTokens(ctx context.Context) {
+	t := time.NewTicker(purgeInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			n, err := s.api.PurgeExpiredTokens(ctx)
+			if err != nil {
+				s.l.Error().Err(err).Msg("failed to purge expired tokens")
+				continue
+			}
+			s.l.Debug().Int64("count", n).Msg("expired tokens purged")
+		}
+	}
+}
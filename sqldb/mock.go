@@ -21,6 +21,27 @@ func (r *RowMock) Err() error {
 	return mArgs.Error(0)
 }
 
+type RowsMock struct {
+	mock.Mock
+}
+
+func (r *RowsMock) Next() bool {
+	return r.Called().Bool(0)
+}
+
+func (r *RowsMock) Scan(args ...interface{}) error {
+	mArgs := r.Called(args...)
+	return mArgs.Error(0)
+}
+
+func (r *RowsMock) Err() error {
+	return r.Called().Error(0)
+}
+
+func (r *RowsMock) Close() error {
+	return r.Called().Error(0)
+}
+
 type ResultMock struct {
 	mock.Mock
 }
@@ -56,3 +77,8 @@ func (m *DBMock) QueryRowContext(ctx context.Context, query string, args ...inte
 	mArgs := m.Called(ctx, query, args)
 	return mArgs.Get(0).(Row)
 }
+
+func (m *DBMock) QueryContext(ctx context.Context, query string, args ...interface{}) (Rows, error) {
+	mArgs := m.Called(ctx, query, args)
+	return mArgs.Get(0).(Rows), mArgs.Error(1)
+}
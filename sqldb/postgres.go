@@ -30,6 +30,10 @@ func (p *Postgres) ExecContext(ctx context.Context, query string, args ...interf
 	return p.db.ExecContext(ctx, query, args...)
 }
 
+func (p *Postgres) QueryContext(ctx context.Context, query string, args ...interface{}) (Rows, error) {
+	return p.db.QueryContext(ctx, query, args...)
+}
+
 func (p *Postgres) QueryRowContext(ctx context.Context, query string, args ...interface{}) Row {
-	return p.db.QueryRowContext(ctx, query, args)
+	return p.db.QueryRowContext(ctx, query, args...)
 }
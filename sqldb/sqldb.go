@@ -10,9 +10,17 @@ type Row interface {
 	Err() error
 }
 
+type Rows interface {
+	Next() bool
+	Scan(args ...interface{}) error
+	Err() error
+	Close() error
+}
+
 type DB interface {
 	DB() *sql.DB
 	PingContext(ctx context.Context) error
 	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
 	QueryRowContext(ctx context.Context, query string, args ...interface{}) Row
+	QueryContext(ctx context.Context, query string, args ...interface{}) (Rows, error)
 }